@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"math"
+	"os"
+	"runtime"
+	"time"
+)
+
+// runCalibrateHash подбирает параметры argon2id под текущую машину и печатает их в виде флагов и переменных окружения.
+func runCalibrateHash(args []string) error {
+	fs := flag.NewFlagSet("calibrate-hash", flag.ContinueOnError)
+	target := fs.Duration("target", 250*time.Millisecond, "target latency of a single password hash")
+	memoryMiB := fs.Uint("memory-budget", 64, "memory budget of a single password hash in MiB")
+	parallelism := fs.Uint("parallelism", uint(min(runtime.NumCPU(), 4)), "argon2id degree of parallelism")
+	saltLength := fs.Uint("salt-length", 16, "salt length in bytes")
+	keyLength := fs.Uint("key-length", 32, "key length in bytes")
+	samples := fs.Int("samples", 3, "number of measurements averaged per probe")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse calibrate-hash flags: %w", err)
+	}
+	// Флаги разбираются в uint, а параметры argon2id уже: значение вне пределов молча обрезалось бы.
+	switch {
+	case *parallelism == 0 || *parallelism > math.MaxUint8:
+		return fmt.Errorf("parallelism must be between 1 and %d", math.MaxUint8)
+	case *memoryMiB == 0 || *memoryMiB > math.MaxUint32/1024:
+		return fmt.Errorf("memory budget must be between 1 and %d MiB", math.MaxUint32/1024)
+	case *saltLength > math.MaxUint32:
+		return fmt.Errorf("salt length must be at most %d", uint32(math.MaxUint32))
+	case *keyLength > math.MaxUint32:
+		return fmt.Errorf("key length must be at most %d", uint32(math.MaxUint32))
+	}
+
+	fmt.Fprintf(os.Stdout, "calibrating argon2id: target %s, memory budget %d MiB, parallelism %d\n",
+		*target, *memoryMiB, *parallelism)
+
+	result, err := store.CalibrateArgon2(store.CalibrationOptions{
+		TargetLatency: *target,
+		MaxMemory:     uint32(*memoryMiB * 1024),
+		Parallelism:   uint8(*parallelism),
+		SaltLength:    uint32(*saltLength),
+		KeyLength:     uint32(*keyLength),
+		Samples:       *samples,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to calibrate argon2id: %w", err)
+	}
+
+	p := result.Params
+	fmt.Fprintf(os.Stdout, "measured latency: %s\n\n", result.Latency)
+	fmt.Fprintf(os.Stdout, "flags:\n  -argon2-memory=%d -argon2-iterations=%d -argon2-parallelism=%d -argon2-salt-length=%d -argon2-key-length=%d\n\n",
+		p.Memory, p.Iterations, p.Parallelism, p.SaltLength, p.KeyLength)
+	fmt.Fprintf(os.Stdout, "environment:\n  ARGON2_MEMORY=%d\n  ARGON2_ITERATIONS=%d\n  ARGON2_PARALLELISM=%d\n  ARGON2_SALT_LENGTH=%d\n  ARGON2_KEY_LENGTH=%d\n",
+		p.Memory, p.Iterations, p.Parallelism, p.SaltLength, p.KeyLength)
+	return nil
+}
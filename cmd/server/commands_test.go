@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+// openTestStorage открывает хранилище так же, как служебные команды: по флагам командной строки.
+func openTestStorage(t *testing.T, args ...string) store.Store {
+	t.Helper()
+	servConfig := &server_config.ServerConfig{}
+	require.NoError(t, servConfig.ParseArgs(flag.NewFlagSet("test", flag.ContinueOnError), args))
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	s, err := store.NewStorage(servConfig, l)
+	require.NoError(t, err)
+	return s
+}
+
+// Служебные команды работают и с SQLite, а не только с Postgres.
+func TestMaintenanceCommands_SQLite(t *testing.T) {
+	ctx := context.Background()
+	dsn := []string{"-d", "sqlite://" + filepath.Join(t.TempDir(), "raya.db"), "-l", "error", "-argon2-memory", "64", "-argon2-iterations", "1"}
+	s := openTestStorage(t, dsn...)
+	anna, err := s.CreateUser(ctx, models.UserRegReq{Login: "anna", Password: "Str0ng-enough-pass"})
+	require.NoError(t, err)
+	require.NoError(t, s.DBConnClose())
+
+	require.NoError(t, runSetUserRole(append(dsn, "-user-id", "1", "-role", "admin")))
+	require.NoError(t, runSetUserStatus(append(dsn, "-user-id", "1", "-status", "suspended", "-reason", "spam")))
+	require.NoError(t, runNormalizeLogins(dsn))
+
+	s = openTestStorage(t, dsn...)
+	defer s.DBConnClose()
+	user, err := s.GetUserByID(ctx, anna.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserRoleAdmin, user.Role)
+	assert.Equal(t, models.UserStatusSuspended, user.Status)
+}
+
+func TestNormalizeLogins_MemoryStorage(t *testing.T) {
+	err := runNormalizeLogins([]string{"-storage", "memory", "-l", "error"})
+	assert.ErrorContains(t, err, "needs a database storage")
+}
+
+func TestCalibrateHash_FlagRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "parallelism above uint8", args: []string{"-parallelism", "256"}, wantErr: "parallelism"},
+		{name: "zero memory", args: []string{"-memory-budget", "0"}, wantErr: "memory budget"},
+		{name: "memory above uint32 KiB", args: []string{"-memory-budget", "4194304"}, wantErr: "memory budget"},
+		{name: "salt length above uint32", args: []string{"-salt-length", "4294967296"}, wantErr: "salt length"},
+		{name: "key length above uint32", args: []string{"-key-length", "4294967296"}, wantErr: "key length"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runCalibrateHash(tt.args)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
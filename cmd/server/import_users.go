@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"os"
+	"time"
+)
+
+// importRecord - строка файла импорта: пользователь прежней системы с готовым хэшем пароля.
+// Поддерживаются хэши argon2id, bcrypt, scrypt и PBKDF2-SHA256 (passlib и Django).
+type importRecord struct {
+	Login        string    `json:"login"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// runImportUsers загружает пользователей из файла в формате JSON Lines, по записи на строку.
+// Уже существующие логины пропускаются, ошибочные строки выводятся и не прерывают импорт.
+func runImportUsers(args []string) error {
+	servConfig := &server_config.ServerConfig{}
+	fs := flag.NewFlagSet("import-users", flag.ContinueOnError)
+	path := fs.String("file", "", "path to a JSON Lines file with login, password_hash and optional created_at")
+	if err := servConfig.ParseArgs(fs, args); err != nil {
+		return fmt.Errorf("failed to parse import-users flags: %w", err)
+	}
+	if *path == "" {
+		return errors.New("-file is required")
+	}
+
+	logger, err := logger.NewZapLogger(servConfig.LogLevel)
+	if err != nil {
+		return fmt.Errorf("failed to create zap logger: %w", err)
+	}
+	s, err := store.NewStorage(servConfig, logger)
+	if err != nil {
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+	defer s.DBConnClose()
+
+	file, err := os.Open(*path)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer file.Close()
+
+	ctx := context.Background()
+	var imported, skipped, failed int
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record importRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			fmt.Fprintf(os.Stderr, "line %d: invalid json: %v\n", lineNum, err)
+			failed++
+			continue
+		}
+		if record.Login == "" || record.PasswordHash == "" {
+			fmt.Fprintf(os.Stderr, "line %d: login and password_hash are required\n", lineNum)
+			failed++
+			continue
+		}
+		ok, err := s.ImportUser(ctx, models.User{
+			Login:        record.Login,
+			PasswordHash: record.PasswordHash,
+			CreatedAt:    record.CreatedAt,
+		})
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", lineNum, err)
+			failed++
+		case !ok:
+			fmt.Fprintf(os.Stderr, "line %d: login %q already exists, skipped\n", lineNum, record.Login)
+			skipped++
+		default:
+			imported++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read import file: %w", err)
+	}
+
+	fmt.Fprintf(os.Stdout, "imported: %d, skipped: %d, failed: %d\n", imported, skipped, failed)
+	return nil
+}
@@ -1,77 +1,316 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/export"
+	"github.com/eampleev23/raya-backend.git/internal/feed"
 	"github.com/eampleev23/raya-backend.git/internal/handlers"
+	"github.com/eampleev23/raya-backend.git/internal/ipfilter"
+	"github.com/eampleev23/raya-backend.git/internal/jobs"
 	"github.com/eampleev23/raya-backend.git/internal/logger"
 	"github.com/eampleev23/raya-backend.git/internal/middlewares"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/notifications"
+	"github.com/eampleev23/raya-backend.git/internal/outbox"
 	"github.com/eampleev23/raya-backend.git/internal/server_config"
 	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/eampleev23/raya-backend.git/internal/webhooks"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
-	err := run()
+	var err error
+	// Служебные подкоманды разбирают свои флаги сами.
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == "calibrate-hash":
+		err = runCalibrateHash(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "import-users":
+		err = runImportUsers(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "normalize-logins":
+		err = runNormalizeLogins(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "set-user-status":
+		err = runSetUserStatus(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "set-user-role":
+		err = runSetUserRole(os.Args[2:])
+	default:
+		err = run()
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
+// shutdownTimeout - сколько ждём завершения текущих запросов при остановке.
+const shutdownTimeout = 10 * time.Second
+
 func run() error {
 
-	servConfig := server_config.NewServerConfig()
+	servConfig, err := server_config.NewServerConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load server config: %w", err)
+	}
 	logger, err := logger.NewZapLogger(servConfig.LogLevel)
 	if err != nil {
 		return fmt.Errorf("failed to create zap logger: %w", err)
 	}
 	logger.ZL.Debug("logger created")
 
-	auth, err := auth.Initialize(servConfig, logger)
+	store, err := store.NewStorage(servConfig, logger)
+	if err != nil {
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+	// Отложенно закрываем соединение с бд; хранилище в памяти при этом сохраняет снимок.
+	defer func() {
+		if err := store.DBConnClose(); err != nil {
+			logger.ZL.Info("store failed to properly close the DB connection", zap.Error(err))
+		}
+	}()
+	// Токены проверяются по актуальному статусу пользователя из хранилища.
+	auth, err := auth.Initialize(servConfig, logger, store)
 	if err != nil {
 		return fmt.Errorf("failed to initialize a new authorizer: %w", err)
 	}
-	store, err := store.NewStorage(servConfig, logger)
+	// Выгрузка персональных данных; новые разделы подключаются через exporter.AddSection.
+	exporter := export.NewExporter(servConfig, logger, store,
+		export.ProfileSection(store),
+		export.AuditSection(store),
+		export.LoginHistorySection(store),
+		export.SessionsSection(store, store, servConfig.TokenExp),
+		export.DevicesSection(store))
+	// Рассылка событий о пользователях по подпискам на вебхуки.
+	dispatcher := webhooks.NewDispatcher(store, servConfig, logger)
+	// Уведомления безопасности для потока /user/events/stream/.
+	securityFeed := feed.NewFeed(store)
+	// События из outbox уходят в лог, в вебхуки и в ленту уведомлений; брокер подключается через outbox.NewBusSink.
+	sinks := []outbox.Sink{outbox.NewLogSink(logger), dispatcher, securityFeed}
+	// Письма ставятся в очередь по событиям из outbox; без транспорта почта не отправляется.
+	transport, err := notifications.NewTransport(servConfig)
 	if err != nil {
-		return fmt.Errorf("failed to create store: %w", err)
+		return fmt.Errorf("failed to create mail transport: %w", err)
 	}
-	if len(servConfig.DBDSN) != 0 {
-		// Отложенно закрываем соединение с бд.
-		defer func() {
-			if err := store.DBConnClose(); err != nil {
-				logger.ZL.Info("store failed to properly close the DB connection")
-			}
-		}()
+	if transport == nil && (servConfig.RiskStepUpScore > 0 || servConfig.RiskBlockScore > 0) {
+		// Код подтверждения входа было бы не отправить, и все такие входы отклонялись бы.
+		return errors.New("risk-step-up-score and risk-block-score need a notify-transport to send login codes")
+	}
+	var notifier *notifications.Notifier
+	if transport != nil {
+		notifier, err = notifications.NewNotifier(store, servConfig, logger, transport)
+		if err != nil {
+			return fmt.Errorf("failed to create notifier: %w", err)
+		}
+		sinks = append(sinks, notifier)
 	}
-	handlers, err := handlers.NewHandlers(store, servConfig, logger, auth)
+	relay := outbox.NewRelay(store, servConfig, logger, sinks...)
+	handlers, err := handlers.NewHandlers(store, servConfig, logger, auth,
+		handlers.WithExporter(exporter), handlers.WithWebhooks(dispatcher), handlers.WithFeed(securityFeed),
+		handlers.WithNotifier(notifier))
 	if err != nil {
 		return fmt.Errorf("failed to create handlers: %w", err)
 	}
+	defer func() {
+		if err := handlers.Close(); err != nil {
+			logger.ZL.Info("failed to release handlers resources", zap.Error(err))
+		}
+	}()
+
+	// Ограничение частоты запросов. Адрес клиента определяется так же, как в хэндлерах, с учётом доверенных прокси.
+	ipFilter, err := ipfilter.NewFilter(servConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create ip filter: %w", err)
+	}
+	limiter, err := newRateLimiter(servConfig, store, logger)
+	if err != nil {
+		return err
+	}
+	loginLimit, err := middlewares.ParseRateLimitPolicy("login", servConfig.RateLimitLogin, middlewares.KeyByIP(ipFilter.ClientIP))
+	if err != nil {
+		return fmt.Errorf("failed to parse login rate limit: %w", err)
+	}
+	registrationLimit, err := middlewares.ParseRateLimitPolicy(
+		"registration", servConfig.RateLimitRegister, middlewares.KeyByIP(ipFilter.ClientIP))
+	if err != nil {
+		return fmt.Errorf("failed to parse registration rate limit: %w", err)
+	}
+	// Лимиты входа и регистрации - защита от подбора паролей, без счётчиков эти роуты закрываются.
+	for _, policy := range []*middlewares.RateLimitPolicy{loginLimit, registrationLimit} {
+		if policy != nil {
+			policy.FailClosed = true
+		}
+	}
+	apiKey, err := middlewares.RateLimitKeyByName(servConfig.RateLimitAPIKey, ipFilter.ClientIP)
+	if err != nil {
+		return fmt.Errorf("failed to parse api rate limit key: %w", err)
+	}
+	apiLimit, err := middlewares.ParseRateLimitPolicy("api", servConfig.RateLimitAPI, apiKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse api rate limit: %w", err)
+	}
 
 	logger.ZL.Info("Running server", zap.String("address", servConfig.RunAddr))
 
 	routers := chi.NewRouter()
 
-	routers.Use(logger.RequestLogger)
-	routers.Use(middlewares.CheckAndSetContenType)
+	routers.Use(middlewares.RequestID, logger.RequestLogger)
 
-	routers.Group(func(router chi.Router) {
-		router.Use(auth.MiddleCheckNoAuth)
-		router.Post("/user/login/", handlers.Login)
-		router.Post("/user/registration/", handlers.Registration)
-	})
+	routers.Group(func(api chi.Router) {
+		api.Use(middlewares.CheckAndSetContenType)
+
+		api.Group(func(router chi.Router) {
+			router.Use(auth.MiddleCheckNoAuth)
+			router.With(limiter.Limit(loginLimit)).Post("/user/login/", handlers.Login)
+			router.With(limiter.Limit(registrationLimit)).Post("/user/registration/", handlers.Registration)
+		})
+
+		api.Group(func(router chi.Router) {
+			router.Use(auth.MiddleCheckAuth, limiter.Limit(apiLimit))
+			router.Post("/user/logout/", handlers.Logout)
+			router.Get("/user/me/", handlers.GetMe)
+			router.Patch("/user/me/", handlers.PatchMe)
+			router.Delete("/user/me/", handlers.DeleteMe)
+			router.Post("/user/export/", handlers.RequestExport)
+			router.Get("/user/export/", handlers.GetExport)
+			router.Get("/user/events/stream/", handlers.EventsStream)
+			router.Get("/user/notifications/", handlers.GetNotificationPreferences)
+			router.Patch("/user/notifications/", handlers.PatchNotificationPreferences)
+			router.Get("/user/devices/", handlers.ListDevices)
+			router.Delete("/user/devices/{id}/", handlers.DeleteDevice)
+		})
+
+		// Управление пользователями, только для администраторов.
+		api.Route("/admin/users", func(router chi.Router) {
+			router.Use(auth.MiddleCheckAuth, limiter.Limit(apiLimit), auth.MiddleRequireRole(models.UserRoleAdmin))
+			router.Get("/", handlers.AdminListUsers)
+			router.Get("/search/", handlers.AdminSearchUsers)
+			router.Post("/", handlers.AdminCreateUser)
+			router.Get("/{id}/", handlers.AdminGetUser)
+			router.Post("/{id}/password/", handlers.AdminResetPassword)
+			router.Post("/{id}/suspend/", handlers.AdminSuspendUser)
+			router.Post("/{id}/reactivate/", handlers.AdminReactivateUser)
+			router.Post("/{id}/logout/", handlers.AdminForceLogout)
+		})
 
-	routers.Group(func(router chi.Router) {
-		router.Use(auth.MiddleCheckAuth)
-		router.Post("/user/logout/", handlers.Logout)
+		// Журнал безопасности, только для администраторов.
+		api.Route("/admin/audit", func(router chi.Router) {
+			router.Use(auth.MiddleCheckAuth, limiter.Limit(apiLimit), auth.MiddleRequireRole(models.UserRoleAdmin))
+			router.Get("/", handlers.AdminListAuditEvents)
+			router.Get("/verify/", handlers.AdminVerifyAudit)
+		})
+
+		// Подписки на вебхуки и журнал доставок, только для администраторов.
+		api.Route("/admin/webhooks", func(router chi.Router) {
+			router.Use(auth.MiddleCheckAuth, limiter.Limit(apiLimit), auth.MiddleRequireRole(models.UserRoleAdmin))
+			router.Get("/", handlers.AdminListWebhooks)
+			router.Post("/", handlers.AdminCreateWebhook)
+			router.Delete("/{id}/", handlers.AdminDeleteWebhook)
+			router.Get("/{id}/deliveries/", handlers.AdminListWebhookDeliveries)
+			router.Get("/deliveries/{deliveryID}/", handlers.AdminGetWebhookDelivery)
+			router.Post("/deliveries/{deliveryID}/replay/", handlers.AdminReplayWebhookDelivery)
+		})
+
+		// Ограничения по адресам для пользователей и ролей, только для администраторов.
+		api.Route("/admin/ip-rules", func(router chi.Router) {
+			router.Use(auth.MiddleCheckAuth, limiter.Limit(apiLimit), auth.MiddleRequireRole(models.UserRoleAdmin))
+			router.Get("/", handlers.AdminListIPRules)
+			router.Post("/", handlers.AdminCreateIPRule)
+			router.Delete("/{id}/", handlers.AdminDeleteIPRule)
+		})
+
+		// Метрики сервиса в формате expvar, только для администраторов.
+		api.With(auth.MiddleCheckAuth, limiter.Limit(apiLimit), auth.MiddleRequireRole(models.UserRoleAdmin)).
+			Get("/debug/vars", handlers.AdminMetrics)
+
+		// Удалённый пользователь не может войти, поэтому восстановление доступно без авторизации.
+		// Восстановление проверяет пароль, поэтому считается вместе со входами.
+		api.With(limiter.Limit(loginLimit)).Post("/user/restore/", handlers.Restore)
 	})
 
-	err = http.ListenAndServe(servConfig.RunAddr, routers)
-	if err != nil {
+	// Архив отдаётся как zip, а доступ к нему даёт подпись в ссылке.
+	routers.Get("/user/export/download/", handlers.DownloadExport)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Фоновые задачи: окончательная очистка удалённых учётных записей, сборка выгрузок,
+	// рассылка событий из outbox, рассылка вебхуков, отправка писем, очистка счётчиков лимитов
+	// и сброс кэша статусов вслед за другими экземплярами сервера.
+	var background sync.WaitGroup
+	background.Add(6)
+	go func() {
+		defer background.Done()
+		jobs.NewPurger(store, servConfig, logger).Run(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		exporter.Run(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		relay.Run(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		dispatcher.Run(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		limiter.Run(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		auth.Run(ctx)
+	}()
+	if notifier != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			notifier.Run(ctx)
+		}()
+	}
+
+	server := &http.Server{Addr: servConfig.RunAddr, Handler: routers}
+	// Shutdown не прерывает запросы, а потоки событий сами не заканчиваются.
+	server.RegisterOnShutdown(handlers.StopStreams)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		stop()
+		background.Wait()
 		return fmt.Errorf("failed to start server: %w", err)
+	case <-ctx.Done():
+	}
+
+	logger.ZL.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
+	// Фоновые задачи остановлены вместе с ctx, их дожидаемся, даже если запросы не успели завершиться.
+	background.Wait()
+	if err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
 	}
 	return nil
 }
+
+// newRateLimiter создаёт ограничитель частоты запросов со счётчиками в памяти или в базе, по настройке.
+func newRateLimiter(c *server_config.ServerConfig, s store.Store, l *logger.ZapLog) (*middlewares.RateLimiter, error) {
+	backend, err := store.NewRateLimitBackend(c.RateLimitBackend, s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rate limit backend: %w", err)
+	}
+	return middlewares.NewRateLimiter(backend, l, c.RateLimitCleanup), nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"os"
+	"sort"
+)
+
+// loginReconciler - хранилище, которое умеет сверять логины; это DBStore с Postgres или SQLite.
+type loginReconciler interface {
+	ReconcileLogins(ctx context.Context, apply bool) (store.LoginReconciliation, error)
+}
+
+// runNormalizeLogins сверяет канонические формы логинов в БД с текущими правилами нормализации
+// и печатает коллизии. С -apply обновляет записи, которые можно исправить без конфликтов.
+func runNormalizeLogins(args []string) error {
+	servConfig := &server_config.ServerConfig{}
+	fs := flag.NewFlagSet("normalize-logins", flag.ContinueOnError)
+	apply := fs.Bool("apply", false, "update drifted normalized logins that do not collide")
+	if err := servConfig.ParseArgs(fs, args); err != nil {
+		return fmt.Errorf("failed to parse normalize-logins flags: %w", err)
+	}
+
+	logger, err := logger.NewZapLogger(servConfig.LogLevel)
+	if err != nil {
+		return fmt.Errorf("failed to create zap logger: %w", err)
+	}
+	s, err := store.NewStorage(servConfig, logger)
+	if err != nil {
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+	defer s.DBConnClose()
+	// У хранилища в памяти сверки логинов нет.
+	reconciler, ok := s.(loginReconciler)
+	if !ok {
+		return errors.New("normalize-logins needs a database storage, Postgres or SQLite")
+	}
+
+	report, err := reconciler.ReconcileLogins(context.Background(), *apply)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile logins: %w", err)
+	}
+
+	for _, drift := range report.Drifted {
+		fmt.Fprintf(os.Stdout, "drifted: %s (id %d): %q -> %q\n",
+			drift.Login, drift.UserID, drift.StoredNormalized, drift.ComputedNormalized)
+	}
+	for _, normalized := range sortedKeys(report.Collisions) {
+		fmt.Fprintf(os.Stdout, "collision: %q is shared by %v\n", normalized, report.Collisions[normalized])
+	}
+	for _, skeleton := range sortedKeys(report.Confusable) {
+		fmt.Fprintf(os.Stdout, "confusable: %v look like %q\n", report.Confusable[skeleton], skeleton)
+	}
+	fmt.Fprintf(os.Stdout, "drifted: %d, collisions: %d, confusable: %d, updated: %d\n",
+		len(report.Drifted), len(report.Collisions), len(report.Confusable), report.Updated)
+	return nil
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"os"
+)
+
+// runSetUserRole выдаёт или снимает роль, например чтобы назначить первого администратора.
+func runSetUserRole(args []string) error {
+	servConfig := &server_config.ServerConfig{}
+	fs := flag.NewFlagSet("set-user-role", flag.ContinueOnError)
+	userID := fs.Int("user-id", 0, "id of the user")
+	role := fs.String("role", "", "new role: user or admin")
+	if err := servConfig.ParseArgs(fs, args); err != nil {
+		return fmt.Errorf("failed to parse set-user-role flags: %w", err)
+	}
+	if *userID <= 0 || *role == "" {
+		return fmt.Errorf("set-user-role requires -user-id and -role")
+	}
+
+	logger, err := logger.NewZapLogger(servConfig.LogLevel)
+	if err != nil {
+		return fmt.Errorf("failed to create zap logger: %w", err)
+	}
+	s, err := store.NewStorage(servConfig, logger)
+	if err != nil {
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+	defer s.DBConnClose()
+
+	user, err := s.SetUserRole(context.Background(), *userID, models.UserRole(*role))
+	if err != nil {
+		return fmt.Errorf("failed to set user role: %w", err)
+	}
+	// Команда запускается с сервера, поэтому исполнитель в журнале не указывается.
+	_, err = s.AppendAuditEvent(context.Background(), models.AuditEvent{
+		Type: models.AuditRoleChange, TargetID: &user.ID, Result: models.AuditSuccess,
+		Reason: string(user.Role), UserAgent: "set-user-role",
+	})
+	if err != nil {
+		return fmt.Errorf("role changed, but failed to record audit event: %w", err)
+	}
+	fmt.Fprintf(os.Stdout, "user %s (id %d) is now %s\n", user.Login, user.ID, user.Role)
+	return nil
+}
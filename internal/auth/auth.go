@@ -3,9 +3,13 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/ipfilter"
 	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
 	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
 	"github.com/golang-jwt/jwt/v4"
 	"go.uber.org/zap"
 	"net/http"
@@ -16,30 +20,111 @@ import (
 type resultMsg struct {
 	IsError       bool
 	ResultMessage string `json:"result_message"`
+	Code          string `json:"code,omitempty"`
 }
 
 type Authorizer struct {
-	logger   *logger.ZapLog
-	servConf *server_config.ServerConfig
+	logger       *logger.ZapLog
+	servConf     *server_config.ServerConfig
+	source       StatusSource
+	statuses     *statusCache // nil - статус пользователя не проверяется.
+	ipFilter     *ipfilter.Filter
+	syncInterval time.Duration
 }
 
 var keyLogger logger.Key = logger.KeyLoggerCtx
 
 // Initialize инициализирует синглтон авторизовывальщика с секретным ключом.
-func Initialize(c *server_config.ServerConfig, l *logger.ZapLog) (*Authorizer, error) {
+// По source проверяется, что владелец токена всё ещё активен; nil отключает проверку.
+func Initialize(c *server_config.ServerConfig, l *logger.ZapLog, source StatusSource) (*Authorizer, error) {
+	ipFilter, err := ipfilter.NewFilter(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ip filter: %w", err)
+	}
 	au := &Authorizer{
-		servConf: c,
-		logger:   l,
+		servConf:     c,
+		logger:       l,
+		source:       source,
+		ipFilter:     ipFilter,
+		syncInterval: c.AuthStatusSyncInterval,
+	}
+	if source != nil {
+		au.statuses = newStatusCache(source, c.AuthStatusCacheTTL)
 	}
 	return au, nil
 }
 
+// InvalidateUserStatus сбрасывает закэшированный статус пользователя после его смены.
+func (au *Authorizer) InvalidateUserStatus(ctx context.Context, userID int) {
+	if au.statuses != nil {
+		au.statuses.invalidate(userID)
+		au.bumpVersion(ctx)
+	}
+}
+
+// InvalidateAllStatuses сбрасывает весь кэш, например после смены ограничений для целой роли.
+func (au *Authorizer) InvalidateAllStatuses(ctx context.Context) {
+	if au.statuses != nil {
+		au.statuses.invalidateAll()
+		au.bumpVersion(ctx)
+	}
+}
+
+// bumpVersion сообщает о сбросе остальным экземплярам сервера. Если не вышло, они увидят изменение
+// по истечении кэша, как и раньше.
+func (au *Authorizer) bumpVersion(ctx context.Context) {
+	if err := au.source.BumpAuthStateVersion(ctx); err != nil {
+		au.logger.ZL.Warn("failed to bump auth state version", zap.Error(err))
+	}
+}
+
+// Run раз в syncInterval сверяет версию состояния в хранилище и сбрасывает весь кэш, если она сменилась:
+// значит, статус или ограничения по адресам поменяли на другом экземпляре сервера. Без кэша или при
+// нулевом syncInterval сразу возвращается.
+func (au *Authorizer) Run(ctx context.Context) {
+	if au.statuses == nil || au.statuses.ttl <= 0 || au.syncInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(au.syncInterval)
+	defer ticker.Stop()
+	var seen int64
+	synced := false
+	for {
+		version, err := au.source.GetAuthStateVersion(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			au.logger.ZL.Error("failed to get auth state version", zap.Error(err))
+		case err == nil && (!synced || version != seen):
+			au.statuses.invalidateAll()
+			seen, synced = version, true
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 type Key string
 
 const (
 	KeyUserIDCtx Key = "user_id_ctx"
+	// KeyClaimsCtx - claims токена, по которому MiddleCheckAuth пропустил запрос.
+	KeyClaimsCtx Key = "claims_ctx"
 )
 
+// accessDenial - почему запрос с действительным токеном обслуживать нельзя, и как об этом ответить.
+type accessDenial struct {
+	statusCode int
+	message    string
+	code       string
+}
+
+func (d *accessDenial) Error() string {
+	return d.message
+}
+
 // MiddleCheckAuth мидлвар, который проверяет авторизацию.
 func (au *Authorizer) MiddleCheckAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(responseWriter http.ResponseWriter, gotRequest *http.Request) {
@@ -81,12 +166,121 @@ func (au *Authorizer) MiddleCheckAuth(next http.Handler) http.Handler {
 			responseWriter.Write(msg)
 			return
 		}
-		// 4. Передаем userID в контекст.
-		ctx := context.WithValue(context.Background(), KeyUserIDCtx, claims.UserID)
+		// 4. Проверяем, что учётную запись не заблокировали после выдачи токена.
+		if err := au.checkAccess(gotRequest, claims); err != nil {
+			var denial *accessDenial
+			if !errors.As(err, &denial) {
+				au.logger.ZL.Error("Failed to get user status", zap.Int("userID", claims.UserID), zap.Error(err))
+				au.writeError(responseWriter, http.StatusServiceUnavailable, "Failed to check account status", "")
+				return
+			}
+			au.writeError(responseWriter, denial.statusCode, denial.message, denial.code)
+			return
+		}
+
+		// 5. Передаем userID в контекст. Контекст запроса сохраняется: по нему потоковые ответы узнают об уходе клиента.
+		ctx := context.WithValue(gotRequest.Context(), KeyUserIDCtx, claims.UserID)
+		ctx = context.WithValue(ctx, KeyClaimsCtx, claims)
 		next.ServeHTTP(responseWriter, gotRequest.WithContext(ctx))
 	})
 }
 
+// checkAccess проверяет, что по токену с claims всё ещё можно работать: сессии не отозваны,
+// учётная запись активна и адрес клиента разрешён. Отказ возвращается как *accessDenial,
+// любая другая ошибка - это сбой хранилища.
+func (au *Authorizer) checkAccess(gotRequest *http.Request, claims *Claims) error {
+	var ipRules []models.IPRule
+	if au.statuses != nil {
+		state, err := au.statuses.get(gotRequest.Context(), claims.UserID)
+		if errors.Is(err, store.ErrUserNotFound) {
+			return &accessDenial{statusCode: http.StatusUnauthorized, message: "Invalid token"}
+		}
+		if err != nil {
+			return err
+		}
+		if revoked(claims, state) {
+			au.logger.ZL.Debug("Revoked token rejected", zap.Int("userID", claims.UserID))
+			return &accessDenial{statusCode: http.StatusUnauthorized, message: "Session has been revoked", code: "session_revoked"}
+		}
+		if state.Status != models.UserStatusActive {
+			au.logger.ZL.Debug("Token of inactive user rejected",
+				zap.Int("userID", claims.UserID), zap.String("status", string(state.Status)))
+			return &accessDenial{
+				statusCode: http.StatusForbidden, message: "Account is " + string(state.Status), code: state.Status.ErrorCode(),
+			}
+		}
+		ipRules = state.IPRules
+	}
+	// Ограничения по адресам действуют и на уже выданные токены.
+	if ip := au.ipFilter.ClientIP(gotRequest); !au.ipFilter.Allowed(ip, ipRules) {
+		au.logger.ZL.Debug("Request from disallowed address rejected", zap.Int("userID", claims.UserID), zap.String("ip", ip))
+		return &accessDenial{statusCode: http.StatusForbidden, message: "Access from this address is not allowed", code: "ip_not_allowed"}
+	}
+	return nil
+}
+
+// Recheck повторяет проверки MiddleCheckAuth для запроса, который она уже пропустила. Нужна долгим ответам,
+// например потоку событий: отзыв сессий, блокировка или удаление учётной записи должны закрывать и их.
+// Статус берётся из того же кэша, поэтому частые повторы не нагружают хранилище.
+func (au *Authorizer) Recheck(gotRequest *http.Request) error {
+	claims, ok := gotRequest.Context().Value(KeyClaimsCtx).(*Claims)
+	if !ok {
+		return errors.New("request was not authorized by a token")
+	}
+	return au.checkAccess(gotRequest, claims)
+}
+
+// MiddleRequireRole пропускает только пользователей с ролью role. Ставится после MiddleCheckAuth.
+// Роль берётся из того же кэша, что и статус, так что снятие прав вступает в силу так же быстро.
+func (au *Authorizer) MiddleRequireRole(role models.UserRole) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+			userID, ok := gotRequest.Context().Value(KeyUserIDCtx).(int)
+			if !ok {
+				au.writeError(responseWriter, http.StatusUnauthorized, "Authentication required", "")
+				return
+			}
+			if au.statuses == nil {
+				// Без хранилища роль не проверить - закрываем доступ.
+				au.writeError(responseWriter, http.StatusForbidden, "Insufficient permissions", "forbidden")
+				return
+			}
+			state, err := au.statuses.get(gotRequest.Context(), userID)
+			if err != nil && !errors.Is(err, store.ErrUserNotFound) {
+				au.logger.ZL.Error("Failed to get user role", zap.Int("userID", userID), zap.Error(err))
+				au.writeError(responseWriter, http.StatusServiceUnavailable, "Failed to check account status", "")
+				return
+			}
+			if err != nil || state.Role != role {
+				au.logger.ZL.Debug("Access denied by role", zap.Int("userID", userID), zap.String("role", string(state.Role)))
+				au.writeError(responseWriter, http.StatusForbidden, "Insufficient permissions", "forbidden")
+				return
+			}
+			next.ServeHTTP(responseWriter, gotRequest)
+		})
+	}
+}
+
+// revoked сообщает, что токен выдан раньше, чем пользователю отозвали все сессии.
+// iat в токене хранится с точностью до секунды, поэтому и момент отзыва округляется вниз.
+func revoked(claims *Claims, state models.UserAuthState) bool {
+	if state.TokensValidAfter.IsZero() {
+		return false
+	}
+	if claims.IssuedAt == nil {
+		// Токены без iat выданы до появления отзыва, проверить их нельзя.
+		return true
+	}
+	return claims.IssuedAt.Time.Before(state.TokensValidAfter.Truncate(time.Second))
+}
+
+func (au *Authorizer) writeError(responseWriter http.ResponseWriter, statusCode int, message, code string) {
+	resultMsg := resultMsg{IsError: true, ResultMessage: message, Code: code}
+	msg, _ := json.Marshal(resultMsg)
+	responseWriter.WriteHeader(statusCode)
+	responseWriter.Write(msg)
+}
+
 // MiddleCheckNoAuth мидлвар, который проверяет роуты, к которым должны обращаться не авторизованные пользователи.
 func (au *Authorizer) MiddleCheckNoAuth(next http.Handler) http.Handler {
 	fn := func(responseWriter http.ResponseWriter, gotRequest *http.Request) {
@@ -107,10 +301,12 @@ func (au *Authorizer) MiddleCheckNoAuth(next http.Handler) http.Handler {
 
 func (au *Authorizer) SetNewCookie(w http.ResponseWriter, userID int, userLogin string) (err error) {
 	au.logger.ZL.Debug("setNewCookie got userID", zap.Int("userID", userID))
+	now := time.Now()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			// Когда создан токен.
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(au.servConf.TokenExp)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(au.servConf.TokenExp)),
 		},
 		// Собственное утверждение.
 		UserID:    userID,
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sync"
+	"time"
+)
+
+// StatusSource отдаёт актуальный статус учётной записи и ограничения по адресам, обычно это хранилище.
+// Версия состояния растёт при каждом сбросе кэша на любом экземпляре сервера, по ней сбрасывают кэш остальные.
+type StatusSource interface {
+	GetUserAuthState(ctx context.Context, userID int) (state models.UserAuthState, err error)
+	GetAuthStateVersion(ctx context.Context) (version int64, err error)
+	BumpAuthStateVersion(ctx context.Context) (err error)
+}
+
+// maxStatusCacheEntries - после этого числа записей кэш вычищает устаревшие, а если не помогло - сбрасывается.
+const maxStatusCacheEntries = 100_000
+
+type statusEntry struct {
+	state     models.UserAuthState
+	expiresAt time.Time
+}
+
+// statusCache кэширует статусы пользователей на короткое время, чтобы не ходить в БД на каждый запрос.
+// Блокировка пользователя вступает в силу не позже чем через ttl.
+type statusCache struct {
+	source  StatusSource
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[int]statusEntry
+	// generation растёт при каждом сбросе. Статус, прочитанный до сброса, в кэш уже не попадает:
+	// иначе старое "active" вернулось бы туда на весь ttl и отменило бы блокировку или отзыв.
+	generation uint64
+}
+
+func newStatusCache(source StatusSource, ttl time.Duration) *statusCache {
+	return &statusCache{
+		source:  source,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[int]statusEntry),
+	}
+}
+
+func (c *statusCache) get(ctx context.Context, userID int) (models.UserAuthState, error) {
+	if c.ttl <= 0 {
+		return c.source.GetUserAuthState(ctx, userID)
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expiresAt) {
+		return entry.state, nil
+	}
+
+	state, err := c.source.GetUserAuthState(ctx, userID)
+	if err != nil {
+		return state, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		// Пока статус читался, кэш сбросили: ответ годится для этого запроса, но не для следующих.
+		return state, nil
+	}
+	now := c.now()
+	if len(c.entries) >= maxStatusCacheEntries {
+		for id, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, id)
+			}
+		}
+		if len(c.entries) >= maxStatusCacheEntries {
+			c.entries = make(map[int]statusEntry)
+		}
+	}
+	c.entries[userID] = statusEntry{state: state, expiresAt: now.Add(c.ttl)}
+	return state, nil
+}
+
+// invalidate забывает статус пользователя, следующий запрос прочитает его заново.
+func (c *statusCache) invalidate(userID int) {
+	c.mu.Lock()
+	delete(c.entries, userID)
+	c.generation++
+	c.mu.Unlock()
+}
+
+// invalidateAll забывает статусы всех пользователей.
+func (c *statusCache) invalidateAll() {
+	c.mu.Lock()
+	c.entries = make(map[int]statusEntry)
+	c.generation++
+	c.mu.Unlock()
+}
@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeStatusSource struct {
+	statuses         map[int]models.UserStatus
+	tokensValidAfter map[int]time.Time
+	roles            map[int]models.UserRole
+	ipRules          map[int][]models.IPRule
+	calls            int
+	version          atomic.Int64
+}
+
+func (f *fakeStatusSource) GetAuthStateVersion(context.Context) (int64, error) {
+	return f.version.Load(), nil
+}
+
+func (f *fakeStatusSource) BumpAuthStateVersion(context.Context) error {
+	f.version.Add(1)
+	return nil
+}
+
+func (f *fakeStatusSource) GetUserAuthState(ctx context.Context, userID int) (models.UserAuthState, error) {
+	f.calls++
+	status, ok := f.statuses[userID]
+	if !ok {
+		return models.UserAuthState{}, store.ErrUserNotFound
+	}
+	return models.UserAuthState{
+		Role: f.roles[userID], Status: status, TokensValidAfter: f.tokensValidAfter[userID], IPRules: f.ipRules[userID],
+	}, nil
+}
+
+func TestMiddleCheckAuth_UserStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     int
+		wantStatus int
+	}{
+		{name: "active user", userID: 1, wantStatus: http.StatusOK},
+		{name: "suspended after token was issued", userID: 2, wantStatus: http.StatusForbidden},
+		{name: "locked user", userID: 3, wantStatus: http.StatusForbidden},
+		{name: "user no longer exists", userID: 4, wantStatus: http.StatusUnauthorized},
+		{name: "sessions revoked after token was issued", userID: 5, wantStatus: http.StatusUnauthorized},
+		{name: "token issued after sessions were revoked", userID: 6, wantStatus: http.StatusOK},
+	}
+	source := &fakeStatusSource{statuses: map[int]models.UserStatus{
+		1: models.UserStatusActive,
+		2: models.UserStatusSuspended,
+		3: models.UserStatusLocked,
+		5: models.UserStatusActive,
+		6: models.UserStatusActive,
+	}, tokensValidAfter: map[int]time.Time{
+		5: time.Now().Add(time.Hour),
+		6: time.Now().Add(-time.Hour),
+	}}
+	c := &server_config.ServerConfig{SecretKey: "secret", TokenExp: time.Hour, AuthStatusCacheTTL: time.Minute}
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	au, err := Initialize(c, l, source)
+	require.NoError(t, err)
+
+	handler := au.MiddleCheckAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			login := httptest.NewRecorder()
+			require.NoError(t, au.SetNewCookie(login, tt.userID, "user"))
+
+			request := httptest.NewRequest(http.MethodGet, "/api/user/me/", nil)
+			request.AddCookie(login.Result().Cookies()[0])
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, request)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestStatusCache(t *testing.T) {
+	source := &fakeStatusSource{statuses: map[int]models.UserStatus{1: models.UserStatusActive}}
+	cache := newStatusCache(source, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	state, err := cache.get(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserStatusActive, state.Status)
+
+	// Пока запись не устарела, смена статуса не видна.
+	source.statuses[1] = models.UserStatusSuspended
+	state, err = cache.get(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserStatusActive, state.Status)
+	assert.Equal(t, 1, source.calls)
+
+	// После ttl статус читается заново.
+	now = now.Add(time.Minute)
+	state, err = cache.get(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserStatusSuspended, state.Status)
+
+	// Явный сброс тоже заставляет перечитать статус.
+	source.statuses[1] = models.UserStatusActive
+	cache.invalidate(1)
+	state, err = cache.get(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserStatusActive, state.Status)
+	assert.Equal(t, 3, source.calls)
+
+	// Ошибки не кэшируются.
+	_, err = cache.get(context.Background(), 2)
+	assert.ErrorIs(t, err, store.ErrUserNotFound)
+}
+
+// slowStatusSource отдаёт статус, прочитанный до сигнала release, как медленная база.
+type slowStatusSource struct {
+	fakeStatusSource
+	fetching chan struct{}
+	release  chan struct{}
+}
+
+func (s *slowStatusSource) GetUserAuthState(ctx context.Context, userID int) (models.UserAuthState, error) {
+	state, err := s.fakeStatusSource.GetUserAuthState(ctx, userID)
+	s.fetching <- struct{}{}
+	<-s.release
+	return state, err
+}
+
+func TestStatusCache_InvalidateDuringFetch(t *testing.T) {
+	source := &slowStatusSource{
+		fakeStatusSource: fakeStatusSource{statuses: map[int]models.UserStatus{1: models.UserStatusActive}},
+		fetching:         make(chan struct{}),
+		release:          make(chan struct{}),
+	}
+	cache := newStatusCache(source, time.Minute)
+
+	done := make(chan models.UserAuthState)
+	go func() {
+		state, _ := cache.get(context.Background(), 1)
+		done <- state
+	}()
+	<-source.fetching
+	// Пользователя блокируют, пока его прежний статус ещё читается.
+	source.statuses[1] = models.UserStatusSuspended
+	cache.invalidate(1)
+	close(source.release)
+	assert.Equal(t, models.UserStatusActive, (<-done).Status, "the request that started earlier sees the old status")
+
+	go func() { <-source.fetching }()
+	state, err := cache.get(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserStatusSuspended, state.Status, "the stale status was not cached")
+}
+
+func TestMiddleRequireRole(t *testing.T) {
+	source := &fakeStatusSource{
+		statuses: map[int]models.UserStatus{1: models.UserStatusActive, 2: models.UserStatusActive},
+		roles:    map[int]models.UserRole{1: models.UserRoleAdmin, 2: models.UserRoleUser},
+	}
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	au, err := Initialize(&server_config.ServerConfig{}, l, source)
+	require.NoError(t, err)
+	handler := au.MiddleRequireRole(models.UserRoleAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name       string
+		userID     any
+		wantStatus int
+	}{
+		{name: "admin", userID: 1, wantStatus: http.StatusOK},
+		{name: "regular user", userID: 2, wantStatus: http.StatusForbidden},
+		{name: "unknown user", userID: 3, wantStatus: http.StatusForbidden},
+		{name: "not authenticated", userID: nil, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/admin/users/", nil)
+			if tt.userID != nil {
+				request = request.WithContext(context.WithValue(request.Context(), KeyUserIDCtx, tt.userID))
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, request)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestMiddleCheckAuth_IPRules(t *testing.T) {
+	source := &fakeStatusSource{
+		statuses: map[int]models.UserStatus{1: models.UserStatusActive, 2: models.UserStatusActive},
+		ipRules: map[int][]models.IPRule{
+			1: {{CIDR: "198.51.100.0/24", Action: models.IPRuleAllow}},
+		},
+	}
+	c := &server_config.ServerConfig{
+		SecretKey: "secret", TokenExp: time.Hour, IPDenyList: "203.0.113.0/24", TrustedProxies: "10.0.0.1",
+	}
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	au, err := Initialize(c, l, source)
+	require.NoError(t, err)
+	handler := au.MiddleCheckAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name         string
+		userID       int
+		remoteAddr   string
+		forwardedFor string
+		wantStatus   int
+	}{
+		{name: "office address", userID: 1, remoteAddr: "198.51.100.7:1000", wantStatus: http.StatusOK},
+		{name: "outside the office", userID: 1, remoteAddr: "192.0.2.7:1000", wantStatus: http.StatusForbidden},
+		{name: "office address behind trusted proxy", userID: 1, remoteAddr: "10.0.0.1:1000", forwardedFor: "198.51.100.7", wantStatus: http.StatusOK},
+		{name: "forged header from untrusted peer", userID: 1, remoteAddr: "192.0.2.7:1000", forwardedFor: "198.51.100.7", wantStatus: http.StatusForbidden},
+		{name: "no own rules", userID: 2, remoteAddr: "192.0.2.7:1000", wantStatus: http.StatusOK},
+		{name: "globally denied", userID: 2, remoteAddr: "203.0.113.9:1000", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			login := httptest.NewRecorder()
+			require.NoError(t, au.SetNewCookie(login, tt.userID, "user"))
+
+			request := httptest.NewRequest(http.MethodGet, "/api/user/me/", nil)
+			request.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				request.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			request.AddCookie(login.Result().Cookies()[0])
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, request)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), "ip_not_allowed")
+			}
+		})
+	}
+}
+
+func TestAuthorizer_SyncsInvalidations(t *testing.T) {
+	source := &fakeStatusSource{statuses: map[int]models.UserStatus{1: models.UserStatusActive}}
+	c := &server_config.ServerConfig{AuthStatusCacheTTL: time.Hour, AuthStatusSyncInterval: time.Millisecond}
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	// Два экземпляра сервера с общим хранилищем.
+	local, err := Initialize(c, l, source)
+	require.NoError(t, err)
+	remote, err := Initialize(c, l, source)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		remote.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	cached := func() bool {
+		remote.statuses.mu.Lock()
+		defer remote.statuses.mu.Unlock()
+		_, ok := remote.statuses.entries[1]
+		return ok
+	}
+	require.Eventually(t, func() bool {
+		_, err := remote.statuses.get(ctx, 1)
+		return err == nil && cached()
+	}, time.Second, time.Millisecond, "remote caches the status once it has synced")
+
+	local.InvalidateUserStatus(ctx, 1)
+	assert.Equal(t, int64(1), source.version.Load())
+	assert.Eventually(t, func() bool { return !cached() }, time.Second, time.Millisecond,
+		"a reset on one instance reaches the others")
+}
@@ -0,0 +1,234 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Выгрузка персональных данных: архив собирается в фоне из зарегистрированных разделов
+// и отдаётся по подписанной ссылке с ограниченным сроком действия.
+
+var (
+	ErrQueueFull   = store.ErrExportQueueFull
+	ErrJobNotFound = store.ErrExportJobNotFound
+)
+
+// Store - где хранятся задания и собранные архивы.
+type Store interface {
+	EnqueueExportJob(ctx context.Context, job models.ExportJob, maxPending int) (queued models.ExportJob, err error)
+	GetLastExportJob(ctx context.Context, userID int) (job *models.ExportJob, err error)
+	ClaimExportJobs(ctx context.Context, now, leaseUntil time.Time, limit int) (jobs []models.ExportJob, err error)
+	CompleteExportJob(ctx context.Context, job models.ExportJob, archive []byte) (err error)
+	GetExportArchive(ctx context.Context, id string) (archive []byte, err error)
+	DeleteExpiredExportJobs(ctx context.Context, before time.Time) (deleted int, err error)
+}
+
+// Section - раздел выгрузки, например профиль или журнал событий. Collect возвращает данные,
+// которые кладутся в архив как <Name>.json.
+type Section interface {
+	Name() string
+	Collect(ctx context.Context, userID int) (any, error)
+}
+
+// SectionFunc позволяет описать раздел функцией.
+type SectionFunc struct {
+	SectionName string
+	CollectFunc func(ctx context.Context, userID int) (any, error)
+}
+
+func (s SectionFunc) Name() string { return s.SectionName }
+
+func (s SectionFunc) Collect(ctx context.Context, userID int) (any, error) {
+	return s.CollectFunc(ctx, userID)
+}
+
+const (
+	// queueSize - сколько выгрузок может ждать сборки.
+	queueSize = 64
+	// pollInterval - как часто проверяется очередь на случай, если выгрузку запросили на другом экземпляре.
+	pollInterval = 10 * time.Second
+	// buildLease - на сколько задание закрепляется за экземпляром, который собирает архив.
+	buildLease = 10 * time.Minute
+)
+
+// Exporter собирает архивы и хранит их вместе с заданиями в store до истечения срока ссылки,
+// так что выгрузку может собрать и отдать любой экземпляр сервера, в том числе после перезапуска.
+type Exporter struct {
+	store    Store
+	linkTTL  time.Duration
+	signer   *signer
+	logger   *logger.ZapLog
+	sections []Section
+	wake     chan struct{}
+	now      func() time.Time
+}
+
+func NewExporter(c *server_config.ServerConfig, l *logger.ZapLog, s Store, sections ...Section) *Exporter {
+	return &Exporter{
+		store:    s,
+		linkTTL:  c.ExportLinkTTL,
+		signer:   newSigner(c.SecretKey),
+		logger:   l,
+		sections: sections,
+		wake:     make(chan struct{}, 1),
+		now:      time.Now,
+	}
+}
+
+// AddSection добавляет раздел выгрузки. Вызывается до запуска Run.
+func (e *Exporter) AddSection(s Section) {
+	e.sections = append(e.sections, s)
+}
+
+// Request ставит выгрузку в очередь. Если у пользователя уже собирается архив, возвращается он.
+func (e *Exporter) Request(ctx context.Context, userID int) (models.ExportJob, error) {
+	id, err := newJobID()
+	if err != nil {
+		return models.ExportJob{}, err
+	}
+	job, err := e.store.EnqueueExportJob(ctx, models.ExportJob{ID: id, UserID: userID, CreatedAt: e.now()}, queueSize)
+	if err != nil {
+		return models.ExportJob{}, err
+	}
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Last возвращает последнюю выгрузку пользователя.
+func (e *Exporter) Last(ctx context.Context, userID int) (models.ExportJob, error) {
+	job, err := e.store.GetLastExportJob(ctx, userID)
+	if err != nil {
+		return models.ExportJob{}, err
+	}
+	return *job, nil
+}
+
+// Run собирает архивы из очереди и удаляет просроченные, пока не отменён ctx.
+func (e *Exporter) Run(ctx context.Context) {
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	cleanup := time.NewTicker(time.Minute)
+	defer cleanup.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.wake:
+			e.buildPending(ctx)
+		case <-poll.C:
+			e.buildPending(ctx)
+		case <-cleanup.C:
+			e.removeExpired(ctx)
+		}
+	}
+}
+
+// buildPending собирает все выгрузки, которые ждут сборки.
+func (e *Exporter) buildPending(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := e.now()
+		jobs, err := e.store.ClaimExportJobs(ctx, now, now.Add(buildLease), 1)
+		if err != nil {
+			if ctx.Err() == nil {
+				e.logger.ZL.Error("failed to claim data exports", zap.Error(err))
+			}
+			return
+		}
+		if len(jobs) == 0 {
+			return
+		}
+		e.build(ctx, jobs[0])
+	}
+}
+
+func (e *Exporter) build(ctx context.Context, job models.ExportJob) {
+	archive, err := e.writeArchive(ctx, job.UserID)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Сервер останавливается; после аренды выгрузку соберёт заново этот или другой экземпляр.
+			return
+		}
+		e.logger.ZL.Error("failed to build data export", zap.Int("user_id", job.UserID), zap.Error(err))
+		job.Status = models.ExportFailed
+	} else {
+		job.Status = models.ExportReady
+	}
+	job.ExpiresAt = e.now().Add(e.linkTTL)
+	err = e.store.CompleteExportJob(ctx, job, archive)
+	if err != nil && !errors.Is(err, ErrJobNotFound) {
+		e.logger.ZL.Error("failed to save data export", zap.Int("user_id", job.UserID), zap.Error(err))
+	}
+}
+
+// manifest - оглавление архива.
+type manifest struct {
+	UserID      int       `json:"user_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Sections    []string  `json:"sections"`
+}
+
+// writeArchive собирает zip из всех разделов.
+func (e *Exporter) writeArchive(ctx context.Context, userID int) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	m := manifest{UserID: userID, GeneratedAt: e.now().UTC()}
+	for _, section := range e.sections {
+		data, err := section.Collect(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect %s: %w", section.Name(), err)
+		}
+		if err := writeJSON(archive, section.Name()+".json", data); err != nil {
+			return nil, err
+		}
+		m.Sections = append(m.Sections, section.Name())
+	}
+	if err := writeJSON(archive, "manifest.json", m); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(archive *zip.Writer, name string, data any) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func (e *Exporter) removeExpired(ctx context.Context) {
+	if _, err := e.store.DeleteExpiredExportJobs(ctx, e.now()); err != nil && ctx.Err() == nil {
+		e.logger.ZL.Warn("failed to remove expired data exports", zap.Error(err))
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate export id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,199 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+	"time"
+)
+
+type fakeUsers map[int]models.User
+
+func (f fakeUsers) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	user, ok := f[userID]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	return &user, nil
+}
+
+func newTestExporter(t *testing.T, sections ...Section) *Exporter {
+	t.Helper()
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	c := &server_config.ServerConfig{ExportLinkTTL: time.Hour, SecretKey: "secret"}
+	return NewExporter(c, l, store.NewExportJobQueue(), sections...)
+}
+
+// buildNext собирает следующий архив из очереди, как это делает Run.
+func buildNext(t *testing.T, e *Exporter) {
+	t.Helper()
+	now := e.now()
+	jobs, err := e.store.ClaimExportJobs(context.Background(), now, now.Add(buildLease), 1)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1, "export queue is empty")
+	e.build(context.Background(), jobs[0])
+}
+
+func TestExporter_Archive(t *testing.T) {
+	ctx := context.Background()
+	users := fakeUsers{1: {ID: 1, Login: "Petr", DisplayName: "Пётр", PasswordHash: "secret-hash"}}
+	e := newTestExporter(t, ProfileSection(users))
+
+	job, err := e.Request(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportPending, job.Status)
+
+	// Пока архив собирается, повторный запрос возвращает то же задание.
+	again, err := e.Request(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, job.ID, again.ID)
+
+	buildNext(t, e)
+	job, err = e.Last(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, models.ExportReady, job.Status)
+
+	data, err := e.Open(ctx, e.DownloadQuery(job))
+	require.NoError(t, err)
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	contents := make(map[string][]byte)
+	for _, f := range archive.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		contents[f.Name], err = io.ReadAll(r)
+		require.NoError(t, err)
+		r.Close()
+	}
+	require.Contains(t, contents, "manifest.json")
+	require.Contains(t, contents, "profile.json")
+	assert.NotContains(t, string(contents["profile.json"]), "secret-hash")
+
+	var profile models.User
+	require.NoError(t, json.Unmarshal(contents["profile.json"], &profile))
+	assert.Equal(t, "Пётр", profile.DisplayName)
+
+	var m manifest
+	require.NoError(t, json.Unmarshal(contents["manifest.json"], &m))
+	assert.Equal(t, []string{"profile"}, m.Sections)
+}
+
+func TestExporter_FailedSection(t *testing.T) {
+	ctx := context.Background()
+	e := newTestExporter(t, ProfileSection(fakeUsers{}))
+	_, err := e.Request(ctx, 1)
+	require.NoError(t, err)
+	buildNext(t, e)
+
+	job, err := e.Last(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportFailed, job.Status)
+
+	// После неудачи можно запросить выгрузку заново.
+	retry, err := e.Request(ctx, 1)
+	require.NoError(t, err)
+	assert.NotEqual(t, job.ID, retry.ID)
+}
+
+func TestExporter_Open(t *testing.T) {
+	ctx := context.Background()
+	e := newTestExporter(t, ProfileSection(fakeUsers{1: {ID: 1}}))
+	job, err := e.Request(ctx, 1)
+	require.NoError(t, err)
+	buildNext(t, e)
+	job, err = e.Last(ctx, 1)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		modify  func(q map[string][]string)
+		shift   time.Duration
+		wantErr error
+	}{
+		{name: "valid link", modify: func(map[string][]string) {}},
+		{name: "tampered signature", modify: func(q map[string][]string) { q["sig"] = []string{"AAAA"} }, wantErr: ErrInvalidLink},
+		{name: "extended expiry", modify: func(q map[string][]string) { q["expires"] = []string{"99999999999"} }, wantErr: ErrInvalidLink},
+		{name: "other archive", modify: func(q map[string][]string) { q["id"] = []string{"00"} }, wantErr: ErrInvalidLink},
+		{name: "expired link", modify: func(map[string][]string) {}, shift: 2 * time.Hour, wantErr: ErrLinkExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e.now = func() time.Time { return time.Now().Add(tt.shift) }
+			query := e.DownloadQuery(job)
+			tt.modify(query)
+			data, err := e.Open(ctx, query)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, data)
+		})
+	}
+}
+
+func TestExporter_RemoveExpired(t *testing.T) {
+	ctx := context.Background()
+	e := newTestExporter(t, ProfileSection(fakeUsers{1: {ID: 1}}))
+	_, err := e.Request(ctx, 1)
+	require.NoError(t, err)
+	buildNext(t, e)
+	job, err := e.Last(ctx, 1)
+	require.NoError(t, err)
+
+	e.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	e.removeExpired(ctx)
+
+	_, err = e.store.GetExportArchive(ctx, job.ID)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	_, err = e.Last(ctx, 1)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestExporter_SharedStore(t *testing.T) {
+	ctx := context.Background()
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	c := &server_config.ServerConfig{ExportLinkTTL: time.Hour, SecretKey: "secret"}
+	jobs := store.NewExportJobQueue()
+	users := fakeUsers{1: {ID: 1}}
+	first := NewExporter(c, l, jobs, ProfileSection(users))
+	second := NewExporter(c, l, jobs, ProfileSection(users))
+
+	// Выгрузку, запрошенную на одном экземпляре, собирает и отдаёт другой.
+	_, err = first.Request(ctx, 1)
+	require.NoError(t, err)
+	buildNext(t, second)
+	job, err := first.Last(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, models.ExportReady, job.Status)
+	data, err := second.Open(ctx, first.DownloadQuery(job))
+	require.NoError(t, err)
+	assert.NotEmpty(t, data)
+}
+
+func TestExporter_QueueFull(t *testing.T) {
+	ctx := context.Background()
+	e := newTestExporter(t)
+	for userID := 1; userID <= queueSize; userID++ {
+		_, err := e.Request(ctx, userID)
+		require.NoError(t, err)
+	}
+	_, err := e.Request(ctx, queueSize+1)
+	assert.ErrorIs(t, err, ErrQueueFull)
+	// Тот, кто уже в очереди, получает своё задание.
+	_, err = e.Request(ctx, 1)
+	assert.NoError(t, err)
+}
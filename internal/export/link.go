@@ -0,0 +1,63 @@
+package export
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidLink = errors.New("export link is invalid")
+	ErrLinkExpired = errors.New("export link has expired")
+)
+
+// signer подписывает ссылки на скачивание. Ключ выводится из SecretKey, чтобы подпись ссылки
+// нельзя было выдать за подпись JWT и наоборот.
+type signer struct {
+	key []byte
+}
+
+func newSigner(secretKey string) *signer {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte("data-export-link"))
+	return &signer{key: mac.Sum(nil)}
+}
+
+func (s *signer) sign(id string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(id + "\n" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// DownloadQuery возвращает параметры подписанной ссылки на готовый архив.
+func (e *Exporter) DownloadQuery(job models.ExportJob) url.Values {
+	expires := job.ExpiresAt.Unix()
+	return url.Values{
+		"id":      {job.ID},
+		"expires": {strconv.FormatInt(expires, 10)},
+		"sig":     {e.signer.sign(job.ID, expires)},
+	}
+}
+
+// Open проверяет подпись и срок ссылки и возвращает архив.
+func (e *Exporter) Open(ctx context.Context, query url.Values) ([]byte, error) {
+	id := query.Get("id")
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || id == "" {
+		return nil, ErrInvalidLink
+	}
+	expected := e.signer.sign(id, expires)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("sig"))) {
+		return nil, ErrInvalidLink
+	}
+	if !e.now().Before(time.Unix(expires, 0)) {
+		return nil, ErrLinkExpired
+	}
+	return e.store.GetExportArchive(ctx, id)
+}
@@ -0,0 +1,165 @@
+package export
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sort"
+	"time"
+)
+
+// UserSource - откуда берётся профиль пользователя.
+type UserSource interface {
+	GetUserByID(ctx context.Context, userID int) (user *models.User, err error)
+}
+
+// ProfileSection - профиль и состояние учётной записи.
+func ProfileSection(source UserSource) Section {
+	return SectionFunc{
+		SectionName: "profile",
+		CollectFunc: func(ctx context.Context, userID int) (any, error) {
+			return source.GetUserByID(ctx, userID)
+		},
+	}
+}
+
+// AuditSource - откуда берутся записи журнала безопасности.
+type AuditSource interface {
+	ListAuditEvents(ctx context.Context, query models.AuditQuery) (events []models.AuditEvent, err error)
+}
+
+// auditSectionBatch - сколько записей журнала читается за раз.
+const auditSectionBatch = 1000
+
+// AuditSection - события журнала безопасности, где пользователь был исполнителем или целью.
+func AuditSection(source AuditSource) Section {
+	return SectionFunc{
+		SectionName: "audit",
+		CollectFunc: func(ctx context.Context, userID int) (any, error) {
+			return listAuditEvents(ctx, source, models.AuditQuery{UserID: &userID})
+		},
+	}
+}
+
+// listAuditEvents читает все записи журнала под query пачками по auditSectionBatch.
+func listAuditEvents(ctx context.Context, source AuditSource, query models.AuditQuery) ([]models.AuditEvent, error) {
+	events := []models.AuditEvent{}
+	query.Limit = auditSectionBatch
+	for {
+		batch, err := source.ListAuditEvents(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, batch...)
+		if len(batch) < auditSectionBatch {
+			return events, nil
+		}
+		query.AfterID = batch[len(batch)-1].ID
+	}
+}
+
+// LoginRecord - одна попытка входа в учётную запись.
+type LoginRecord struct {
+	OccurredAt time.Time          `json:"occurred_at"`
+	Result     models.AuditResult `json:"result"`
+	Reason     string             `json:"reason,omitempty"`
+	IP         string             `json:"ip"`
+	UserAgent  string             `json:"user_agent"`
+}
+
+// LoginHistorySection - все попытки входа в учётную запись пользователя, удачные и нет.
+func LoginHistorySection(source AuditSource) Section {
+	return SectionFunc{
+		SectionName: "login_history",
+		CollectFunc: func(ctx context.Context, userID int) (any, error) {
+			events, err := listAuditEvents(ctx, source, models.AuditQuery{Type: models.AuditLogin, TargetID: &userID})
+			if err != nil {
+				return nil, err
+			}
+			logins := make([]LoginRecord, 0, len(events))
+			for _, event := range events {
+				logins = append(logins, LoginRecord{
+					OccurredAt: event.OccurredAt,
+					Result:     event.Result,
+					Reason:     event.Reason,
+					IP:         event.IP,
+					UserAgent:  event.UserAgent,
+				})
+			}
+			return logins, nil
+		},
+	}
+}
+
+// AuthStateSource - откуда берётся момент последнего отзыва токенов.
+type AuthStateSource interface {
+	GetUserAuthState(ctx context.Context, userID int) (state models.UserAuthState, err error)
+}
+
+// Session - выданный пользователю токен, который ещё не истёк и не отозван.
+type Session struct {
+	StartedAt time.Time `json:"started_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+}
+
+// SessionsSection - действующие сессии. Токены нигде не хранятся, поэтому сессии восстанавливаются
+// по журналу: это успешные входы и регистрации не старше tokenTTL и после последнего отзыва токенов.
+func SessionsSection(audit AuditSource, states AuthStateSource, tokenTTL time.Duration) Section {
+	return SectionFunc{
+		SectionName: "sessions",
+		CollectFunc: func(ctx context.Context, userID int) (any, error) {
+			state, err := states.GetUserAuthState(ctx, userID)
+			if err != nil {
+				return nil, err
+			}
+			from := time.Now().Add(-tokenTTL)
+			if state.TokensValidAfter.After(from) {
+				from = state.TokensValidAfter
+			}
+			sessions := []Session{}
+			for _, eventType := range []models.AuditEventType{models.AuditRegistration, models.AuditLogin} {
+				// Токен получает только сам пользователь: учётные записи, заведённые администратором, не в счёт.
+				events, err := listAuditEvents(ctx, audit, models.AuditQuery{
+					Type:     eventType,
+					ActorID:  &userID,
+					TargetID: &userID,
+					Result:   models.AuditSuccess,
+					From:     from,
+				})
+				if err != nil {
+					return nil, err
+				}
+				for _, event := range events {
+					sessions = append(sessions, Session{
+						StartedAt: event.OccurredAt,
+						ExpiresAt: event.OccurredAt.Add(tokenTTL),
+						IP:        event.IP,
+						UserAgent: event.UserAgent,
+					})
+				}
+			}
+			sort.Slice(sessions, func(i, j int) bool { return sessions[i].StartedAt.Before(sessions[j].StartedAt) })
+			return sessions, nil
+		},
+	}
+}
+
+// DeviceSource - откуда берутся известные устройства пользователя.
+type DeviceSource interface {
+	ListDevices(ctx context.Context, userID int) (devices []models.Device, err error)
+}
+
+// DevicesSection - устройства, с которых пользователь входил, с их адресами и клиентами.
+func DevicesSection(source DeviceSource) Section {
+	return SectionFunc{
+		SectionName: "devices",
+		CollectFunc: func(ctx context.Context, userID int) (any, error) {
+			devices, err := source.ListDevices(ctx, userID)
+			if devices == nil {
+				devices = []models.Device{}
+			}
+			return devices, err
+		},
+	}
+}
@@ -0,0 +1,98 @@
+package export
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// fakeAudit фильтрует записи так же, как хранилище, по полям, которые используют разделы.
+type fakeAudit struct {
+	events []models.AuditEvent
+	state  models.UserAuthState
+}
+
+func (f fakeAudit) ListAuditEvents(_ context.Context, query models.AuditQuery) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	for _, event := range f.events {
+		switch {
+		case event.ID <= query.AfterID,
+			query.Type != "" && event.Type != query.Type,
+			query.Result != "" && event.Result != query.Result,
+			query.TargetID != nil && (event.TargetID == nil || *event.TargetID != *query.TargetID),
+			query.ActorID != nil && (event.ActorID == nil || *event.ActorID != *query.ActorID),
+			!query.From.IsZero() && event.OccurredAt.Before(query.From):
+			continue
+		}
+		events = append(events, event)
+		if len(events) == query.Limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+func (f fakeAudit) GetUserAuthState(context.Context, int) (models.UserAuthState, error) {
+	return f.state, nil
+}
+
+func TestLoginHistorySection(t *testing.T) {
+	user, admin := 1, 2
+	now := time.Now()
+	audit := fakeAudit{events: []models.AuditEvent{
+		{ID: 1, OccurredAt: now, Type: models.AuditLogin, TargetID: &user, Result: models.AuditFailure, Reason: "wrong_password", IP: "192.0.2.1"},
+		{ID: 2, OccurredAt: now, Type: models.AuditLogin, ActorID: &user, TargetID: &user, Result: models.AuditSuccess, IP: "192.0.2.1"},
+		{ID: 3, OccurredAt: now, Type: models.AuditLogin, ActorID: &admin, TargetID: &admin, Result: models.AuditSuccess},
+		{ID: 4, OccurredAt: now, Type: models.AuditPasswordChange, ActorID: &user, TargetID: &user, Result: models.AuditSuccess},
+	}}
+
+	data, err := LoginHistorySection(audit).Collect(context.Background(), user)
+	require.NoError(t, err)
+	logins := data.([]LoginRecord)
+	require.Len(t, logins, 2)
+	assert.Equal(t, models.AuditFailure, logins[0].Result)
+	assert.Equal(t, "wrong_password", logins[0].Reason)
+	assert.Equal(t, models.AuditSuccess, logins[1].Result)
+}
+
+func TestSessionsSection(t *testing.T) {
+	user, admin := 1, 2
+	now := time.Now()
+	const ttl = 24 * time.Hour
+	tests := []struct {
+		name       string
+		revokedAt  time.Time
+		wantStarts []time.Time
+	}{
+		{name: "not revoked", wantStarts: []time.Time{now.Add(-3 * time.Hour), now.Add(-time.Hour)}},
+		{name: "revoked in between", revokedAt: now.Add(-2 * time.Hour), wantStarts: []time.Time{now.Add(-time.Hour)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := fakeAudit{
+				state: models.UserAuthState{TokensValidAfter: tt.revokedAt},
+				events: []models.AuditEvent{
+					// Токен уже истёк.
+					{ID: 1, OccurredAt: now.Add(-2 * ttl), Type: models.AuditLogin, ActorID: &user, TargetID: &user, Result: models.AuditSuccess},
+					// Учётную запись завёл администратор, токена пользователь не получал.
+					{ID: 2, OccurredAt: now.Add(-4 * time.Hour), Type: models.AuditRegistration, ActorID: &admin, TargetID: &user, Result: models.AuditSuccess},
+					{ID: 3, OccurredAt: now.Add(-3 * time.Hour), Type: models.AuditLogin, ActorID: &user, TargetID: &user, Result: models.AuditSuccess, IP: "192.0.2.1"},
+					{ID: 4, OccurredAt: now.Add(-90 * time.Minute), Type: models.AuditLogin, TargetID: &user, Result: models.AuditFailure},
+					{ID: 5, OccurredAt: now.Add(-time.Hour), Type: models.AuditLogin, ActorID: &user, TargetID: &user, Result: models.AuditSuccess, IP: "198.51.100.1"},
+				},
+			}
+			data, err := SessionsSection(audit, audit, ttl).Collect(context.Background(), user)
+			require.NoError(t, err)
+			sessions := data.([]Session)
+			var starts []time.Time
+			for _, session := range sessions {
+				starts = append(starts, session.StartedAt)
+				assert.Equal(t, session.StartedAt.Add(ttl), session.ExpiresAt)
+			}
+			assert.Equal(t, tt.wantStarts, starts)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sync"
+)
+
+// Лента уведомлений безопасности: relay сохраняет события о входах, смене пароля и отзыве сессий
+// как уведомления пользователя и будит его открытые потоки на этом экземпляре сервера.
+// Потоки на других экземплярах узнают о новых уведомлениях, периодически перечитывая хранилище.
+
+// Store - то, что нужно ленте от хранилища.
+type Store interface {
+	AppendSecurityEvent(ctx context.Context, event models.SecurityEvent) (appended bool, err error)
+	ListSecurityEvents(ctx context.Context, userID int, afterID int64, limit int) (events []models.SecurityEvent, err error)
+}
+
+// securityEventTypes - события, о которых сообщается пользователю.
+var securityEventTypes = map[models.DomainEventType]bool{
+	models.EventUserLoggedIn:        true,
+	models.EventUserNewDeviceLogin:  true,
+	models.EventUserPasswordChanged: true,
+	models.EventUserSessionsRevoked: true,
+}
+
+type Feed struct {
+	store       Store
+	mu          sync.Mutex
+	subscribers map[int]map[chan struct{}]struct{}
+}
+
+func NewFeed(s Store) *Feed {
+	return &Feed{
+		store:       s,
+		subscribers: make(map[int]map[chan struct{}]struct{}),
+	}
+}
+
+// Name - имя получателя outbox.
+func (f *Feed) Name() string {
+	return "security-feed"
+}
+
+// Publish сохраняет событие из outbox как уведомление пользователя. Остальные события пропускаются.
+func (f *Feed) Publish(ctx context.Context, event models.DomainEvent) error {
+	if !securityEventTypes[event.Type] {
+		return nil
+	}
+	appended, err := f.store.AppendSecurityEvent(ctx, models.SecurityEvent{
+		EventID:    event.ID,
+		UserID:     event.UserID,
+		Type:       event.Type,
+		OccurredAt: event.OccurredAt,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to append security event: %w", err)
+	}
+	if appended {
+		f.wake(event.UserID)
+	}
+	return nil
+}
+
+// List возвращает до limit уведомлений пользователя после afterID.
+func (f *Feed) List(ctx context.Context, userID int, afterID int64, limit int) ([]models.SecurityEvent, error) {
+	return f.store.ListSecurityEvents(ctx, userID, afterID, limit)
+}
+
+// Subscribe возвращает канал, в который приходит сигнал о новых уведомлениях пользователя.
+// Сигналы не копятся: пока предыдущий не прочитан, новые сливаются с ним. cancel отписывает.
+func (f *Feed) Subscribe(userID int) (wake <-chan struct{}, cancel func()) {
+	ch := make(chan struct{}, 1)
+	f.mu.Lock()
+	if f.subscribers[userID] == nil {
+		f.subscribers[userID] = make(map[chan struct{}]struct{})
+	}
+	f.subscribers[userID][ch] = struct{}{}
+	f.mu.Unlock()
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.subscribers[userID], ch)
+		if len(f.subscribers[userID]) == 0 {
+			delete(f.subscribers, userID)
+		}
+	}
+}
+
+func (f *Feed) wake(userID int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subscribers[userID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
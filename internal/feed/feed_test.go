@@ -0,0 +1,52 @@
+package feed
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFeed_Publish(t *testing.T) {
+	ctx := context.Background()
+	f := NewFeed(store.NewSecurityEventLog())
+	wake, cancel := f.Subscribe(1)
+	defer cancel()
+	other, cancelOther := f.Subscribe(2)
+	defer cancelOther()
+
+	login := store.NewDomainEvent(models.EventUserLoggedIn, &models.User{ID: 1})
+	login.IP = "10.0.0.1"
+	require.NoError(t, f.Publish(ctx, login))
+	require.NoError(t, f.Publish(ctx, store.NewDomainEvent(models.EventUserProfileUpdated, &models.User{ID: 1})))
+
+	select {
+	case <-wake:
+	default:
+		t.Fatal("subscriber was not woken")
+	}
+	select {
+	case <-other:
+		t.Fatal("other user's subscriber was woken")
+	default:
+	}
+
+	// Повтор из outbox не даёт второго уведомления и не будит подписчика.
+	require.NoError(t, f.Publish(ctx, login))
+	select {
+	case <-wake:
+		t.Fatal("duplicate event woke the subscriber")
+	default:
+	}
+
+	events, err := f.List(ctx, 1, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.EventUserLoggedIn, events[0].Type)
+	assert.Equal(t, "10.0.0.1", events[0].IP)
+	events, err = f.List(ctx, 1, events[0].ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
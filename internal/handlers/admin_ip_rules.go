@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+// ipRulesResponse - список ограничений по адресам.
+type ipRulesResponse struct {
+	Rules []models.IPRule `json:"rules"`
+}
+
+// ipRuleReq - модель запроса на создание правила.
+type ipRuleReq struct {
+	UserID  *int                `json:"user_id"`
+	Role    models.UserRole     `json:"role"`
+	CIDR    string              `json:"cidr"`
+	Action  models.IPRuleAction `json:"action"`
+	Comment string              `json:"comment"`
+}
+
+// AdminListIPRules отдаёт ограничения по адресам, фильтры: user_id, role.
+func (handlers *Handlers) AdminListIPRules(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	var query models.IPRuleQuery
+	values := gotRequest.URL.Query()
+	if raw := values.Get("user_id"); raw != "" {
+		userID, err := strconv.Atoi(raw)
+		if err != nil || userID < 1 {
+			sendResponse(true, "Not a valid user id", http.StatusBadRequest, responseWriter)
+			return
+		}
+		query.UserID = &userID
+	}
+	if raw := values.Get("role"); raw != "" {
+		query.Role = models.UserRole(raw)
+		if !query.Role.Valid() {
+			sendResponse(true, "Not a valid role", http.StatusBadRequest, responseWriter)
+			return
+		}
+	}
+
+	rules, err := handlers.store.ListIPRules(gotRequest.Context(), query)
+	if err != nil {
+		handlers.logger.ZL.Error("failed to list ip rules", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	if rules == nil {
+		rules = []models.IPRule{}
+	}
+	sendJSON(ipRulesResponse{Rules: rules}, http.StatusOK, responseWriter)
+}
+
+/*
+AdminCreateIPRule ограничивает адреса, с которых может входить пользователь или все пользователи роли.
+Правило действует и на уже выданные токены. На вход хэндлер ожидает json такого формата:
+
+	{
+	    "user_id": 42,                 // или "role": "admin"
+	    "cidr": "198.51.100.0/24",     // или одиночный адрес
+	    "action": "allow",             // или "deny"
+	    "comment": "office"
+	}
+*/
+func (handlers *Handlers) AdminCreateIPRule(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	var req ipRuleReq
+	decoder := json.NewDecoder(gotRequest.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		sendResponse(true, "Not a valid ip rule request", http.StatusBadRequest, responseWriter)
+		return
+	}
+	rule := models.IPRule{UserID: req.UserID, Role: req.Role, CIDR: req.CIDR, Action: req.Action, Comment: req.Comment}
+	if fieldErrors := rule.Normalize(); len(fieldErrors) > 0 {
+		sendFieldErrors("Not a valid ip rule", fieldErrors, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	lockedOut, err := handlers.locksOutCaller(gotRequest, func(rules []models.IPRule, role models.UserRole, callerID int) []models.IPRule {
+		if (rule.UserID != nil && *rule.UserID == callerID) || (rule.Role != "" && rule.Role == role) {
+			rules = append(rules, rule)
+		}
+		return rules
+	})
+	if err != nil {
+		handlers.logger.ZL.Error("failed to check own ip rules", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	if lockedOut {
+		sendLockedOutSelf(responseWriter)
+		return
+	}
+
+	created, err := handlers.store.CreateIPRule(gotRequest.Context(), rule)
+	if errors.Is(err, store.ErrUserNotFound) {
+		sendResponse(true, "User not found", http.StatusNotFound, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to create ip rule", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	handlers.invalidateIPRule(gotRequest.Context(), created)
+	sendJSON(created, http.StatusCreated, responseWriter)
+}
+
+// AdminDeleteIPRule снимает ограничение.
+func (handlers *Handlers) AdminDeleteIPRule(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	id, err := strconv.Atoi(chi.URLParam(gotRequest, "id"))
+	if err != nil || id < 1 {
+		sendResponse(true, "Not a valid ip rule id", http.StatusBadRequest, responseWriter)
+		return
+	}
+	// Снятое разрешение тоже может закрыть вход: если адрес администратора был только в нём.
+	lockedOut, err := handlers.locksOutCaller(gotRequest, func(rules []models.IPRule, _ models.UserRole, _ int) []models.IPRule {
+		kept := make([]models.IPRule, 0, len(rules))
+		for _, rule := range rules {
+			if rule.ID != id {
+				kept = append(kept, rule)
+			}
+		}
+		return kept
+	})
+	if err != nil {
+		handlers.logger.ZL.Error("failed to check own ip rules", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	if lockedOut {
+		sendLockedOutSelf(responseWriter)
+		return
+	}
+	deleted, err := handlers.store.DeleteIPRule(gotRequest.Context(), id)
+	if errors.Is(err, store.ErrIPRuleNotFound) {
+		sendResponse(true, "IP rule not found", http.StatusNotFound, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to delete ip rule", zap.Int("id", id), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	handlers.invalidateIPRule(gotRequest.Context(), *deleted)
+	sendResponse(false, "IP rule deleted", http.StatusOK, responseWriter)
+}
+
+// invalidateIPRule сбрасывает кэш проверки токенов у тех, на кого действует правило,
+// чтобы оно вступило в силу сразу, а не по истечении кэша.
+func (handlers *Handlers) invalidateIPRule(ctx context.Context, rule models.IPRule) {
+	if rule.UserID != nil {
+		handlers.auth.InvalidateUserStatus(ctx, *rule.UserID)
+		return
+	}
+	handlers.auth.InvalidateAllStatuses(ctx)
+}
+
+// locksOutCaller сообщает, что администратор, который меняет правила, проходит проверку адреса сейчас,
+// а после изменения не прошёл бы. change получает правила, действующие на него сейчас, его роль и ID и возвращает
+// правила после изменения. Если кто делает запрос, неизвестно, проверять нечего.
+func (handlers *Handlers) locksOutCaller(
+	gotRequest *http.Request,
+	change func(rules []models.IPRule, role models.UserRole, callerID int) []models.IPRule,
+) (bool, error) {
+	callerID, ok := userIDFromRequest(gotRequest)
+	if !ok {
+		return false, nil
+	}
+	state, err := handlers.store.GetUserAuthState(gotRequest.Context(), callerID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get caller auth state: %w", err)
+	}
+	ip := handlers.clientIP(gotRequest)
+	rules := change(append([]models.IPRule(nil), state.IPRules...), state.Role, callerID)
+	return handlers.ipFilter.Allowed(ip, state.IPRules) && !handlers.ipFilter.Allowed(ip, rules), nil
+}
+
+// sendLockedOutSelf отказывает в изменении, после которого администратор потерял бы доступ сам.
+func sendLockedOutSelf(responseWriter http.ResponseWriter) {
+	sendErrorCode(
+		"locks_out_self",
+		"The change would block your own address",
+		http.StatusConflict,
+		responseWriter)
+}
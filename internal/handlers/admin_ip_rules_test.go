@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestHandlers_AdminIPRules(t *testing.T) {
+	s := newMockStorage()
+	s.users["anna"] = models.User{ID: 1, Login: "anna", PasswordHash: "password", Role: models.UserRoleUser}
+	s.users["admin"] = models.User{ID: 2, Login: "admin", PasswordHash: "password", Role: models.UserRoleAdmin}
+	handlers, err := NewHandlers(s, testConfig, testLogger, testAuth)
+	require.NoError(t, err)
+	router := chi.NewRouter()
+	router.Route("/admin/ip-rules", func(router chi.Router) {
+		router.Get("/", handlers.AdminListIPRules)
+		router.Post("/", handlers.AdminCreateIPRule)
+		router.Delete("/{id}/", handlers.AdminDeleteIPRule)
+	})
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+	login := func(userLogin, remoteAddr string) int {
+		body, _ := json.Marshal(models.UserLoginReq{Login: userLogin, Password: "correctPassword"})
+		request := httptest.NewRequest(http.MethodPost, "/api/user/login/", bytes.NewBuffer(body))
+		request.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handlers.Login(w, request)
+		return w.Code
+	}
+
+	// Ошибки в правиле перечисляются по полям.
+	w := do(http.MethodPost, "/admin/ip-rules/", `{"user_id": 1, "role": "admin", "cidr": "10.0.0.300/8", "action": "maybe"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	var response resultMsg
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	var fields []string
+	for _, fieldError := range response.FieldErrors {
+		fields = append(fields, fieldError.Field)
+	}
+	assert.Equal(t, []string{"user_id", "cidr", "action"}, fields)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/ip-rules/", `{"role": "admin", "cidr": "10.0.0.0/8", "action": "allow", "x": 1}`).Code)
+
+	// Анне можно входить только из офиса, администраторам нельзя из одной подсети.
+	w = do(http.MethodPost, "/admin/ip-rules/", `{"user_id": 1, "cidr": "198.51.100.77/24", "action": "allow", "comment": "office"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var office models.IPRule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &office))
+	assert.Equal(t, "198.51.100.0/24", office.CIDR, "host bits are cleared")
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/admin/ip-rules/", `{"role": "admin", "cidr": "192.0.2.0/24", "action": "deny"}`).Code)
+
+	assert.Equal(t, http.StatusOK, login("anna", "198.51.100.5:1000"))
+	assert.Equal(t, http.StatusForbidden, login("anna", "192.0.2.5:1000"))
+	assert.Equal(t, http.StatusForbidden, login("admin", "192.0.2.5:1000"))
+	assert.Equal(t, http.StatusOK, login("admin", "203.0.113.5:1000"))
+
+	w = do(http.MethodGet, "/admin/ip-rules/?user_id=1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ipRulesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Rules, 1)
+	assert.Equal(t, office.ID, list.Rules[0].ID)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/admin/ip-rules/?role=root", "").Code)
+
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, "/admin/ip-rules/"+strconv.Itoa(office.ID)+"/", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/admin/ip-rules/"+strconv.Itoa(office.ID)+"/", "").Code)
+	assert.Equal(t, http.StatusOK, login("anna", "192.0.2.5:1000"))
+}
+
+func TestHandlers_AdminIPRulesSelfLockout(t *testing.T) {
+	s := newMockStorage()
+	s.users["anna"] = models.User{ID: 1, Login: "anna", Role: models.UserRoleUser}
+	s.users["admin"] = models.User{ID: 2, Login: "admin", Role: models.UserRoleAdmin}
+	handlers, err := NewHandlers(s, testConfig, testLogger, testAuth)
+	require.NoError(t, err)
+	router := chi.NewRouter()
+	router.Post("/admin/ip-rules/", handlers.AdminCreateIPRule)
+	router.Delete("/admin/ip-rules/{id}/", handlers.AdminDeleteIPRule)
+	// Администратор работает с адреса 203.0.113.5.
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		request = request.WithContext(context.WithValue(request.Context(), auth.KeyUserIDCtx, 2))
+		request.RemoteAddr = "203.0.113.5:1000"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+	create := func(body string) (int, models.IPRule) {
+		w := do(http.MethodPost, "/admin/ip-rules/", body)
+		var rule models.IPRule
+		json.Unmarshal(w.Body.Bytes(), &rule)
+		return w.Code, rule
+	}
+
+	for _, body := range []string{
+		`{"role": "admin", "cidr": "203.0.113.0/24", "action": "deny"}`,
+		`{"user_id": 2, "cidr": "203.0.113.5", "action": "deny"}`,
+		`{"user_id": 2, "cidr": "198.51.100.0/24", "action": "allow"}`,
+	} {
+		w := do(http.MethodPost, "/admin/ip-rules/", body)
+		assert.Equal(t, http.StatusConflict, w.Code, body)
+		assert.Contains(t, w.Body.String(), "locks_out_self", body)
+	}
+	status, _ := create(`{"user_id": 1, "cidr": "203.0.113.0/24", "action": "deny"}`)
+	assert.Equal(t, http.StatusCreated, status, "rules for other users are not checked against the caller")
+
+	status, own := create(`{"user_id": 2, "cidr": "203.0.113.0/24", "action": "allow"}`)
+	require.Equal(t, http.StatusCreated, status)
+	status, office := create(`{"user_id": 2, "cidr": "198.51.100.0/24", "action": "allow"}`)
+	require.Equal(t, http.StatusCreated, status, "the own address is still allowed by the other rule")
+
+	// Без своего разрешения остался бы только офис.
+	assert.Equal(t, http.StatusConflict, do(http.MethodDelete, "/admin/ip-rules/"+strconv.Itoa(own.ID)+"/", "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/admin/ip-rules/"+strconv.Itoa(office.ID)+"/", "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/admin/ip-rules/"+strconv.Itoa(own.ID)+"/", "").Code,
+		"without allow rules every address is open again")
+}
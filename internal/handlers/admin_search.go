@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// userSearchResponse - страница результатов поиска.
+type userSearchResponse struct {
+	Results    []models.UserSearchResult `json:"results"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+}
+
+// AdminSearchUsers ищет пользователей по логину, имени и почте: GET /admin/users/search/?q=...&limit=...&cursor=...
+func (handlers *Handlers) AdminSearchUsers(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	values := gotRequest.URL.Query()
+	query := models.UserSearchQuery{Query: values.Get("q"), Limit: defaultUserListLimit}
+	var fieldErrors []models.FieldError
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxUserListLimit {
+			fieldErrors = append(fieldErrors, models.FieldError{
+				Field: "limit", Code: "invalid", Message: "Limit must be between 1 and " + strconv.Itoa(maxUserListLimit),
+			})
+		}
+		query.Limit = limit
+	}
+	if raw := values.Get("cursor"); raw != "" {
+		cursor, err := decodeSearchCursor(raw)
+		if err != nil {
+			fieldErrors = append(fieldErrors, models.FieldError{Field: "cursor", Code: "invalid", Message: "Cursor is not valid"})
+		}
+		query.After = cursor
+	}
+	if len(fieldErrors) > 0 {
+		sendFieldErrors("Not a valid user search request", fieldErrors, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	limit := query.Limit
+	query.Limit++
+	results, err := handlers.store.SearchUsers(gotRequest.Context(), query)
+	if errors.Is(err, store.ErrSearchQueryTooShort) {
+		sendFieldErrors("Not a valid user search request", []models.FieldError{{
+			Field: "q", Code: "too_short", Message: "Search query must be at least 2 characters long",
+		}}, http.StatusBadRequest, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to search users", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+
+	response := userSearchResponse{Results: results}
+	if len(results) > limit {
+		response.Results = results[:limit]
+		last := response.Results[limit-1]
+		response.NextCursor = encodeSearchCursor(models.SearchCursor{Rank: last.Rank, ID: last.User.ID})
+	}
+	if response.Results == nil {
+		response.Results = []models.UserSearchResult{}
+	}
+	sendJSON(response, http.StatusOK, responseWriter)
+}
+
+// Релевантность в курсоре записывается без потери точности, иначе страницы на стыке разъедутся.
+func encodeSearchCursor(cursor models.SearchCursor) string {
+	raw := "r:" + strconv.FormatFloat(float64(cursor.Rank), 'g', -1, 32) + ":" + strconv.Itoa(cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(cursor string) (*models.SearchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || parts[0] != "r" {
+		return nil, errors.New("unknown cursor format")
+	}
+	rank, err := strconv.ParseFloat(parts[1], 32)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	return &models.SearchCursor{Rank: float32(rank), ID: id}, nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestHandlers_AdminSearchUsers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantLogins []string
+	}{
+		{name: "Test login prefix ranks first", query: "?q=an", wantStatus: http.StatusOK, wantLogins: []string{"anna", "anton", "andrey"}},
+		{name: "Test no matches", query: "?q=zzz", wantStatus: http.StatusOK, wantLogins: nil},
+		{name: "Test query too short", query: "?q=a", wantStatus: http.StatusBadRequest},
+		{name: "Test invalid cursor", query: "?q=an&cursor=bm9wZQ", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			router := newAdminRouter(t, newAdminTestStorage(), 1)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/search/"+tt.query, nil))
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var response userSearchResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			var logins []string
+			for _, result := range response.Results {
+				logins = append(logins, result.User.Login)
+			}
+			assert.Equal(t, tt.wantLogins, logins)
+		})
+	}
+}
+
+func TestHandlers_AdminSearchUsersPagination(t *testing.T) {
+	router := newAdminRouter(t, newAdminTestStorage(), 1)
+	get := func(query url.Values) userSearchResponse {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/search/?"+query.Encode(), nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var response userSearchResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	full := get(url.Values{"q": {"an"}})
+	var paged []models.UserSearchResult
+	cursor := ""
+	for i := 0; i < 10; i++ {
+		page := get(url.Values{"q": {"an"}, "limit": {"1"}, "cursor": {cursor}})
+		paged = append(paged, page.Results...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, full.Results, paged)
+}
+
+func TestSearchCursorRoundTrip(t *testing.T) {
+	cursor := models.SearchCursor{Rank: 1.3636364, ID: 42}
+	decoded, err := decodeSearchCursor(encodeSearchCursor(cursor))
+	require.NoError(t, err)
+	assert.Equal(t, cursor, *decoded)
+}
@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Служебные ручки для администраторов. Доступ к ним ограничивается MiddleRequireRole на группе роутов.
+
+const (
+	defaultUserListLimit = 50
+	maxUserListLimit     = 200
+)
+
+// userListResponse - страница списка пользователей.
+type userListResponse struct {
+	Users      []models.User `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// AdminListUsers отдаёт пользователей с фильтрами status, created_from, created_to (RFC 3339),
+// login_prefix и постраничной навигацией через limit и cursor.
+func (handlers *Handlers) AdminListUsers(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	query, fieldErrors := parseUserListQuery(gotRequest)
+	if len(fieldErrors) > 0 {
+		sendFieldErrors("Not a valid user list request", fieldErrors, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	// Берём на одного больше, чтобы понять, есть ли следующая страница.
+	limit := query.Limit
+	query.Limit++
+	users, err := handlers.store.ListUsers(gotRequest.Context(), query)
+	if err != nil {
+		handlers.logger.ZL.Error("failed to list users", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+
+	response := userListResponse{Users: users}
+	if len(users) > limit {
+		response.Users = users[:limit]
+		response.NextCursor = encodeUserCursor(response.Users[limit-1].ID)
+	}
+	if response.Users == nil {
+		response.Users = []models.User{}
+	}
+	sendJSON(response, http.StatusOK, responseWriter)
+}
+
+func parseUserListQuery(gotRequest *http.Request) (query models.UserListQuery, fieldErrors []models.FieldError) {
+	values := gotRequest.URL.Query()
+	invalid := func(field, msg string) {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: field, Code: "invalid", Message: msg})
+	}
+
+	if status := models.UserStatus(values.Get("status")); status != "" {
+		if !status.Valid() {
+			invalid("status", "Unknown user status")
+		}
+		query.Status = status
+	}
+	for field, dst := range map[string]*time.Time{"created_from": &query.CreatedFrom, "created_to": &query.CreatedTo} {
+		if raw := values.Get(field); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				invalid(field, "Time must be in RFC 3339 format")
+			}
+			*dst = parsed
+		}
+	}
+	query.LoginPrefix = strings.TrimSpace(values.Get("login_prefix"))
+
+	query.Limit = defaultUserListLimit
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxUserListLimit {
+			invalid("limit", "Limit must be between 1 and "+strconv.Itoa(maxUserListLimit))
+		}
+		query.Limit = limit
+	}
+	if raw := values.Get("cursor"); raw != "" {
+		afterID, err := decodeUserCursor(raw)
+		if err != nil {
+			invalid("cursor", "Cursor is not valid")
+		}
+		query.AfterID = afterID
+	}
+	return query, fieldErrors
+}
+
+// Курсор непрозрачен для клиента, чтобы формат можно было поменять без смены API.
+func encodeUserCursor(afterID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("id:" + strconv.Itoa(afterID)))
+}
+
+func decodeUserCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, ok := strings.CutPrefix(string(raw), "id:")
+	if !ok {
+		return 0, errors.New("unknown cursor format")
+	}
+	return strconv.Atoi(id)
+}
+
+// adminTargetID достаёт id пользователя из пути запроса.
+func adminTargetID(gotRequest *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(gotRequest, "id"))
+	return id, err == nil && id > 0
+}
+
+// AdminGetUser отдаёт одного пользователя.
+func (handlers *Handlers) AdminGetUser(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	targetID, ok := adminTargetID(gotRequest)
+	if !ok {
+		sendResponse(true, "Not a valid user id", http.StatusBadRequest, responseWriter)
+		return
+	}
+	user, err := handlers.store.GetUserByID(gotRequest.Context(), targetID)
+	if errors.Is(err, store.ErrUserNotFound) {
+		sendResponse(true, "User not found", http.StatusNotFound, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to get user", zap.Int("user_id", targetID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	sendJSON(user, http.StatusOK, responseWriter)
+}
+
+/*
+AdminCreateUser создаёт пользователя в обход регистрации, политики логинов и паролей при этом действуют.
+На вход хэндлер ожидает json такого формата:
+
+	{
+	    "login": "<login>",
+	    "password": "<password>",
+	    "role": "user|admin"
+	}
+*/
+func (handlers *Handlers) AdminCreateUser(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	var req models.AdminCreateUserReq
+	if err := json.NewDecoder(gotRequest.Body).Decode(&req); err != nil {
+		sendResponse(true, "Not a valid user creation request", http.StatusBadRequest, responseWriter)
+		return
+	}
+	if req.Login == "" || req.Password == "" {
+		sendResponse(true, "Login and password are required", http.StatusBadRequest, responseWriter)
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.UserRoleUser
+	}
+	fieldErrors := handlers.validateCredentials(req.Login, req.Password)
+	if !req.Role.Valid() {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: "role", Code: "invalid", Message: "Unknown user role"})
+	}
+	if len(fieldErrors) > 0 {
+		sendFieldErrors("Login or password does not satisfy the policy", fieldErrors, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	// Роль задаётся сразу при вставке, чтобы не осталось пользователя с ролью по умолчанию, если второй шаг не пройдёт.
+	newUser, err := handlers.store.CreateUser(gotRequest.Context(), models.UserRegReq{Login: req.Login, Password: req.Password, Role: req.Role})
+	switch {
+	case errors.Is(err, store.ErrHashingOverloaded):
+		handlers.sendHashingOverloaded(responseWriter)
+		return
+	case errors.Is(err, store.ErrLoginConfusable), errors.Is(err, store.ErrLoginTaken):
+		sendStoreError(err, responseWriter)
+		return
+	case err != nil:
+		handlers.logger.ZL.Error("failed to create user", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+
+	adminID, _ := userIDFromRequest(gotRequest)
+	handlers.recordAudit(gotRequest, models.AuditEvent{
+		Type: models.AuditRegistration, ActorID: userRef(adminID), TargetID: userRef(newUser.ID),
+		Result: models.AuditSuccess, Reason: "created_by_admin",
+	})
+	if req.Role != models.UserRoleUser {
+		handlers.recordAudit(gotRequest, models.AuditEvent{
+			Type: models.AuditRoleChange, ActorID: userRef(adminID), TargetID: userRef(newUser.ID),
+			Result: models.AuditSuccess, Reason: string(req.Role),
+		})
+	}
+	sendJSON(newUser, http.StatusCreated, responseWriter)
+}
+
+// AdminResetPassword задаёт пользователю новый пароль и завершает все его сессии.
+func (handlers *Handlers) AdminResetPassword(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	targetID, ok := adminTargetID(gotRequest)
+	if !ok {
+		sendResponse(true, "Not a valid user id", http.StatusBadRequest, responseWriter)
+		return
+	}
+	var req models.AdminPasswordReq
+	if err := json.NewDecoder(gotRequest.Body).Decode(&req); err != nil || req.Password == "" {
+		sendResponse(true, "Password is required", http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	user, err := handlers.store.GetUserByID(gotRequest.Context(), targetID)
+	if errors.Is(err, store.ErrUserNotFound) {
+		sendResponse(true, "User not found", http.StatusNotFound, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to get user", zap.Int("user_id", targetID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	passwordErrors, err := handlers.passwordPolicy.Validate(user.Login, req.Password)
+	if err != nil {
+		handlers.logger.ZL.Warn("failed to check password against breached corpus", zap.Error(err))
+	}
+	if len(passwordErrors) > 0 {
+		sendFieldErrors("Password does not satisfy the policy", passwordErrors, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	err = handlers.store.UpdatePassword(gotRequest.Context(), targetID, req.Password)
+	if errors.Is(err, store.ErrHashingOverloaded) {
+		handlers.sendHashingOverloaded(responseWriter)
+		return
+	}
+	if err == nil {
+		err = handlers.store.RevokeSessions(gotRequest.Context(), targetID)
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to reset password", zap.Int("user_id", targetID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	handlers.auth.InvalidateUserStatus(gotRequest.Context(), targetID)
+	adminID, _ := userIDFromRequest(gotRequest)
+	handlers.recordAudit(gotRequest, models.AuditEvent{
+		Type: models.AuditPasswordChange, ActorID: userRef(adminID), TargetID: userRef(targetID),
+		Result: models.AuditSuccess, Reason: "admin_reset",
+	})
+	sendResponse(false, "Password has been reset", http.StatusOK, responseWriter)
+}
+
+// AdminSuspendUser временно блокирует пользователя.
+func (handlers *Handlers) AdminSuspendUser(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+	handlers.adminSetStatus(responseWriter, gotRequest, models.UserStatusSuspended)
+}
+
+// AdminReactivateUser снимает блокировку с пользователя.
+func (handlers *Handlers) AdminReactivateUser(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+	handlers.adminSetStatus(responseWriter, gotRequest, models.UserStatusActive)
+}
+
+func (handlers *Handlers) adminSetStatus(responseWriter http.ResponseWriter, gotRequest *http.Request, status models.UserStatus) {
+
+	targetID, ok := adminTargetID(gotRequest)
+	if !ok {
+		sendResponse(true, "Not a valid user id", http.StatusBadRequest, responseWriter)
+		return
+	}
+	adminID, _ := userIDFromRequest(gotRequest)
+	if adminID == targetID {
+		sendResponse(true, "Admins can not change their own status", http.StatusConflict, responseWriter)
+		return
+	}
+	// Тело с причиной необязательно.
+	var req models.AdminStatusReq
+	if gotRequest.ContentLength != 0 {
+		if err := json.NewDecoder(gotRequest.Body).Decode(&req); err != nil {
+			sendResponse(true, "Not a valid status change request", http.StatusBadRequest, responseWriter)
+			return
+		}
+	}
+
+	user, err := handlers.store.SetUserStatus(gotRequest.Context(), targetID, status, req.Reason)
+	if errors.Is(err, store.ErrUserNotFound) {
+		sendResponse(true, "User not found", http.StatusNotFound, responseWriter)
+		return
+	}
+	if errors.Is(err, store.ErrInvalidStatusTransition) {
+		sendResponse(true, "User can not be moved to status "+string(status), http.StatusConflict, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to set user status", zap.Int("user_id", targetID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	handlers.auth.InvalidateUserStatus(gotRequest.Context(), targetID)
+	reason := string(status)
+	if req.Reason != "" {
+		reason += ": " + req.Reason
+	}
+	handlers.recordAudit(gotRequest, models.AuditEvent{
+		Type: models.AuditStatusChange, ActorID: userRef(adminID), TargetID: userRef(targetID),
+		Result: models.AuditSuccess, Reason: reason,
+	})
+	sendJSON(user, http.StatusOK, responseWriter)
+}
+
+// AdminForceLogout завершает все сессии пользователя.
+func (handlers *Handlers) AdminForceLogout(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	targetID, ok := adminTargetID(gotRequest)
+	if !ok {
+		sendResponse(true, "Not a valid user id", http.StatusBadRequest, responseWriter)
+		return
+	}
+	err := handlers.store.RevokeSessions(gotRequest.Context(), targetID)
+	if errors.Is(err, store.ErrUserNotFound) {
+		sendResponse(true, "User not found", http.StatusNotFound, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to revoke sessions", zap.Int("user_id", targetID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	handlers.auth.InvalidateUserStatus(gotRequest.Context(), targetID)
+	sendResponse(false, "User has been logged out everywhere", http.StatusOK, responseWriter)
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newAdminRouter собирает админские роуты так же, как main, но без проверки роли.
+func newAdminRouter(t *testing.T, s *mockStorage, adminID int) http.Handler {
+	t.Helper()
+	handlers, err := NewHandlers(s, testConfig, testLogger, testAuth)
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), auth.KeyUserIDCtx, adminID)))
+		})
+	})
+	router.Route("/admin/users", func(router chi.Router) {
+		router.Get("/", handlers.AdminListUsers)
+		router.Get("/search/", handlers.AdminSearchUsers)
+		router.Post("/", handlers.AdminCreateUser)
+		router.Get("/{id}/", handlers.AdminGetUser)
+		router.Post("/{id}/password/", handlers.AdminResetPassword)
+		router.Post("/{id}/suspend/", handlers.AdminSuspendUser)
+		router.Post("/{id}/reactivate/", handlers.AdminReactivateUser)
+		router.Post("/{id}/logout/", handlers.AdminForceLogout)
+	})
+	router.Route("/admin/audit", func(router chi.Router) {
+		router.Get("/", handlers.AdminListAuditEvents)
+		router.Get("/verify/", handlers.AdminVerifyAudit)
+	})
+	return router
+}
+
+func newAdminTestStorage() *mockStorage {
+	s := newMockStorage()
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, login := range []string{"admin", "anna", "andrey", "boris", "anton"} {
+		s.users[login] = models.User{
+			ID:        i + 1,
+			Login:     login,
+			Status:    models.UserStatusActive,
+			CreatedAt: created.AddDate(0, i, 0),
+		}
+	}
+	suspended := s.users["boris"]
+	suspended.Status = models.UserStatusSuspended
+	s.users["boris"] = suspended
+	return s
+}
+
+func TestHandlers_AdminListUsers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantLogins []string
+		wantMore   bool
+	}{
+		{name: "Test all users", query: "", wantStatus: http.StatusOK, wantLogins: []string{"admin", "anna", "andrey", "boris", "anton"}},
+		{name: "Test status filter", query: "?status=suspended", wantStatus: http.StatusOK, wantLogins: []string{"boris"}},
+		{name: "Test login prefix", query: "?login_prefix=an", wantStatus: http.StatusOK, wantLogins: []string{"anna", "andrey", "anton"}},
+		{
+			name:       "Test created range",
+			query:      "?created_from=2025-02-01T00:00:00Z&created_to=2025-04-01T00:00:00Z",
+			wantStatus: http.StatusOK,
+			wantLogins: []string{"anna", "andrey"},
+		},
+		{name: "Test first page", query: "?limit=2", wantStatus: http.StatusOK, wantLogins: []string{"admin", "anna"}, wantMore: true},
+		{name: "Test invalid filters", query: "?status=banned&limit=1000&cursor=%21", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			router := newAdminRouter(t, newAdminTestStorage(), 1)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/"+tt.query, nil))
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				var response resultMsg
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Len(t, response.FieldErrors, 3)
+				return
+			}
+			var response userListResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			var logins []string
+			for _, user := range response.Users {
+				logins = append(logins, user.Login)
+			}
+			assert.Equal(t, tt.wantLogins, logins)
+			assert.Equal(t, tt.wantMore, response.NextCursor != "")
+		})
+	}
+}
+
+func TestHandlers_AdminListUsersPagination(t *testing.T) {
+	router := newAdminRouter(t, newAdminTestStorage(), 1)
+
+	var logins []string
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/?limit=2&cursor="+cursor, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var response userListResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		for _, user := range response.Users {
+			logins = append(logins, user.Login)
+		}
+		if response.NextCursor == "" {
+			break
+		}
+		cursor = response.NextCursor
+	}
+	assert.Equal(t, []string{"admin", "anna", "andrey", "boris", "anton"}, logins)
+}
+
+func TestHandlers_AdminActions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		check      func(t *testing.T, s *mockStorage)
+	}{
+		{name: "Test get user", method: http.MethodGet, path: "/admin/users/2/", wantStatus: http.StatusOK},
+		{name: "Test get missing user", method: http.MethodGet, path: "/admin/users/42/", wantStatus: http.StatusNotFound},
+		{name: "Test get invalid id", method: http.MethodGet, path: "/admin/users/abc/", wantStatus: http.StatusBadRequest},
+		{
+			name: "Test create admin", method: http.MethodPost, path: "/admin/users/",
+			body: `{"login": "oleg", "password": "Str0ng-passw0rd", "role": "admin"}`, wantStatus: http.StatusCreated,
+			check: func(t *testing.T, s *mockStorage) {
+				assert.Equal(t, models.UserRoleAdmin, s.users["oleg"].Role)
+			},
+		},
+		{
+			name: "Test create existing login", method: http.MethodPost, path: "/admin/users/",
+			body: `{"login": "anna", "password": "Str0ng-passw0rd"}`, wantStatus: http.StatusConflict,
+		},
+		{
+			name: "Test create with unknown role", method: http.MethodPost, path: "/admin/users/",
+			body: `{"login": "oleg", "password": "Str0ng-passw0rd", "role": "root"}`, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Test reset password revokes sessions", method: http.MethodPost, path: "/admin/users/2/password/",
+			body: `{"password": "N3w-passw0rd"}`, wantStatus: http.StatusOK,
+			check: func(t *testing.T, s *mockStorage) {
+				assert.Equal(t, []int{2}, s.updated)
+				assert.Equal(t, []int{2}, s.revoked)
+			},
+		},
+		{
+			name: "Test suspend", method: http.MethodPost, path: "/admin/users/2/suspend/",
+			body: `{"reason": "spam"}`, wantStatus: http.StatusOK,
+			check: func(t *testing.T, s *mockStorage) {
+				assert.Equal(t, models.UserStatusSuspended, s.users["anna"].Status)
+				assert.Equal(t, "spam", s.users["anna"].StatusReason)
+			},
+		},
+		{name: "Test suspend self", method: http.MethodPost, path: "/admin/users/1/suspend/", wantStatus: http.StatusConflict},
+		{
+			name: "Test reactivate", method: http.MethodPost, path: "/admin/users/4/reactivate/", wantStatus: http.StatusOK,
+			check: func(t *testing.T, s *mockStorage) {
+				assert.Equal(t, models.UserStatusActive, s.users["boris"].Status)
+			},
+		},
+		{name: "Test reactivate active user", method: http.MethodPost, path: "/admin/users/2/reactivate/", wantStatus: http.StatusConflict},
+		{
+			name: "Test force logout", method: http.MethodPost, path: "/admin/users/3/logout/", wantStatus: http.StatusOK,
+			check: func(t *testing.T, s *mockStorage) {
+				assert.Equal(t, []int{3}, s.revoked)
+			},
+		},
+		{name: "Test force logout missing user", method: http.MethodPost, path: "/admin/users/42/logout/", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := newAdminTestStorage()
+			router := newAdminRouter(t, s, 1)
+
+			request := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			request.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.check != nil {
+				tt.check(t, s)
+			}
+		})
+	}
+}
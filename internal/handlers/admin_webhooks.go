@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/eampleev23/raya-backend.git/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultDeliveryListLimit = 50
+	maxDeliveryListLimit     = 200
+)
+
+// webhookSubscriptionsResponse - список подписок.
+type webhookSubscriptionsResponse struct {
+	Subscriptions []models.WebhookSubscription `json:"subscriptions"`
+}
+
+// webhookDeliveriesResponse - страница журнала доставок.
+type webhookDeliveriesResponse struct {
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+// webhookDeliveryResponse - доставка вместе с журналом попыток.
+type webhookDeliveryResponse struct {
+	Delivery models.WebhookDelivery  `json:"delivery"`
+	Attempts []models.WebhookAttempt `json:"attempts"`
+}
+
+/*
+AdminCreateWebhook подписывает адрес на события. Секрет для проверки подписи возвращается только здесь.
+На вход хэндлер ожидает json такого формата:
+
+	{
+	    "url": "https://example.com/hooks/raya",
+	    "event_types": ["user.registered", "user.deleted"]
+	}
+*/
+func (handlers *Handlers) AdminCreateWebhook(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	if handlers.webhooks == nil {
+		sendResponse(true, "Webhooks are not available", http.StatusServiceUnavailable, responseWriter)
+		return
+	}
+	var req models.WebhookSubscriptionReq
+	if err := json.NewDecoder(gotRequest.Body).Decode(&req); err != nil {
+		sendResponse(true, "Not a valid webhook subscription request", http.StatusBadRequest, responseWriter)
+		return
+	}
+	sub, err := handlers.webhooks.CreateSubscription(gotRequest.Context(), req.URL, req.EventTypes)
+	if errors.Is(err, webhooks.ErrInvalidURL) {
+		sendFieldErrors("Not a valid webhook subscription", []models.FieldError{{
+			Field: "url", Code: "invalid", Message: "URL must be an absolute http or https URL with a resolvable host",
+		}}, http.StatusBadRequest, responseWriter)
+		return
+	}
+	if errors.Is(err, webhooks.ErrForbiddenAddress) {
+		sendFieldErrors("Not a valid webhook subscription", []models.FieldError{{
+			Field: "url", Code: "forbidden", Message: "URL must not point to a private or local address",
+		}}, http.StatusBadRequest, responseWriter)
+		return
+	}
+	if errors.Is(err, webhooks.ErrUnknownEventType) {
+		sendFieldErrors("Not a valid webhook subscription", []models.FieldError{{
+			Field: "event_types", Code: "invalid", Message: "Unknown event type",
+		}}, http.StatusBadRequest, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to create webhook subscription", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	sendJSON(sub, http.StatusCreated, responseWriter)
+}
+
+// AdminListWebhooks отдаёт все подписки без секретов.
+func (handlers *Handlers) AdminListWebhooks(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	subs, err := handlers.store.ListWebhookSubscriptions(gotRequest.Context())
+	if err != nil {
+		handlers.logger.ZL.Error("failed to list webhook subscriptions", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	if subs == nil {
+		subs = []models.WebhookSubscription{}
+	}
+	sendJSON(webhookSubscriptionsResponse{Subscriptions: subs}, http.StatusOK, responseWriter)
+}
+
+// AdminDeleteWebhook удаляет подписку вместе с её журналом доставок.
+func (handlers *Handlers) AdminDeleteWebhook(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	id, err := strconv.Atoi(chi.URLParam(gotRequest, "id"))
+	if err != nil || id < 1 {
+		sendResponse(true, "Not a valid webhook id", http.StatusBadRequest, responseWriter)
+		return
+	}
+	err = handlers.store.DeleteWebhookSubscription(gotRequest.Context(), id)
+	if errors.Is(err, store.ErrWebhookNotFound) {
+		sendResponse(true, "Webhook subscription not found", http.StatusNotFound, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to delete webhook subscription", zap.Int("id", id), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	sendResponse(false, "Webhook subscription deleted", http.StatusOK, responseWriter)
+}
+
+// AdminListWebhookDeliveries отдаёт журнал доставок подписки с фильтром status и страницами через limit и cursor.
+func (handlers *Handlers) AdminListWebhookDeliveries(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	id, err := strconv.Atoi(chi.URLParam(gotRequest, "id"))
+	if err != nil || id < 1 {
+		sendResponse(true, "Not a valid webhook id", http.StatusBadRequest, responseWriter)
+		return
+	}
+	query := models.WebhookDeliveryQuery{SubscriptionID: id, Limit: defaultDeliveryListLimit}
+	values := gotRequest.URL.Query()
+	var fieldErrors []models.FieldError
+	invalid := func(field, msg string) {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: field, Code: "invalid", Message: msg})
+	}
+	switch status := models.WebhookDeliveryStatus(values.Get("status")); status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryDead:
+		query.Status = status
+	default:
+		invalid("status", "Status must be pending, delivered or dead")
+	}
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxDeliveryListLimit {
+			invalid("limit", "Limit must be between 1 and "+strconv.Itoa(maxDeliveryListLimit))
+		}
+		query.Limit = limit
+	}
+	if raw := values.Get("cursor"); raw != "" {
+		afterID, err := decodeDeliveryCursor(raw)
+		if err != nil {
+			invalid("cursor", "Cursor is not valid")
+		}
+		query.AfterID = afterID
+	}
+	if len(fieldErrors) > 0 {
+		sendFieldErrors("Not a valid webhook delivery list request", fieldErrors, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	limit := query.Limit
+	query.Limit++
+	deliveries, err := handlers.store.ListWebhookDeliveries(gotRequest.Context(), query)
+	if err != nil {
+		handlers.logger.ZL.Error("failed to list webhook deliveries", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	response := webhookDeliveriesResponse{Deliveries: deliveries}
+	if len(deliveries) > limit {
+		response.Deliveries = deliveries[:limit]
+		response.NextCursor = encodeDeliveryCursor(response.Deliveries[limit-1].ID)
+	}
+	if response.Deliveries == nil {
+		response.Deliveries = []models.WebhookDelivery{}
+	}
+	sendJSON(response, http.StatusOK, responseWriter)
+}
+
+// AdminGetWebhookDelivery отдаёт доставку и все попытки её отправить.
+func (handlers *Handlers) AdminGetWebhookDelivery(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	id, ok := deliveryIDFromRequest(gotRequest)
+	if !ok {
+		sendResponse(true, "Not a valid delivery id", http.StatusBadRequest, responseWriter)
+		return
+	}
+	delivery, attempts, err := handlers.store.GetWebhookDelivery(gotRequest.Context(), id)
+	if errors.Is(err, store.ErrWebhookDeliveryNotFound) {
+		sendResponse(true, "Webhook delivery not found", http.StatusNotFound, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to get webhook delivery", zap.Int64("id", id), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	if attempts == nil {
+		attempts = []models.WebhookAttempt{}
+	}
+	sendJSON(webhookDeliveryResponse{Delivery: *delivery, Attempts: attempts}, http.StatusOK, responseWriter)
+}
+
+// AdminReplayWebhookDelivery ставит доставку в очередь заново с чистым счётчиком попыток.
+func (handlers *Handlers) AdminReplayWebhookDelivery(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	if handlers.webhooks == nil {
+		sendResponse(true, "Webhooks are not available", http.StatusServiceUnavailable, responseWriter)
+		return
+	}
+	id, ok := deliveryIDFromRequest(gotRequest)
+	if !ok {
+		sendResponse(true, "Not a valid delivery id", http.StatusBadRequest, responseWriter)
+		return
+	}
+	delivery, err := handlers.webhooks.Replay(gotRequest.Context(), id)
+	if errors.Is(err, store.ErrWebhookDeliveryNotFound) {
+		sendResponse(true, "Webhook delivery not found", http.StatusNotFound, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to replay webhook delivery", zap.Int64("id", id), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	sendJSON(delivery, http.StatusAccepted, responseWriter)
+}
+
+func deliveryIDFromRequest(gotRequest *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(gotRequest, "deliveryID"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+func encodeDeliveryCursor(afterID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("delivery:" + strconv.FormatInt(afterID, 10)))
+}
+
+func decodeDeliveryCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, ok := strings.CutPrefix(string(raw), "delivery:")
+	if !ok {
+		return 0, errors.New("unknown cursor format")
+	}
+	return strconv.ParseInt(id, 10, 64)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/outbox"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newWebhookRouter(t *testing.T, s *mockStorage) (http.Handler, *Handlers) {
+	t.Helper()
+	dispatcher := webhooks.NewDispatcher(s, &server_config.ServerConfig{WebhookTimeout: time.Second}, testLogger)
+	handlers, err := NewHandlers(s, testConfig, testLogger, testAuth, WithWebhooks(dispatcher))
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), auth.KeyUserIDCtx, 1)))
+		})
+	})
+	router.Route("/admin/webhooks", func(router chi.Router) {
+		router.Get("/", handlers.AdminListWebhooks)
+		router.Post("/", handlers.AdminCreateWebhook)
+		router.Delete("/{id}/", handlers.AdminDeleteWebhook)
+		router.Get("/{id}/deliveries/", handlers.AdminListWebhookDeliveries)
+		router.Get("/deliveries/{deliveryID}/", handlers.AdminGetWebhookDelivery)
+		router.Post("/deliveries/{deliveryID}/replay/", handlers.AdminReplayWebhookDelivery)
+	})
+	return router, handlers
+}
+
+func serve(router http.Handler, method, path string, body any) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		jsonBody, _ := json.Marshal(body)
+		reader = bytes.NewReader(jsonBody)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, reader))
+	return w
+}
+
+func TestHandlers_AdminCreateWebhook(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		body       any
+		wantStatus int
+		wantField  string
+	}{
+		{
+			name:       "Test valid subscription",
+			body:       models.WebhookSubscriptionReq{URL: "https://203.0.113.10/hook", EventTypes: []models.WebhookEventType{models.WebhookUserDeleted}},
+			wantStatus: http.StatusCreated,
+		},
+		{name: "Test all events", body: models.WebhookSubscriptionReq{URL: "https://203.0.113.10/hook"}, wantStatus: http.StatusCreated},
+		{name: "Test bad url", body: models.WebhookSubscriptionReq{URL: "example.com"}, wantStatus: http.StatusBadRequest, wantField: "url"},
+		{name: "Test loopback url", body: models.WebhookSubscriptionReq{URL: "http://127.0.0.1:8080/hook"}, wantStatus: http.StatusBadRequest, wantField: "url"},
+		{name: "Test metadata url", body: models.WebhookSubscriptionReq{URL: "http://169.254.169.254/latest/meta-data/"}, wantStatus: http.StatusBadRequest, wantField: "url"},
+		{
+			name:       "Test unknown event",
+			body:       models.WebhookSubscriptionReq{URL: "https://203.0.113.10/hook", EventTypes: []models.WebhookEventType{"user.sneezed"}},
+			wantStatus: http.StatusBadRequest,
+			wantField:  "event_types",
+		},
+		{name: "Test not json", body: "hook", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := newWebhookRouter(t, newMockStorage())
+			w := serve(router, http.MethodPost, "/admin/webhooks/", tt.body)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusCreated {
+				var sub models.WebhookSubscription
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sub))
+				assert.NotEmpty(t, sub.Secret)
+				return
+			}
+			if tt.wantField != "" {
+				var response resultMsg
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				require.Len(t, response.FieldErrors, 1)
+				assert.Equal(t, tt.wantField, response.FieldErrors[0].Field)
+			}
+		})
+	}
+}
+
+func TestHandlers_WebhookFlow(t *testing.T) {
+	s := newMockStorage()
+	router, handlers := newWebhookRouter(t, s)
+
+	w := serve(router, http.MethodPost, "/admin/webhooks/", models.WebhookSubscriptionReq{URL: "https://203.0.113.10/hook"})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// Секрет в списке подписок не показывается.
+	w = serve(router, http.MethodGet, "/admin/webhooks/", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var subs webhookSubscriptionsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &subs))
+	require.Len(t, subs.Subscriptions, 1)
+	assert.Empty(t, subs.Subscriptions[0].Secret)
+
+	// Регистрация пишет событие в outbox, а relay ставит его в очередь подписчику.
+	request := httptest.NewRequest(http.MethodPost, "/api/user/registration/",
+		bytes.NewBufferString(`{"login": "petr", "password": "Str0ng-enough-pass"}`))
+	registration := httptest.NewRecorder()
+	handlers.Registration(registration, request)
+	require.Equal(t, http.StatusOK, registration.Code)
+	relay := outbox.NewRelay(s, &server_config.ServerConfig{}, testLogger, handlers.webhooks)
+	relayed, err := relay.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, relayed)
+
+	w = serve(router, http.MethodGet, "/admin/webhooks/1/deliveries/?status=pending", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var deliveries webhookDeliveriesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deliveries))
+	require.Len(t, deliveries.Deliveries, 1)
+	assert.Equal(t, models.WebhookUserRegistered, deliveries.Deliveries[0].EventType)
+
+	w = serve(router, http.MethodGet, "/admin/webhooks/deliveries/1/", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var delivery webhookDeliveryResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &delivery))
+	assert.Equal(t, models.WebhookDeliveryPending, delivery.Delivery.Status)
+	assert.Empty(t, delivery.Attempts)
+
+	w = serve(router, http.MethodPost, "/admin/webhooks/deliveries/1/replay/", nil)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	w = serve(router, http.MethodPost, "/admin/webhooks/deliveries/9/replay/", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(router, http.MethodGet, "/admin/webhooks/1/deliveries/?status=lost", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, http.MethodDelete, "/admin/webhooks/1/", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(router, http.MethodDelete, "/admin/webhooks/1/", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(router, http.MethodGet, "/admin/webhooks/deliveries/1/", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
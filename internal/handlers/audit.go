@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/middlewares"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recordAudit пишет событие в журнал безопасности, дополняя его сведениями о запросе.
+// Сбой записи не мешает ответу клиенту, но попадает в лог с полным содержимым события.
+func (handlers *Handlers) recordAudit(gotRequest *http.Request, event models.AuditEvent) {
+	event.IP = handlers.clientIP(gotRequest)
+	event.UserAgent = gotRequest.UserAgent()
+	event.RequestID = middlewares.RequestIDFromContext(gotRequest.Context())
+	if _, err := handlers.store.AppendAuditEvent(gotRequest.Context(), event); err != nil {
+		handlers.logger.ZL.Error("failed to record audit event", zap.Any("event", event), zap.Error(err))
+	}
+}
+
+// clientIP - адрес клиента с учётом доверенных прокси.
+func (handlers *Handlers) clientIP(gotRequest *http.Request) string {
+	return handlers.ipFilter.ClientIP(gotRequest)
+}
+
+// userRef - ссылка на пользователя в событии журнала.
+func userRef(userID int) *int {
+	return &userID
+}
+
+const (
+	defaultAuditListLimit = 100
+	maxAuditListLimit     = 1000
+)
+
+// auditListResponse - страница журнала безопасности.
+type auditListResponse struct {
+	Events     []models.AuditEvent `json:"events"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// AdminListAuditEvents отдаёт журнал безопасности с фильтрами type, result, actor_id, target_id, user_id,
+// from, to (RFC 3339) и постраничной навигацией через limit и cursor. Записи идут от старых к новым.
+func (handlers *Handlers) AdminListAuditEvents(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	query, fieldErrors := parseAuditQuery(gotRequest)
+	if len(fieldErrors) > 0 {
+		sendFieldErrors("Not a valid audit log request", fieldErrors, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	limit := query.Limit
+	query.Limit++
+	events, err := handlers.store.ListAuditEvents(gotRequest.Context(), query)
+	if err != nil {
+		handlers.logger.ZL.Error("failed to list audit events", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+
+	response := auditListResponse{Events: events}
+	if len(events) > limit {
+		response.Events = events[:limit]
+		response.NextCursor = encodeAuditCursor(response.Events[limit-1].ID)
+	}
+	if response.Events == nil {
+		response.Events = []models.AuditEvent{}
+	}
+	sendJSON(response, http.StatusOK, responseWriter)
+}
+
+// AdminVerifyAudit проверяет целостность цепочки хэшей журнала.
+func (handlers *Handlers) AdminVerifyAudit(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	verification, err := store.VerifyAuditChain(gotRequest.Context(), handlers.store)
+	if err != nil {
+		handlers.logger.ZL.Error("failed to verify audit chain", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	if !verification.Valid {
+		handlers.logger.ZL.Error("audit chain is broken", zap.Int64("broken_at_id", verification.BrokenAtID))
+	}
+	sendJSON(verification, http.StatusOK, responseWriter)
+}
+
+func parseAuditQuery(gotRequest *http.Request) (query models.AuditQuery, fieldErrors []models.FieldError) {
+	values := gotRequest.URL.Query()
+	invalid := func(field, msg string) {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: field, Code: "invalid", Message: msg})
+	}
+
+	query.Type = models.AuditEventType(values.Get("type"))
+	if result := models.AuditResult(values.Get("result")); result != "" {
+		if result != models.AuditSuccess && result != models.AuditFailure {
+			invalid("result", "Result must be success or failure")
+		}
+		query.Result = result
+	}
+	for field, dst := range map[string]**int{"actor_id": &query.ActorID, "target_id": &query.TargetID, "user_id": &query.UserID} {
+		if raw := values.Get(field); raw != "" {
+			id, err := strconv.Atoi(raw)
+			if err != nil || id < 1 {
+				invalid(field, "User id must be a positive integer")
+			}
+			*dst = &id
+		}
+	}
+	for field, dst := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if raw := values.Get(field); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				invalid(field, "Time must be in RFC 3339 format")
+			}
+			*dst = parsed
+		}
+	}
+
+	query.Limit = defaultAuditListLimit
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxAuditListLimit {
+			invalid("limit", "Limit must be between 1 and "+strconv.Itoa(maxAuditListLimit))
+		}
+		query.Limit = limit
+	}
+	if raw := values.Get("cursor"); raw != "" {
+		afterID, err := decodeAuditCursor(raw)
+		if err != nil {
+			invalid("cursor", "Cursor is not valid")
+		}
+		query.AfterID = afterID
+	}
+	return query, fieldErrors
+}
+
+func encodeAuditCursor(afterID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("audit:" + strconv.FormatInt(afterID, 10)))
+}
+
+func decodeAuditCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, ok := strings.CutPrefix(string(raw), "audit:")
+	if !ok {
+		return 0, errors.New("unknown cursor format")
+	}
+	return strconv.ParseInt(id, 10, 64)
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/eampleev23/raya-backend.git/internal/middlewares"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlers_LoginAudit(t *testing.T) {
+	s := newMockStorage()
+	s.users["Petr"] = models.User{ID: 7, Login: "Petr"}
+	handlers, err := NewHandlers(s, testConfig, testLogger, testAuth)
+	require.NoError(t, err)
+	login := middlewares.RequestID(http.HandlerFunc(handlers.Login))
+
+	for _, body := range []models.UserLoginReq{
+		{Login: "Nobody", Password: "correctPassword"},
+		{Login: "Petr", Password: "wrongPassword"},
+		{Login: "Petr", Password: "correctPassword"},
+	} {
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		request := httptest.NewRequest(http.MethodPost, "/api/user/login/", bytes.NewBuffer(jsonBody))
+		request.Header.Set("User-Agent", "audit-test")
+		request.Header.Set(middlewares.RequestIDHeader, "req-"+body.Password)
+		login.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	events, err := s.ListAuditEvents(context.Background(), models.AuditQuery{})
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	assert.Equal(t, models.AuditFailure, events[0].Result)
+	assert.Equal(t, "user_not_found", events[0].Reason)
+	assert.Nil(t, events[0].TargetID)
+
+	assert.Equal(t, models.AuditFailure, events[1].Result)
+	assert.Equal(t, "wrong_password", events[1].Reason)
+	assert.Equal(t, userRef(7), events[1].TargetID)
+	assert.Nil(t, events[1].ActorID)
+	assert.Equal(t, "req-wrongPassword", events[1].RequestID)
+
+	assert.Equal(t, models.AuditSuccess, events[2].Result)
+	assert.Equal(t, userRef(7), events[2].ActorID)
+	assert.Equal(t, "192.0.2.1", events[2].IP)
+	assert.Equal(t, "audit-test", events[2].UserAgent)
+	for _, event := range events {
+		assert.Equal(t, models.AuditLogin, event.Type)
+	}
+}
+
+func TestHandlers_AdminAuditRecorded(t *testing.T) {
+	s := newAdminTestStorage()
+	router := newAdminRouter(t, s, 1)
+
+	for _, path := range []string{"/admin/users/2/suspend/", "/admin/users/2/reactivate/"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	events, err := s.ListAuditEvents(context.Background(), models.AuditQuery{})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, []models.AuditEventType{models.AuditStatusChange, models.AuditStatusChange}, s.auditTypes())
+	assert.Equal(t, userRef(1), events[0].ActorID)
+	assert.Equal(t, userRef(2), events[0].TargetID)
+	assert.Equal(t, "suspended", events[0].Reason)
+	assert.Equal(t, "active", events[1].Reason)
+}
+
+func TestHandlers_AdminListAuditEvents(t *testing.T) {
+	t.Parallel()
+
+	s := newAdminTestStorage()
+	for _, event := range []models.AuditEvent{
+		{Type: models.AuditLogin, TargetID: userRef(2), Result: models.AuditFailure},
+		{Type: models.AuditLogin, ActorID: userRef(2), TargetID: userRef(2), Result: models.AuditSuccess},
+		{Type: models.AuditLogout, ActorID: userRef(2), TargetID: userRef(2), Result: models.AuditSuccess},
+		{Type: models.AuditRoleChange, ActorID: userRef(1), TargetID: userRef(3), Result: models.AuditSuccess},
+	} {
+		_, err := s.AppendAuditEvent(context.Background(), event)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []int64
+		wantMore   bool
+	}{
+		{name: "Test all events", query: "", wantStatus: http.StatusOK, wantIDs: []int64{1, 2, 3, 4}},
+		{name: "Test type filter", query: "?type=user.login", wantStatus: http.StatusOK, wantIDs: []int64{1, 2}},
+		{name: "Test result filter", query: "?result=failure", wantStatus: http.StatusOK, wantIDs: []int64{1}},
+		{name: "Test actor filter", query: "?actor_id=1", wantStatus: http.StatusOK, wantIDs: []int64{4}},
+		{name: "Test user filter", query: "?user_id=3", wantStatus: http.StatusOK, wantIDs: []int64{4}},
+		{name: "Test first page", query: "?limit=2", wantStatus: http.StatusOK, wantIDs: []int64{1, 2}, wantMore: true},
+		{name: "Test time range in the future", query: "?from=2100-01-01T00:00:00Z", wantStatus: http.StatusOK, wantIDs: nil},
+		{name: "Test unknown result", query: "?result=maybe", wantStatus: http.StatusBadRequest},
+		{name: "Test bad actor id", query: "?actor_id=abc", wantStatus: http.StatusBadRequest},
+		{name: "Test bad cursor", query: "?cursor=aWQ6Mw", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			router := newAdminRouter(t, s, 1)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/audit/"+tt.query, nil))
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var response auditListResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			var ids []int64
+			for _, event := range response.Events {
+				ids = append(ids, event.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+			assert.Equal(t, tt.wantMore, response.NextCursor != "")
+		})
+	}
+}
+
+func TestHandlers_AdminAuditCursor(t *testing.T) {
+	s := newAdminTestStorage()
+	for i := 0; i < 3; i++ {
+		_, err := s.AppendAuditEvent(context.Background(), models.AuditEvent{Type: models.AuditLogout, Result: models.AuditSuccess})
+		require.NoError(t, err)
+	}
+	router := newAdminRouter(t, s, 1)
+
+	var ids []int64
+	path := "/admin/audit/?limit=2"
+	for path != "" {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var response auditListResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		for _, event := range response.Events {
+			ids = append(ids, event.ID)
+		}
+		path = ""
+		if response.NextCursor != "" {
+			path = "/admin/audit/?limit=2&cursor=" + response.NextCursor
+		}
+	}
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+}
+
+func TestHandlers_AdminVerifyAudit(t *testing.T) {
+	s := newAdminTestStorage()
+	for i := 0; i < 3; i++ {
+		_, err := s.AppendAuditEvent(context.Background(), models.AuditEvent{Type: models.AuditLogout, Result: models.AuditSuccess})
+		require.NoError(t, err)
+	}
+	router := newAdminRouter(t, s, 1)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/audit/verify/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var verification models.AuditVerification
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &verification))
+	assert.Equal(t, models.AuditVerification{Checked: 3, Valid: true}, verification)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingStorage - хранилище, чьи запросы за пользователем висят, пока не отменят контекст.
+type blockingStorage struct {
+	*mockStorage
+	mu      sync.Mutex
+	aborted []error // С какой ошибкой контекста прервались запросы.
+}
+
+func (b *blockingStorage) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.aborted = append(b.aborted, ctx.Err())
+		b.mu.Unlock()
+		return ctx.Err()
+	case <-time.After(5 * time.Second):
+		return errors.New("query was not aborted")
+	}
+}
+
+func (b *blockingStorage) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	return nil, b.wait(ctx)
+}
+
+func (b *blockingStorage) GetUserByLogin(ctx context.Context, req models.UserLoginReq) (*models.User, error) {
+	return nil, b.wait(ctx)
+}
+
+func TestHandlers_ContextAbortsStoreCalls(t *testing.T) {
+	t.Parallel()
+
+	canceled := func() (context.Context, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(context.Background())
+		// Клиент уходит, пока запрос ждёт базу.
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return ctx, cancel
+	}
+	deadline := func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(context.Background(), 10*time.Millisecond)
+	}
+	loginBody, _ := json.Marshal(models.UserLoginReq{Login: "alice", Password: "password"})
+
+	tests := []struct {
+		name        string
+		ctx         func() (context.Context, context.CancelFunc)
+		method      string
+		target      string
+		body        []byte
+		handler     func(h *Handlers) http.HandlerFunc
+		wantStatus  int
+		wantCode    string
+		wantAborted error
+	}{
+		{
+			name: "me canceled", ctx: canceled, method: http.MethodGet, target: "/user/me/",
+			handler:    func(h *Handlers) http.HandlerFunc { return h.GetMe },
+			wantStatus: http.StatusServiceUnavailable, wantCode: "canceled", wantAborted: context.Canceled,
+		},
+		{
+			name: "me deadline", ctx: deadline, method: http.MethodGet, target: "/user/me/",
+			handler:    func(h *Handlers) http.HandlerFunc { return h.GetMe },
+			wantStatus: http.StatusGatewayTimeout, wantCode: "timeout", wantAborted: context.DeadlineExceeded,
+		},
+		{
+			name: "login deadline is not reported as unknown user", ctx: deadline, method: http.MethodPost, target: "/user/login/",
+			body:       loginBody,
+			handler:    func(h *Handlers) http.HandlerFunc { return h.Login },
+			wantStatus: http.StatusGatewayTimeout, wantCode: "timeout", wantAborted: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := &blockingStorage{mockStorage: newMockStorage()}
+			handlers, err := NewHandlers(s, testConfig, testLogger, testAuth)
+			require.NoError(t, err)
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			request := httptest.NewRequest(tt.method, tt.target, bytes.NewReader(tt.body))
+			request = request.WithContext(context.WithValue(ctx, auth.KeyUserIDCtx, 1))
+			w := httptest.NewRecorder()
+			tt.handler(handlers)(w, request)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var response resultMsg
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.wantCode, response.Code)
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			require.Len(t, s.aborted, 1, "the store call must stop with the request")
+			assert.ErrorIs(t, s.aborted[0], tt.wantAborted)
+		})
+	}
+}
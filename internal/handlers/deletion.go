@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// deletionResponse - ответ на удаление учётной записи.
+type deletionResponse struct {
+	IsError       bool
+	ResultMessage string    `json:"result_message"`
+	RestoreBefore time.Time `json:"restore_before"`
+}
+
+// DeleteMe удаляет учётную запись текущего пользователя. Все его сессии сразу отзываются,
+// а восстановить запись можно в течение DeletionGracePeriod через Restore.
+func (handlers *Handlers) DeleteMe(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	userID, ok := userIDFromRequest(gotRequest)
+	if !ok {
+		sendResponse(true, "Unauthorized", http.StatusUnauthorized, responseWriter)
+		return
+	}
+
+	user, err := handlers.store.DeleteUser(gotRequest.Context(), userID, "deleted by user")
+	if errors.Is(err, store.ErrUserNotFound) {
+		sendResponse(true, "User not found", http.StatusNotFound, responseWriter)
+		return
+	}
+	if errors.Is(err, store.ErrInvalidStatusTransition) {
+		sendResponse(true, "Account can not be deleted in its current state", http.StatusConflict, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to delete user", zap.Int("user_id", userID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+
+	// Другие запросы этого пользователя должны сразу увидеть удаление, не дожидаясь кэша.
+	handlers.auth.InvalidateUserStatus(gotRequest.Context(), userID)
+	handlers.auth.Logout(responseWriter)
+	handlers.recordAudit(gotRequest, models.AuditEvent{
+		Type: models.AuditStatusChange, ActorID: userRef(userID), TargetID: userRef(userID),
+		Result: models.AuditSuccess, Reason: string(models.UserStatusDeleted),
+	})
+
+	deletedAt := time.Now()
+	if user.DeletedAt != nil {
+		deletedAt = *user.DeletedAt
+	}
+	sendJSON(deletionResponse{
+		ResultMessage: "Account deleted",
+		RestoreBefore: deletedAt.Add(handlers.servConf.DeletionGracePeriod),
+	}, http.StatusOK, responseWriter)
+}
+
+/*
+Restore восстанавливает удалённую учётную запись и авторизует пользователя.
+На вход хэндлер ожидает json такого формата:
+
+	{
+	    "login": "<login>",
+	    "password": "<password>"
+	}
+*/
+func (handlers *Handlers) Restore(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	var userLoginReq models.UserLoginReq
+	decoder := json.NewDecoder(gotRequest.Body)
+	if err := decoder.Decode(&userLoginReq); err != nil {
+		sendResponse(true, "Not a valid account restore request", http.StatusBadRequest, responseWriter)
+		return
+	}
+	if userLoginReq.Login == "" || userLoginReq.Password == "" {
+		sendResponse(true, "Login and password are required", http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	foundUser, err := handlers.store.GetUserByLogin(gotRequest.Context(), userLoginReq)
+	if err != nil && !errors.Is(err, store.ErrUserNotFound) {
+		sendStoreError(err, responseWriter)
+		return
+	}
+	if err != nil {
+		sendResponse(true, "User with this login does not exist", http.StatusNotFound, responseWriter)
+		return
+	}
+	isCorrectPassword, err := handlers.store.VerifyPassword(gotRequest.Context(), userLoginReq.Password, foundUser.PasswordHash)
+	if errors.Is(err, store.ErrHashingOverloaded) {
+		handlers.sendHashingOverloaded(responseWriter)
+		return
+	}
+	if err != nil {
+		sendStoreError(err, responseWriter)
+		return
+	}
+	if !isCorrectPassword {
+		sendResponse(true, "The passwords don't match", http.StatusUnauthorized, responseWriter)
+		return
+	}
+
+	deletedAfter := time.Now().Add(-handlers.servConf.DeletionGracePeriod)
+	restored, err := handlers.store.RestoreUser(gotRequest.Context(), foundUser.ID, deletedAfter)
+	if errors.Is(err, store.ErrInvalidStatusTransition) {
+		sendResponse(true, "Account is not deleted", http.StatusConflict, responseWriter)
+		return
+	}
+	if errors.Is(err, store.ErrRestoreExpired) {
+		sendErrorCode("restore_expired", "Account can no longer be restored", http.StatusGone, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to restore user", zap.Int("user_id", foundUser.ID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	handlers.auth.InvalidateUserStatus(gotRequest.Context(), restored.ID)
+	handlers.recordAudit(gotRequest, models.AuditEvent{
+		Type: models.AuditStatusChange, ActorID: userRef(restored.ID), TargetID: userRef(restored.ID),
+		Result: models.AuditSuccess, Reason: string(restored.Status),
+	})
+	// Учётная запись вернулась в статус, который был до удаления. Если её блокировал администратор,
+	// восстановление не снимает блокировку и войти по-прежнему нельзя.
+	if restored.Status != models.UserStatusActive {
+		sendErrorCode(
+			restored.Status.ErrorCode(),
+			"Account restored but is "+string(restored.Status),
+			http.StatusForbidden,
+			responseWriter)
+		return
+	}
+
+	if err := handlers.auth.SetNewCookie(responseWriter, restored.ID, restored.Login); err != nil {
+		sendResponse(true, "Error setting authorization cookie", http.StatusInternalServerError, responseWriter)
+		return
+	}
+	sendResponse(false, "Account restored", http.StatusOK, responseWriter)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandlers_DeleteMe(t *testing.T) {
+	t.Parallel()
+
+	s := newMockStorage()
+	s.users["Petr"] = models.User{ID: 1, Login: "Petr", Status: models.UserStatusActive}
+	c := &server_config.ServerConfig{DeletionGracePeriod: 24 * time.Hour}
+	handlers, err := NewHandlers(s, c, testLogger, testAuth)
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodDelete, "/api/user/me/", nil)
+	request = request.WithContext(context.WithValue(request.Context(), auth.KeyUserIDCtx, 1))
+	w := httptest.NewRecorder()
+	handlers.DeleteMe(w, request)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response deletionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.IsError)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), response.RestoreBefore, time.Minute)
+	assert.Equal(t, models.UserStatusDeleted, s.users["Petr"].Status)
+
+	// Кука с токеном сбрасывается.
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, -1, cookies[0].MaxAge)
+
+	// Повторное удаление невозможно.
+	w = httptest.NewRecorder()
+	handlers.DeleteMe(w, request)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestHandlers_Restore(t *testing.T) {
+	t.Parallel()
+
+	recently := time.Now().Add(-time.Hour)
+	longAgo := time.Now().Add(-48 * time.Hour)
+
+	tests := []struct {
+		name                 string
+		password             string
+		user                 models.User
+		statusBeforeDeletion models.UserStatus
+		wantStatus           int
+		wantCode             string
+	}{
+		{
+			name:       "Test restore within grace period",
+			password:   "correctPassword",
+			user:       models.User{ID: 1, Login: "Petr", Status: models.UserStatusDeleted, DeletedAt: &recently},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test wrong password",
+			password:   "wrongPassword",
+			user:       models.User{ID: 1, Login: "Petr", Status: models.UserStatusDeleted, DeletedAt: &recently},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test grace period expired",
+			password:   "correctPassword",
+			user:       models.User{ID: 1, Login: "Petr", Status: models.UserStatusDeleted, DeletedAt: &longAgo},
+			wantStatus: http.StatusGone,
+			wantCode:   "restore_expired",
+		},
+		{
+			name:                 "Test suspended account stays suspended",
+			password:             "correctPassword",
+			user:                 models.User{ID: 1, Login: "Petr", Status: models.UserStatusDeleted, DeletedAt: &recently},
+			statusBeforeDeletion: models.UserStatusSuspended,
+			wantStatus:           http.StatusForbidden,
+			wantCode:             "account_suspended",
+		},
+		{
+			name:       "Test account is not deleted",
+			password:   "correctPassword",
+			user:       models.User{ID: 1, Login: "Petr", Status: models.UserStatusActive},
+			wantStatus: http.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMockStorage()
+			s.users["Petr"] = tt.user
+			if tt.statusBeforeDeletion != "" {
+				s.statusBeforeDeletion[tt.user.ID] = tt.statusBeforeDeletion
+			}
+			c := &server_config.ServerConfig{DeletionGracePeriod: 24 * time.Hour}
+			handlers, err := NewHandlers(s, c, testLogger, testAuth)
+			require.NoError(t, err)
+
+			body, err := json.Marshal(models.UserLoginReq{Login: "Petr", Password: tt.password})
+			require.NoError(t, err)
+			request := httptest.NewRequest(http.MethodPost, "/api/user/restore/", bytes.NewBuffer(body))
+			request.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			handlers.Restore(w, request)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var response resultMsg
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.wantCode, response.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, models.UserStatusActive, s.users["Petr"].Status)
+				assert.NotEmpty(t, w.Result().Cookies())
+			} else {
+				assert.Empty(t, w.Result().Cookies())
+			}
+		})
+	}
+}
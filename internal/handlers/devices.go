@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+// devicesResponse - известные устройства пользователя.
+type devicesResponse struct {
+	Devices []models.Device `json:"devices"`
+}
+
+// ListDevices отдаёт устройства, с которых текущий пользователь уже входил.
+func (handlers *Handlers) ListDevices(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	userID, ok := userIDFromRequest(gotRequest)
+	if !ok {
+		sendResponse(true, "Unauthorized", http.StatusUnauthorized, responseWriter)
+		return
+	}
+
+	devices, err := handlers.store.ListDevices(gotRequest.Context(), userID)
+	if err != nil {
+		handlers.logger.ZL.Error("failed to list devices", zap.Int("user_id", userID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	if devices == nil {
+		devices = []models.Device{}
+	}
+	sendJSON(devicesResponse{Devices: devices}, http.StatusOK, responseWriter)
+}
+
+// DeleteDevice забывает устройство текущего пользователя: следующий вход с него снова будет входом с нового устройства.
+func (handlers *Handlers) DeleteDevice(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	userID, ok := userIDFromRequest(gotRequest)
+	if !ok {
+		sendResponse(true, "Unauthorized", http.StatusUnauthorized, responseWriter)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(gotRequest, "id"), 10, 64)
+	if err != nil || id < 1 {
+		sendResponse(true, "Not a valid device id", http.StatusBadRequest, responseWriter)
+		return
+	}
+	err = handlers.store.DeleteDevice(gotRequest.Context(), userID, id)
+	if errors.Is(err, store.ErrDeviceNotFound) {
+		sendResponse(true, "Device not found", http.StatusNotFound, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to delete device", zap.Int("user_id", userID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	sendResponse(false, "Device deleted", http.StatusOK, responseWriter)
+}
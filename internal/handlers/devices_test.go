@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHandlers_Devices(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newMockStorage()
+	laptop, err := s.TouchDevice(ctx, models.Device{UserID: 1, ClientFamily: "Firefox/Linux", Subnet: "192.0.2.0/24", LastSeenAt: time.Now()})
+	require.NoError(t, err)
+	foreign, err := s.TouchDevice(ctx, models.Device{UserID: 2, ClientFamily: "Safari/iOS", Subnet: "192.0.2.0/24", LastSeenAt: time.Now()})
+	require.NoError(t, err)
+
+	handlers, err := NewHandlers(s, testConfig, testLogger, testAuth)
+	require.NoError(t, err)
+	router := chi.NewRouter()
+	router.Get("/user/devices/", handlers.ListDevices)
+	router.Delete("/user/devices/{id}/", handlers.DeleteDevice)
+	do := func(method, target string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, nil)
+		request = request.WithContext(context.WithValue(request.Context(), auth.KeyUserIDCtx, 1))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	w := do(http.MethodGet, "/user/devices/")
+	require.Equal(t, http.StatusOK, w.Code)
+	var response devicesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Devices, 1)
+	assert.Equal(t, laptop.ID, response.Devices[0].ID)
+	assert.Equal(t, "Firefox/Linux", response.Devices[0].ClientFamily)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodDelete, "/user/devices/abc/").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/user/devices/"+strconv.FormatInt(foreign.ID, 10)+"/").Code,
+		"devices of other users cannot be deleted")
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/user/devices/"+strconv.FormatInt(laptop.ID, 10)+"/").Code)
+
+	w = do(http.MethodGet, "/user/devices/")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"devices":[]}`, w.Body.String())
+}
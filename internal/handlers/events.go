@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// publishEvent пишет в outbox событие, которое не сопровождается изменением данных, например вход.
+// События об изменениях хранилище пишет само в той же транзакции. Сбой записи не мешает ответу клиенту.
+func (handlers *Handlers) publishEvent(gotRequest *http.Request, eventType models.DomainEventType, user *models.User) {
+	event := store.NewDomainEvent(eventType, user)
+	event.IP = handlers.clientIP(gotRequest)
+	event.UserAgent = gotRequest.UserAgent()
+	if err := handlers.store.AppendOutboxEvent(gotRequest.Context(), event); err != nil {
+		handlers.logger.ZL.Error("failed to append outbox event",
+			zap.String("type", string(eventType)), zap.Int("user_id", user.ID), zap.Error(err))
+	}
+}
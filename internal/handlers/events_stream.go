@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Сколько уведомлений читается из хранилища за раз.
+	streamBatchSize = 100
+	// Через сколько браузер переподключается после обрыва.
+	streamRetry            = 3 * time.Second
+	defaultStreamHeartbeat = 15 * time.Second
+	defaultStreamPoll      = 5 * time.Second
+)
+
+/*
+EventsStream отдаёт пользователю уведомления безопасности потоком Server-Sent Events:
+
+	id: 12
+	event: user.logged_in
+	data: {"id":12,"type":"user.logged_in","occurred_at":"...","ip":"..."}
+
+После обрыва клиент переподключается с Last-Event-ID (заголовком или параметром last_event_id)
+и получает всё, что пропустил. Без него поток начинается с новых уведомлений.
+*/
+func (handlers *Handlers) EventsStream(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+	if handlers.feed == nil {
+		sendResponse(true, "Event stream is not available", http.StatusServiceUnavailable, responseWriter)
+		return
+	}
+	userID, ok := userIDFromRequest(gotRequest)
+	if !ok {
+		sendResponse(true, "Unauthorized", http.StatusUnauthorized, responseWriter)
+		return
+	}
+	lastID, resume, err := lastEventID(gotRequest)
+	if err != nil {
+		sendResponse(true, "Last-Event-ID must be a non-negative integer", http.StatusBadRequest, responseWriter)
+		return
+	}
+	flusher, ok := responseWriter.(http.Flusher)
+	if !ok {
+		sendResponse(true, "Streaming is not supported", http.StatusInternalServerError, responseWriter)
+		return
+	}
+	ctx := gotRequest.Context()
+
+	// Подписываемся до чтения хранилища, чтобы не пропустить уведомление между ними.
+	wake, cancel := handlers.feed.Subscribe(userID)
+	defer cancel()
+
+	if !resume {
+		// Новый поток начинается с текущего момента: пропускаем уже накопленные уведомления.
+		for {
+			events, err := handlers.feed.List(ctx, userID, lastID, streamBatchSize)
+			if err != nil {
+				handlers.logger.ZL.Error("failed to list security events", zap.Int("user_id", userID), zap.Error(err))
+				sendStoreError(err, responseWriter)
+				return
+			}
+			if len(events) > 0 {
+				lastID = events[len(events)-1].ID
+			}
+			if len(events) < streamBatchSize {
+				break
+			}
+		}
+	}
+
+	header := responseWriter.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// Запрещаем nginx буферизовать поток.
+	header.Set("X-Accel-Buffering", "no")
+	responseWriter.WriteHeader(http.StatusOK)
+	fmt.Fprintf(responseWriter, "retry: %d\n\n", streamRetry.Milliseconds())
+	flusher.Flush()
+
+	// send пишет в поток все уведомления после lastID.
+	send := func() error {
+		for {
+			events, err := handlers.feed.List(ctx, userID, lastID, streamBatchSize)
+			if err != nil {
+				return fmt.Errorf("failed to list security events: %w", err)
+			}
+			for _, event := range events {
+				if err := writeStreamEvent(responseWriter, event); err != nil {
+					return err
+				}
+				lastID = event.ID
+			}
+			flusher.Flush()
+			if len(events) < streamBatchSize {
+				return nil
+			}
+		}
+	}
+
+	// Доступ проверен один раз при подключении, а поток живёт долго: на каждом тике проверка повторяется,
+	// чтобы отзыв сессий, блокировка или удаление учётной записи закрывали и открытые потоки.
+	heartbeat := time.NewTicker(positiveOr(handlers.servConf.SSEHeartbeat, defaultStreamHeartbeat))
+	defer heartbeat.Stop()
+	poll := time.NewTicker(positiveOr(handlers.servConf.SSEPollInterval, defaultStreamPoll))
+	defer poll.Stop()
+
+	err = send()
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			return
+		case <-handlers.streamsDone:
+			// Сервер останавливается.
+			return
+		case <-wake:
+			err = send()
+		case <-poll.C:
+			if err = handlers.auth.Recheck(gotRequest); err == nil {
+				err = send()
+			}
+		case <-heartbeat.C:
+			if err = handlers.auth.Recheck(gotRequest); err != nil {
+				break
+			}
+			// Комментарий не виден клиенту, но не даёт прокси закрыть простаивающее соединение.
+			if _, err = fmt.Fprint(responseWriter, ": heartbeat\n\n"); err == nil {
+				flusher.Flush()
+			}
+		}
+	}
+	if ctx.Err() == nil {
+		// Клиент переподключится с последним полученным id и ничего не потеряет.
+		handlers.logger.ZL.Warn("event stream closed", zap.Int("user_id", userID), zap.Error(err))
+	}
+}
+
+// lastEventID - id последнего полученного клиентом уведомления, если он переподключается.
+func lastEventID(gotRequest *http.Request) (id int64, resume bool, err error) {
+	raw := gotRequest.Header.Get("Last-Event-ID")
+	if raw == "" {
+		// EventSource не умеет задавать заголовки при первом подключении.
+		raw = gotRequest.URL.Query().Get("last_event_id")
+	}
+	if raw == "" {
+		return 0, false, nil
+	}
+	id, err = strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, fmt.Errorf("invalid last event id %q", raw)
+	}
+	return id, true, nil
+}
+
+func writeStreamEvent(responseWriter http.ResponseWriter, event models.SecurityEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode security event: %w", err)
+	}
+	if _, err := fmt.Fprintf(responseWriter, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+		return fmt.Errorf("failed to write security event: %w", err)
+	}
+	return nil
+}
+
+func positiveOr(d, fallback time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return fallback
+}
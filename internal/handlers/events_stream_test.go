@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/feed"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newStreamServer поднимает поток за логгером запросов, как в main, от имени пользователя 1.
+func newStreamServer(t *testing.T, f *feed.Feed) *httptest.Server {
+	t.Helper()
+	var opts []Option
+	if f != nil {
+		opts = append(opts, WithFeed(f))
+	}
+	handlers, err := NewHandlers(newMockStorage(), testConfig, testLogger, testAuth, opts...)
+	require.NoError(t, err)
+	withUser := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.EventsStream(w, r.WithContext(context.WithValue(r.Context(), auth.KeyUserIDCtx, 1)))
+	})
+	server := httptest.NewServer(testLogger.RequestLogger(withUser))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func openStream(t *testing.T, server *httptest.Server, lastEventID string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	t.Cleanup(func() { response.Body.Close() })
+	return response, bufio.NewReader(response.Body)
+}
+
+// nextEvent читает из потока следующее событие и возвращает его строки без пустой строки в конце.
+func nextEvent(t *testing.T, reader *bufio.Reader) []string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(lines) > 0 && !strings.HasPrefix(lines[0], "retry:") {
+				return lines
+			}
+			lines = nil
+			continue
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestHandlers_EventsStream(t *testing.T) {
+	ctx := context.Background()
+	f := feed.NewFeed(store.NewSecurityEventLog())
+	server := newStreamServer(t, f)
+	login := func(userID int) {
+		require.NoError(t, f.Publish(ctx, store.NewDomainEvent(models.EventUserLoggedIn, &models.User{ID: userID})))
+	}
+	login(1)
+
+	// Новый поток не повторяет старые уведомления, а новые получает сразу.
+	response, reader := openStream(t, server, "")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+	login(2)
+	require.NoError(t, f.Publish(ctx, store.NewDomainEvent(models.EventUserPasswordChanged, &models.User{ID: 1})))
+	event := nextEvent(t, reader)
+	require.Len(t, event, 3)
+	assert.Equal(t, "id: 3", event[0])
+	assert.Equal(t, "event: user.password_changed", event[1])
+	assert.Contains(t, event[2], `"type":"user.password_changed"`)
+
+	// После обрыва клиент получает пропущенное начиная с Last-Event-ID.
+	_, reader = openStream(t, server, "0")
+	assert.Equal(t, "id: 1", nextEvent(t, reader)[0])
+	assert.Equal(t, "id: 3", nextEvent(t, reader)[0])
+}
+
+func TestHandlers_EventsStreamErrors(t *testing.T) {
+	response, _ := openStream(t, newStreamServer(t, feed.NewFeed(store.NewSecurityEventLog())), "latest")
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+	response, _ = openStream(t, newStreamServer(t, nil), "")
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+}
+
+func TestHandlers_EventsStreamShutdown(t *testing.T) {
+	handlers, err := NewHandlers(newMockStorage(), testConfig, testLogger, testAuth, WithFeed(feed.NewFeed(store.NewSecurityEventLog())))
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.EventsStream(w, r.WithContext(context.WithValue(r.Context(), auth.KeyUserIDCtx, 1)))
+	}))
+	server.Config.RegisterOnShutdown(handlers.StopStreams)
+	server.Start()
+	t.Cleanup(server.Close)
+
+	response, reader := openStream(t, server, "")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	_, err = reader.ReadString('\n') // retry:
+	require.NoError(t, err)
+
+	// Открытый поток не держит остановку сервера до таймаута.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, server.Config.Shutdown(ctx))
+	_, err = io.ReadAll(reader)
+	assert.NoError(t, err, "the stream ends cleanly")
+}
+
+// revocableSource - источник статусов для проверки токенов, в котором сессии можно отозвать из теста.
+type revocableSource struct {
+	mu               sync.Mutex
+	tokensValidAfter time.Time
+}
+
+func (s *revocableSource) GetUserAuthState(ctx context.Context, userID int) (models.UserAuthState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return models.UserAuthState{Status: models.UserStatusActive, TokensValidAfter: s.tokensValidAfter}, nil
+}
+
+func (s *revocableSource) GetAuthStateVersion(ctx context.Context) (int64, error) { return 0, nil }
+func (s *revocableSource) BumpAuthStateVersion(ctx context.Context) error         { return nil }
+
+func TestHandlers_EventsStreamRevoked(t *testing.T) {
+	config := &server_config.ServerConfig{SecretKey: "secret", TokenExp: time.Hour, SSEPollInterval: 10 * time.Millisecond}
+	source := &revocableSource{}
+	authorizer, err := auth.Initialize(config, testLogger, source)
+	require.NoError(t, err)
+	handlers, err := NewHandlers(newMockStorage(), config, testLogger, authorizer, WithFeed(feed.NewFeed(store.NewSecurityEventLog())))
+	require.NoError(t, err)
+	server := httptest.NewServer(authorizer.MiddleCheckAuth(http.HandlerFunc(handlers.EventsStream)))
+	t.Cleanup(server.Close)
+
+	w := httptest.NewRecorder()
+	require.NoError(t, authorizer.SetNewCookie(w, 1, "anna"))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	request.AddCookie(w.Result().Cookies()[0])
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	reader := bufio.NewReader(response.Body)
+	_, err = reader.ReadString('\n') // retry:
+	require.NoError(t, err)
+
+	// Сессии отозваны, пока поток открыт: сервер закрывает его сам, не дожидаясь клиента.
+	source.mu.Lock()
+	source.tokensValidAfter = time.Now().Add(time.Second)
+	source.mu.Unlock()
+	_, err = io.ReadAll(reader)
+	assert.NoError(t, err, "the stream ends instead of hanging until the client timeout")
+	assert.NoError(t, ctx.Err())
+}
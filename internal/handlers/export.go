@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/export"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// exportDownloadPath - путь, по которому отдаются готовые архивы.
+const exportDownloadPath = "/user/export/download/"
+
+// exportResponse - ответ о состоянии выгрузки данных.
+type exportResponse struct {
+	IsError       bool
+	ResultMessage string           `json:"result_message"`
+	Export        models.ExportJob `json:"export"`
+	DownloadURL   string           `json:"download_url,omitempty"`
+}
+
+// RequestExport ставит в очередь выгрузку всех данных о текущем пользователе.
+// Готовность проверяется через GetExport.
+func (handlers *Handlers) RequestExport(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	userID, ok := userIDFromRequest(gotRequest)
+	if !ok {
+		sendResponse(true, "Unauthorized", http.StatusUnauthorized, responseWriter)
+		return
+	}
+	if handlers.exporter == nil {
+		sendResponse(true, "Data export is not available", http.StatusServiceUnavailable, responseWriter)
+		return
+	}
+
+	job, err := handlers.exporter.Request(gotRequest.Context(), userID)
+	if errors.Is(err, export.ErrQueueFull) {
+		responseWriter.Header().Set("Retry-After", "60")
+		sendResponse(true, "Server is busy, please retry later", http.StatusServiceUnavailable, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to request data export", zap.Int("user_id", userID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	sendJSON(exportResponse{ResultMessage: "Export is being prepared", Export: job}, http.StatusAccepted, responseWriter)
+}
+
+// GetExport сообщает состояние последней выгрузки и, если архив готов, подписанную ссылку на него.
+func (handlers *Handlers) GetExport(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	userID, ok := userIDFromRequest(gotRequest)
+	if !ok {
+		sendResponse(true, "Unauthorized", http.StatusUnauthorized, responseWriter)
+		return
+	}
+	if handlers.exporter == nil {
+		sendResponse(true, "Data export is not available", http.StatusServiceUnavailable, responseWriter)
+		return
+	}
+
+	job, err := handlers.exporter.Last(gotRequest.Context(), userID)
+	if errors.Is(err, export.ErrJobNotFound) {
+		sendResponse(true, "No data export requested", http.StatusNotFound, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to get data export", zap.Int("user_id", userID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	response := exportResponse{ResultMessage: "Export is " + string(job.Status), Export: job}
+	if job.Status == models.ExportReady {
+		response.DownloadURL = exportDownloadPath + "?" + handlers.exporter.DownloadQuery(job).Encode()
+	}
+	sendJSON(response, http.StatusOK, responseWriter)
+}
+
+// DownloadExport отдаёт архив по подписанной ссылке. Ссылка сама служит пропуском, кука не нужна.
+func (handlers *Handlers) DownloadExport(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	if handlers.exporter == nil {
+		sendResponse(true, "Data export is not available", http.StatusServiceUnavailable, responseWriter)
+		return
+	}
+
+	archive, err := handlers.exporter.Open(gotRequest.Context(), gotRequest.URL.Query())
+	switch {
+	case errors.Is(err, export.ErrInvalidLink):
+		sendResponse(true, "Invalid download link", http.StatusForbidden, responseWriter)
+		return
+	case errors.Is(err, export.ErrLinkExpired), errors.Is(err, export.ErrJobNotFound):
+		sendResponse(true, "Download link has expired", http.StatusGone, responseWriter)
+		return
+	case err != nil:
+		handlers.logger.ZL.Error("failed to open data export", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/zip")
+	responseWriter.Header().Set("Content-Disposition", `attachment; filename="personal-data.zip"`)
+	responseWriter.Header().Set("Cache-Control", "no-store")
+	responseWriter.WriteHeader(http.StatusOK)
+	if _, err := responseWriter.Write(archive); err != nil {
+		handlers.logger.ZL.Warn("failed to send data export", zap.Error(err))
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/export"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandlers_Export(t *testing.T) {
+	s := newMockStorage()
+	s.users["Petr"] = models.User{ID: 1, Login: "Petr"}
+	c := &server_config.ServerConfig{ExportLinkTTL: time.Hour, SecretKey: "secret"}
+	exporter := export.NewExporter(c, testLogger, s, export.ProfileSection(s))
+	handlers, err := NewHandlers(s, c, testLogger, testAuth, WithExporter(exporter))
+	require.NoError(t, err)
+
+	asUser := func(r *http.Request) *http.Request {
+		return r.WithContext(context.WithValue(r.Context(), auth.KeyUserIDCtx, 1))
+	}
+
+	// До запроса выгрузки её нет.
+	w := httptest.NewRecorder()
+	handlers.GetExport(w, asUser(httptest.NewRequest(http.MethodGet, "/user/export/", nil)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	handlers.RequestExport(w, asUser(httptest.NewRequest(http.MethodPost, "/user/export/", nil)))
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go exporter.Run(ctx)
+
+	var response exportResponse
+	require.Eventually(t, func() bool {
+		w = httptest.NewRecorder()
+		handlers.GetExport(w, asUser(httptest.NewRequest(http.MethodGet, "/user/export/", nil)))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Export.Status == models.ExportReady
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NotEmpty(t, response.DownloadURL)
+
+	// Скачивание по ссылке не требует авторизации.
+	w = httptest.NewRecorder()
+	handlers.DownloadExport(w, httptest.NewRequest(http.MethodGet, response.DownloadURL, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	_, err = zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	assert.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	handlers.DownloadExport(w, httptest.NewRequest(http.MethodGet, response.DownloadURL+"x", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestHandlers_ExportNotConfigured(t *testing.T) {
+	handlers, err := NewHandlers(newMockStorage(), testConfig, testLogger, testAuth)
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodPost, "/user/export/", nil)
+	request = request.WithContext(context.WithValue(request.Context(), auth.KeyUserIDCtx, 1))
+	w := httptest.NewRecorder()
+	handlers.RequestExport(w, request)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
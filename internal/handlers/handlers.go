@@ -1,19 +1,78 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/export"
+	"github.com/eampleev23/raya-backend.git/internal/feed"
+	"github.com/eampleev23/raya-backend.git/internal/ipfilter"
 	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/login_policy"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/notifications"
+	"github.com/eampleev23/raya-backend.git/internal/password_policy"
+	"github.com/eampleev23/raya-backend.git/internal/risk"
 	"github.com/eampleev23/raya-backend.git/internal/server_config"
 	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/eampleev23/raya-backend.git/internal/webhooks"
+	"math"
 	"net/http"
+	"strconv"
+	"sync"
 )
 
 type Handlers struct {
-	store    store.Store
-	servConf *server_config.ServerConfig
-	logger   *logger.ZapLog
-	auth     *auth.Authorizer
+	store          store.Store
+	servConf       *server_config.ServerConfig
+	logger         *logger.ZapLog
+	auth           *auth.Authorizer
+	passwordPolicy *password_policy.Policy
+	loginPolicy    *login_policy.Policy
+	risk           *risk.Assessor
+	ipFilter       *ipfilter.Filter
+	exporter       *export.Exporter
+	webhooks       *webhooks.Dispatcher
+	feed           *feed.Feed
+	notifier       *notifications.Notifier
+
+	// streamsDone закрывается при остановке сервера и завершает потоки событий, которые иначе
+	// держали бы соединения до конца таймаута Shutdown.
+	streamsDone     chan struct{}
+	stopStreamsOnce sync.Once
+}
+
+// Option подключает к хэндлерам необязательные подсистемы.
+type Option func(*Handlers)
+
+// WithExporter включает выгрузку персональных данных.
+func WithExporter(exporter *export.Exporter) Option {
+	return func(h *Handlers) {
+		h.exporter = exporter
+	}
+}
+
+// WithWebhooks включает рассылку событий о пользователях внешним системам.
+func WithWebhooks(dispatcher *webhooks.Dispatcher) Option {
+	return func(h *Handlers) {
+		h.webhooks = dispatcher
+	}
+}
+
+// WithFeed включает поток уведомлений безопасности.
+func WithFeed(f *feed.Feed) Option {
+	return func(h *Handlers) {
+		h.feed = f
+	}
+}
+
+// WithNotifier включает письма с кодом подтверждения входа.
+func WithNotifier(notifier *notifications.Notifier) Option {
+	return func(h *Handlers) {
+		h.notifier = notifier
+	}
 }
 
 func NewHandlers(
@@ -21,19 +80,56 @@ func NewHandlers(
 	servConf *server_config.ServerConfig,
 	logger *logger.ZapLog,
 	auth *auth.Authorizer, // Убрать *
+	opts ...Option,
 ) (*Handlers, error) {
-	return &Handlers{
-		store:    store,
-		servConf: servConf,
-		logger:   logger,
-		auth:     auth,
-	}, nil
+	passwordPolicy, err := password_policy.NewPolicy(servConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create password policy: %w", err)
+	}
+	loginPolicy, err := login_policy.NewPolicy(servConf)
+	if err != nil {
+		passwordPolicy.Close()
+		return nil, fmt.Errorf("failed to create login policy: %w", err)
+	}
+	ipFilter, err := ipfilter.NewFilter(servConf)
+	if err != nil {
+		passwordPolicy.Close()
+		return nil, fmt.Errorf("failed to create ip filter: %w", err)
+	}
+	h := &Handlers{
+		store:          store,
+		servConf:       servConf,
+		logger:         logger,
+		auth:           auth,
+		passwordPolicy: passwordPolicy,
+		loginPolicy:    loginPolicy,
+		risk:           risk.NewAssessor(store, servConf),
+		ipFilter:       ipFilter,
+		streamsDone:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h, nil
+}
+
+// StopStreams завершает открытые и будущие потоки событий. Вызывается в начале остановки сервера,
+// см. http.Server.RegisterOnShutdown; клиенты переподключатся к другому экземпляру с Last-Event-ID.
+func (handlers *Handlers) StopStreams() {
+	handlers.stopStreamsOnce.Do(func() { close(handlers.streamsDone) })
+}
+
+// Close освобождает ресурсы хэндлеров: файл базы утечек паролей.
+func (handlers *Handlers) Close() error {
+	return handlers.passwordPolicy.Close()
 }
 
 // resultMessage - структура для возврата json ответа более детализированного, чем просто статус.
 type resultMsg struct {
 	IsError       bool
-	ResultMessage string `json:"result_message"`
+	ResultMessage string              `json:"result_message"`
+	Code          string              `json:"code,omitempty"` // Машиночитаемая причина ошибки.
+	FieldErrors   []models.FieldError `json:"field_errors,omitempty"`
 }
 
 func sendResponse(
@@ -48,3 +144,92 @@ func sendResponse(
 	responseWriter.Write(msg)
 	return nil
 }
+
+// sendErrorCode отвечает ошибкой с машиночитаемым кодом, по которому клиент выбирает реакцию.
+func sendErrorCode(
+	code string,
+	mg string,
+	statusCode int,
+	responseWriter http.ResponseWriter,
+) (err error) {
+	resultMsg := resultMsg{IsError: true, ResultMessage: mg, Code: code}
+	msg, _ := json.Marshal(resultMsg)
+	responseWriter.WriteHeader(statusCode)
+	responseWriter.Write(msg)
+	return nil
+}
+
+// sendJSON отвечает произвольной моделью в JSON.
+func sendJSON(
+	value any,
+	statusCode int,
+	responseWriter http.ResponseWriter,
+) (err error) {
+	msg, err := json.Marshal(value)
+	if err != nil {
+		return sendResponse(true, "Failed to encode response", http.StatusInternalServerError, responseWriter)
+	}
+	responseWriter.WriteHeader(statusCode)
+	responseWriter.Write(msg)
+	return nil
+}
+
+// userIDFromRequest достаёт ID пользователя, положенный в контекст MiddleCheckAuth.
+func userIDFromRequest(gotRequest *http.Request) (userID int, ok bool) {
+	userID, ok = gotRequest.Context().Value(auth.KeyUserIDCtx).(int)
+	return userID, ok
+}
+
+// sendFieldErrors отвечает ошибкой валидации с перечнем нарушений по полям.
+func sendFieldErrors(
+	mg string,
+	fieldErrors []models.FieldError,
+	statusCode int,
+	responseWriter http.ResponseWriter,
+) (err error) {
+	resultMsg := resultMsg{IsError: true, ResultMessage: mg, FieldErrors: fieldErrors}
+	msg, _ := json.Marshal(resultMsg)
+	responseWriter.WriteHeader(statusCode)
+	responseWriter.Write(msg)
+	return nil
+}
+
+// sendHashingOverloaded отвечает 503, когда очередь на хэширование паролей переполнена.
+// Retry-After подсказывает клиенту, когда очередь успеет разойтись.
+func (handlers *Handlers) sendHashingOverloaded(responseWriter http.ResponseWriter) {
+	retryAfter := int(math.Ceil(handlers.servConf.HashQueueTimeout.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	responseWriter.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	sendResponse(
+		true,
+		"Server is busy, please retry later",
+		http.StatusServiceUnavailable,
+		responseWriter)
+}
+
+// sendStoreError переводит ошибку хранилища в ответ. Доменные ошибки store получают свои статусы,
+// если база не ответила за отведённое время - это 504, если запрос прервался раньше - 503; всё остальное - 500.
+func sendStoreError(err error, responseWriter http.ResponseWriter) {
+	switch {
+	case errors.Is(err, store.ErrUserNotFound):
+		sendResponse(true, "User not found", http.StatusNotFound, responseWriter)
+	case errors.Is(err, store.ErrLoginTaken):
+		sendResponse(true, "User with this login already exists", http.StatusConflict, responseWriter)
+	case errors.Is(err, store.ErrLoginConfusable):
+		sendResponse(true, "Login is too similar to an existing one", http.StatusConflict, responseWriter)
+	case errors.Is(err, store.ErrConflict):
+		sendErrorCode("conflict", "Conflicting change, please retry", http.StatusConflict, responseWriter)
+	case errors.Is(err, store.ErrConstraint):
+		sendErrorCode("constraint_violation", "Change conflicts with existing data", http.StatusConflict, responseWriter)
+	case errors.Is(err, store.ErrUnavailable):
+		sendErrorCode("unavailable", "Storage is temporarily unavailable", http.StatusServiceUnavailable, responseWriter)
+	case errors.Is(err, context.DeadlineExceeded):
+		sendErrorCode("timeout", "Storage did not respond in time", http.StatusGatewayTimeout, responseWriter)
+	case errors.Is(err, context.Canceled):
+		sendErrorCode("canceled", "Request was canceled", http.StatusServiceUnavailable, responseWriter)
+	default:
+		sendResponse(true, "Internal server error", http.StatusInternalServerError, responseWriter)
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendStoreError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{err: store.ErrUserNotFound, wantStatus: http.StatusNotFound},
+		{err: store.ErrLoginTaken, wantStatus: http.StatusConflict},
+		{err: store.ErrLoginConfusable, wantStatus: http.StatusConflict},
+		{err: fmt.Errorf("failed to set user role: %w", store.ErrConflict), wantStatus: http.StatusConflict, wantCode: "conflict"},
+		{err: fmt.Errorf("failed to create ip rule: %w", store.ErrConstraint), wantStatus: http.StatusConflict, wantCode: "constraint_violation"},
+		{err: fmt.Errorf("failed to list users: %w", store.ErrUnavailable), wantStatus: http.StatusServiceUnavailable, wantCode: "unavailable"},
+		{err: context.DeadlineExceeded, wantStatus: http.StatusGatewayTimeout, wantCode: "timeout"},
+		{err: context.Canceled, wantStatus: http.StatusServiceUnavailable, wantCode: "canceled"},
+		{err: errors.New("unexpected"), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			sendStoreError(tt.err, w)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var response resultMsg
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.True(t, response.IsError)
+			assert.Equal(t, tt.wantCode, response.Code)
+		})
+	}
+}
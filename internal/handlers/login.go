@@ -2,9 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/eampleev23/raya-backend.git/internal/models"
 	"github.com/eampleev23/raya-backend.git/internal/store"
+	"go.uber.org/zap"
 	"net/http"
+	"strings"
 )
 
 /*
@@ -44,7 +47,15 @@ func (handlers *Handlers) Login(responseWriter http.ResponseWriter, gotRequest *
 	}
 
 	foundUser, err := handlers.store.GetUserByLogin(gotRequest.Context(), userLoginReq)
+	if err != nil && !errors.Is(err, store.ErrUserNotFound) {
+		handlers.logger.ZL.Error("failed to get user by login", zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
 	if err != nil {
+		handlers.recordAudit(gotRequest, models.AuditEvent{
+			Type: models.AuditLogin, Result: models.AuditFailure, Reason: "user_not_found",
+		})
 		sendResponse(
 			true,
 			"User with this login does not exist",
@@ -53,17 +64,40 @@ func (handlers *Handlers) Login(responseWriter http.ResponseWriter, gotRequest *
 		return
 	}
 
-	isCorrectPassword, err := store.VerifyPassword(userLoginReq.Password, foundUser.PasswordHash)
+	// Адреса проверяются до пароля: из закрытой сети подбирать его бесполезно.
+	authState, err := handlers.store.GetUserAuthState(gotRequest.Context(), foundUser.ID)
 	if err != nil {
-		sendResponse(
-			true,
-			"Internal server error",
-			http.StatusInternalServerError,
+		handlers.logger.ZL.Error("failed to get account auth state", zap.Int("userID", foundUser.ID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	if !handlers.ipFilter.Allowed(handlers.clientIP(gotRequest), authState.IPRules) {
+		handlers.recordAudit(gotRequest, models.AuditEvent{
+			Type: models.AuditLogin, TargetID: userRef(foundUser.ID), Result: models.AuditFailure, Reason: "ip_not_allowed",
+		})
+		sendErrorCode(
+			"ip_not_allowed",
+			"Login from this address is not allowed",
+			http.StatusForbidden,
 			responseWriter)
 		return
 	}
 
+	isCorrectPassword, err := handlers.store.VerifyPassword(gotRequest.Context(), userLoginReq.Password, foundUser.PasswordHash)
+	if errors.Is(err, store.ErrHashingOverloaded) {
+		handlers.sendHashingOverloaded(responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to verify password", zap.Int("userID", foundUser.ID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+
 	if !isCorrectPassword {
+		handlers.recordAudit(gotRequest, models.AuditEvent{
+			Type: models.AuditLogin, TargetID: userRef(foundUser.ID), Result: models.AuditFailure, Reason: "wrong_password",
+		})
 		sendResponse(
 			true,
 			"The passwords don't match",
@@ -72,6 +106,52 @@ func (handlers *Handlers) Login(responseWriter http.ResponseWriter, gotRequest *
 		return
 	}
 
+	// Пароль верный, но войти может только активный пользователь.
+	if foundUser.Status != models.UserStatusActive {
+		handlers.recordAudit(gotRequest, models.AuditEvent{
+			Type: models.AuditLogin, TargetID: userRef(foundUser.ID), Result: models.AuditFailure, Reason: foundUser.Status.ErrorCode(),
+		})
+		sendErrorCode(
+			foundUser.Status.ErrorCode(),
+			"Account is "+string(foundUser.Status),
+			http.StatusForbidden,
+			responseWriter)
+		return
+	}
+
+	// Вход с незнакомого устройства или после серии неверных паролей может потребовать код из письма или быть отклонён.
+	// Если оценить риск не удалось, вход не задерживается: хранилище, скорее всего, отказало целиком.
+	loginRisk, err := handlers.risk.Assess(gotRequest.Context(), foundUser.ID, handlers.clientIP(gotRequest), gotRequest.UserAgent())
+	if err != nil {
+		handlers.logger.ZL.Error("failed to assess login risk", zap.Int("userID", foundUser.ID), zap.Error(err))
+		loginRisk.Decision = models.LoginAllow
+	}
+	switch loginRisk.Decision {
+	case models.LoginBlock:
+		handlers.recordAudit(gotRequest, models.AuditEvent{
+			Type: models.AuditLogin, TargetID: userRef(foundUser.ID), Result: models.AuditFailure, Reason: "risk_blocked",
+		})
+		sendErrorCode(
+			"login_blocked",
+			"Login from this device is not allowed",
+			http.StatusForbidden,
+			responseWriter)
+		return
+	case models.LoginStepUp:
+		reason, ok := handlers.stepUp(responseWriter, gotRequest, foundUser, userLoginReq.Code, loginRisk.Device)
+		if !ok {
+			return
+		}
+		loginRisk.Reasons = append(loginRisk.Reasons, reason)
+	}
+
+	// Хэш посчитан со старыми параметрами или прежним перцем - пересчитываем, пока знаем пароль.
+	if handlers.store.NeedsRehash(foundUser.PasswordHash) {
+		if err := handlers.store.RehashPassword(gotRequest.Context(), foundUser.ID, userLoginReq.Password); err != nil {
+			handlers.logger.ZL.Warn("failed to upgrade password hash", zap.Int("userID", foundUser.ID), zap.Error(err))
+		}
+	}
+
 	err = handlers.auth.SetNewCookie(responseWriter, foundUser.ID, foundUser.Login)
 	if err != nil {
 		sendResponse(
@@ -82,6 +162,17 @@ func (handlers *Handlers) Login(responseWriter http.ResponseWriter, gotRequest *
 		return
 	}
 
+	if _, err := handlers.store.TouchDevice(gotRequest.Context(), loginRisk.Device); err != nil {
+		handlers.logger.ZL.Warn("failed to remember device", zap.Int("userID", foundUser.ID), zap.Error(err))
+	}
+	handlers.recordAudit(gotRequest, models.AuditEvent{
+		Type: models.AuditLogin, ActorID: userRef(foundUser.ID), TargetID: userRef(foundUser.ID), Result: models.AuditSuccess,
+		Reason: strings.Join(loginRisk.Reasons, ","),
+	})
+	handlers.publishEvent(gotRequest, models.EventUserLoggedIn, foundUser)
+	if loginRisk.Decision == models.LoginNotify || loginRisk.Decision == models.LoginStepUp {
+		handlers.publishEvent(gotRequest, models.EventUserNewDeviceLogin, foundUser)
+	}
 	sendResponse(
 		false,
 		"Successfully logged in",
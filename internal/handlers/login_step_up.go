@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"go.uber.org/zap"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// defaultStepUpCodeTTL - срок кода подтверждения, если в конфигурации он не задан.
+const defaultStepUpCodeTTL = 10 * time.Minute
+
+// stepUp подтверждает кодом из письма вход с устройства, которому оценка риска не доверяет. Вход без кода
+// получает новый код на почту, вход с кодом - сверку, а без почты для кода вход отклоняется.
+// ok ложно, если ответ клиенту уже отправлен; reason дописывается к причинам успешного входа в журнале.
+func (handlers *Handlers) stepUp(
+	responseWriter http.ResponseWriter,
+	gotRequest *http.Request,
+	user *models.User,
+	code string,
+	device models.Device,
+) (reason string, ok bool) {
+	if handlers.notifier == nil || user.Email == "" {
+		// Код отправить некуда. Пропустить вход значило бы не проверять рискованные входы вовсе
+		// у всех, кто не указал почту, поэтому вход отклоняется: сначала нужно войти с известного устройства
+		// и указать почту в профиле.
+		handlers.logger.ZL.Warn("login step up is not possible without email", zap.Int("user_id", user.ID))
+		handlers.recordAudit(gotRequest, models.AuditEvent{
+			Type: models.AuditLogin, TargetID: userRef(user.ID), Result: models.AuditFailure, Reason: "step_up_unavailable",
+		})
+		sendErrorCode(
+			"step_up_unavailable",
+			"Login from this device needs a confirmation code, but the account has no email to send it to",
+			http.StatusForbidden,
+			responseWriter)
+		return "", false
+	}
+	ctx := gotRequest.Context()
+	now := time.Now()
+	challenge := models.LoginChallenge{UserID: user.ID, ClientFamily: device.ClientFamily, Subnet: device.Subnet}
+
+	if code != "" {
+		challenge.CodeHash = hashLoginCode(code)
+		passed, err := handlers.store.CheckLoginChallenge(ctx, challenge, now)
+		if err != nil {
+			handlers.logger.ZL.Error("failed to check login code", zap.Int("user_id", user.ID), zap.Error(err))
+			sendStoreError(err, responseWriter)
+			return "", false
+		}
+		if passed {
+			return "step_up_passed", true
+		}
+		handlers.recordAudit(gotRequest, models.AuditEvent{
+			Type: models.AuditLogin, TargetID: userRef(user.ID), Result: models.AuditFailure, Reason: "wrong_step_up_code",
+		})
+		sendErrorCode(
+			"invalid_step_up_code",
+			"The confirmation code is wrong or expired",
+			http.StatusUnauthorized,
+			responseWriter)
+		return "", false
+	}
+
+	code, err := newLoginCode()
+	if err != nil {
+		handlers.logger.ZL.Error("failed to generate login code", zap.Error(err))
+		sendResponse(true, "Internal server error", http.StatusInternalServerError, responseWriter)
+		return "", false
+	}
+	ttl := handlers.servConf.StepUpCodeTTL
+	if ttl <= 0 {
+		ttl = defaultStepUpCodeTTL
+	}
+	challenge.CodeHash = hashLoginCode(code)
+	challenge.ExpiresAt = now.Add(ttl)
+	if err := handlers.store.PutLoginChallenge(ctx, challenge, now); err != nil {
+		handlers.logger.ZL.Error("failed to save login code", zap.Int("user_id", user.ID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return "", false
+	}
+	if _, err := handlers.notifier.SendLoginCode(ctx, user.ID, code, device, challenge.ExpiresAt); err != nil {
+		handlers.logger.ZL.Error("failed to send login code", zap.Int("user_id", user.ID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return "", false
+	}
+	handlers.recordAudit(gotRequest, models.AuditEvent{
+		Type: models.AuditLogin, TargetID: userRef(user.ID), Result: models.AuditFailure, Reason: "step_up_required",
+	})
+	sendErrorCode(
+		"step_up_required",
+		"A confirmation code has been sent to your email",
+		http.StatusUnauthorized,
+		responseWriter)
+	return "", false
+}
+
+// newLoginCode возвращает случайный код из шести цифр.
+func newLoginCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashLoginCode - под каким хэшем код хранится. Соль не нужна: код живёт минуты и число попыток ограничено.
+func hashLoginCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,8 +2,13 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/notifications"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
 	"github.com/eampleev23/raya-backend.git/internal/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -11,6 +16,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestHandlers_Login(t *testing.T) {
@@ -28,6 +34,7 @@ func TestHandlers_Login(t *testing.T) {
 		requestUrl  string
 		requestBody interface{}
 		tableUsers  map[string]models.User
+		wantRehash  bool
 		want        want
 	}{
 		{
@@ -52,6 +59,29 @@ func TestHandlers_Login(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:       "Test outdated hash is upgraded on login",
+			requestUrl: "/api/user/login/",
+			requestBody: models.UserLoginReq{
+				Login:    "Petr",
+				Password: "correctPassword",
+			},
+			tableUsers: map[string]models.User{
+				"Petr": {
+					ID:           1,
+					Login:        "Petr",
+					PasswordHash: "$argon2id$v=19$m=16,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA",
+				},
+			},
+			wantRehash: true,
+			want: want{
+				statusCode: http.StatusOK,
+				jsonResponse: resultMsg{
+					IsError:       false,
+					ResultMessage: "Successfully logged in",
+				},
+			},
+		},
 		{
 			name:        "Test invalid request body",
 			requestUrl:  "/api/user/login/",
@@ -122,6 +152,52 @@ func TestHandlers_Login(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:       "Test suspended account",
+			requestUrl: "/api/user/login/",
+			requestBody: models.UserLoginReq{
+				Login:    "Petr",
+				Password: "correctPassword",
+			},
+			tableUsers: map[string]models.User{
+				"Petr": {
+					ID:           1,
+					Login:        "Petr",
+					PasswordHash: hashedPassword,
+					Status:       models.UserStatusSuspended,
+				},
+			},
+			want: want{
+				statusCode: http.StatusForbidden,
+				jsonResponse: resultMsg{
+					IsError:       true,
+					ResultMessage: "Account is suspended",
+					Code:          "account_suspended",
+				},
+			},
+		},
+		{
+			name:       "Test hashing queue overloaded",
+			requestUrl: "/api/user/login/",
+			requestBody: models.UserLoginReq{
+				Login:    "Petr",
+				Password: "busyPassword",
+			},
+			tableUsers: map[string]models.User{
+				"Petr": {
+					ID:           1,
+					Login:        "Petr",
+					PasswordHash: hashedPassword,
+				},
+			},
+			want: want{
+				statusCode: http.StatusServiceUnavailable,
+				jsonResponse: resultMsg{
+					IsError:       true,
+					ResultMessage: "Server is busy, please retry later",
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -139,17 +215,6 @@ func TestHandlers_Login(t *testing.T) {
 			s := newMockStorage()
 			s.users = tt.tableUsers
 
-			// Мокаем функцию VerifyPassword
-			originalVerifyPassword := store.VerifyPassword
-			defer func() { store.VerifyPassword = originalVerifyPassword }()
-			store.VerifyPassword = func(password, hash string) (bool, error) {
-				// В реальном приложении здесь должна быть правильная проверка хэша
-				if password == "correctPassword" {
-					return true, nil
-				}
-				return false, nil
-			}
-
 			handlers, err := NewHandlers(s, testConfig, testLogger, testAuth)
 			require.NoError(t, err)
 			h := http.HandlerFunc(handlers.Login)
@@ -158,6 +223,14 @@ func TestHandlers_Login(t *testing.T) {
 			result := w.Result()
 
 			assert.Equal(t, tt.want.statusCode, result.StatusCode)
+			if tt.wantRehash {
+				assert.Equal(t, []int{1}, s.updated)
+			} else {
+				assert.Empty(t, s.updated)
+			}
+			if tt.want.statusCode == http.StatusServiceUnavailable {
+				assert.NotEmpty(t, result.Header.Get("Retry-After"))
+			}
 
 			bytesJsonResponse, err := io.ReadAll(result.Body)
 			require.NoError(t, err)
@@ -172,3 +245,194 @@ func TestHandlers_Login(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlers_LoginRisk(t *testing.T) {
+	const (
+		knownUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+		newUserAgent   = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	)
+	riskConfig := &server_config.ServerConfig{RiskNotifyScore: 30, RiskStepUpScore: 60, RiskBlockScore: 90, RiskFailureWindow: time.Hour}
+
+	tests := []struct {
+		name        string
+		email       string
+		remoteAddr  string
+		userAgent   string
+		failures    int
+		wantStatus  int
+		wantCode    string
+		wantDevices int
+		wantEvents  []models.DomainEventType
+	}{
+		{
+			name:       "Test known device",
+			remoteAddr: "192.0.2.10:5000", userAgent: knownUserAgent,
+			wantStatus: http.StatusOK, wantDevices: 1,
+			wantEvents: []models.DomainEventType{models.EventUserLoggedIn},
+		},
+		{
+			name:       "Test new subnet is allowed with a notification",
+			remoteAddr: "198.51.100.10:5000", userAgent: knownUserAgent,
+			wantStatus: http.StatusOK, wantDevices: 2,
+			wantEvents: []models.DomainEventType{models.EventUserLoggedIn, models.EventUserNewDeviceLogin},
+		},
+		{
+			name:       "Test new device requires step up",
+			email:      "petr@example.com",
+			remoteAddr: "198.51.100.10:5000", userAgent: newUserAgent,
+			wantStatus: http.StatusUnauthorized, wantCode: "step_up_required", wantDevices: 1,
+		},
+		{
+			name:       "Test new device without email is refused",
+			remoteAddr: "198.51.100.10:5000", userAgent: newUserAgent,
+			wantStatus: http.StatusForbidden, wantCode: "step_up_unavailable", wantDevices: 1,
+		},
+		{
+			name:       "Test new device after failures is blocked",
+			remoteAddr: "198.51.100.10:5000", userAgent: newUserAgent, failures: 3,
+			wantStatus: http.StatusForbidden, wantCode: "login_blocked", wantDevices: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMockStorage()
+			s.users["Petr"] = models.User{ID: 1, Login: "Petr", Email: tt.email, PasswordHash: "password"}
+			_, err := s.TouchDevice(context.Background(), models.Device{
+				UserID: 1, ClientFamily: "Firefox/Linux", Subnet: "192.0.2.0/24", LastSeenAt: time.Now(),
+			})
+			require.NoError(t, err)
+			notifier, err := notifications.NewNotifier(s, riskConfig, testLogger, notifications.NewMemoryTransport())
+			require.NoError(t, err)
+
+			handlers, err := NewHandlers(s, riskConfig, testLogger, testAuth, WithNotifier(notifier))
+			require.NoError(t, err)
+			login := func(password string) *httptest.ResponseRecorder {
+				body, _ := json.Marshal(models.UserLoginReq{Login: "Petr", Password: password})
+				request := httptest.NewRequest(http.MethodPost, "/api/user/login/", bytes.NewBuffer(body))
+				request.RemoteAddr = tt.remoteAddr
+				request.Header.Set("User-Agent", tt.userAgent)
+				w := httptest.NewRecorder()
+				handlers.Login(w, request)
+				return w
+			}
+			for i := 0; i < tt.failures; i++ {
+				require.Equal(t, http.StatusUnauthorized, login("wrongPassword").Code)
+			}
+
+			w := login("correctPassword")
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var response resultMsg
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.wantCode, response.Code)
+			assert.Equal(t, tt.wantStatus == http.StatusOK, w.Header().Get("Set-Cookie") != "")
+
+			devices, err := s.ListDevices(context.Background(), 1)
+			require.NoError(t, err)
+			assert.Len(t, devices, tt.wantDevices)
+			var events []models.DomainEventType
+			for _, event := range s.OutboxEvents() {
+				events = append(events, event.Event.Type)
+			}
+			assert.Equal(t, tt.wantEvents, events)
+		})
+	}
+}
+
+func TestHandlers_LoginStepUp(t *testing.T) {
+	const newUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	riskConfig := &server_config.ServerConfig{RiskStepUpScore: 60, StepUpCodeTTL: time.Minute}
+	s := newMockStorage()
+	s.users["Petr"] = models.User{ID: 1, Login: "Petr", Email: "petr@example.com", PasswordHash: "password"}
+	_, err := s.TouchDevice(context.Background(), models.Device{
+		UserID: 1, ClientFamily: "Firefox/Linux", Subnet: "192.0.2.0/24", LastSeenAt: time.Now(),
+	})
+	require.NoError(t, err)
+	notifier, err := notifications.NewNotifier(s, riskConfig, testLogger, notifications.NewMemoryTransport())
+	require.NoError(t, err)
+	handlers, err := NewHandlers(s, riskConfig, testLogger, testAuth, WithNotifier(notifier))
+	require.NoError(t, err)
+
+	login := func(code string) (int, string) {
+		body, _ := json.Marshal(models.UserLoginReq{Login: "Petr", Password: "correctPassword", Code: code})
+		request := httptest.NewRequest(http.MethodPost, "/api/user/login/", bytes.NewBuffer(body))
+		request.RemoteAddr = "198.51.100.10:5000"
+		request.Header.Set("User-Agent", newUserAgent)
+		w := httptest.NewRecorder()
+		handlers.Login(w, request)
+		var response resultMsg
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response.Code
+	}
+
+	status, code := login("")
+	require.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "step_up_required", code)
+	sent := s.Notifications()
+	require.Len(t, sent, 1)
+	assert.Equal(t, models.NotificationLoginCode, sent[0].Kind)
+	assert.Equal(t, "petr@example.com", sent[0].Recipient)
+	loginCode := sent[0].Data["Code"]
+	assert.Len(t, loginCode, 6)
+
+	status, code = login("not-the-code")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "invalid_step_up_code", code)
+
+	status, _ = login(loginCode)
+	require.Equal(t, http.StatusOK, status)
+	devices, err := s.ListDevices(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Len(t, devices, 2, "the confirmed device is remembered")
+
+	// Устройство запомнено - следующий вход с него обходится без кода.
+	status, _ = login("")
+	assert.Equal(t, http.StatusOK, status)
+	status, code = login(loginCode)
+	assert.Equal(t, http.StatusOK, status, "a code is not needed any more: %s", code)
+	assert.Len(t, s.Notifications(), 1)
+}
+
+func TestHandlers_LoginAuthStateError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "storage is down", err: store.ErrUnavailable, wantStatus: http.StatusServiceUnavailable, wantCode: "unavailable"},
+		{name: "storage is slow", err: context.DeadlineExceeded, wantStatus: http.StatusGatewayTimeout, wantCode: "timeout"},
+		{name: "unexpected error", err: errors.New("boom"), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMockStorage()
+			s.users["Petr"] = models.User{ID: 1, Login: "Petr", PasswordHash: "password"}
+			s.authStateErr = fmt.Errorf("failed to get auth state: %w", tt.err)
+			handlers, err := NewHandlers(s, testConfig, testLogger, testAuth)
+			require.NoError(t, err)
+
+			body, _ := json.Marshal(models.UserLoginReq{Login: "Petr", Password: "correctPassword"})
+			w := httptest.NewRecorder()
+			handlers.Login(w, httptest.NewRequest(http.MethodPost, "/api/user/login/", bytes.NewBuffer(body)))
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var response resultMsg
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.wantCode, response.Code)
+		})
+	}
+}
+
+func TestHandlers_LoginVerifyPasswordError(t *testing.T) {
+	s := newMockStorage()
+	s.users["Petr"] = models.User{ID: 1, Login: "Petr", PasswordHash: "password"}
+	handlers, err := NewHandlers(s, testConfig, testLogger, testAuth)
+	require.NoError(t, err)
+
+	body, _ := json.Marshal(models.UserLoginReq{Login: "Petr", Password: "unavailablePassword"})
+	w := httptest.NewRecorder()
+	handlers.Login(w, httptest.NewRequest(http.MethodPost, "/api/user/login/", bytes.NewBuffer(body)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var response resultMsg
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "unavailable", response.Code)
+}
@@ -1,11 +1,19 @@
 package handlers
 
-import "net/http"
+import (
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"net/http"
+)
 
 func (handlers *Handlers) Logout(responseWriter http.ResponseWriter, gotRequest *http.Request) {
 
 	handlers.logger.ZL.Debug("Logout handler started successfully")
 	handlers.auth.Logout(responseWriter)
+	if userID, ok := userIDFromRequest(gotRequest); ok {
+		handlers.recordAudit(gotRequest, models.AuditEvent{
+			Type: models.AuditLogout, ActorID: userRef(userID), TargetID: userRef(userID), Result: models.AuditSuccess,
+		})
+	}
 	sendResponse(
 		false,
 		"Logged out successfully",
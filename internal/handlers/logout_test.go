@@ -16,7 +16,7 @@ func TestHandlers_Logout(t *testing.T) {
 	store := newMockStorage()
 	servConf := newMockServerConfig()
 	logger, _ := logger.NewZapLogger("info")
-	auth, _ := auth.Initialize(servConf, logger)
+	auth, _ := auth.Initialize(servConf, logger, nil)
 
 	// Создание хэндлера
 	h, err := NewHandlers(store, servConf, logger, auth)
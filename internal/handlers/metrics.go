@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+)
+
+// hiddenMetrics - стандартные переменные expvar, которые наружу не отдаём: в cmdline видны флаги запуска
+// вместе с секретом токенов и паролем в DSN, а memstats раскрывает лишнее о процессе.
+var hiddenMetrics = map[string]bool{
+	"cmdline":  true,
+	"memstats": true,
+}
+
+// AdminMetrics отдаёт метрики сервиса (очередь хэширования паролей, пул соединений с базой, отказы
+// ограничителя частоты и т.п.) в формате expvar, без стандартных переменных из hiddenMetrics.
+func (handlers *Handlers) AdminMetrics(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+	responseWriter.WriteHeader(http.StatusOK)
+	fmt.Fprint(responseWriter, "{")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if hiddenMetrics[kv.Key] {
+			return
+		}
+		if !first {
+			fmt.Fprint(responseWriter, ",")
+		}
+		first = false
+		fmt.Fprintf(responseWriter, "%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprint(responseWriter, "}")
+}
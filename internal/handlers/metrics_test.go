@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"encoding/json"
+	"expvar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlers_AdminMetrics(t *testing.T) {
+	expvar.NewInt("metrics_test_counter").Set(3)
+	handlers, err := NewHandlers(newMockStorage(), testConfig, testLogger, testAuth)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handlers.AdminMetrics(w, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var metrics map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	assert.JSONEq(t, "3", string(metrics["metrics_test_counter"]))
+	assert.NotContains(t, metrics, "cmdline", "command line carries the token secret and the DSN")
+	assert.NotContains(t, metrics, "memstats")
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"go.uber.org/zap"
+	"net/http"
+	"sort"
+)
+
+// notificationPreferencesResponse - подписки пользователя на письма.
+type notificationPreferencesResponse struct {
+	Preferences []models.NotificationPreference `json:"preferences"`
+}
+
+// GetNotificationPreferences отдаёт, на какие письма подписан текущий пользователь.
+func (handlers *Handlers) GetNotificationPreferences(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+	userID, ok := userIDFromRequest(gotRequest)
+	if !ok {
+		sendResponse(true, "Unauthorized", http.StatusUnauthorized, responseWriter)
+		return
+	}
+	handlers.sendNotificationPreferences(responseWriter, gotRequest, userID)
+}
+
+/*
+PatchNotificationPreferences подписывает пользователя на письма или отписывает от них.
+Виды, которых нет в запросе, не меняются:
+
+	{
+	    "welcome": false,
+	    "new_device_login": true
+	}
+*/
+func (handlers *Handlers) PatchNotificationPreferences(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+	userID, ok := userIDFromRequest(gotRequest)
+	if !ok {
+		sendResponse(true, "Unauthorized", http.StatusUnauthorized, responseWriter)
+		return
+	}
+	var patch map[models.NotificationKind]bool
+	if err := json.NewDecoder(gotRequest.Body).Decode(&patch); err != nil || len(patch) == 0 {
+		sendResponse(true, "Not a valid notification preferences request", http.StatusBadRequest, responseWriter)
+		return
+	}
+	kinds := make([]models.NotificationKind, 0, len(patch))
+	var fieldErrors []models.FieldError
+	for kind, enabled := range patch {
+		switch {
+		case !kind.Valid():
+			fieldErrors = append(fieldErrors, models.FieldError{Field: string(kind), Code: "unknown", Message: "Unknown notification kind"})
+		case !kind.Optional() && !enabled:
+			fieldErrors = append(fieldErrors, models.FieldError{Field: string(kind), Code: "required", Message: "These emails can not be turned off"})
+		}
+		kinds = append(kinds, kind)
+	}
+	if len(fieldErrors) > 0 {
+		sort.Slice(fieldErrors, func(i, j int) bool { return fieldErrors[i].Field < fieldErrors[j].Field })
+		sendFieldErrors("Notification preferences are not valid", fieldErrors, http.StatusBadRequest, responseWriter)
+		return
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	for _, kind := range kinds {
+		if err := handlers.store.SetNotificationOptOut(gotRequest.Context(), userID, kind, !patch[kind]); err != nil {
+			handlers.logger.ZL.Error("failed to set notification preference", zap.Int("user_id", userID), zap.Error(err))
+			sendStoreError(err, responseWriter)
+			return
+		}
+	}
+	handlers.sendNotificationPreferences(responseWriter, gotRequest, userID)
+}
+
+func (handlers *Handlers) sendNotificationPreferences(responseWriter http.ResponseWriter, gotRequest *http.Request, userID int) {
+	optOuts, err := handlers.store.GetNotificationOptOuts(gotRequest.Context(), userID)
+	if err != nil {
+		handlers.logger.ZL.Error("failed to get notification preferences", zap.Int("user_id", userID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	optedOut := make(map[models.NotificationKind]bool, len(optOuts))
+	for _, kind := range optOuts {
+		optedOut[kind] = true
+	}
+	response := notificationPreferencesResponse{Preferences: make([]models.NotificationPreference, 0, len(models.NotificationKinds))}
+	for _, kind := range models.NotificationKinds {
+		response.Preferences = append(response.Preferences, models.NotificationPreference{
+			Kind:     kind,
+			Enabled:  !kind.Optional() || !optedOut[kind],
+			Required: !kind.Optional(),
+		})
+	}
+	sendJSON(response, http.StatusOK, responseWriter)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlers_PatchNotificationPreferences(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantEnabled map[models.NotificationKind]bool
+		wantFields  []string
+	}{
+		{
+			name:       "Test opt out and back in",
+			body:       `{"welcome": false, "new_device_login": true}`,
+			wantStatus: http.StatusOK,
+			wantEnabled: map[models.NotificationKind]bool{
+				models.NotificationWelcome:         false,
+				models.NotificationNewDeviceLogin:  true,
+				models.NotificationPasswordChanged: false,
+				models.NotificationLoginCode:       true,
+			},
+		},
+		{name: "Test required kind", body: `{"login_code": false}`, wantStatus: http.StatusBadRequest, wantFields: []string{"login_code"}},
+		{name: "Test unknown kind", body: `{"sms": false, "welcome": false}`, wantStatus: http.StatusBadRequest, wantFields: []string{"sms"}},
+		{name: "Test empty request", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "Test not json", body: `welcome`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMockStorage()
+			ctx := context.WithValue(context.Background(), auth.KeyUserIDCtx, 1)
+			require.NoError(t, s.SetNotificationOptOut(ctx, 1, models.NotificationPasswordChanged, true))
+			require.NoError(t, s.SetNotificationOptOut(ctx, 1, models.NotificationNewDeviceLogin, true))
+			handlers, err := NewHandlers(s, testConfig, testLogger, testAuth)
+			require.NoError(t, err)
+
+			request := httptest.NewRequest(http.MethodPatch, "/api/user/notifications/", bytes.NewBufferString(tt.body)).WithContext(ctx)
+			w := httptest.NewRecorder()
+			handlers.PatchNotificationPreferences(w, request)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				var response notificationPreferencesResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				require.Len(t, response.Preferences, len(models.NotificationKinds))
+				for _, preference := range response.Preferences {
+					assert.Equal(t, tt.wantEnabled[preference.Kind], preference.Enabled, preference.Kind)
+					assert.Equal(t, preference.Kind == models.NotificationLoginCode, preference.Required)
+				}
+				return
+			}
+			var response resultMsg
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			var fields []string
+			for _, fieldError := range response.FieldErrors {
+				fields = append(fields, fieldError.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+			optOuts, err := s.GetNotificationOptOuts(ctx, 1)
+			require.NoError(t, err)
+			assert.Len(t, optOuts, 2, "invalid request changes nothing")
+		})
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/eampleev23/raya-backend.git/internal/models"
-	"github.com/jackc/pgerrcode"
-	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"go.uber.org/zap"
 	"net/http"
 )
 
@@ -43,20 +43,47 @@ func (handlers *Handlers) Registration(responseWriter http.ResponseWriter, gotRe
 		return
 	}
 
+	// Проверяем логин и пароль на соответствие политикам.
+	fieldErrors := handlers.validateCredentials(userRegRequest.Login, userRegRequest.Password)
+	if len(fieldErrors) > 0 {
+		handlers.recordAudit(gotRequest, models.AuditEvent{
+			Type: models.AuditRegistration, Result: models.AuditFailure, Reason: "policy_violation",
+		})
+		sendFieldErrors(
+			"Login or password does not satisfy the policy",
+			fieldErrors,
+			http.StatusBadRequest,
+			responseWriter)
+		return
+	}
+
 	// Спарсили, пробуем зарегистрировать нового пользователя.
 	newUser, err := handlers.store.CreateUser(gotRequest.Context(), userRegRequest)
+	if errors.Is(err, store.ErrHashingOverloaded) {
+		handlers.sendHashingOverloaded(responseWriter)
+		return
+	}
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
-			sendResponse(
-				true,
-				"User with this login already exists",
-				http.StatusConflict,
-				responseWriter)
-			return
+		switch {
+		case errors.Is(err, store.ErrLoginConfusable):
+			handlers.recordAudit(gotRequest, models.AuditEvent{
+				Type: models.AuditRegistration, Result: models.AuditFailure, Reason: "login_confusable",
+			})
+		case errors.Is(err, store.ErrLoginTaken):
+			handlers.recordAudit(gotRequest, models.AuditEvent{
+				Type: models.AuditRegistration, Result: models.AuditFailure, Reason: "login_taken",
+			})
+		default:
+			handlers.logger.ZL.Error("failed to create user", zap.Error(err))
 		}
+		sendStoreError(err, responseWriter)
+		return
 	}
 
+	handlers.recordAudit(gotRequest, models.AuditEvent{
+		Type: models.AuditRegistration, ActorID: userRef(newUser.ID), TargetID: userRef(newUser.ID), Result: models.AuditSuccess,
+	})
+
 	// Зарегистрировали, авторизуем сразу на лету.
 	err = handlers.auth.SetNewCookie(responseWriter, newUser.ID, newUser.Login)
 	if err != nil {
@@ -74,3 +101,14 @@ func (handlers *Handlers) Registration(responseWriter http.ResponseWriter, gotRe
 		http.StatusOK,
 		responseWriter)
 }
+
+// validateCredentials проверяет логин и пароль по политикам и возвращает все нарушения.
+func (handlers *Handlers) validateCredentials(login, password string) []models.FieldError {
+	fieldErrors := handlers.loginPolicy.Validate(login)
+	passwordErrors, err := handlers.passwordPolicy.Validate(login, password)
+	if err != nil {
+		// База утечек недоступна - не блокируем регистрацию, но сообщаем в лог.
+		handlers.logger.ZL.Warn("failed to check password against breached corpus", zap.Error(err))
+	}
+	return append(fieldErrors, passwordErrors...)
+}
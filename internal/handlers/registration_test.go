@@ -3,7 +3,10 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io"
@@ -26,6 +29,7 @@ func TestHandlers_Registration(t *testing.T) {
 		requestUrl  string
 		requestBody interface{}
 		tableUsers  map[string]models.User
+		storeErr    error
 		want        want
 	}{
 		{
@@ -96,6 +100,75 @@ func TestHandlers_Registration(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:       "Test confusable login",
+			requestUrl: "/api/user/registration/",
+			requestBody: models.UserRegReq{
+				Login:    "Реtr",
+				Password: "petrPass",
+			},
+			tableUsers: map[string]models.User{},
+			storeErr:   store.ErrLoginConfusable,
+			want: want{
+				statusCode: http.StatusConflict,
+				jsonResponse: resultMsg{
+					IsError:       true,
+					ResultMessage: "Login is too similar to an existing one",
+				},
+			},
+		},
+		{
+			name:       "Test status http.StatusServiceUnavailable",
+			requestUrl: "/api/user/registration/",
+			requestBody: models.UserRegReq{
+				Login:    "Petr",
+				Password: "petrPass",
+			},
+			tableUsers: map[string]models.User{},
+			storeErr:   store.ErrHashingOverloaded,
+			want: want{
+				statusCode: http.StatusServiceUnavailable,
+				jsonResponse: resultMsg{
+					IsError:       true,
+					ResultMessage: "Server is busy, please retry later",
+				},
+			},
+		},
+		{
+			name:       "Test storage unavailable",
+			requestUrl: "/api/user/registration/",
+			requestBody: models.UserRegReq{
+				Login:    "Petr",
+				Password: "petrPass",
+			},
+			tableUsers: map[string]models.User{},
+			storeErr:   fmt.Errorf("failed to begin transaction: %w", store.ErrUnavailable),
+			want: want{
+				statusCode: http.StatusServiceUnavailable,
+				jsonResponse: resultMsg{
+					IsError:       true,
+					ResultMessage: "Storage is temporarily unavailable",
+					Code:          "unavailable",
+				},
+			},
+		},
+		{
+			name:       "Test unexpected store error is not a success",
+			requestUrl: "/api/user/registration/",
+			requestBody: models.UserRegReq{
+				Login:    "Petr",
+				Password: "petrPass",
+			},
+			tableUsers: map[string]models.User{},
+			storeErr:   errors.New("disk exploded"),
+			want: want{
+				statusCode: http.StatusInternalServerError,
+				jsonResponse: resultMsg{
+					IsError:       true,
+					ResultMessage: "Internal server error",
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		tt := tt // capture range variable
@@ -115,6 +188,7 @@ func TestHandlers_Registration(t *testing.T) {
 			for _, user := range tt.tableUsers {
 				s.users[user.Login] = user // Заполняем мок данными из тест-кейса
 			}
+			s.createErr = tt.storeErr
 
 			handlers, err := NewHandlers(s, testConfig, testLogger, testAuth)
 			require.NoError(t, err)
@@ -139,3 +213,34 @@ func TestHandlers_Registration(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlers_RegistrationPolicies(t *testing.T) {
+	servConf := newMockServerConfig()
+	servConf.PasswordMinLength = 10
+	servConf.PasswordForbidLogin = true
+	servConf.LoginAllowedChars = `\p{L}\p{N}`
+
+	handlers, err := NewHandlers(newMockStorage(), servConf, testLogger, testAuth)
+	require.NoError(t, err)
+
+	jsonBody, err := json.Marshal(models.UserRegReq{Login: "Petr!", Password: "petr!1"})
+	require.NoError(t, err)
+	request := httptest.NewRequest(http.MethodPost, "/api/user/registration/", bytes.NewBuffer(jsonBody))
+	request.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handlers.Registration(w, request)
+
+	result := w.Result()
+	defer result.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+
+	var jsonResponse resultMsg
+	require.NoError(t, json.NewDecoder(result.Body).Decode(&jsonResponse))
+	assert.True(t, jsonResponse.IsError)
+	assert.Equal(t, []models.FieldError{
+		{Field: "login", Code: "invalid_characters", Message: "Login contains characters that are not allowed"},
+		{Field: "password", Code: "too_short", Message: "Password must be at least 10 characters long"},
+		{Field: "password", Code: "contains_login", Message: "Password must not contain the login"},
+	}, jsonResponse.FieldErrors)
+}
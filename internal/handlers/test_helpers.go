@@ -7,15 +7,16 @@ import (
 	"github.com/eampleev23/raya-backend.git/internal/models"
 	"github.com/eampleev23/raya-backend.git/internal/server_config"
 	"github.com/eampleev23/raya-backend.git/internal/store"
-	"github.com/jackc/pgerrcode"
-	"github.com/jackc/pgx/v5/pgconn"
+	"sort"
+	"strings"
+	"time"
 )
 
 // Общие тестовые переменные.
 var (
 	testConfig    = newMockServerConfig()
 	testLogger, _ = logger.NewZapLogger("info")
-	testAuth, _   = auth.Initialize(testConfig, testLogger)
+	testAuth, _   = auth.Initialize(testConfig, testLogger, nil)
 )
 
 func newMockServerConfig() *server_config.ServerConfig {
@@ -24,29 +25,66 @@ func newMockServerConfig() *server_config.ServerConfig {
 
 // Общая реализация мока хранилища.
 type mockStorage struct {
-	users map[string]models.User
+	users        map[string]models.User
+	createErr    error // Если задана, CreateUser возвращает эту ошибку.
+	authStateErr error // Если задана, GetUserAuthState возвращает эту ошибку.
+	updated      []int // ID пользователей, чей пароль был перезаписан.
+	revoked      []int // ID пользователей, чьи сессии отозваны.
+	// Статусы удалённых пользователей до удаления, в них они возвращаются при восстановлении.
+	statusBeforeDeletion map[int]models.UserStatus
+	audit                *store.AuditLog
+	*store.WebhookQueue
+	*store.Outbox
+	*store.SecurityEventLog
+	*store.NotificationQueue
+	*store.DeviceRegistry
+	*store.LoginChallengeSet
+	*store.ExportJobQueue
+	*store.IPRuleSet
+	*store.RateLimitCounters
+	*store.AuthStateVersion
 }
 
 // Конструктор мока хранилища.
 func newMockStorage() *mockStorage {
 	return &mockStorage{
-		users: make(map[string]models.User),
+		users:                make(map[string]models.User),
+		statusBeforeDeletion: make(map[int]models.UserStatus),
+		audit:                store.NewAuditLog(),
+
+		WebhookQueue:      store.NewWebhookQueue(),
+		Outbox:            store.NewOutbox(),
+		SecurityEventLog:  store.NewSecurityEventLog(),
+		NotificationQueue: store.NewNotificationQueue(),
+		DeviceRegistry:    store.NewDeviceRegistry(),
+		LoginChallengeSet: store.NewLoginChallengeSet(),
+		ExportJobQueue:    store.NewExportJobQueue(),
+		IPRuleSet:         store.NewIPRuleSet(),
+		RateLimitCounters: store.NewRateLimitCounters(),
+		AuthStateVersion:  store.NewAuthStateVersion(),
 	}
 }
 
+// emit пишет событие в outbox, как это делает хранилище вместе с изменением.
+func (m *mockStorage) emit(eventType models.DomainEventType, user *models.User) {
+	m.AppendOutboxEvent(context.Background(), store.NewDomainEvent(eventType, user))
+}
+
 func (m *mockStorage) CreateUser(ctx context.Context, userReq models.UserRegReq) (*models.User, error) {
-	// Для теста BadRequest возвращаем ошибку при пустом логине
-	if userReq.Login == "" {
-		return nil, &pgconn.PgError{Code: pgerrcode.NotNullViolation}
+	if m.createErr != nil {
+		return nil, m.createErr
 	}
 	if _, exists := m.users[userReq.Login]; exists {
-		return &models.User{}, &pgconn.PgError{Code: pgerrcode.UniqueViolation}
+		return nil, store.ErrLoginTaken
 	}
 	newUser := models.User{
-		ID:    len(m.users) + 1,
-		Login: userReq.Login,
+		ID:     len(m.users) + 1,
+		Login:  userReq.Login,
+		Role:   userReq.Role,
+		Status: models.UserStatusActive,
 	}
 	m.users[userReq.Login] = newUser
+	m.emit(models.EventUserRegistered, &newUser)
 	return &newUser, nil
 }
 
@@ -55,9 +93,242 @@ func (m *mockStorage) GetUserByLogin(ctx context.Context, userLoginReq models.Us
 	if !exists {
 		return nil, store.ErrUserNotFound
 	}
+	// Как и в БД, по умолчанию пользователь активен.
+	if user.Status == "" {
+		user.Status = models.UserStatusActive
+	}
 	return &user, nil
 }
 
+func (m *mockStorage) UpdatePassword(ctx context.Context, userID int, password string) error {
+	user, err := m.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	m.updated = append(m.updated, userID)
+	m.emit(models.EventUserPasswordChanged, user)
+	return nil
+}
+
+func (m *mockStorage) RehashPassword(ctx context.Context, userID int, password string) error {
+	m.updated = append(m.updated, userID)
+	return nil
+}
+
+// VerifyPassword принимает пароль correctPassword, а busyPassword изображает переполненную очередь хэширования.
+func (m *mockStorage) VerifyPassword(ctx context.Context, password, encodedHash string) (bool, error) {
+	if password == "busyPassword" {
+		return false, store.ErrHashingOverloaded
+	}
+	if password == "unavailablePassword" {
+		return false, store.ErrUnavailable
+	}
+	return password == "correctPassword", nil
+}
+
+// NeedsRehash считает устаревшими хэши argon2id, остальные хэши в тестах - заглушки.
+func (m *mockStorage) NeedsRehash(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$argon2id$")
+}
+
+func (m *mockStorage) ImportUser(ctx context.Context, user models.User) (bool, error) {
+	if _, exists := m.users[user.Login]; exists {
+		return false, nil
+	}
+	user.ID = len(m.users) + 1
+	m.users[user.Login] = user
+	return true, nil
+}
+
 func (m *mockStorage) DBConnClose() error {
 	return nil
 }
+
+func (m *mockStorage) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	for _, user := range m.users {
+		if user.ID == userID {
+			return &user, nil
+		}
+	}
+	return nil, store.ErrUserNotFound
+}
+
+func (m *mockStorage) UpdateUserProfile(ctx context.Context, userID int, patch models.UserProfilePatch) (*models.User, error) {
+	for login, user := range m.users {
+		if user.ID != userID {
+			continue
+		}
+		set := func(dst *string, src *string) {
+			if src != nil {
+				*dst = *src
+			}
+		}
+		set(&user.DisplayName, patch.DisplayName)
+		set(&user.Email, patch.Email)
+		set(&user.Phone, patch.Phone)
+		set(&user.Locale, patch.Locale)
+		set(&user.Timezone, patch.Timezone)
+		set(&user.AvatarURL, patch.AvatarURL)
+		m.users[login] = user
+		m.emit(models.EventUserProfileUpdated, &user)
+		return &user, nil
+	}
+	return nil, store.ErrUserNotFound
+}
+
+func (m *mockStorage) GetUserAuthState(ctx context.Context, userID int) (models.UserAuthState, error) {
+	if m.authStateErr != nil {
+		return models.UserAuthState{}, m.authStateErr
+	}
+	user, err := m.GetUserByID(ctx, userID)
+	if err != nil {
+		return models.UserAuthState{}, err
+	}
+	if user.Status == "" {
+		user.Status = models.UserStatusActive
+	}
+	return models.UserAuthState{
+		Role: user.Role, Status: user.Status, StatusChangedAt: user.StatusChangedAt, IPRules: m.RulesFor(userID, user.Role),
+	}, nil
+}
+
+func (m *mockStorage) SetUserStatus(ctx context.Context, userID int, status models.UserStatus, reason string) (*models.User, error) {
+	for login, user := range m.users {
+		if user.ID != userID {
+			continue
+		}
+		if user.Status == "" {
+			user.Status = models.UserStatusActive
+		}
+		if !user.Status.CanTransitionTo(status) {
+			return nil, store.ErrInvalidStatusTransition
+		}
+		if status == models.UserStatusDeleted {
+			m.statusBeforeDeletion[userID] = user.Status
+		}
+		user.Status = status
+		user.StatusReason = reason
+		m.users[login] = user
+		if status == models.UserStatusDeleted {
+			m.emit(models.EventUserDeleted, &user)
+		} else {
+			m.emit(models.EventUserStatusChanged, &user)
+		}
+		return &user, nil
+	}
+	return nil, store.ErrUserNotFound
+}
+
+func (m *mockStorage) DeleteUser(ctx context.Context, userID int, reason string) (*models.User, error) {
+	user, err := m.SetUserStatus(ctx, userID, models.UserStatusDeleted, reason)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	user.DeletedAt = &now
+	m.users[user.Login] = *user
+	return user, nil
+}
+
+func (m *mockStorage) RestoreUser(ctx context.Context, userID int, deletedAfter time.Time) (*models.User, error) {
+	for login, user := range m.users {
+		if user.ID != userID {
+			continue
+		}
+		if user.Status != models.UserStatusDeleted {
+			return nil, store.ErrInvalidStatusTransition
+		}
+		if user.DeletedAt == nil || user.DeletedAt.Before(deletedAfter) {
+			return nil, store.ErrRestoreExpired
+		}
+		user.Status = models.UserStatusActive
+		if previous, ok := m.statusBeforeDeletion[userID]; ok {
+			user.Status = previous
+			delete(m.statusBeforeDeletion, userID)
+		}
+		user.DeletedAt = nil
+		m.users[login] = user
+		m.emit(models.EventUserRestored, &user)
+		return &user, nil
+	}
+	return nil, store.ErrUserNotFound
+}
+
+func (m *mockStorage) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time, limit int, anonymize bool) (int, error) {
+	purged := 0
+	for login, user := range m.users {
+		if purged == limit {
+			break
+		}
+		if user.Status == models.UserStatusDeleted && user.DeletedAt != nil && user.DeletedAt.Before(deletedBefore) {
+			delete(m.users, login)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (m *mockStorage) ListUsers(ctx context.Context, query models.UserListQuery) ([]models.User, error) {
+	var users []models.User
+	for _, user := range m.users {
+		if user.ID <= query.AfterID ||
+			(query.Status != "" && user.Status != query.Status) ||
+			(!query.CreatedFrom.IsZero() && user.CreatedAt.Before(query.CreatedFrom)) ||
+			(!query.CreatedTo.IsZero() && !user.CreatedAt.Before(query.CreatedTo)) ||
+			!strings.HasPrefix(strings.ToLower(user.Login), strings.ToLower(query.LoginPrefix)) {
+			continue
+		}
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	if len(users) > query.Limit {
+		users = users[:query.Limit]
+	}
+	return users, nil
+}
+
+func (m *mockStorage) SetUserRole(ctx context.Context, userID int, role models.UserRole) (*models.User, error) {
+	for login, user := range m.users {
+		if user.ID == userID {
+			user.Role = role
+			m.users[login] = user
+			m.emit(models.EventUserRoleChanged, &user)
+			return &user, nil
+		}
+	}
+	return nil, store.ErrUserNotFound
+}
+
+func (m *mockStorage) RevokeSessions(ctx context.Context, userID int) error {
+	if _, err := m.GetUserByID(ctx, userID); err != nil {
+		return err
+	}
+	m.revoked = append(m.revoked, userID)
+	return nil
+}
+
+func (m *mockStorage) SearchUsers(ctx context.Context, query models.UserSearchQuery) ([]models.UserSearchResult, error) {
+	idx := store.NewUserIndex()
+	for _, user := range m.users {
+		idx.Put(user)
+	}
+	return idx.SearchUsers(ctx, query)
+}
+
+func (m *mockStorage) AppendAuditEvent(ctx context.Context, event models.AuditEvent) (models.AuditEvent, error) {
+	return m.audit.AppendAuditEvent(ctx, event)
+}
+
+func (m *mockStorage) ListAuditEvents(ctx context.Context, query models.AuditQuery) ([]models.AuditEvent, error) {
+	return m.audit.ListAuditEvents(ctx, query)
+}
+
+// auditTypes - виды записанных событий по порядку.
+func (m *mockStorage) auditTypes() []models.AuditEventType {
+	events, _ := m.audit.ListAuditEvents(context.Background(), models.AuditQuery{})
+	var types []models.AuditEventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// GetMe отдаёт профиль текущего пользователя.
+func (handlers *Handlers) GetMe(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	userID, ok := userIDFromRequest(gotRequest)
+	if !ok {
+		sendResponse(true, "Unauthorized", http.StatusUnauthorized, responseWriter)
+		return
+	}
+
+	user, err := handlers.store.GetUserByID(gotRequest.Context(), userID)
+	if errors.Is(err, store.ErrUserNotFound) {
+		sendResponse(true, "User not found", http.StatusNotFound, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to get user profile", zap.Int("user_id", userID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	sendJSON(user, http.StatusOK, responseWriter)
+}
+
+/*
+PatchMe частично обновляет профиль текущего пользователя. Меняются только переданные поля,
+пустая строка очищает значение:
+
+	{
+	    "display_name": "<display name>",
+	    "email": "<email>",
+	    "phone": "<+E.164>",
+	    "locale": "<BCP 47>",
+	    "timezone": "<IANA time zone>",
+	    "avatar_url": "<http(s) url>"
+	}
+*/
+func (handlers *Handlers) PatchMe(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+
+	userID, ok := userIDFromRequest(gotRequest)
+	if !ok {
+		sendResponse(true, "Unauthorized", http.StatusUnauthorized, responseWriter)
+		return
+	}
+
+	var patch models.UserProfilePatch
+	decoder := json.NewDecoder(gotRequest.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		sendResponse(true, "Not a valid profile update request", http.StatusBadRequest, responseWriter)
+		return
+	}
+	if patch.IsEmpty() {
+		sendResponse(true, "Nothing to update", http.StatusBadRequest, responseWriter)
+		return
+	}
+	if fieldErrors := patch.Normalize(); len(fieldErrors) > 0 {
+		sendFieldErrors("Profile fields are not valid", fieldErrors, http.StatusBadRequest, responseWriter)
+		return
+	}
+
+	user, err := handlers.store.UpdateUserProfile(gotRequest.Context(), userID, patch)
+	if errors.Is(err, store.ErrUserNotFound) {
+		sendResponse(true, "User not found", http.StatusNotFound, responseWriter)
+		return
+	}
+	if err != nil {
+		handlers.logger.ZL.Error("failed to update user profile", zap.Int("user_id", userID), zap.Error(err))
+		sendStoreError(err, responseWriter)
+		return
+	}
+	sendJSON(user, http.StatusOK, responseWriter)
+}
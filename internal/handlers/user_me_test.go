@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlers_GetMe(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		userID     any
+		wantStatus int
+		wantLogin  string
+	}{
+		{name: "Test own profile", userID: 1, wantStatus: http.StatusOK, wantLogin: "Petr"},
+		{name: "Test deleted user", userID: 2, wantStatus: http.StatusNotFound},
+		{name: "Test no user in context", userID: nil, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMockStorage()
+			s.users["Petr"] = models.User{ID: 1, Login: "Petr", DisplayName: "Пётр", PasswordHash: "secret"}
+
+			request := httptest.NewRequest(http.MethodGet, "/api/user/me/", nil)
+			if tt.userID != nil {
+				request = request.WithContext(context.WithValue(request.Context(), auth.KeyUserIDCtx, tt.userID))
+			}
+			w := httptest.NewRecorder()
+
+			handlers, err := NewHandlers(s, testConfig, testLogger, testAuth)
+			require.NoError(t, err)
+			handlers.GetMe(w, request)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			assert.NotContains(t, w.Body.String(), "secret")
+			var user models.User
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+			assert.Equal(t, tt.wantLogin, user.Login)
+			assert.Equal(t, "Пётр", user.DisplayName)
+		})
+	}
+}
+
+func TestHandlers_PatchMe(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		requestBody     string
+		wantStatus      int
+		wantUser        models.User
+		wantFieldErrors []string
+	}{
+		{
+			name:        "Test partial update keeps other fields",
+			requestBody: `{"display_name": "Пётр Первый", "locale": "ru-ru", "timezone": "Europe/Moscow"}`,
+			wantStatus:  http.StatusOK,
+			wantUser: models.User{
+				ID: 1, Login: "Petr", DisplayName: "Пётр Первый", Email: "petr@example.com",
+				Locale: "ru-RU", Timezone: "Europe/Moscow",
+			},
+		},
+		{
+			name:        "Test empty string clears field",
+			requestBody: `{"email": ""}`,
+			wantStatus:  http.StatusOK,
+			wantUser:    models.User{ID: 1, Login: "Petr", DisplayName: "Пётр"},
+		},
+		{
+			name:        "Test email is normalized",
+			requestBody: `{"email": "<petr@example.org>"}`,
+			wantStatus:  http.StatusOK,
+			wantUser:    models.User{ID: 1, Login: "Petr", DisplayName: "Пётр", Email: "petr@example.org"},
+		},
+		{
+			name: "Test invalid fields",
+			requestBody: `{"email": "Petr <petr@example.com>", "phone": "8 999 123", "locale": "not a locale",
+				"timezone": "Mars/Olympus", "avatar_url": "javascript:alert(1)"}`,
+			wantStatus:      http.StatusBadRequest,
+			wantFieldErrors: []string{"email", "phone", "locale", "timezone", "avatar_url"},
+		},
+		{
+			name: "Test too long fields",
+			requestBody: `{"email": "` + strings.Repeat("p", 250) + `@example.com", "locale": "en-US-x-` + strings.Repeat("abcdefgh-", 4) + `x",
+				"avatar_url": "https://example.com/` + strings.Repeat("a", 2048) + `"}`,
+			wantStatus:      http.StatusBadRequest,
+			wantFieldErrors: []string{"email", "locale", "avatar_url"},
+		},
+		{
+			name:        "Test unknown field",
+			requestBody: `{"login": "Ivan"}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "Test empty patch",
+			requestBody: `{}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMockStorage()
+			s.users["Petr"] = models.User{ID: 1, Login: "Petr", DisplayName: "Пётр", Email: "petr@example.com"}
+
+			request := httptest.NewRequest(http.MethodPatch, "/api/user/me/", bytes.NewBufferString(tt.requestBody))
+			request.Header.Set("Content-Type", "application/json")
+			request = request.WithContext(context.WithValue(request.Context(), auth.KeyUserIDCtx, 1))
+			w := httptest.NewRecorder()
+
+			handlers, err := NewHandlers(s, testConfig, testLogger, testAuth)
+			require.NoError(t, err)
+			handlers.PatchMe(w, request)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				var user models.User
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+				assert.Equal(t, tt.wantUser, user)
+				assert.Equal(t, tt.wantUser, s.users["Petr"])
+				return
+			}
+
+			var response resultMsg
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.True(t, response.IsError)
+			var fields []string
+			for _, fieldError := range response.FieldErrors {
+				fields = append(fields, fieldError.Field)
+			}
+			assert.Equal(t, tt.wantFieldErrors, fields)
+			assert.Equal(t, "Пётр", s.users["Petr"].DisplayName)
+		})
+	}
+}
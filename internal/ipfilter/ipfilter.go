@@ -0,0 +1,126 @@
+package ipfilter
+
+import (
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Ограничения по адресам: глобальные списки из конфига действуют на всех, правила из БД - на пользователя
+// и его роль. Запрет сильнее разрешения. Непустой список разрешений закрывает все адреса, которых в нём нет,
+// причём глобальный список и правила пользователя проверяются независимо, и пройти нужно оба.
+// Адрес клиента берётся из X-Forwarded-For, только если запрос пришёл от доверенного прокси.
+
+type Filter struct {
+	allow   []netip.Prefix
+	deny    []netip.Prefix
+	trusted []netip.Prefix
+}
+
+func NewFilter(c *server_config.ServerConfig) (*Filter, error) {
+	allow, err := parseList(c.IPAllowList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ip allow list: %w", err)
+	}
+	deny, err := parseList(c.IPDenyList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ip deny list: %w", err)
+	}
+	trusted, err := parseList(c.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trusted proxies: %w", err)
+	}
+	return &Filter{allow: allow, deny: deny, trusted: trusted}, nil
+}
+
+// parseList разбирает диапазоны через запятую.
+func parseList(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		prefix, err := models.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: %w", item, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// ClientIP - адрес клиента. Если запрос пришёл от доверенного прокси, X-Forwarded-For читается справа налево
+// до первого адреса, который доверенным прокси не является: всё левее него мог подставить сам клиент.
+func (f *Filter) ClientIP(gotRequest *http.Request) string {
+	host, _, err := net.SplitHostPort(gotRequest.RemoteAddr)
+	if err != nil {
+		host = gotRequest.RemoteAddr
+	}
+	if !f.isTrusted(host) {
+		return host
+	}
+	var hops []string
+	for _, header := range gotRequest.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			// Испорченной цепочке дальше верить нельзя, последний надёжный адрес - предыдущий.
+			return host
+		}
+		host = hop
+		if !f.isTrusted(hop) {
+			return hop
+		}
+	}
+	return host
+}
+
+func (f *Filter) isTrusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && contains(f.trusted, addr.Unmap())
+}
+
+// Allowed сообщает, можно ли войти с адреса ip с учётом глобальных списков и правил пользователя rules.
+func (f *Filter) Allowed(ip string, rules []models.IPRule) bool {
+	var allow, deny []netip.Prefix
+	for _, rule := range rules {
+		prefix, err := models.ParseCIDR(rule.CIDR)
+		if err != nil {
+			continue
+		}
+		if rule.Action == models.IPRuleDeny {
+			deny = append(deny, prefix)
+		} else {
+			allow = append(allow, prefix)
+		}
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		// Нераспознанный адрес не попадает ни в какой диапазон: пропускаем его, только если ограничений нет.
+		return len(f.allow) == 0 && len(allow) == 0
+	}
+	addr = addr.Unmap()
+	if contains(f.deny, addr) || contains(deny, addr) {
+		return false
+	}
+	if len(f.allow) > 0 && !contains(f.allow, addr) {
+		return false
+	}
+	return len(allow) == 0 || contains(allow, addr)
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package ipfilter
+
+import (
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFilter_ClientIP(t *testing.T) {
+	f, err := NewFilter(&server_config.ServerConfig{TrustedProxies: "10.0.0.0/8, 192.0.2.1"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.5:4000", want: "203.0.113.5"},
+		{name: "untrusted peer is not believed", remoteAddr: "203.0.113.5:4000", forwardedFor: []string{"198.51.100.1"}, want: "203.0.113.5"},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:4000", forwardedFor: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{
+			name:         "spoofed hops left of the client are ignored",
+			remoteAddr:   "10.1.2.3:4000",
+			forwardedFor: []string{"1.1.1.1, 198.51.100.1", "192.0.2.1"},
+			want:         "198.51.100.1",
+		},
+		{name: "garbage in the chain", remoteAddr: "10.1.2.3:4000", forwardedFor: []string{"198.51.100.1, junk"}, want: "10.1.2.3"},
+		{name: "only proxies", remoteAddr: "10.1.2.3:4000", forwardedFor: []string{"10.0.0.9"}, want: "10.0.0.9"},
+		{name: "trusted proxy without header", remoteAddr: "[::ffff:10.1.2.3]:4000", want: "::ffff:10.1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.RemoteAddr = tt.remoteAddr
+			for _, header := range tt.forwardedFor {
+				request.Header.Add("X-Forwarded-For", header)
+			}
+			assert.Equal(t, tt.want, f.ClientIP(request))
+		})
+	}
+}
+
+func TestFilter_Allowed(t *testing.T) {
+	office := models.IPRule{CIDR: "198.51.100.0/24", Action: models.IPRuleAllow}
+	vpn := models.IPRule{CIDR: "203.0.113.7/32", Action: models.IPRuleAllow}
+	banned := models.IPRule{CIDR: "198.51.100.66/32", Action: models.IPRuleDeny}
+
+	tests := []struct {
+		name   string
+		config server_config.ServerConfig
+		ip     string
+		rules  []models.IPRule
+		want   bool
+	}{
+		{name: "no restrictions", ip: "192.0.2.1", want: true},
+		{name: "global deny", config: server_config.ServerConfig{IPDenyList: "192.0.2.0/24"}, ip: "192.0.2.1", want: false},
+		{name: "outside global allow", config: server_config.ServerConfig{IPAllowList: "10.0.0.0/8"}, ip: "192.0.2.1", want: false},
+		{name: "inside global allow", config: server_config.ServerConfig{IPAllowList: "10.0.0.0/8"}, ip: "10.2.3.4", want: true},
+		{name: "user allow list", ip: "203.0.113.7", rules: []models.IPRule{office, vpn}, want: true},
+		{name: "outside user allow list", ip: "192.0.2.1", rules: []models.IPRule{office, vpn}, want: false},
+		{name: "deny beats allow", ip: "198.51.100.66", rules: []models.IPRule{office, banned}, want: false},
+		{name: "deny only rules keep other addresses open", ip: "192.0.2.1", rules: []models.IPRule{banned}, want: true},
+		{
+			name:   "both global and user allow must pass",
+			config: server_config.ServerConfig{IPAllowList: "10.0.0.0/8"},
+			ip:     "198.51.100.1",
+			rules:  []models.IPRule{office},
+			want:   false,
+		},
+		{name: "mapped ipv4", ip: "::ffff:198.51.100.1", rules: []models.IPRule{office}, want: true},
+		{name: "unparsable address with restrictions", ip: "pipe", rules: []models.IPRule{office}, want: false},
+		{name: "unparsable address without restrictions", ip: "pipe", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(&tt.config)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, f.Allowed(tt.ip, tt.rules))
+		})
+	}
+}
+
+func TestNewFilter_InvalidList(t *testing.T) {
+	_, err := NewFilter(&server_config.ServerConfig{IPDenyList: "10.0.0.0/8, nonsense"})
+	assert.Error(t, err)
+}
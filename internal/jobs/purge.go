@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"go.uber.org/zap"
+	"time"
+)
+
+// PurgeStore - то, что нужно задаче очистки от хранилища.
+type PurgeStore interface {
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time, limit int, anonymize bool) (purged int, err error)
+}
+
+// Purger периодически окончательно удаляет учётные записи, у которых истёк срок на восстановление.
+type Purger struct {
+	store       PurgeStore
+	logger      *logger.ZapLog
+	gracePeriod time.Duration
+	interval    time.Duration
+	batchSize   int
+	anonymize   bool
+	now         func() time.Time
+}
+
+func NewPurger(s PurgeStore, c *server_config.ServerConfig, l *logger.ZapLog) *Purger {
+	batchSize := c.PurgeBatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &Purger{
+		store:       s,
+		logger:      l,
+		gracePeriod: c.DeletionGracePeriod,
+		interval:    c.PurgeInterval,
+		batchSize:   batchSize,
+		anonymize:   c.PurgeAnonymize,
+		now:         time.Now,
+	}
+}
+
+// Run запускает очистку сразу и затем раз в interval, пока не отменён ctx.
+// При нулевом interval сразу возвращается.
+func (p *Purger) Run(ctx context.Context) {
+	if p.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		purged, err := p.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			p.logger.ZL.Error("failed to purge deleted users", zap.Error(err))
+		}
+		if purged > 0 {
+			p.logger.ZL.Info("deleted users purged", zap.Int("count", purged), zap.Bool("anonymized", p.anonymize))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce очищает все просроченные учётные записи пачками по batchSize.
+func (p *Purger) RunOnce(ctx context.Context) (total int, err error) {
+	deletedBefore := p.now().Add(-p.gracePeriod)
+	for {
+		purged, err := p.store.PurgeDeletedUsers(ctx, deletedBefore, p.batchSize, p.anonymize)
+		total += purged
+		if err != nil {
+			return total, fmt.Errorf("failed to purge batch: %w", err)
+		}
+		if purged < p.batchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type fakePurgeStore struct {
+	pending       int // Сколько учётных записей ждёт очистки.
+	failOnBatch   int // Номер пачки, на которой вернуть ошибку, 0 - не возвращать.
+	batches       int
+	deletedBefore time.Time
+	anonymize     bool
+}
+
+func (f *fakePurgeStore) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time, limit int, anonymize bool) (int, error) {
+	f.batches++
+	f.deletedBefore = deletedBefore
+	f.anonymize = anonymize
+	if f.batches == f.failOnBatch {
+		return 0, errors.New("connection reset")
+	}
+	purged := min(limit, f.pending)
+	f.pending -= purged
+	return purged, nil
+}
+
+func TestPurger_RunOnce(t *testing.T) {
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		pending     int
+		failOnBatch int
+		wantTotal   int
+		wantBatches int
+		wantErr     bool
+	}{
+		{name: "nothing to purge", pending: 0, wantTotal: 0, wantBatches: 1},
+		{name: "less than a batch", pending: 7, wantTotal: 7, wantBatches: 1},
+		{name: "several batches", pending: 25, wantTotal: 25, wantBatches: 3},
+		{name: "exact batches need one more query", pending: 20, wantTotal: 20, wantBatches: 3},
+		{name: "error stops purging", pending: 25, failOnBatch: 2, wantTotal: 10, wantBatches: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakePurgeStore{pending: tt.pending, failOnBatch: tt.failOnBatch}
+			c := &server_config.ServerConfig{
+				DeletionGracePeriod: 24 * time.Hour,
+				PurgeBatchSize:      10,
+				PurgeAnonymize:      true,
+			}
+			p := NewPurger(s, c, l)
+			p.now = func() time.Time { return now }
+
+			total, err := p.RunOnce(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantTotal, total)
+			assert.Equal(t, tt.wantBatches, s.batches)
+			assert.Equal(t, now.Add(-24*time.Hour), s.deletedBefore)
+			assert.True(t, s.anonymize)
+		})
+	}
+}
+
+func TestPurger_RunStopsOnCancel(t *testing.T) {
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	s := &fakePurgeStore{}
+	p := NewPurger(s, &server_config.ServerConfig{PurgeInterval: time.Hour}, l)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("purger did not stop after cancel")
+	}
+}
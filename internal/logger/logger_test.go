@@ -77,6 +77,19 @@ func TestLoggingResponseWriter(t *testing.T) {
 	assert.Equal(t, testBody, rec.Body.String())
 }
 
+func TestLoggingResponseWriter_Flush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	var w http.ResponseWriter = &loggingResponseWriter{ResponseWriter: rec, responseData: &responseData{}}
+
+	// Потоковые хэндлеры должны видеть http.Flusher и через обёртку логгера.
+	flusher, ok := w.(http.Flusher)
+	assert.True(t, ok)
+	flusher.Flush()
+	assert.True(t, rec.Flushed)
+
+	assert.NoError(t, http.NewResponseController(w).Flush())
+}
+
 func TestShortDur(t *testing.T) {
 	tests := []struct {
 		name     string
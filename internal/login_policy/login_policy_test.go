@@ -0,0 +1,73 @@
+package login_policy
+
+import (
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		login string
+		want  string
+	}{
+		{"case", "Ivan", "ivan"},
+		{"spaces", "  ivan \t", "ivan"},
+		{"fullwidth letters", "Ｉｖａｎ", "ivan"},
+		{"ligature", "ﬁlip", "filip"},
+		{"sharp s", "Straße", "strasse"},
+		{"cyrillic", "Иван", "иван"},
+		{"composed and decomposed", "émile", "émile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Normalize(tt.login))
+		})
+	}
+}
+
+func TestSkeleton(t *testing.T) {
+	// Кириллические «р», «а», «е», «о» неотличимы от латинских.
+	assert.Equal(t, Skeleton(Normalize("paveo")), Skeleton(Normalize("раvео")))
+	assert.NotEqual(t, Skeleton(Normalize("ivan1")), Skeleton(Normalize("ivanl")))
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	policy, err := NewPolicy(&server_config.ServerConfig{
+		LoginMinLength:         3,
+		LoginMaxLength:         10,
+		LoginAllowedChars:      `\p{L}\p{N}._-`,
+		LoginRejectMixedScript: true,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		login     string
+		wantCodes []string
+	}{
+		{"valid latin", "ivan.petrov", []string{"too_long"}},
+		{"valid short", "ivan_p", nil},
+		{"valid cyrillic", "Иван", nil},
+		{"too short", "iv", []string{"too_short"}},
+		{"spaces inside", "ivan p", []string{"invalid_characters"}},
+		{"mixed scripts", "pаvel", []string{"mixed_script"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var codes []string
+			for _, fieldError := range policy.Validate(tt.login) {
+				assert.Equal(t, "login", fieldError.Field)
+				codes = append(codes, fieldError.Code)
+			}
+			assert.Equal(t, tt.wantCodes, codes)
+		})
+	}
+}
+
+func TestNewPolicyInvalidCharset(t *testing.T) {
+	_, err := NewPolicy(&server_config.ServerConfig{LoginAllowedChars: `\p{Nope}`})
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package login_policy
+
+import (
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+// Normalize приводит логин к канонической форме, по которой логины сравниваются между собой:
+// обрезает пробелы, приводит к NFKC (совместимые формы вроде полноширинных букв) и сворачивает регистр.
+func Normalize(login string) string {
+	login = norm.NFKC.String(strings.TrimSpace(login))
+	// После свёртки регистра строка может перестать быть в NFKC, поэтому нормализуем повторно.
+	return norm.NFKC.String(cases.Fold().String(login))
+}
+
+// confusables - буквы других алфавитов, неотличимые на глаз от латинских (после свёртки регистра).
+// Такая же таблица используется в миграции при заполнении login_skeleton.
+var confusables = map[rune]rune{
+	// Кириллица.
+	'а': 'a', 'с': 'c', 'ԁ': 'd', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j', 'к': 'k', 'ӏ': 'l',
+	'о': 'o', 'р': 'p', 'ԛ': 'q', 'ѕ': 's', 'у': 'y', 'х': 'x', 'ԝ': 'w',
+	// Греческий.
+	'α': 'a', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'γ': 'y',
+	// Латинские буквы без точки и т.п.
+	'ı': 'i', 'ɡ': 'g',
+}
+
+// Skeleton возвращает «скелет» нормализованного логина: все похожие на латиницу буквы заменены латинскими.
+// Два логина с одинаковым скелетом выглядят одинаково и не должны принадлежать разным людям.
+func Skeleton(normalized string) string {
+	return strings.Map(func(r rune) rune {
+		if latin, ok := confusables[r]; ok {
+			return latin
+		}
+		return r
+	}, normalized)
+}
+
+// isMixedScript сообщает, что в логине смешаны буквы латиницы, кириллицы или греческого алфавита.
+func isMixedScript(login string) bool {
+	var scripts []*unicode.RangeTable
+	for _, r := range login {
+		for _, script := range []*unicode.RangeTable{unicode.Latin, unicode.Cyrillic, unicode.Greek} {
+			if unicode.Is(script, r) && !containsTable(scripts, script) {
+				scripts = append(scripts, script)
+			}
+		}
+	}
+	return len(scripts) > 1
+}
+
+func containsTable(tables []*unicode.RangeTable, table *unicode.RangeTable) bool {
+	for _, t := range tables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
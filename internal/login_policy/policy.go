@@ -0,0 +1,64 @@
+package login_policy
+
+import (
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"regexp"
+	"unicode/utf8"
+)
+
+// maxStoredLoginLength - длина колонки users.login.
+const maxStoredLoginLength = 200
+
+// Policy - правила, которым должен соответствовать логин при регистрации.
+// Проверяется нормализованная форма логина. Нулевые значения отключают соответствующую проверку.
+type Policy struct {
+	MinLength         int
+	MaxLength         int
+	RejectMixedScript bool // Запрещает смешивать латиницу, кириллицу и греческий в одном логине.
+	allowed           *regexp.Regexp
+}
+
+// NewPolicy собирает политику логинов из конфигурации сервера.
+func NewPolicy(c *server_config.ServerConfig) (*Policy, error) {
+	p := &Policy{
+		MinLength:         c.LoginMinLength,
+		MaxLength:         c.LoginMaxLength,
+		RejectMixedScript: c.LoginRejectMixedScript,
+	}
+	if p.MaxLength <= 0 || p.MaxLength > maxStoredLoginLength {
+		p.MaxLength = maxStoredLoginLength
+	}
+	if c.LoginAllowedChars != "" {
+		allowed, err := regexp.Compile(`^[` + c.LoginAllowedChars + `]*$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed login characters %q: %w", c.LoginAllowedChars, err)
+		}
+		p.allowed = allowed
+	}
+	return p, nil
+}
+
+// Validate проверяет логин и возвращает все найденные нарушения.
+func (p *Policy) Validate(login string) (fieldErrors []models.FieldError) {
+	violation := func(code, msg string) {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: "login", Code: code, Message: msg})
+	}
+
+	normalized := Normalize(login)
+	length := utf8.RuneCountInString(normalized)
+	if p.MinLength > 0 && length < p.MinLength {
+		violation("too_short", fmt.Sprintf("Login must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violation("too_long", fmt.Sprintf("Login must be at most %d characters long", p.MaxLength))
+	}
+	if p.allowed != nil && !p.allowed.MatchString(normalized) {
+		violation("invalid_characters", "Login contains characters that are not allowed")
+	}
+	if p.RejectMixedScript && isMixedScript(normalized) {
+		violation("mixed_script", "Login must not mix letters from different alphabets")
+	}
+	return fieldErrors
+}
@@ -6,10 +6,19 @@ import (
 	"strings"
 )
 
+// bodylessMethods - методы, у которых нет тела запроса, поэтому Content-Type может отсутствовать.
+var bodylessMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
 func CheckAndSetContenType(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(responseWriter http.ResponseWriter, gotRequest *http.Request) {
 		contentType := gotRequest.Header.Get("Content-Type")
-		if !strings.HasPrefix(contentType, "application/json") {
+		bodyless := contentType == "" && bodylessMethods[gotRequest.Method] && gotRequest.ContentLength <= 0
+		if !bodyless && !strings.HasPrefix(contentType, "application/json") {
 			resultMsg := resultMsg{IsError: true, ResultMessage: "Content-Type header is not application/json"}
 			msg, _ := json.Marshal(resultMsg)
 			responseWriter.WriteHeader(http.StatusUnsupportedMediaType)
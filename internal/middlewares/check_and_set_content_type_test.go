@@ -17,6 +17,7 @@ func (h *TestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func TestCheckAndSetContentType(t *testing.T) {
 	tests := []struct {
 		name           string
+		method         string
 		contentType    string
 		expectedStatus int
 		expectError    bool
@@ -42,6 +43,20 @@ func TestCheckAndSetContentType(t *testing.T) {
 		{
 			name:           "empty content type",
 			contentType:    "",
+			expectedStatus: http.StatusOK,
+			expectError:    false,
+		},
+		{
+			name:           "empty content type with body",
+			method:         http.MethodPost,
+			contentType:    "",
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectError:    true,
+		},
+		{
+			name:           "empty content type on patch",
+			method:         http.MethodPatch,
+			contentType:    "",
 			expectedStatus: http.StatusUnsupportedMediaType,
 			expectError:    true,
 		},
@@ -49,7 +64,11 @@ func TestCheckAndSetContentType(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/", nil)
 			req.Header.Set("Content-Type", tt.contentType)
 
 			// Создаем новый респонз рекордер.
@@ -4,4 +4,5 @@ package middlewares
 type resultMsg struct {
 	IsError       bool
 	ResultMessage string `json:"result_message"`
+	Code          string `json:"code,omitempty"` // Машиночитаемая причина ошибки.
 }
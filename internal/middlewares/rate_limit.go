@@ -0,0 +1,249 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"go.uber.org/zap"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ограничение частоты запросов скользящим окном: число запросов в текущем окне складывается с долей
+// предыдущего окна, ещё попадающей в последние window. Так нельзя удвоить лимит, уложив пачку запросов
+// в конец одного окна и начало следующего, а хранить нужно всего два счётчика на ключ.
+// Засчитываются все запросы, в том числе отклонённые: кто продолжает долбить, остаётся заблокированным.
+
+var (
+	rateLimitMetrics      = expvar.NewMap("rate_limit_rejected")
+	rateLimitErrorMetrics = expvar.NewMap("rate_limit_errors") // Отказы хранилища счётчиков по политикам.
+)
+
+// RateLimitStore - где хранятся счётчики: в памяти для одного экземпляра или в БД для общего лимита.
+type RateLimitStore interface {
+	IncrementRateLimit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (current, previous int, err error)
+	DeleteStaleRateLimits(ctx context.Context, before time.Time) (deleted int, err error)
+}
+
+// RateLimitKeyFunc - по чему считаются запросы: адрес, пользователь, токен.
+type RateLimitKeyFunc func(gotRequest *http.Request) string
+
+// RateLimitPolicy - сколько запросов за окно разрешено одному ключу на группе роутов.
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+	Key    RateLimitKeyFunc
+	// FailClosed отклоняет запросы, пока хранилище счётчиков недоступно. Нужно там, где лимит - единственная
+	// защита от подбора, как на входе; остальные роуты без счётчиков продолжают работать.
+	FailClosed bool
+}
+
+// ParseRateLimitPolicy разбирает лимит вида "10/1m". Пустая строка или "0" выключают лимит, тогда возвращается nil.
+func ParseRateLimitPolicy(name, spec string, key RateLimitKeyFunc) (*RateLimitPolicy, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "0" {
+		return nil, nil
+	}
+	rawLimit, rawWindow, ok := strings.Cut(spec, "/")
+	if !ok {
+		return nil, fmt.Errorf("rate limit %q must look like 10/1m", spec)
+	}
+	limit, err := strconv.Atoi(rawLimit)
+	if err != nil || limit < 1 {
+		return nil, fmt.Errorf("rate limit %q must have a positive request count", spec)
+	}
+	window, err := time.ParseDuration(rawWindow)
+	if err != nil || window < time.Second {
+		return nil, fmt.Errorf("rate limit %q must have a window of at least 1s", spec)
+	}
+	return &RateLimitPolicy{Name: name, Limit: limit, Window: window, Key: key}, nil
+}
+
+// KeyByIP считает запросы по адресу клиента.
+func KeyByIP(clientIP func(gotRequest *http.Request) string) RateLimitKeyFunc {
+	return func(gotRequest *http.Request) string {
+		return "ip:" + clientIP(gotRequest)
+	}
+}
+
+// KeyByUser считает запросы по пользователю, положенному в контекст MiddleCheckAuth, а без него - по адресу.
+func KeyByUser(clientIP func(gotRequest *http.Request) string) RateLimitKeyFunc {
+	byIP := KeyByIP(clientIP)
+	return func(gotRequest *http.Request) string {
+		if userID, ok := gotRequest.Context().Value(auth.KeyUserIDCtx).(int); ok {
+			return "user:" + strconv.Itoa(userID)
+		}
+		return byIP(gotRequest)
+	}
+}
+
+// KeyByToken считает запросы по токену из Authorization: Bearer или из куки, а без него - по адресу.
+// Сам токен в ключ не попадает, только его хэш.
+func KeyByToken(clientIP func(gotRequest *http.Request) string) RateLimitKeyFunc {
+	byIP := KeyByIP(clientIP)
+	return func(gotRequest *http.Request) string {
+		token, ok := strings.CutPrefix(gotRequest.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			if cookie, err := gotRequest.Cookie("token"); err == nil {
+				token = cookie.Value
+			}
+		}
+		if token == "" {
+			return byIP(gotRequest)
+		}
+		sum := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(sum[:16])
+	}
+}
+
+type RateLimiter struct {
+	store     RateLimitStore
+	logger    *logger.ZapLog
+	interval  time.Duration // Как часто удаляются счётчики окон, которые уже не влияют на лимит.
+	maxWindow time.Duration
+	now       func() time.Time
+}
+
+func NewRateLimiter(s RateLimitStore, l *logger.ZapLog, cleanupInterval time.Duration) *RateLimiter {
+	return &RateLimiter{store: s, logger: l, interval: cleanupInterval, now: time.Now}
+}
+
+// Limit возвращает мидлвар с политикой policy. Для nil-политики мидлвар ничего не делает.
+// Если хранилище счётчиков недоступно, запросы пропускаются, а с FailClosed - отклоняются с 503.
+func (rl *RateLimiter) Limit(policy *RateLimitPolicy) func(http.Handler) http.Handler {
+	if policy == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	if policy.Window > rl.maxWindow {
+		rl.maxWindow = policy.Window
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+			now := rl.now()
+			windowStart := now.Truncate(policy.Window)
+			key := policy.Name + ":" + policy.Key(gotRequest)
+			current, previous, err := rl.store.IncrementRateLimit(gotRequest.Context(), key, windowStart, policy.Window)
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					// Клиент ушёл сам, хранилище тут ни при чём.
+					return
+				}
+				rateLimitErrorMetrics.Add(policy.Name, 1)
+				rl.logger.ZL.Error("failed to check rate limit",
+					zap.String("policy", policy.Name), zap.Bool("fail_closed", policy.FailClosed), zap.Error(err))
+				if policy.FailClosed {
+					writeLimitError(responseWriter, http.StatusServiceUnavailable, "rate_limit_unavailable", "Service is temporarily unavailable")
+					return
+				}
+				next.ServeHTTP(responseWriter, gotRequest)
+				return
+			}
+
+			elapsed := now.Sub(windowStart)
+			used := float64(previous)*(1-float64(elapsed)/float64(policy.Window)) + float64(current)
+			remaining := max(policy.Limit-int(math.Ceil(used)), 0)
+			// Лимит полностью восстановится, когда предыдущее окно выйдет из скользящего, а текущее станет предыдущим.
+			reset := policy.Window - elapsed
+			if previous > 0 || used > float64(policy.Limit) {
+				reset += policy.Window
+			}
+			header := responseWriter.Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(policy.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(reset)))
+			header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Window)))
+
+			if used > float64(policy.Limit) {
+				rateLimitMetrics.Add(policy.Name, 1)
+				header.Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter(policy, previous, current, elapsed))))
+				writeLimitError(responseWriter, http.StatusTooManyRequests, "rate_limited", "Too many requests")
+				return
+			}
+			next.ServeHTTP(responseWriter, gotRequest)
+		})
+	}
+}
+
+// writeLimitError отвечает отказом ограничителя в том же виде, что и хэндлеры.
+func writeLimitError(responseWriter http.ResponseWriter, statusCode int, code, message string) {
+	msg, _ := json.Marshal(resultMsg{IsError: true, ResultMessage: message, Code: code})
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(statusCode)
+	responseWriter.Write(msg)
+}
+
+// retryAfter - через сколько, если больше не стучаться, доля предыдущего окна уменьшится настолько,
+// что следующий запрос уложится в лимит.
+func retryAfter(policy *RateLimitPolicy, previous, current int, elapsed time.Duration) time.Duration {
+	// Следующий запрос засчитается поверх current, поэтому в лимит должно влезть current+1.
+	room := policy.Limit - current - 1
+	if room < 0 || previous == 0 {
+		// Текущее окно уже переполнено само: ждём, пока оно станет предыдущим и его доля упадёт до допустимой.
+		return policy.Window - elapsed + overflowWait(policy, current)
+	}
+	// Доля предыдущего окна previous*(1-t/window) должна опуститься до room.
+	at := time.Duration((1 - float64(room)/float64(previous)) * float64(policy.Window))
+	return max(at-elapsed, time.Second)
+}
+
+// overflowWait - сколько от начала следующего окна ждать, пока доля окна с current запросами не опустится ниже лимита.
+func overflowWait(policy *RateLimitPolicy, current int) time.Duration {
+	room := policy.Limit - 1
+	if current <= room {
+		return 0
+	}
+	return time.Duration((1 - float64(room)/float64(current)) * float64(policy.Window))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return max(int(math.Ceil(d.Seconds())), 0)
+}
+
+// Run периодически удаляет счётчики окон, которые уже не влияют ни на один лимит, пока не отменён ctx.
+// При нулевом интервале сразу возвращается.
+func (rl *RateLimiter) Run(ctx context.Context) {
+	if rl.interval <= 0 || rl.maxWindow <= 0 {
+		return
+	}
+	ticker := time.NewTicker(rl.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Счётчик нужен, пока его окно может оказаться предыдущим для текущего.
+		deleted, err := rl.store.DeleteStaleRateLimits(ctx, rl.now().Add(-2*rl.maxWindow))
+		if err != nil && ctx.Err() == nil {
+			rl.logger.ZL.Error("failed to delete stale rate limits", zap.Error(err))
+		}
+		if deleted > 0 {
+			rl.logger.ZL.Debug("stale rate limits deleted", zap.Int("count", deleted))
+		}
+	}
+}
+
+// RateLimitKeyByName возвращает ключ по его имени из конфига: ip, user или token.
+func RateLimitKeyByName(name string, clientIP func(gotRequest *http.Request) string) (RateLimitKeyFunc, error) {
+	switch name {
+	case "ip":
+		return KeyByIP(clientIP), nil
+	case "user":
+		return KeyByUser(clientIP), nil
+	case "token":
+		return KeyByToken(clientIP), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit key %q", name)
+	}
+}
@@ -0,0 +1,175 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/auth"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func remoteIP(gotRequest *http.Request) string {
+	return gotRequest.RemoteAddr
+}
+
+func TestParseRateLimitPolicy(t *testing.T) {
+	tests := []struct {
+		spec       string
+		wantLimit  int
+		wantWindow time.Duration
+		wantNil    bool
+		wantErr    bool
+	}{
+		{spec: "10/1m", wantLimit: 10, wantWindow: time.Minute},
+		{spec: " 5/1h ", wantLimit: 5, wantWindow: time.Hour},
+		{spec: "", wantNil: true},
+		{spec: "0", wantNil: true},
+		{spec: "10", wantErr: true},
+		{spec: "-1/1m", wantErr: true},
+		{spec: "10/100ms", wantErr: true},
+		{spec: "10/minute", wantErr: true},
+	}
+	for _, tt := range tests {
+		policy, err := ParseRateLimitPolicy("login", tt.spec, KeyByIP(remoteIP))
+		if tt.wantErr {
+			assert.Error(t, err, tt.spec)
+			continue
+		}
+		require.NoError(t, err, tt.spec)
+		if tt.wantNil {
+			assert.Nil(t, policy, tt.spec)
+			continue
+		}
+		assert.Equal(t, tt.wantLimit, policy.Limit)
+		assert.Equal(t, tt.wantWindow, policy.Window)
+	}
+}
+
+func TestRateLimiter_Limit(t *testing.T) {
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	limiter := NewRateLimiter(store.NewRateLimitCounters(), l, 0)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	policy, err := ParseRateLimitPolicy("login", "3/1m", KeyByIP(remoteIP))
+	require.NoError(t, err)
+	handler := limiter.Limit(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	do := func(ip string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/user/login/", nil)
+		request.RemoteAddr = ip
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+		return w
+	}
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		w := do("a")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, strconv.Itoa(remaining), w.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "3;w=60", w.Header().Get("RateLimit-Policy"))
+	}
+	w := do("a")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	var response resultMsg
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, resultMsg{IsError: true, ResultMessage: "Too many requests", Code: "rate_limited"}, response)
+	assert.Equal(t, http.StatusOK, do("b").Code, "other clients are not affected")
+
+	// Через полминуты следующего окна от предыдущего остаётся половина: 4 * 0.5 + 1 = 3 - ещё можно.
+	now = now.Add(90 * time.Second)
+	assert.Equal(t, http.StatusOK, do("a").Code)
+	assert.Equal(t, http.StatusTooManyRequests, do("a").Code)
+
+	// Через два окна лимит восстанавливается полностью.
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, "2", do("a").Header().Get("RateLimit-Remaining"))
+}
+
+// failingRateLimitStore - хранилище счётчиков, которое всегда отказывает.
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) IncrementRateLimit(context.Context, string, time.Time, time.Duration) (int, int, error) {
+	return 0, 0, errors.New("connection refused")
+}
+
+func (failingRateLimitStore) DeleteStaleRateLimits(context.Context, time.Time) (int, error) {
+	return 0, nil
+}
+
+func TestRateLimiter_StoreFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		failClosed bool
+		wantStatus int
+	}{
+		{name: "api_fails_open", wantStatus: http.StatusOK},
+		{name: "login_fails_closed", failClosed: true, wantStatus: http.StatusServiceUnavailable},
+	}
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParseRateLimitPolicy(tt.name, "1/1m", KeyByIP(remoteIP))
+			require.NoError(t, err)
+			policy.FailClosed = tt.failClosed
+			handler := NewRateLimiter(failingRateLimitStore{}, l, 0).Limit(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			for i := 0; i < 3; i++ {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+				assert.Equal(t, tt.wantStatus, w.Code)
+				if tt.failClosed {
+					assert.Contains(t, w.Body.String(), "rate_limit_unavailable")
+				}
+			}
+			assert.Equal(t, "3", rateLimitErrorMetrics.Get(tt.name).String(), "every failure is counted")
+
+			// Отменённый клиентом запрос ошибкой хранилища не считается.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			handler = NewRateLimiter(canceledRateLimitStore{}, l, 0).Limit(policy)(http.NotFoundHandler())
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+			assert.Equal(t, "3", rateLimitErrorMetrics.Get(tt.name).String())
+		})
+	}
+}
+
+// canceledRateLimitStore отвечает так, как хранилище отвечает на отменённый запрос.
+type canceledRateLimitStore struct{ failingRateLimitStore }
+
+func (canceledRateLimitStore) IncrementRateLimit(ctx context.Context, _ string, _ time.Time, _ time.Duration) (int, int, error) {
+	return 0, 0, ctx.Err()
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.RemoteAddr = "192.0.2.1"
+	assert.Equal(t, "ip:192.0.2.1", KeyByUser(remoteIP)(request))
+	assert.Equal(t, "ip:192.0.2.1", KeyByToken(remoteIP)(request))
+
+	withUser := request.WithContext(context.WithValue(request.Context(), auth.KeyUserIDCtx, 42))
+	assert.Equal(t, "user:42", KeyByUser(remoteIP)(withUser))
+
+	bearer := request.Clone(context.Background())
+	bearer.Header.Set("Authorization", "Bearer secret-token")
+	cookie := request.Clone(context.Background())
+	cookie.AddCookie(&http.Cookie{Name: "token", Value: "secret-token"})
+	key := KeyByToken(remoteIP)(bearer)
+	assert.Regexp(t, `^token:[0-9a-f]{32}$`, key)
+	assert.NotContains(t, key, "secret")
+	assert.Equal(t, key, KeyByToken(remoteIP)(cookie), "the same token is the same key wherever it comes from")
+}
@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+type Key string
+
+const (
+	KeyRequestIDCtx Key = "request_id_ctx"
+	// RequestIDHeader - заголовок, в котором id запроса приходит от балансировщика и возвращается клиенту.
+	RequestIDHeader = "X-Request-ID"
+)
+
+// requestIDPattern - какие id из входящего заголовка принимаются как есть.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID присваивает запросу id, по которому его можно найти в логах и журнале безопасности.
+// Id от балансировщика сохраняется, если он похож на id, иначе генерируется новый.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, gotRequest *http.Request) {
+		requestID := gotRequest.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		responseWriter.Header().Set(RequestIDHeader, requestID)
+		ctx := context.WithValue(gotRequest.Context(), KeyRequestIDCtx, requestID)
+		next.ServeHTTP(responseWriter, gotRequest.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext возвращает id запроса или пустую строку, если мидлвар не подключён.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(KeyRequestIDCtx).(string)
+	return requestID
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
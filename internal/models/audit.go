@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// AuditEventType - вид события в журнале безопасности.
+type AuditEventType string
+
+const (
+	AuditRegistration   AuditEventType = "user.registration"
+	AuditLogin          AuditEventType = "user.login"
+	AuditLogout         AuditEventType = "user.logout"
+	AuditPasswordChange AuditEventType = "user.password_change"
+	AuditRoleChange     AuditEventType = "user.role_change"
+	AuditStatusChange   AuditEventType = "user.status_change"
+)
+
+// AuditResult - чем закончилось действие.
+type AuditResult string
+
+const (
+	AuditSuccess AuditResult = "success"
+	AuditFailure AuditResult = "failure"
+)
+
+// AuditEvent - запись журнала безопасности. Записи только добавляются, а Hash сцепляет каждую
+// с предыдущей, так что правку или удаление строки задним числом видно при проверке цепочки.
+type AuditEvent struct {
+	ID         int64          `json:"id"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	Type       AuditEventType `json:"type"`
+	ActorID    *int           `json:"actor_id,omitempty"`  // Кто выполнил действие; пусто для анонимных запросов и служебных команд.
+	TargetID   *int           `json:"target_id,omitempty"` // Над чьей учётной записью.
+	IP         string         `json:"ip"`
+	UserAgent  string         `json:"user_agent"`
+	Result     AuditResult    `json:"result"`
+	Reason     string         `json:"reason,omitempty"`
+	RequestID  string         `json:"request_id,omitempty"`
+	PrevHash   []byte         `json:"prev_hash"`
+	Hash       []byte         `json:"hash"`
+}
+
+// AuditQuery - фильтры и страница журнала. Пустые поля не фильтруют, страницы идут по возрастанию id.
+type AuditQuery struct {
+	Type     AuditEventType
+	ActorID  *int
+	TargetID *int
+	UserID   *int // Пользователь выступает либо исполнителем, либо целью.
+	Result   AuditResult
+	From     time.Time // Включительно.
+	To       time.Time // Не включительно.
+	AfterID  int64
+	Limit    int
+}
+
+// AuditVerification - результат проверки цепочки хэшей.
+type AuditVerification struct {
+	Checked    int   `json:"checked"`
+	Valid      bool  `json:"valid"`
+	BrokenAtID int64 `json:"broken_at_id,omitempty"` // Первая запись, на которой цепочка не сошлась.
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Device - устройство, с которого пользователь уже входил. Устройство узнаётся по семейству клиента
+// (браузер и ОС) и подсети адреса, так что смена версии браузера или адреса внутри подсети не делает его новым.
+type Device struct {
+	ID           int64     `json:"id"`
+	UserID       int       `json:"-"`
+	ClientFamily string    `json:"client_family"` // Например "Firefox/Linux".
+	Subnet       string    `json:"subnet"`        // /24 для IPv4, /48 для IPv6.
+	LastIP       string    `json:"last_ip"`
+	UserAgent    string    `json:"user_agent"`
+	FirstSeenAt  time.Time `json:"first_seen_at"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+}
+
+// LoginDecision - как поступить со входом по оценке риска.
+type LoginDecision string
+
+const (
+	LoginAllow  LoginDecision = "allow"   // Вход обычный.
+	LoginNotify LoginDecision = "notify"  // Вход разрешён, но пользователю сообщается о новом устройстве.
+	LoginStepUp LoginDecision = "step_up" // Нужен код подтверждения из письма.
+	LoginBlock  LoginDecision = "block"   // Вход отклонён.
+)
+
+// LoginRisk - оценка риска входа.
+type LoginRisk struct {
+	Score    int           `json:"score"` // От 0 до 100.
+	Decision LoginDecision `json:"decision"`
+	Reasons  []string      `json:"reasons,omitempty"` // Что повысило оценку: new_client, new_subnet, recent_failures.
+	Device   Device        `json:"device"`            // Устройство текущего входа; ID пуст, если оно новое.
+}
+
+// LoginChallenge - код подтверждения входа с устройства, для которого оценка риска потребовала второй фактор.
+// На устройство действует один код: новый заменяет прежний.
+type LoginChallenge struct {
+	UserID       int
+	ClientFamily string
+	Subnet       string
+	CodeHash     string // SHA-256 кода в hex: сам код есть только в письме.
+	Attempts     int    // Сколько раз вводили неверный код.
+	ExpiresAt    time.Time
+}
@@ -0,0 +1,8 @@
+package models
+
+// FieldError - ошибка валидации конкретного поля запроса.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// DomainEventType - что произошло с учётной записью.
+type DomainEventType string
+
+const (
+	EventUserRegistered      DomainEventType = "user.registered"
+	EventUserLoggedIn        DomainEventType = "user.logged_in"
+	EventUserNewDeviceLogin  DomainEventType = "user.new_device_login" // Вход с незнакомого устройства, вдобавок к user.logged_in.
+	EventUserPasswordChanged DomainEventType = "user.password_changed"
+	EventUserProfileUpdated  DomainEventType = "user.profile_updated"
+	EventUserStatusChanged   DomainEventType = "user.status_changed"
+	EventUserRoleChanged     DomainEventType = "user.role_changed"
+	EventUserSessionsRevoked DomainEventType = "user.sessions_revoked"
+	EventUserDeleted         DomainEventType = "user.deleted"
+	EventUserRestored        DomainEventType = "user.restored"
+	EventUserPurged          DomainEventType = "user.purged"
+)
+
+// DomainEvent - событие об изменении учётной записи. Записывается в outbox той же транзакцией,
+// что и само изменение, и затем рассылается подписчикам как минимум один раз.
+type DomainEvent struct {
+	ID         string          `json:"id"` // Одинаков при повторной рассылке, по нему получатели отсеивают дубли.
+	Type       DomainEventType `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	UserID     int             `json:"user_id"`
+	Login      string          `json:"login,omitempty"`
+	Status     UserStatus      `json:"status,omitempty"`
+	Role       UserRole        `json:"role,omitempty"`
+	IP         string          `json:"ip,omitempty"` // Адрес и клиент запроса, если событие вызвал сам пользователь.
+	UserAgent  string          `json:"user_agent,omitempty"`
+}
+
+// OutboxEvent - строка outbox: событие и состояние его рассылки.
+type OutboxEvent struct {
+	ID            int64       `json:"id"`
+	Event         DomainEvent `json:"event"`
+	Attempts      int         `json:"attempts"`
+	NextAttemptAt time.Time   `json:"next_attempt_at"`
+	LastError     string      `json:"last_error,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	PublishedAt   *time.Time  `json:"published_at,omitempty"`
+	DeadAt        *time.Time  `json:"dead_at,omitempty"` // Когда попытки кончились; такое событие больше не рассылается.
+}
+
+// SecurityEvent - уведомление пользователя о событии, важном для безопасности его учётной записи.
+type SecurityEvent struct {
+	ID         int64           `json:"id"` // Растёт по порядку, служит id события в потоке.
+	EventID    string          `json:"-"`  // Id исходного события, по нему отсеиваются повторы из outbox.
+	UserID     int             `json:"-"`
+	Type       DomainEventType `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	IP         string          `json:"ip,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ExportStatus - состояние выгрузки персональных данных.
+type ExportStatus string
+
+const (
+	ExportPending ExportStatus = "pending"
+	ExportReady   ExportStatus = "ready"
+	ExportFailed  ExportStatus = "failed"
+)
+
+// ExportJob - одна выгрузка данных пользователя. Задание и собранный архив хранятся в базе,
+// так что выгрузка переживает перезапуск и её может собрать и отдать любой экземпляр сервера.
+type ExportJob struct {
+	ID         string       `json:"id"`
+	UserID     int          `json:"-"`
+	Status     ExportStatus `json:"status"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  time.Time    `json:"expires_at,omitempty"` // До какого момента архив можно скачать.
+	LeaseUntil time.Time    `json:"-"`                    // Пока идёт сборка, задание не берут другие экземпляры.
+}
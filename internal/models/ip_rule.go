@@ -0,0 +1,83 @@
+package models
+
+import (
+	"net/netip"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// IPRuleAction - пускать или не пускать адреса из диапазона.
+type IPRuleAction string
+
+const (
+	IPRuleAllow IPRuleAction = "allow"
+	IPRuleDeny  IPRuleAction = "deny"
+)
+
+const maxIPRuleCommentLength = 200
+
+// IPRule - диапазон адресов, из которого пользователю или всем пользователям роли можно или нельзя входить.
+// Запрет сильнее разрешения; если у пользователя есть хоть одно разрешение, остальные адреса для него закрыты.
+type IPRule struct {
+	ID        int          `json:"id"`
+	UserID    *int         `json:"user_id,omitempty"` // Задано ровно одно из UserID и Role.
+	Role      UserRole     `json:"role,omitempty"`
+	CIDR      string       `json:"cidr"`
+	Action    IPRuleAction `json:"action"`
+	Comment   string       `json:"comment,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// IPRuleQuery - фильтры списка правил. Пустые поля не фильтруют.
+type IPRuleQuery struct {
+	UserID *int
+	Role   UserRole
+}
+
+// Normalize проверяет правило и приводит диапазон к каноническому виду: одиночный адрес - к /32 или /128,
+// адрес с заполненной хостовой частью - к адресу сети.
+func (r *IPRule) Normalize() (fieldErrors []FieldError) {
+	violation := func(field, code, msg string) {
+		fieldErrors = append(fieldErrors, FieldError{Field: field, Code: code, Message: msg})
+	}
+
+	if (r.UserID == nil) == (r.Role == "") {
+		violation("user_id", "scope", "Exactly one of user_id and role must be set")
+	} else if r.UserID != nil && *r.UserID < 1 {
+		violation("user_id", "invalid", "User id must be positive")
+	} else if r.Role != "" && !r.Role.Valid() {
+		violation("role", "invalid", "Role is not known")
+	}
+	prefix, err := ParseCIDR(r.CIDR)
+	if err != nil {
+		violation("cidr", "invalid", "CIDR must be an IP range like 10.0.0.0/8 or a single address")
+	} else {
+		r.CIDR = prefix.String()
+	}
+	if r.Action != IPRuleAllow && r.Action != IPRuleDeny {
+		violation("action", "invalid", "Action must be allow or deny")
+	}
+	if utf8.RuneCountInString(r.Comment) > maxIPRuleCommentLength {
+		violation("comment", "too_long", "Comment is too long")
+	}
+	return fieldErrors
+}
+
+// ParseCIDR разбирает диапазон адресов или одиночный адрес. Хостовая часть диапазона обнуляется.
+func ParseCIDR(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// NotificationKind - вид письма пользователю.
+type NotificationKind string
+
+const (
+	NotificationWelcome         NotificationKind = "welcome"
+	NotificationNewDeviceLogin  NotificationKind = "new_device_login"
+	NotificationPasswordChanged NotificationKind = "password_changed"
+	NotificationLoginCode       NotificationKind = "login_code"
+)
+
+// NotificationKinds - все виды писем.
+var NotificationKinds = []NotificationKind{
+	NotificationWelcome,
+	NotificationNewDeviceLogin,
+	NotificationPasswordChanged,
+	NotificationLoginCode,
+}
+
+// Valid сообщает, что такой вид писем есть.
+func (k NotificationKind) Valid() bool {
+	for _, known := range NotificationKinds {
+		if k == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Optional сообщает, что от писем этого вида можно отказаться.
+// Без кода подтверждения не войти с нового устройства, поэтому он приходит всегда.
+func (k NotificationKind) Optional() bool {
+	return k != NotificationLoginCode
+}
+
+// NotificationStatus - состояние письма в очереди.
+type NotificationStatus string
+
+const (
+	NotificationPending NotificationStatus = "pending"
+	NotificationSent    NotificationStatus = "sent"
+	NotificationDead    NotificationStatus = "dead" // Попытки исчерпаны или письмо не собрать.
+)
+
+// Notification - письмо в очереди. Текст собирается из шаблона при отправке.
+type Notification struct {
+	ID            int64              `json:"id"`
+	DedupKey      string             `json:"-"` // Повторная постановка с тем же ключом пропускается.
+	UserID        int                `json:"user_id"`
+	Kind          NotificationKind   `json:"kind"`
+	Locale        string             `json:"locale"`
+	Recipient     string             `json:"recipient"`
+	Data          map[string]string  `json:"data"` // Подстановки для шаблона.
+	Status        NotificationStatus `json:"status"`
+	Attempts      int                `json:"attempts"`
+	NextAttemptAt time.Time          `json:"next_attempt_at"`
+	LastError     string             `json:"last_error,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	SentAt        *time.Time         `json:"sent_at,omitempty"`
+}
+
+// NotificationPreference - подписан ли пользователь на письма этого вида.
+type NotificationPreference struct {
+	Kind     NotificationKind `json:"kind"`
+	Enabled  bool             `json:"enabled"`
+	Required bool             `json:"required"` // От таких писем отказаться нельзя.
+}
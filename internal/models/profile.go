@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"golang.org/x/text/language"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"time"
+	// Встроенная база часовых поясов: в контейнере её может не быть, а проверка timezone должна работать везде.
+	_ "time/tzdata"
+	"unicode/utf8"
+)
+
+// Предельные длины полей, как у колонок в таблице users.
+const (
+	maxDisplayNameLength = 100
+	maxEmailLength       = 254
+	maxLocaleLength      = 35
+	maxAvatarURLLength   = 2048
+)
+
+// phonePattern - номер телефона в формате E.164.
+var phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// UserProfilePatch - модель запроса на частичное обновление профиля.
+// Отсутствующее поле не меняется, пустая строка очищает значение.
+type UserProfilePatch struct {
+	DisplayName *string `json:"display_name"`
+	Email       *string `json:"email"`
+	Phone       *string `json:"phone"`
+	Locale      *string `json:"locale"`
+	Timezone    *string `json:"timezone"`
+	AvatarURL   *string `json:"avatar_url"`
+}
+
+// IsEmpty сообщает, что в запросе нет ни одного поля для обновления.
+func (p UserProfilePatch) IsEmpty() bool {
+	return p.DisplayName == nil && p.Email == nil && p.Phone == nil &&
+		p.Locale == nil && p.Timezone == nil && p.AvatarURL == nil
+}
+
+// Normalize проверяет поля и приводит их к каноническому виду (адрес почты без имени, тег локали и т.п.).
+func (p *UserProfilePatch) Normalize() (fieldErrors []FieldError) {
+	violation := func(field, code, msg string) {
+		fieldErrors = append(fieldErrors, FieldError{Field: field, Code: code, Message: msg})
+	}
+
+	if p.DisplayName != nil && utf8.RuneCountInString(*p.DisplayName) > maxDisplayNameLength {
+		violation("display_name", "too_long", fmt.Sprintf("Display name must be at most %d characters long", maxDisplayNameLength))
+	}
+	if p.Email != nil && *p.Email != "" {
+		addr, err := mail.ParseAddress(*p.Email)
+		if err == nil && utf8.RuneCountInString(addr.Address) > maxEmailLength {
+			violation("email", "too_long", fmt.Sprintf("Email must be at most %d characters long", maxEmailLength))
+		} else if err != nil || addr.Name != "" {
+			violation("email", "invalid", "Email is not a valid address")
+		} else {
+			p.Email = &addr.Address
+		}
+	}
+	if p.Phone != nil && *p.Phone != "" && !phonePattern.MatchString(*p.Phone) {
+		violation("phone", "invalid", "Phone must be in international format, e.g. +79991234567")
+	}
+	if p.Locale != nil && *p.Locale != "" {
+		tag, err := language.Parse(*p.Locale)
+		if err != nil {
+			violation("locale", "invalid", "Locale must be a BCP 47 language tag, e.g. ru-RU")
+		} else if len(tag.String()) > maxLocaleLength {
+			violation("locale", "too_long", fmt.Sprintf("Locale must be at most %d characters long", maxLocaleLength))
+		} else {
+			canonical := tag.String()
+			p.Locale = &canonical
+		}
+	}
+	if p.Timezone != nil && *p.Timezone != "" {
+		if _, err := time.LoadLocation(*p.Timezone); err != nil || *p.Timezone == "Local" {
+			violation("timezone", "invalid", "Timezone must be an IANA time zone, e.g. Europe/Moscow")
+		}
+	}
+	if p.AvatarURL != nil && utf8.RuneCountInString(*p.AvatarURL) > maxAvatarURLLength {
+		violation("avatar_url", "too_long", fmt.Sprintf("Avatar URL must be at most %d characters long", maxAvatarURLLength))
+	} else if p.AvatarURL != nil && *p.AvatarURL != "" {
+		u, err := url.Parse(*p.AvatarURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			violation("avatar_url", "invalid", "Avatar URL must be an absolute http(s) URL")
+		}
+	}
+	return fieldErrors
+}
@@ -2,12 +2,37 @@ package models
 
 // UserRegReq - модель запроса на регистрацию.
 type UserRegReq struct {
-	Login    string `json:"login"`
-	Password string `json:"password"`
+	Login    string   `json:"login"`
+	Password string   `json:"password"`
+	Role     UserRole `json:"-"` // Задаёт только администратор при создании пользователя; пусто - обычный пользователь.
 }
 
 // UserLoginReq - модель запроса на авторизацию.
 type UserLoginReq struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
+	Code     string `json:"code,omitempty"` // Код подтверждения из письма, если вход его потребовал.
+}
+
+// AdminCreateUserReq - модель запроса на создание пользователя администратором.
+type AdminCreateUserReq struct {
+	Login    string   `json:"login"`
+	Password string   `json:"password"`
+	Role     UserRole `json:"role"`
+}
+
+// AdminStatusReq - модель запроса на смену статуса пользователя администратором.
+type AdminStatusReq struct {
+	Reason string `json:"reason"`
+}
+
+// AdminPasswordReq - модель запроса на сброс пароля пользователя администратором.
+type AdminPasswordReq struct {
+	Password string `json:"password"`
+}
+
+// WebhookSubscriptionReq - модель запроса на подписку на вебхуки. Пустой event_types - все события.
+type WebhookSubscriptionReq struct {
+	URL        string             `json:"url"`
+	EventTypes []WebhookEventType `json:"event_types"`
 }
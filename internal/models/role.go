@@ -0,0 +1,14 @@
+package models
+
+// UserRole - роль пользователя, от неё зависит доступ к служебным ручкам.
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = "user"
+	UserRoleAdmin UserRole = "admin"
+)
+
+// Valid сообщает, что роль входит в список известных.
+func (r UserRole) Valid() bool {
+	return r == UserRoleUser || r == UserRoleAdmin
+}
@@ -0,0 +1,21 @@
+package models
+
+// UserSearchQuery - запрос поиска пользователей. Результаты идут по убыванию релевантности,
+// при равной релевантности - по возрастанию id; After продолжает выдачу после указанной позиции.
+type UserSearchQuery struct {
+	Query string
+	After *SearchCursor
+	Limit int
+}
+
+// SearchCursor - позиция последнего результата на предыдущей странице.
+type SearchCursor struct {
+	Rank float32
+	ID   int
+}
+
+// UserSearchResult - найденный пользователь и его релевантность.
+type UserSearchResult struct {
+	User User    `json:"user"`
+	Rank float32 `json:"rank"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// UserStatus - состояние учётной записи. Войти и пользоваться токеном может только активный пользователь.
+type UserStatus string
+
+const (
+	UserStatusPending   UserStatus = "pending"   // Создан, но ещё не подтверждён.
+	UserStatusActive    UserStatus = "active"    // Обычное рабочее состояние.
+	UserStatusSuspended UserStatus = "suspended" // Временно заблокирован администратором.
+	UserStatusLocked    UserStatus = "locked"    // Заблокирован по соображениям безопасности.
+	UserStatusDeleted   UserStatus = "deleted"   // Удалён, войти нельзя.
+)
+
+// statusTransitions - в какие состояния можно перевести учётную запись из текущего.
+var statusTransitions = map[UserStatus][]UserStatus{
+	UserStatusPending:   {UserStatusActive, UserStatusDeleted},
+	UserStatusActive:    {UserStatusSuspended, UserStatusLocked, UserStatusDeleted},
+	UserStatusSuspended: {UserStatusActive, UserStatusLocked, UserStatusDeleted},
+	UserStatusLocked:    {UserStatusActive, UserStatusSuspended, UserStatusDeleted},
+	UserStatusDeleted:   {}, // Восстановление идёт отдельно и только в течение срока на восстановление.
+}
+
+// Valid сообщает, что статус входит в список известных.
+func (s UserStatus) Valid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+// CanTransitionTo сообщает, разрешён ли переход из текущего статуса в next.
+func (s UserStatus) CanTransitionTo(next UserStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorCode - машиночитаемый код ошибки, которым отвечаем неактивному пользователю.
+func (s UserStatus) ErrorCode() string {
+	return "account_" + string(s)
+}
+
+// UserAuthState - то, что нужно знать о пользователе, чтобы принять его токен.
+type UserAuthState struct {
+	Role            UserRole
+	Status          UserStatus
+	StatusChangedAt time.Time
+	// TokensValidAfter - токены, выданные раньше, отозваны. Нулевое значение - отзыва не было.
+	TokensValidAfter time.Time
+	// IPRules - ограничения по адресам, заданные пользователю и его роли.
+	IPRules []IPRule
+}
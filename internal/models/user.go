@@ -4,10 +4,21 @@ import "time"
 
 // User - модель пользователя.
 type User struct {
-	ID           int       `json:"id"`
-	Login        string    `json:"login"`
-	PasswordHash string    `json:"-"`
-	Salt         string    `json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID              int        `json:"id"`
+	Login           string     `json:"login"`
+	PasswordHash    string     `json:"-"`
+	Salt            string     `json:"-"`
+	DisplayName     string     `json:"display_name"`
+	Email           string     `json:"email"`
+	Phone           string     `json:"phone"`
+	Locale          string     `json:"locale"`
+	Timezone        string     `json:"timezone"`
+	AvatarURL       string     `json:"avatar_url"`
+	Role            UserRole   `json:"role"`
+	Status          UserStatus `json:"status"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusChangedAt time.Time  `json:"status_changed_at"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// UserListQuery - фильтры и страница для списка пользователей. Пустые поля не фильтруют.
+// Страницы нарезаются по id: следующая начинается после последнего id предыдущей.
+type UserListQuery struct {
+	Status      UserStatus
+	CreatedFrom time.Time // Включительно.
+	CreatedTo   time.Time // Не включительно.
+	LoginPrefix string
+	AfterID     int
+	Limit       int
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookEventType - событие, о котором сообщается внешним системам.
+type WebhookEventType string
+
+const (
+	WebhookUserRegistered      WebhookEventType = "user.registered"
+	WebhookUserLoggedIn        WebhookEventType = "user.logged_in"
+	WebhookUserPasswordChanged WebhookEventType = "user.password_changed"
+	WebhookUserDeleted         WebhookEventType = "user.deleted"
+)
+
+// WebhookEventTypes - все события, на которые можно подписаться.
+var WebhookEventTypes = []WebhookEventType{
+	WebhookUserRegistered,
+	WebhookUserLoggedIn,
+	WebhookUserPasswordChanged,
+	WebhookUserDeleted,
+}
+
+// Valid сообщает, что на событие можно подписаться.
+func (t WebhookEventType) Valid() bool {
+	for _, known := range WebhookEventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvent - событие в том виде, в каком оно уходит получателю.
+type WebhookEvent struct {
+	ID         string           `json:"id"` // Одинаков во всех повторах, получатель может по нему отсеять дубли.
+	Type       WebhookEventType `json:"type"`
+	OccurredAt time.Time        `json:"occurred_at"`
+	Data       WebhookUserData  `json:"data"`
+}
+
+// WebhookUserData - пользователь, с которым произошло событие.
+type WebhookUserData struct {
+	UserID int    `json:"user_id"`
+	Login  string `json:"login"`
+}
+
+// WebhookSubscription - адрес, куда доставляются события. Пустой EventTypes - все события.
+// Секрет показывается только при создании подписки.
+type WebhookSubscription struct {
+	ID         int                `json:"id"`
+	URL        string             `json:"url"`
+	EventTypes []WebhookEventType `json:"event_types"`
+	Secret     string             `json:"secret,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// Accepts сообщает, подписан ли адрес на событие.
+func (s WebhookSubscription) Accepts(eventType WebhookEventType) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeliveryStatus - состояние доставки одного события одному подписчику.
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"   // Ждёт первой или очередной попытки.
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered" // Получатель ответил 2xx.
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"      // Попытки кончились, помочь может только повтор вручную.
+)
+
+// WebhookDelivery - доставка события подписчику.
+type WebhookDelivery struct {
+	ID             int64                 `json:"id"`
+	SubscriptionID int                   `json:"subscription_id"`
+	EventID        string                `json:"event_id"`
+	EventType      WebhookEventType      `json:"event_type"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	LastError      string                `json:"last_error,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+
+	// Куда и с каким ключом отправлять, заполняется при выборке доставок на отправку.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+	// UserID - чьё это событие, по нему доставки удаляются вместе с пользователем.
+	UserID int `json:"-"`
+}
+
+// WebhookAttempt - запись журнала доставки об одной попытке.
+type WebhookAttempt struct {
+	ID          int64     `json:"id"`
+	DeliveryID  int64     `json:"delivery_id"`
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  int       `json:"status_code,omitempty"` // 0 - ответа не было.
+	Error       string    `json:"error,omitempty"`
+	DurationMS  int64     `json:"duration_ms"`
+}
+
+// WebhookDeliveryQuery - фильтры и страница журнала доставок, страницы идут по возрастанию id.
+type WebhookDeliveryQuery struct {
+	SubscriptionID int
+	Status         WebhookDeliveryStatus
+	AfterID        int64
+	Limit          int
+}
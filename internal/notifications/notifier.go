@@ -0,0 +1,250 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Письма пользователям: события из outbox и коды подтверждения входа ставят письмо в очередь, а Run собирает его
+// из шаблона на языке пользователя и отправляет через транспорт, повторяя неудачные попытки с растущей паузой.
+// Синхронно ничего не отправляется, так что недоступный почтовый сервер не задерживает ответы API.
+
+// Store - то, что нужно письмам от хранилища.
+type Store interface {
+	GetUserByID(ctx context.Context, userID int) (user *models.User, err error)
+	GetNotificationOptOuts(ctx context.Context, userID int) (kinds []models.NotificationKind, err error)
+	EnqueueNotification(ctx context.Context, n models.Notification) (enqueued bool, err error)
+	ClaimNotifications(ctx context.Context, now, leaseUntil time.Time, limit int) (notifications []models.Notification, err error)
+	RecordNotificationResult(ctx context.Context, n models.Notification) (err error)
+}
+
+// eventNotifications - какое письмо отправляется по какому событию.
+var eventNotifications = map[models.DomainEventType]models.NotificationKind{
+	models.EventUserRegistered:      models.NotificationWelcome,
+	models.EventUserNewDeviceLogin:  models.NotificationNewDeviceLogin,
+	models.EventUserPasswordChanged: models.NotificationPasswordChanged,
+}
+
+type Notifier struct {
+	store        Store
+	logger       *logger.ZapLog
+	templates    *Templates
+	transport    Transport
+	timeout      time.Duration
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	retryBase    time.Duration
+	retryMax     time.Duration
+	now          func() time.Time
+}
+
+func NewNotifier(s Store, c *server_config.ServerConfig, l *logger.ZapLog, transport Transport) (*Notifier, error) {
+	defaultLocale := c.NotifyDefaultLocale
+	if defaultLocale == "" {
+		defaultLocale = "en"
+	}
+	templates, err := LoadTemplates(defaultLocale)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notification templates: %w", err)
+	}
+	n := &Notifier{
+		store:        s,
+		logger:       l,
+		templates:    templates,
+		transport:    transport,
+		timeout:      c.NotifyTimeout,
+		pollInterval: c.NotifyPollInterval,
+		batchSize:    c.NotifyBatchSize,
+		maxAttempts:  c.NotifyMaxAttempts,
+		retryBase:    c.NotifyRetryBase,
+		retryMax:     c.NotifyRetryMax,
+		now:          time.Now,
+	}
+	if n.timeout <= 0 {
+		n.timeout = 30 * time.Second
+	}
+	if n.batchSize <= 0 {
+		n.batchSize = 20
+	}
+	if n.maxAttempts <= 0 {
+		n.maxAttempts = 1
+	}
+	if n.retryBase <= 0 {
+		n.retryBase = time.Minute
+	}
+	if n.retryMax < n.retryBase {
+		n.retryMax = n.retryBase
+	}
+	return n, nil
+}
+
+// enqueue ставит пользователю письмо вида kind. Повтор с тем же dedupKey пропускается.
+// Письмо не ставится, если у пользователя нет почты или он отказался от писем этого вида.
+func (n *Notifier) enqueue(ctx context.Context, userID int, kind models.NotificationKind, dedupKey string, data map[string]string) (enqueued bool, err error) {
+	user, err := n.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.Email == "" {
+		return false, nil
+	}
+	if kind.Optional() {
+		optOuts, err := n.store.GetNotificationOptOuts(ctx, userID)
+		if err != nil {
+			return false, fmt.Errorf("failed to get notification opt-outs: %w", err)
+		}
+		for _, optOut := range optOuts {
+			if optOut == kind {
+				return false, nil
+			}
+		}
+	}
+	values := map[string]string{
+		"Login":       user.Login,
+		"DisplayName": user.DisplayName,
+	}
+	for key, value := range data {
+		values[key] = value
+	}
+	enqueued, err = n.store.EnqueueNotification(ctx, models.Notification{
+		DedupKey:  dedupKey,
+		UserID:    userID,
+		Kind:      kind,
+		Locale:    user.Locale,
+		Recipient: user.Email,
+		Data:      values,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue notification: %w", err)
+	}
+	return enqueued, nil
+}
+
+// SendLoginCode ставит письмо с кодом подтверждения входа с устройства device. enqueued ложно,
+// если у пользователя нет почты: тогда код ему не доставить.
+func (n *Notifier) SendLoginCode(ctx context.Context, userID int, code string, device models.Device, expiresAt time.Time) (enqueued bool, err error) {
+	return n.enqueue(ctx, userID, models.NotificationLoginCode, "login-code:"+randomHex(16), map[string]string{
+		"Code":      code,
+		"Device":    device.ClientFamily + ", " + device.LastIP,
+		"ExpiresAt": expiresAt.UTC().Format("2006-01-02 15:04 MST"),
+	})
+}
+
+// Name - имя получателя outbox.
+func (n *Notifier) Name() string {
+	return "notifications"
+}
+
+// Publish ставит письмо по событию из outbox. События, о которых писем нет, пропускаются.
+func (n *Notifier) Publish(ctx context.Context, event models.DomainEvent) error {
+	kind, ok := eventNotifications[event.Type]
+	if !ok {
+		return nil
+	}
+	_, err := n.enqueue(ctx, event.UserID, kind, event.ID+":"+string(kind), map[string]string{
+		"OccurredAt": event.OccurredAt.UTC().Format("2006-01-02 15:04 MST"),
+		"IP":         event.IP,
+		"UserAgent":  event.UserAgent,
+	})
+	if errors.Is(err, store.ErrUserNotFound) {
+		// Пользователя уже удалили окончательно - писать некому.
+		return nil
+	}
+	return err
+}
+
+// Run отправляет очередь раз в pollInterval, пока не отменён ctx. При нулевом pollInterval сразу возвращается.
+func (n *Notifier) Run(ctx context.Context) {
+	if n.pollInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(n.pollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := n.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			n.logger.ZL.Error("failed to send notifications", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce отправляет все письма, которым пора уходить, пачками по batchSize.
+func (n *Notifier) RunOnce(ctx context.Context) (sent int, err error) {
+	for {
+		now := n.now()
+		// Пока идёт отправка, письмо арендовано; упавший отправитель отдаст его после аренды.
+		batch, err := n.store.ClaimNotifications(ctx, now, now.Add(time.Duration(n.batchSize+1)*n.timeout), n.batchSize)
+		if err != nil {
+			return sent, fmt.Errorf("failed to claim notifications: %w", err)
+		}
+		for _, notification := range batch {
+			n.deliver(ctx, notification)
+		}
+		sent += len(batch)
+		if len(batch) < n.batchSize || ctx.Err() != nil {
+			return sent, nil
+		}
+	}
+}
+
+// deliver собирает и отправляет одно письмо и записывает итог.
+func (n *Notifier) deliver(ctx context.Context, notification models.Notification) {
+	notification.Attempts++
+	msg, err := n.templates.Render(notification)
+	if err != nil {
+		// Шаблон не собрать - повтор не поможет.
+		notification.Status = models.NotificationDead
+		notification.LastError = err.Error()
+		n.record(ctx, notification)
+		return
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, n.timeout)
+	err = n.transport.Send(sendCtx, msg)
+	cancel()
+	switch {
+	case err == nil:
+		sentAt := n.now()
+		notification.Status = models.NotificationSent
+		notification.SentAt = &sentAt
+		notification.LastError = ""
+	case notification.Attempts >= n.maxAttempts:
+		notification.Status = models.NotificationDead
+		notification.LastError = err.Error()
+		n.logger.ZL.Warn("notification is dead",
+			zap.Int64("notification_id", notification.ID), zap.Int("attempts", notification.Attempts), zap.Error(err))
+	default:
+		notification.LastError = err.Error()
+		notification.NextAttemptAt = n.now().Add(n.retryDelay(notification.Attempts))
+	}
+	n.record(ctx, notification)
+}
+
+func (n *Notifier) record(ctx context.Context, notification models.Notification) {
+	if err := n.store.RecordNotificationResult(ctx, notification); err != nil && !errors.Is(err, store.ErrNotificationNotFound) {
+		n.logger.ZL.Error("failed to record notification result", zap.Int64("notification_id", notification.ID), zap.Error(err))
+	}
+}
+
+// retryDelay - пауза после attempts неудачных попыток: retryBase, дальше вдвое больше, но не дольше retryMax.
+func (n *Notifier) retryDelay(attempts int) time.Duration {
+	delay := n.retryBase
+	for i := 1; i < attempts && delay < n.retryMax; i++ {
+		delay *= 2
+	}
+	if delay > n.retryMax {
+		delay = n.retryMax
+	}
+	return delay
+}
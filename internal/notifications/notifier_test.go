@@ -0,0 +1,163 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStore - очередь писем в памяти и несколько пользователей.
+type fakeStore struct {
+	*store.NotificationQueue
+	users map[int]models.User
+}
+
+func (f *fakeStore) GetUserByID(_ context.Context, userID int) (*models.User, error) {
+	user, ok := f.users[userID]
+	if !ok {
+		return nil, store.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func newTestNotifier(t *testing.T) (*Notifier, *fakeStore, *MemoryTransport, *time.Time) {
+	t.Helper()
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	s := &fakeStore{
+		NotificationQueue: store.NewNotificationQueue(),
+		users: map[int]models.User{
+			1: {ID: 1, Login: "anna", DisplayName: "Анна", Email: "anna@example.com", Locale: "ru-RU"},
+			2: {ID: 2, Login: "boris", Email: "boris@example.com", Locale: "de"},
+			3: {ID: 3, Login: "no-mail"},
+		},
+	}
+	transport := NewMemoryTransport()
+	n, err := NewNotifier(s, &server_config.ServerConfig{
+		NotifyBatchSize:   10,
+		NotifyMaxAttempts: 2,
+		NotifyRetryBase:   time.Minute,
+		NotifyRetryMax:    time.Hour,
+	}, l, transport)
+	require.NoError(t, err)
+	// Письма ставятся в очередь по настоящему времени, поэтому часы начинают с него.
+	now := time.Now().Add(time.Second)
+	n.now = func() time.Time { return now }
+	return n, s, transport, &now
+}
+
+func TestNotifier_Publish(t *testing.T) {
+	ctx := context.Background()
+	n, s, transport, _ := newTestNotifier(t)
+	require.NoError(t, s.SetNotificationOptOut(ctx, 2, models.NotificationPasswordChanged, true))
+
+	registered := store.NewDomainEvent(models.EventUserRegistered, &models.User{ID: 1})
+	for _, event := range []models.DomainEvent{
+		registered,
+		registered, // Повтор из outbox.
+		store.NewDomainEvent(models.EventUserRegistered, &models.User{ID: 2}),
+		store.NewDomainEvent(models.EventUserPasswordChanged, &models.User{ID: 2}), // Отказался.
+		store.NewDomainEvent(models.EventUserRegistered, &models.User{ID: 3}),      // Нет почты.
+		store.NewDomainEvent(models.EventUserRegistered, &models.User{ID: 9}),      // Уже удалён.
+		store.NewDomainEvent(models.EventUserProfileUpdated, &models.User{ID: 1}),  // Писем нет.
+	} {
+		require.NoError(t, n.Publish(ctx, event))
+	}
+	require.Len(t, s.Notifications(), 2)
+
+	sent, err := n.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	messages := transport.Messages()
+	require.Len(t, messages, 2)
+	assert.Equal(t, "anna@example.com", messages[0].To)
+	assert.Equal(t, "Добро пожаловать в Raya", messages[0].Subject)
+	assert.Contains(t, messages[0].Text, "Здравствуйте, Анна!")
+	// Шаблонов на немецком нет - письмо уходит на языке по умолчанию.
+	assert.Equal(t, "Welcome to Raya", messages[1].Subject)
+	assert.Contains(t, messages[1].HTML, "<b>boris</b>")
+	for _, notification := range s.Notifications() {
+		assert.Equal(t, models.NotificationSent, notification.Status)
+	}
+}
+
+func TestNotifier_RetriesThenDead(t *testing.T) {
+	ctx := context.Background()
+	n, s, transport, now := newTestNotifier(t)
+	_, err := n.enqueue(ctx, 1, models.NotificationPasswordChanged, "password-1", nil)
+	require.NoError(t, err)
+	transport.Fail(errors.New("connection refused"))
+
+	_, err = n.RunOnce(ctx)
+	require.NoError(t, err)
+	notification := s.Notifications()[0]
+	assert.Equal(t, models.NotificationPending, notification.Status)
+	assert.Equal(t, now.Add(time.Minute), notification.NextAttemptAt)
+	assert.Equal(t, "connection refused", notification.LastError)
+
+	sent, err := n.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sent, "retry must wait for the backoff")
+
+	*now = now.Add(time.Minute)
+	_, err = n.RunOnce(ctx)
+	require.NoError(t, err)
+	notification = s.Notifications()[0]
+	assert.Equal(t, models.NotificationDead, notification.Status)
+	assert.Equal(t, 2, notification.Attempts)
+	assert.Empty(t, transport.Messages())
+}
+
+func TestTemplates_Render(t *testing.T) {
+	templates, err := LoadTemplates("en")
+	require.NoError(t, err)
+	for _, locale := range []string{"en", "ru"} {
+		for _, kind := range models.NotificationKinds {
+			msg, err := templates.Render(models.Notification{
+				Kind: kind, Locale: locale, Recipient: "anna@example.com",
+				Data: map[string]string{"Login": "anna"},
+			})
+			require.NoError(t, err, "%s/%s", locale, kind)
+			assert.NotEmpty(t, msg.Subject)
+			assert.NotContains(t, msg.Text, "<no value>")
+		}
+	}
+
+	// Подстановки в HTML экранируются.
+	msg, err := templates.Render(models.Notification{Kind: models.NotificationWelcome, Data: map[string]string{"Login": "<script>"}})
+	require.NoError(t, err)
+	assert.NotContains(t, msg.HTML, "<script>")
+
+	_, err = LoadTemplates("fr")
+	assert.Error(t, err, "default locale must have every template")
+}
+
+func TestFileTransport(t *testing.T) {
+	dir := t.TempDir()
+	transport, err := NewFileTransport(dir, "Raya <no-reply@raya.local>")
+	require.NoError(t, err)
+	require.NoError(t, transport.Send(context.Background(), Message{
+		To: "anna@example.com", Subject: "Пароль изменён", Text: "текст", HTML: "<p>текст</p>",
+	}))
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	body, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(body), "From: \"Raya\" <no-reply@raya.local>\r\nTo: <anna@example.com>\r\n"))
+	assert.Contains(t, string(body), "Subject: =?utf-8?q?")
+	assert.Contains(t, string(body), "Content-Type: multipart/alternative")
+
+	err = transport.Send(context.Background(), Message{To: "anna@example.com\r\nBcc: x@example.com"})
+	assert.Error(t, err, "header injection is rejected")
+}
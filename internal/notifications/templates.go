@@ -0,0 +1,131 @@
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Шаблоны лежат в templates/<язык>/<вид>.txt и <вид>.html. Текстовый шаблон задаёт блоки subject и body,
+// HTML-шаблон - тело письма целиком. Подстановки берутся из Notification.Data; отсутствующие пусты.
+
+//go:embed templates
+var templateFS embed.FS
+
+// Message - собранное письмо.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Templates - шаблоны писем по языкам.
+type Templates struct {
+	defaultLocale string
+	text          map[string]*texttemplate.Template
+	html          map[string]*htmltemplate.Template
+}
+
+// LoadTemplates разбирает встроенные шаблоны. Для defaultLocale обязаны быть шаблоны всех видов писем:
+// на него переключаются, когда на языке пользователя писем нет.
+func LoadTemplates(defaultLocale string) (*Templates, error) {
+	return loadTemplates(templateFS, "templates", defaultLocale)
+}
+
+func loadTemplates(fsys fs.FS, root, defaultLocale string) (*Templates, error) {
+	t := &Templates{
+		defaultLocale: normalizeLocale(defaultLocale),
+		text:          make(map[string]*texttemplate.Template),
+		html:          make(map[string]*htmltemplate.Template),
+	}
+	locales, err := fs.ReadDir(fsys, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+	for _, locale := range locales {
+		if !locale.IsDir() {
+			continue
+		}
+		for _, kind := range models.NotificationKinds {
+			key := templateKey(locale.Name(), kind)
+			base := root + "/" + locale.Name() + "/" + string(kind)
+			text, textErr := texttemplate.New(string(kind)).Option("missingkey=zero").ParseFS(fsys, base+".txt")
+			html, htmlErr := htmltemplate.New(string(kind)).Option("missingkey=zero").ParseFS(fsys, base+".html")
+			if textErr != nil || htmlErr != nil {
+				// Вида письма на этом языке может не быть - тогда пишем на языке по умолчанию.
+				if locale.Name() == t.defaultLocale {
+					return nil, fmt.Errorf("failed to parse %s templates: %w", key, firstErr(textErr, htmlErr))
+				}
+				continue
+			}
+			if text.Lookup("subject") == nil || text.Lookup("body") == nil {
+				return nil, fmt.Errorf("template %s.txt must define subject and body", key)
+			}
+			t.text[key] = text
+			t.html[key] = html.Lookup(string(kind) + ".html")
+		}
+	}
+	for _, kind := range models.NotificationKinds {
+		if t.text[templateKey(t.defaultLocale, kind)] == nil {
+			return nil, fmt.Errorf("no %s templates for default locale %q", kind, t.defaultLocale)
+		}
+	}
+	return t, nil
+}
+
+// Render собирает письмо на языке пользователя, а если такого нет - на языке по умолчанию.
+func (t *Templates) Render(n models.Notification) (Message, error) {
+	key := templateKey(normalizeLocale(n.Locale), n.Kind)
+	if t.text[key] == nil {
+		key = templateKey(t.defaultLocale, n.Kind)
+	}
+	text, html := t.text[key], t.html[key]
+	if text == nil {
+		return Message{}, fmt.Errorf("no templates for %s", n.Kind)
+	}
+	msg := Message{To: n.Recipient}
+	var buf bytes.Buffer
+	if err := text.ExecuteTemplate(&buf, "subject", n.Data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s subject: %w", key, err)
+	}
+	msg.Subject = strings.TrimSpace(buf.String())
+	buf.Reset()
+	if err := text.ExecuteTemplate(&buf, "body", n.Data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s text: %w", key, err)
+	}
+	msg.Text = strings.TrimLeft(buf.String(), "\n")
+	buf.Reset()
+	if err := html.Execute(&buf, n.Data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s html: %w", key, err)
+	}
+	msg.HTML = buf.String()
+	return msg, nil
+}
+
+// normalizeLocale оставляет от локали только язык: "ru-RU" и "ru_RU" превращаются в "ru".
+func normalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+	return locale
+}
+
+func templateKey(locale string, kind models.NotificationKind) string {
+	return locale + "/" + string(kind)
+}
+
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transport доставляет собранные письма.
+type Transport interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Способы доставки писем.
+const (
+	TransportSMTP   = "smtp"
+	TransportFile   = "file"
+	TransportMemory = "memory"
+)
+
+// NewTransport создаёт транспорт, выбранный в конфигурации. Пустой NotifyTransport - письма не отправляются, nil.
+func NewTransport(c *server_config.ServerConfig) (Transport, error) {
+	switch c.NotifyTransport {
+	case "":
+		return nil, nil
+	case TransportSMTP:
+		return NewSMTPTransport(c.SMTPAddr, c.SMTPUsername, c.SMTPPassword, c.MailFrom)
+	case TransportFile:
+		return NewFileTransport(c.NotifyFileDir, c.MailFrom)
+	case TransportMemory:
+		return NewMemoryTransport(), nil
+	default:
+		return nil, fmt.Errorf("unknown notification transport %q", c.NotifyTransport)
+	}
+}
+
+// SMTPTransport отправляет письма через SMTP-сервер. STARTTLS включается, если сервер его предлагает.
+type SMTPTransport struct {
+	addr      string
+	host      string
+	auth      smtp.Auth
+	from      *mail.Address
+	tlsConfig *tls.Config // Основа настроек TLS; nil - системные корневые сертификаты.
+}
+
+func NewSMTPTransport(addr, username, password, from string) (*SMTPTransport, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp address %q: %w", addr, err)
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mail from %q: %w", from, err)
+	}
+	t := &SMTPTransport{addr: addr, host: host, from: sender}
+	if username != "" {
+		t.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return t, nil
+}
+
+// Send отправляет письмо. net/smtp не принимает контекст, поэтому время ограничивает дедлайн соединения.
+func (t *SMTPTransport) Send(ctx context.Context, msg Message) error {
+	body, err := buildMessage(t.from, msg)
+	if err != nil {
+		return err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, t.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start smtp session: %w", err)
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		// Без ServerName StartTLS не проверит сертификат сервера и откажется соединяться.
+		config := &tls.Config{}
+		if t.tlsConfig != nil {
+			config = t.tlsConfig.Clone()
+		}
+		config.ServerName = t.host
+		if err := client.StartTLS(config); err != nil {
+			return fmt.Errorf("failed to start tls: %w", err)
+		}
+	}
+	if t.auth != nil {
+		if err := client.Auth(t.auth); err != nil {
+			return fmt.Errorf("smtp auth failed: %w", err)
+		}
+	}
+	if err := client.Mail(t.from.Address); err != nil {
+		return fmt.Errorf("smtp MAIL FROM failed: %w", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("smtp RCPT TO failed: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp server rejected message: %w", err)
+	}
+	return client.Quit()
+}
+
+// FileTransport складывает письма в каталог файлами .eml. Для разработки.
+type FileTransport struct {
+	dir  string
+	from *mail.Address
+}
+
+func NewFileTransport(dir, from string) (*FileTransport, error) {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mail from %q: %w", from, err)
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create mail dir: %w", err)
+	}
+	return &FileTransport{dir: dir, from: sender}, nil
+}
+
+func (t *FileTransport) Send(_ context.Context, msg Message) error {
+	body, err := buildMessage(t.from, msg)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), randomHex(4))
+	if err := os.WriteFile(filepath.Join(t.dir, name), body, 0o640); err != nil {
+		return fmt.Errorf("failed to write message file: %w", err)
+	}
+	return nil
+}
+
+// MemoryTransport запоминает письма. Для разработки и тестов.
+type MemoryTransport struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{}
+}
+
+func (t *MemoryTransport) Send(_ context.Context, msg Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return t.err
+	}
+	t.messages = append(t.messages, msg)
+	return nil
+}
+
+// Fail заставляет следующие отправки возвращать err; nil снова разрешает их.
+func (t *MemoryTransport) Fail(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.err = err
+}
+
+// Messages возвращает отправленные письма по порядку.
+func (t *MemoryTransport) Messages() []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Message(nil), t.messages...)
+}
+
+// buildMessage собирает письмо multipart/alternative с текстовой и HTML-версией.
+func buildMessage(from *mail.Address, msg Message) ([]byte, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", msg.To, err)
+	}
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+	domain := from.Address[strings.LastIndexByte(from.Address, '@')+1:]
+	header := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMessage-ID: <%s@%s>\r\n"+
+		"MIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=%q\r\n\r\n",
+		from.String(), to.String(), mime.QEncoding.Encode("utf-8", msg.Subject),
+		time.Now().Format(time.RFC1123Z), randomHex(16), domain, parts.Boundary())
+	var out bytes.Buffer
+	out.WriteString(header)
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to build message: %w", err)
+		}
+		qp := quotedprintable.NewWriter(w)
+		qp.Write([]byte(part.body))
+		qp.Close()
+	}
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build message: %w", err)
+	}
+	out.Write(buf.Bytes())
+	return out.Bytes(), nil
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
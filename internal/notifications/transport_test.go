@@ -0,0 +1,136 @@
+package notifications
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer - SMTP-сервер, который предлагает STARTTLS и принимает письма только по TLS.
+type fakeSMTPServer struct {
+	listener net.Listener
+	cert     tls.Certificate
+	received chan string
+}
+
+func newFakeSMTPServer(t *testing.T) (*fakeSMTPServer, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "smtp.test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	parsed, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(parsed)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	server := &fakeSMTPServer{
+		listener: listener,
+		cert:     tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+		received: make(chan string, 1),
+	}
+	go server.serve()
+	return server, roots
+}
+
+func (s *fakeSMTPServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 smtp.test ESMTP")
+	secure := false
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.Fields(line + " ")[0])
+		switch {
+		case verb == "EHLO" && !secure:
+			tp.PrintfLine("250-smtp.test\r\n250 STARTTLS")
+		case verb == "EHLO":
+			tp.PrintfLine("250-smtp.test\r\n250 AUTH PLAIN")
+		case verb == "STARTTLS":
+			tp.PrintfLine("220 ready to start tls")
+			tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{s.cert}})
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, tp, secure = tlsConn, textproto.NewConn(tlsConn), true
+		case !secure:
+			tp.PrintfLine("530 must issue STARTTLS first")
+		case verb == "AUTH":
+			tp.PrintfLine("235 authenticated")
+		case verb == "MAIL" || verb == "RCPT":
+			tp.PrintfLine("250 ok")
+		case verb == "DATA":
+			tp.PrintfLine("354 go ahead")
+			body, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.received <- string(body)
+			tp.PrintfLine("250 queued")
+		case verb == "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 unknown command")
+		}
+	}
+}
+
+func TestSMTPTransport_StartTLS(t *testing.T) {
+	server, roots := newFakeSMTPServer(t)
+	transport, err := NewSMTPTransport(server.listener.Addr().String(), "raya", "secret", "Raya <no-reply@raya.local>")
+	require.NoError(t, err)
+	transport.tlsConfig = &tls.Config{RootCAs: roots}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, transport.Send(ctx, Message{To: "anna@example.com", Subject: "Hi", Text: "text", HTML: "<p>text</p>"}))
+	select {
+	case body := <-server.received:
+		assert.Contains(t, body, "To: <anna@example.com>")
+	default:
+		t.Fatal("message was not delivered")
+	}
+}
+
+func TestSMTPTransport_StartTLSUntrusted(t *testing.T) {
+	server, _ := newFakeSMTPServer(t)
+	transport, err := NewSMTPTransport(server.listener.Addr().String(), "", "", "no-reply@raya.local")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = transport.Send(ctx, Message{To: "anna@example.com"})
+	assert.ErrorContains(t, err, "failed to start tls", "certificate is verified against the server name")
+}
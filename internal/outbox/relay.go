@@ -0,0 +1,181 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Relay забирает события из outbox и отдаёт их всем получателям. Событие считается разосланным,
+// только когда его приняли все получатели; иначе оно повторяется целиком, так что получатели
+// должны отсеивать дубли по id события. После maxAttempts неудач событие помечается мёртвым
+// и больше не рассылается.
+
+// Sink - получатель событий.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event models.DomainEvent) error
+}
+
+// Store - то, что нужно relay от хранилища.
+type Store interface {
+	ClaimOutboxEvents(ctx context.Context, now, leaseUntil time.Time, limit int) (events []models.OutboxEvent, err error)
+	RecordOutboxResult(ctx context.Context, event models.OutboxEvent) (err error)
+	DeletePublishedOutboxEvents(ctx context.Context, before time.Time) (deleted int, err error)
+}
+
+// claimLease - на сколько забранная пачка закрепляется за экземпляром. Если он упадёт, не записав итог,
+// события после аренды разошлёт другой.
+const claimLease = 5 * time.Minute
+
+type Relay struct {
+	store       Store
+	logger      *logger.ZapLog
+	sinks       []Sink
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	retryBase   time.Duration
+	retryMax    time.Duration
+	retention   time.Duration
+	now         func() time.Time
+}
+
+func NewRelay(s Store, c *server_config.ServerConfig, l *logger.ZapLog, sinks ...Sink) *Relay {
+	r := &Relay{
+		store:       s,
+		logger:      l,
+		sinks:       sinks,
+		interval:    c.OutboxRelayInterval,
+		batchSize:   c.OutboxBatchSize,
+		maxAttempts: c.OutboxMaxAttempts,
+		retryBase:   c.OutboxRetryBase,
+		retryMax:    c.OutboxRetryMax,
+		retention:   c.OutboxRetention,
+		now:         time.Now,
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = 100
+	}
+	if r.maxAttempts <= 0 {
+		r.maxAttempts = 1
+	}
+	if r.retryBase <= 0 {
+		r.retryBase = 5 * time.Second
+	}
+	if r.retryMax < r.retryBase {
+		r.retryMax = r.retryBase
+	}
+	return r
+}
+
+// Run рассылает outbox раз в interval, пока не отменён ctx. При нулевом interval сразу возвращается.
+func (r *Relay) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			r.logger.ZL.Error("failed to relay outbox events", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce рассылает все события, которым пора уходить, пачками по batchSize,
+// и удаляет разосланные, срок хранения которых истёк.
+func (r *Relay) RunOnce(ctx context.Context) (relayed int, err error) {
+	for {
+		now := r.now()
+		events, err := r.store.ClaimOutboxEvents(ctx, now, now.Add(claimLease), r.batchSize)
+		if err != nil {
+			return relayed, fmt.Errorf("failed to claim outbox events: %w", err)
+		}
+		n := 0
+		for _, event := range events {
+			published, err := r.relay(ctx, event)
+			if err != nil {
+				return relayed, err
+			}
+			if published {
+				n++
+			}
+		}
+		relayed += n
+		// Неудачные события в пачке откладываются, так что неполная пачка значит, что очередь пуста
+		// или получатель недоступен и остальным событиям лучше подождать.
+		if n < r.batchSize || ctx.Err() != nil {
+			break
+		}
+	}
+	if r.retention > 0 {
+		deleted, err := r.store.DeletePublishedOutboxEvents(ctx, r.now().Add(-r.retention))
+		if err != nil {
+			return relayed, fmt.Errorf("failed to delete published outbox events: %w", err)
+		}
+		if deleted > 0 {
+			r.logger.ZL.Debug("published outbox events deleted", zap.Int("count", deleted))
+		}
+	}
+	return relayed, nil
+}
+
+// relay рассылает одно забранное событие и записывает итог.
+func (r *Relay) relay(ctx context.Context, event models.OutboxEvent) (published bool, err error) {
+	publishErr := r.publish(ctx, event.Event)
+	now := r.now()
+	event.Attempts++
+	switch {
+	case publishErr == nil:
+		event.LastError = ""
+		event.PublishedAt = &now
+	case event.Attempts >= r.maxAttempts:
+		event.LastError = publishErr.Error()
+		event.DeadAt = &now
+		r.logger.ZL.Error("outbox event is dead",
+			zap.String("event_id", event.Event.ID), zap.Int("attempts", event.Attempts), zap.Error(publishErr))
+	default:
+		event.LastError = publishErr.Error()
+		event.NextAttemptAt = now.Add(r.retryDelay(event.Attempts))
+	}
+	if err := r.store.RecordOutboxResult(ctx, event); err != nil {
+		return false, fmt.Errorf("failed to record outbox event %d: %w", event.ID, err)
+	}
+	return publishErr == nil, nil
+}
+
+// publish отдаёт событие всем получателям и собирает их ошибки.
+func (r *Relay) publish(ctx context.Context, event models.DomainEvent) error {
+	var errs []error
+	for _, sink := range r.sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			r.logger.ZL.Warn("outbox sink failed",
+				zap.String("sink", sink.Name()), zap.String("event_id", event.ID), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// retryDelay - пауза после attempts неудачных попыток: retryBase, дальше вдвое больше, но не дольше retryMax.
+func (r *Relay) retryDelay(attempts int) time.Duration {
+	delay := r.retryBase
+	for i := 1; i < attempts && delay < r.retryMax; i++ {
+		delay *= 2
+	}
+	if delay > r.retryMax {
+		delay = r.retryMax
+	}
+	return delay
+}
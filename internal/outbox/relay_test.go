@@ -0,0 +1,189 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// recordingSink запоминает события и, пока down, отказывает.
+type recordingSink struct {
+	name   string
+	down   bool
+	events []models.DomainEvent
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Publish(_ context.Context, event models.DomainEvent) error {
+	if s.down {
+		return errors.New("unavailable")
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func newTestRelay(t *testing.T, s Store, sinks ...Sink) (*Relay, *time.Time) {
+	t.Helper()
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	r := NewRelay(s, &server_config.ServerConfig{
+		OutboxBatchSize:   2,
+		OutboxMaxAttempts: 3,
+		OutboxRetryBase:   time.Minute,
+		OutboxRetryMax:    3 * time.Minute,
+		OutboxRetention:   time.Hour,
+	}, l, sinks...)
+	// События пишутся по настоящему времени, поэтому часы начинают с него.
+	now := time.Now().Add(time.Second)
+	r.now = func() time.Time { return now }
+	return r, &now
+}
+
+func TestRelay_RunOnce(t *testing.T) {
+	ctx := context.Background()
+	box := store.NewOutbox()
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, box.AppendOutboxEvent(ctx, store.NewDomainEvent(models.EventUserLoggedIn, &models.User{ID: i})))
+	}
+	log, bus := &recordingSink{name: "log"}, &recordingSink{name: "bus", down: true}
+	r, now := newTestRelay(t, box, log, bus)
+
+	// Получатель недоступен - события остаются в outbox, хотя другой получатель их уже принял.
+	relayed, err := r.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, relayed)
+	assert.Len(t, log.events, 2, "failed batch stops the pass")
+	for _, event := range box.OutboxEvents() {
+		if event.Attempts > 0 {
+			assert.Contains(t, event.LastError, "bus: unavailable")
+		}
+	}
+
+	// Получатель поднялся - все события уходят, включая отложенные.
+	bus.down = false
+	*now = now.Add(time.Minute)
+	relayed, err = r.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, relayed)
+	require.Len(t, bus.events, 5)
+	for i, event := range bus.events {
+		assert.Equal(t, i+1, event.UserID, "events keep their order")
+	}
+	assert.Len(t, log.events, 7, "delivery is at least once")
+
+	// Разосланные удаляются, когда истекает срок хранения.
+	*now = now.Add(time.Hour + time.Second)
+	_, err = r.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, box.OutboxEvents())
+}
+
+func TestRelay_DeadAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	box := store.NewOutbox()
+	require.NoError(t, box.AppendOutboxEvent(ctx, store.NewDomainEvent(models.EventUserLoggedIn, &models.User{ID: 1})))
+	bus := &recordingSink{name: "bus", down: true}
+	r, now := newTestRelay(t, box, bus)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		_, err := r.RunOnce(ctx)
+		require.NoError(t, err)
+		*now = now.Add(time.Hour)
+	}
+	events := box.OutboxEvents()
+	require.Len(t, events, 1)
+	assert.Equal(t, 3, events[0].Attempts)
+	require.NotNil(t, events[0].DeadAt, "the event is dead after max attempts")
+	assert.Contains(t, events[0].LastError, "bus: unavailable")
+
+	// Мёртвое событие не рассылается, даже когда получатель поднялся.
+	bus.down = false
+	relayed, err := r.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, relayed)
+	assert.Empty(t, bus.events)
+}
+
+// leaseSink проверяет, что событие у получателя уже забрано: другой экземпляр его не получит.
+type leaseSink struct {
+	t    *testing.T
+	box  *store.Outbox
+	seen int
+}
+
+func (s *leaseSink) Name() string { return "lease" }
+
+func (s *leaseSink) Publish(ctx context.Context, event models.DomainEvent) error {
+	s.seen++
+	again, err := s.box.ClaimOutboxEvents(ctx, time.Now().Add(time.Second), time.Now().Add(time.Minute), 10)
+	require.NoError(s.t, err)
+	for _, claimed := range again {
+		assert.NotEqual(s.t, event.ID, claimed.Event.ID)
+	}
+	return nil
+}
+
+func TestRelay_PublishesAfterClaim(t *testing.T) {
+	ctx := context.Background()
+	box := store.NewOutbox()
+	require.NoError(t, box.AppendOutboxEvent(ctx, store.NewDomainEvent(models.EventUserLoggedIn, &models.User{ID: 1})))
+	sink := &leaseSink{t: t, box: box}
+	r, _ := newTestRelay(t, box, sink)
+
+	relayed, err := r.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, relayed)
+	assert.Equal(t, 1, sink.seen)
+	events := box.OutboxEvents()
+	require.Len(t, events, 1)
+	assert.NotNil(t, events[0].PublishedAt)
+}
+
+func TestRelay_retryDelay(t *testing.T) {
+	r, _ := newTestRelay(t, store.NewOutbox())
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 3, want: 3 * time.Minute},
+		{attempts: 30, want: 3 * time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, r.retryDelay(tt.attempts), "attempts %d", tt.attempts)
+	}
+}
+
+type fakeBus struct {
+	topic, key string
+	payload    []byte
+}
+
+func (b *fakeBus) Publish(_ context.Context, topic, key string, payload []byte) error {
+	b.topic, b.key, b.payload = topic, key, payload
+	return nil
+}
+
+func TestBusSink_Publish(t *testing.T) {
+	bus := &fakeBus{}
+	sink := NewBusSink(bus, "raya.users")
+	event := store.NewDomainEvent(models.EventUserRegistered, &models.User{ID: 42, Login: "petr"})
+
+	require.NoError(t, sink.Publish(context.Background(), event))
+	assert.Equal(t, "bus:raya.users", sink.Name())
+	assert.Equal(t, "raya.users", bus.topic)
+	assert.Equal(t, "42", bus.key)
+	var got models.DomainEvent
+	require.NoError(t, json.Unmarshal(bus.payload, &got))
+	assert.Equal(t, event, got)
+}
@@ -0,0 +1,64 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"go.uber.org/zap"
+	"strconv"
+)
+
+// LogSink пишет события в лог.
+type LogSink struct {
+	logger *logger.ZapLog
+}
+
+func NewLogSink(l *logger.ZapLog) *LogSink {
+	return &LogSink{logger: l}
+}
+
+func (s *LogSink) Name() string {
+	return "log"
+}
+
+func (s *LogSink) Publish(_ context.Context, event models.DomainEvent) error {
+	s.logger.ZL.Info("domain event",
+		zap.String("event_id", event.ID),
+		zap.String("type", string(event.Type)),
+		zap.Int("user_id", event.UserID),
+		zap.Time("occurred_at", event.OccurredAt),
+	)
+	return nil
+}
+
+// Bus - брокер сообщений. Сообщения с одним key должны попадать в одну партицию, чтобы сохранить порядок.
+type Bus interface {
+	Publish(ctx context.Context, topic, key string, payload []byte) error
+}
+
+// BusSink отправляет события в брокер в виде json, ключом служит id пользователя.
+type BusSink struct {
+	bus   Bus
+	topic string
+}
+
+func NewBusSink(bus Bus, topic string) *BusSink {
+	return &BusSink{bus: bus, topic: topic}
+}
+
+func (s *BusSink) Name() string {
+	return "bus:" + s.topic
+}
+
+func (s *BusSink) Publish(ctx context.Context, event models.DomainEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode domain event: %w", err)
+	}
+	if err := s.bus.Publish(ctx, s.topic, strconv.Itoa(event.UserID), payload); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", s.topic, err)
+	}
+	return nil
+}
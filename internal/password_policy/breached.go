@@ -0,0 +1,100 @@
+package password_policy
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// BreachedCorpus - локальная база утёкших паролей в формате Have I Been Pwned:
+// по строке на хэш вида "SHA1HEX:COUNT", строки отсортированы по хэшу.
+// Файл не загружается в память, поиск идёт бинарным поиском по смещениям.
+type BreachedCorpus struct {
+	file *os.File
+	size int64
+}
+
+// maxCorpusLine - с запасом больше, чем строка HIBP (40 символов хэша, двоеточие и счётчик).
+const maxCorpusLine = 256
+
+// OpenBreachedCorpus открывает файл с базой утёкших паролей.
+func OpenBreachedCorpus(path string) (*BreachedCorpus, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password corpus: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat breached password corpus: %w", err)
+	}
+	return &BreachedCorpus{file: file, size: info.Size()}, nil
+}
+
+func (b *BreachedCorpus) Close() error {
+	return b.file.Close()
+}
+
+// Contains сообщает, встречается ли пароль в базе утечек.
+func (b *BreachedCorpus) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	target := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// Ищем наименьшее смещение, с которого первая целая строка не меньше искомого хэша.
+	lo, hi := int64(0), b.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		key, ok, err := b.keyAfter(mid)
+		if err != nil {
+			return false, err
+		}
+		if ok && key < target {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	key, ok, err := b.keyAfter(lo)
+	if err != nil {
+		return false, err
+	}
+	return ok && key == target, nil
+}
+
+// keyAfter возвращает хэш из первой строки, начинающейся на смещении offset или позже.
+func (b *BreachedCorpus) keyAfter(offset int64) (key string, ok bool, err error) {
+	start := offset
+	if offset > 0 {
+		// Строка начинается на offset, только если перед ним перевод строки.
+		start = offset - 1
+	}
+	buf := make([]byte, 2*maxCorpusLine)
+	n, err := b.file.ReadAt(buf, start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", false, fmt.Errorf("failed to read breached password corpus: %w", err)
+	}
+	buf = buf[:n]
+	if offset > 0 {
+		newline := bytes.IndexByte(buf, '\n')
+		if newline < 0 {
+			return "", false, nil
+		}
+		buf = buf[newline+1:]
+	}
+	if len(buf) == 0 {
+		return "", false, nil
+	}
+	if end := bytes.IndexByte(buf, '\n'); end >= 0 {
+		buf = buf[:end]
+	}
+	line := strings.TrimSpace(string(buf))
+	if colon := strings.IndexByte(line, ':'); colon >= 0 {
+		line = line[:colon]
+	}
+	return strings.ToUpper(line), true, nil
+}
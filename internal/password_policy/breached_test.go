@@ -0,0 +1,59 @@
+package password_policy
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeCorpus пишет файл в формате HIBP из переданных паролей.
+func writeCorpus(t *testing.T, passwords []string) string {
+	t.Helper()
+	lines := make([]string, 0, len(passwords))
+	for i, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(sum[:])), i+1))
+	}
+	sort.Strings(lines)
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600))
+	return path
+}
+
+func TestBreachedCorpus(t *testing.T) {
+	breached := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		breached = append(breached, fmt.Sprintf("leaked-%d", i))
+	}
+	corpus, err := OpenBreachedCorpus(writeCorpus(t, breached))
+	require.NoError(t, err)
+	defer corpus.Close()
+
+	for _, password := range []string{"leaked-0", "leaked-500", "leaked-999"} {
+		found, err := corpus.Contains(password)
+		require.NoError(t, err)
+		assert.True(t, found, password)
+	}
+	for _, password := range []string{"leaked-1000", "not leaked", ""} {
+		found, err := corpus.Contains(password)
+		require.NoError(t, err)
+		assert.False(t, found, password)
+	}
+}
+
+func TestBreachedCorpusEmptyFile(t *testing.T) {
+	corpus, err := OpenBreachedCorpus(writeCorpus(t, nil))
+	require.NoError(t, err)
+	defer corpus.Close()
+
+	found, err := corpus.Contains("anything")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
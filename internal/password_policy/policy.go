@@ -0,0 +1,134 @@
+package password_policy
+
+import (
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CharClass - класс символов, который может требовать политика.
+type CharClass string
+
+const (
+	ClassLower  CharClass = "lower"
+	ClassUpper  CharClass = "upper"
+	ClassDigit  CharClass = "digit"
+	ClassSymbol CharClass = "symbol"
+)
+
+// Policy - правила, которым должен соответствовать пароль при регистрации.
+// Нулевые значения отключают соответствующую проверку.
+type Policy struct {
+	MinLength       int
+	MaxLength       int
+	RequiredClasses []CharClass
+	ForbidLogin     bool // Пароль не должен содержать логин.
+	MinScore        int  // Минимальная оценка стойкости по шкале 0..4.
+	breached        *BreachedCorpus
+}
+
+// NewPolicy собирает политику паролей из конфигурации сервера.
+func NewPolicy(c *server_config.ServerConfig) (*Policy, error) {
+	p := &Policy{
+		MinLength:   c.PasswordMinLength,
+		MaxLength:   c.PasswordMaxLength,
+		ForbidLogin: c.PasswordForbidLogin,
+		MinScore:    c.PasswordMinScore,
+	}
+	for _, class := range strings.Split(c.PasswordRequiredClasses, ",") {
+		class = strings.TrimSpace(class)
+		if class == "" {
+			continue
+		}
+		switch CharClass(class) {
+		case ClassLower, ClassUpper, ClassDigit, ClassSymbol:
+			p.RequiredClasses = append(p.RequiredClasses, CharClass(class))
+		default:
+			return nil, fmt.Errorf("unknown password character class %q", class)
+		}
+	}
+	if c.PasswordBreachedFile != "" {
+		corpus, err := OpenBreachedCorpus(c.PasswordBreachedFile)
+		if err != nil {
+			return nil, err
+		}
+		p.breached = corpus
+	}
+	return p, nil
+}
+
+// Close освобождает файл базы утечек, если он открыт.
+func (p *Policy) Close() error {
+	if p.breached == nil {
+		return nil
+	}
+	return p.breached.Close()
+}
+
+// Validate проверяет пароль и возвращает все найденные нарушения.
+// Ошибка возвращается, только если не удалось прочитать базу утечек, остальные проверки при этом выполнены.
+func (p *Policy) Validate(login, password string) (fieldErrors []models.FieldError, err error) {
+	violation := func(code, msg string) {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: "password", Code: code, Message: msg})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		violation("too_short", fmt.Sprintf("Password must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violation("too_long", fmt.Sprintf("Password must be at most %d characters long", p.MaxLength))
+	}
+
+	for _, class := range p.RequiredClasses {
+		if !containsClass(password, class) {
+			violation("missing_"+string(class), fmt.Sprintf("Password must contain at least one %s character", class))
+		}
+	}
+
+	if p.ForbidLogin && utf8.RuneCountInString(login) >= minPatternLength &&
+		strings.Contains(strings.ToLower(password), strings.ToLower(login)) {
+		violation("contains_login", "Password must not contain the login")
+	}
+
+	if p.MinScore > 0 && Score(password, login) < p.MinScore {
+		violation("too_weak", "Password is too easy to guess")
+	}
+
+	if p.breached != nil {
+		found, breachedErr := p.breached.Contains(password)
+		if breachedErr != nil {
+			err = breachedErr
+		} else if found {
+			violation("breached", "Password has appeared in a data breach")
+		}
+	}
+	return fieldErrors, err
+}
+
+func containsClass(password string, class CharClass) bool {
+	for _, r := range password {
+		switch class {
+		case ClassLower:
+			if unicode.IsLower(r) {
+				return true
+			}
+		case ClassUpper:
+			if unicode.IsUpper(r) {
+				return true
+			}
+		case ClassDigit:
+			if unicode.IsDigit(r) {
+				return true
+			}
+		case ClassSymbol:
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
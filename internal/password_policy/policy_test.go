@@ -0,0 +1,63 @@
+package password_policy
+
+import (
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	conf := &server_config.ServerConfig{
+		PasswordMinLength:       8,
+		PasswordMaxLength:       20,
+		PasswordRequiredClasses: "lower, digit",
+		PasswordForbidLogin:     true,
+		PasswordMinScore:        2,
+		PasswordBreachedFile:    writeCorpus(t, []string{"mB7#rTq9xL"}),
+	}
+	policy, err := NewPolicy(conf)
+	require.NoError(t, err)
+	defer policy.Close()
+
+	tests := []struct {
+		name      string
+		login     string
+		password  string
+		wantCodes []string
+	}{
+		{"valid password", "ivan", "kT4#pWz9qR", nil},
+		{"too short and weak", "ivan", "ab1", []string{"too_short", "too_weak"}},
+		{"too long", "ivan", "kT4#pWz9qRkT4#pWz9qR1", []string{"too_long"}},
+		{"missing digit", "ivan", "kTf#pWzmqR", []string{"missing_digit"}},
+		{"contains login", "ivanovich", "Ivanovich7", []string{"contains_login", "too_weak"}},
+		{"breached", "ivan", "mB7#rTq9xL", []string{"breached"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fieldErrors, err := policy.Validate(tt.login, tt.password)
+			require.NoError(t, err)
+			codes := make([]string, 0, len(fieldErrors))
+			for _, fieldError := range fieldErrors {
+				assert.Equal(t, "password", fieldError.Field)
+				codes = append(codes, fieldError.Code)
+			}
+			if tt.wantCodes == nil {
+				assert.Empty(t, codes)
+				return
+			}
+			assert.Equal(t, tt.wantCodes, codes)
+		})
+	}
+}
+
+func TestNewPolicyUnknownClass(t *testing.T) {
+	_, err := NewPolicy(&server_config.ServerConfig{PasswordRequiredClasses: "emoji"})
+	assert.Error(t, err)
+}
+
+func TestNewPolicyMissingCorpus(t *testing.T) {
+	_, err := NewPolicy(&server_config.ServerConfig{PasswordBreachedFile: "/nonexistent/pwned.txt"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,261 @@
+package password_policy
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Оценка стойкости в духе zxcvbn: пароль разбивается на фрагменты (словарные слова, последовательности,
+// повторы, ряды клавиатуры, годы, отдельные символы), для каждого оценивается число попыток подбора,
+// и выбирается самое дешёвое для атакующего разбиение. Итог переводится в шкалу 0..4.
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// commonRanks - место пароля или слова в списке самых частых, 1 - самый популярный.
+var commonRanks = loadRanks(commonPasswordsFile)
+
+// leetReplacer откатывает типичные замены букв цифрами и символами.
+var leetReplacer = strings.NewReplacer(
+	"4", "a", "@", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t", "+", "t",
+)
+
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+	"йцукенгшщзхъ",
+	"фывапролджэ",
+	"ячсмитьбю",
+}
+
+const (
+	minPatternLength = 3
+	maxWordLength    = 24
+)
+
+func loadRanks(list string) map[string]int {
+	ranks := make(map[string]int)
+	for i, word := range strings.Fields(list) {
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}
+
+// Score оценивает стойкость пароля от 0 (угадывается мгновенно) до 4 (очень стойкий).
+// userInputs - данные пользователя (логин и т.п.), они считаются самыми вероятными догадками.
+func Score(password string, userInputs ...string) int {
+	guesses := estimateGuessesLog10(password, userInputs)
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	}
+	return 4
+}
+
+// estimateGuessesLog10 возвращает десятичный логарифм числа попыток, нужных для подбора пароля.
+func estimateGuessesLog10(password string, userInputs []string) float64 {
+	original := []rune(password)
+	n := len(original)
+	if n == 0 {
+		return 0
+	}
+	lower := []rune(strings.ToLower(password))
+	unleet := []rune(leetReplacer.Replace(string(lower)))
+	if len(lower) != n || len(unleet) != n {
+		// Смена регистра изменила длину (редкие символы) - оцениваем перебором.
+		return bruteforceLog10(original)
+	}
+
+	words := make(map[string]int, len(userInputs))
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if len([]rune(input)) >= minPatternLength {
+			words[input] = 1
+		}
+	}
+	rankOf := func(word string) (int, bool) {
+		if rank, ok := words[word]; ok {
+			return rank, true
+		}
+		rank, ok := commonRanks[word]
+		return rank, ok
+	}
+
+	// best[i] - минимальная оценка для первых i символов.
+	best := make([]float64, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = math.Inf(1)
+	}
+	for i := 0; i < n; i++ {
+		if math.IsInf(best[i], 1) {
+			continue
+		}
+		relax := func(j int, cost float64) {
+			if best[i]+cost < best[j] {
+				best[j] = best[i] + cost
+			}
+		}
+
+		relax(i+1, math.Log10(charPool(original[i])))
+
+		for j := i + minPatternLength; j <= n && j-i <= maxWordLength; j++ {
+			if rank, ok := rankOf(string(lower[i:j])); ok {
+				relax(j, math.Log10(float64(rank))+caseVariationsLog10(original[i:j]))
+			}
+			if rank, ok := rankOf(string(unleet[i:j])); ok && string(unleet[i:j]) != string(lower[i:j]) {
+				relax(j, math.Log10(float64(rank))+caseVariationsLog10(original[i:j])+math.Log10(2))
+			}
+		}
+
+		if l := repeatLength(lower[i:]); l >= minPatternLength {
+			for j := minPatternLength; j <= l; j++ {
+				relax(i+j, math.Log10(charPool(original[i])*float64(j)))
+			}
+		}
+		if l, descending := sequenceLength(lower[i:]); l >= minPatternLength {
+			for j := minPatternLength; j <= l; j++ {
+				relax(i+j, sequenceLog10(lower[i], j, descending))
+			}
+		}
+		if l, rowLen := keyboardRunLength(lower[i:]); l >= minPatternLength {
+			for j := minPatternLength; j <= l; j++ {
+				relax(i+j, math.Log10(float64(rowLen*2*j)))
+			}
+		}
+		if i+4 <= n && isYear(lower[i:i+4]) {
+			relax(i+4, math.Log10(200))
+		}
+	}
+	return best[n]
+}
+
+func bruteforceLog10(runes []rune) float64 {
+	var total float64
+	for _, r := range runes {
+		total += math.Log10(charPool(r))
+	}
+	return total
+}
+
+// charPool - размер алфавита, из которого атакующему пришлось бы перебирать символ.
+func charPool(r rune) float64 {
+	switch {
+	case r >= '0' && r <= '9':
+		return 10
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return 26
+	case r < unicode.MaxASCII:
+		return 33
+	}
+	return 100
+}
+
+// caseVariationsLog10 - надбавка за регистр: заглавная первая буква или все заглавные почти ничего не стоят.
+func caseVariationsLog10(word []rune) float64 {
+	upper := 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 0
+	case upper == len(word), upper == 1 && unicode.IsUpper(word[0]):
+		return math.Log10(2)
+	}
+	return float64(upper) * math.Log10(2)
+}
+
+func repeatLength(runes []rune) int {
+	l := 1
+	for l < len(runes) && runes[l] == runes[0] {
+		l++
+	}
+	return l
+}
+
+// sequenceLength - длина последовательности вида abc или 987 с начала среза.
+func sequenceLength(runes []rune) (length int, descending bool) {
+	if len(runes) < 2 {
+		return len(runes), false
+	}
+	delta := runes[1] - runes[0]
+	if delta != 1 && delta != -1 {
+		return 1, false
+	}
+	l := 2
+	for l < len(runes) && runes[l]-runes[l-1] == delta {
+		l++
+	}
+	return l, delta < 0
+}
+
+func sequenceLog10(first rune, length int, descending bool) float64 {
+	base := 26.0
+	switch {
+	case first == 'a' || first == 'z' || first == '0' || first == '1' || first == '9':
+		base = 4
+	case first >= '0' && first <= '9':
+		base = 10
+	}
+	if descending {
+		base *= 2
+	}
+	return math.Log10(base * float64(length))
+}
+
+// keyboardRunLength - длина прохода по соседним клавишам одного ряда с начала среза.
+func keyboardRunLength(runes []rune) (length int, rowLen int) {
+	for _, row := range keyboardRows {
+		keys := []rune(row)
+		pos := indexRune(keys, runes[0])
+		if pos < 0 || len(runes) < 2 {
+			continue
+		}
+		next := indexRune(keys, runes[1])
+		step := next - pos
+		if next < 0 || (step != 1 && step != -1) {
+			continue
+		}
+		l := 2
+		for l < len(runes) && indexRune(keys, runes[l])-indexRune(keys, runes[l-1]) == step && indexRune(keys, runes[l]) >= 0 {
+			l++
+		}
+		if l > length {
+			length, rowLen = l, len(keys)
+		}
+	}
+	return length, rowLen
+}
+
+func indexRune(runes []rune, r rune) int {
+	for i, candidate := range runes {
+		if candidate == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func isYear(runes []rune) bool {
+	for _, r := range runes {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	prefix := string(runes[:2])
+	return prefix == "19" || prefix == "20"
+}
@@ -0,0 +1,35 @@
+package password_policy
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		userInputs []string
+		wantMin    int
+		wantMax    int
+	}{
+		{"common password", "password", nil, 0, 0},
+		{"l33t common password", "P@ssw0rd", nil, 0, 0},
+		{"digits sequence", "123456789", nil, 0, 0},
+		{"keyboard row", "qwertyuiop", nil, 0, 0},
+		{"repeated char", "aaaaaaaa", nil, 0, 0},
+		{"login as password", "ivanpetrov", []string{"ivanpetrov"}, 0, 0},
+		{"word with year", "summer2024", nil, 0, 1},
+		{"short random", "x7#Kq", nil, 1, 2},
+		{"long random", "gT7#vQ9!mZ2$wL", nil, 4, 4},
+		{"passphrase", "correct horse battery staple", nil, 4, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := Score(tt.password, tt.userInputs...)
+			assert.GreaterOrEqual(t, score, tt.wantMin)
+			assert.LessOrEqual(t, score, tt.wantMax)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package risk
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// Устройство узнаётся грубо: по семейству браузера и ОС и по подсети адреса. Версии клиента и адрес
+// внутри подсети меняются слишком часто, чтобы по ним отличать одно устройство от другого.
+
+// browserFamilies - признаки браузеров в User-Agent. Порядок важен: Chrome-подобные браузеры
+// упоминают Chrome и Safari, а Chrome упоминает Safari.
+var browserFamilies = []struct {
+	marker string
+	family string
+}{
+	{"Edg", "Edge"},
+	{"OPR/", "Opera"},
+	{"YaBrowser/", "Yandex"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"curl/", "curl"},
+	{"okhttp/", "okhttp"},
+}
+
+// osFamilies - признаки операционных систем в User-Agent, в том же духе.
+var osFamilies = []struct {
+	marker string
+	family string
+}{
+	{"Windows", "Windows"},
+	{"iPhone", "iOS"},
+	{"iPad", "iOS"},
+	{"Android", "Android"},
+	{"Mac OS X", "macOS"},
+	{"CrOS", "ChromeOS"},
+	{"Linux", "Linux"},
+}
+
+// ClientFamily сводит User-Agent к семейству браузера и ОС, например "Firefox/Linux".
+func ClientFamily(userAgent string) string {
+	return matchFamily(userAgent, browserFamilies) + "/" + matchFamily(userAgent, osFamilies)
+}
+
+func matchFamily(userAgent string, families []struct {
+	marker string
+	family string
+}) string {
+	if strings.TrimSpace(userAgent) == "" {
+		return "Unknown"
+	}
+	for _, f := range families {
+		if strings.Contains(userAgent, f.marker) {
+			return f.family
+		}
+	}
+	return "Other"
+}
+
+// Подсети, в пределах которых адрес считается тем же: домашний или офисный провайдер обычно
+// выдаёт адреса из одной /24, а для IPv6 сети клиента - /48.
+const (
+	ipv4SubnetBits = 24
+	ipv6SubnetBits = 48
+)
+
+// Subnet возвращает подсеть адреса. Нераспознанный адрес возвращается как есть.
+func Subnet(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap().WithZone("")
+	bits := ipv6SubnetBits
+	if addr.Is4() {
+		bits = ipv4SubnetBits
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
@@ -0,0 +1,151 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"time"
+)
+
+// Оценка риска входа: после верного пароля вход сравнивается с известными устройствами пользователя
+// и недавними неудачными попытками. Чем меньше совпадений, тем выше оценка, а пороги из конфига
+// решают, пропустить вход молча, сообщить о нём, потребовать второй фактор или отклонить.
+
+// Store - то, что нужно оценке от хранилища.
+type Store interface {
+	ListDevices(ctx context.Context, userID int) (devices []models.Device, err error)
+	ListAuditEvents(ctx context.Context, query models.AuditQuery) (events []models.AuditEvent, err error)
+}
+
+// Вклад признаков в оценку. Сумма всех не превышает 100.
+const (
+	newClientScore      = 40 // Браузер и ОС, с которых пользователь ещё не входил.
+	newSubnetScore      = 30 // Подсеть, из которой пользователь ещё не входил.
+	failureScore        = 10 // За каждую недавнюю попытку с неверным паролем.
+	maxFailureScore     = 30
+	maxFailuresToReview = 100
+)
+
+// Причины, повысившие оценку.
+const (
+	ReasonNewClient      = "new_client"
+	ReasonNewSubnet      = "new_subnet"
+	ReasonRecentFailures = "recent_failures"
+)
+
+type Assessor struct {
+	store         Store
+	notifyScore   int
+	stepUpScore   int
+	blockScore    int
+	failureWindow time.Duration
+	now           func() time.Time
+}
+
+func NewAssessor(s Store, c *server_config.ServerConfig) *Assessor {
+	return &Assessor{
+		store:         s,
+		notifyScore:   c.RiskNotifyScore,
+		stepUpScore:   c.RiskStepUpScore,
+		blockScore:    c.RiskBlockScore,
+		failureWindow: c.RiskFailureWindow,
+		now:           time.Now,
+	}
+}
+
+// Assess оценивает вход пользователя с адреса ip и клиента userAgent. Первый вход пользователя
+// сравнивать не с чем, поэтому новым устройством он не считается.
+func (a *Assessor) Assess(ctx context.Context, userID int, ip, userAgent string) (models.LoginRisk, error) {
+	now := a.now()
+	risk := models.LoginRisk{
+		Decision: models.LoginAllow,
+		Device: models.Device{
+			UserID:       userID,
+			ClientFamily: ClientFamily(userAgent),
+			Subnet:       Subnet(ip),
+			LastIP:       ip,
+			UserAgent:    userAgent,
+			LastSeenAt:   now,
+		},
+	}
+
+	devices, err := a.store.ListDevices(ctx, userID)
+	if err != nil {
+		return risk, fmt.Errorf("failed to list known devices: %w", err)
+	}
+	knownClient, knownSubnet := len(devices) == 0, len(devices) == 0
+	for _, device := range devices {
+		if device.ClientFamily == risk.Device.ClientFamily && device.Subnet == risk.Device.Subnet {
+			risk.Device.ID = device.ID
+		}
+		knownClient = knownClient || device.ClientFamily == risk.Device.ClientFamily
+		knownSubnet = knownSubnet || device.Subnet == risk.Device.Subnet
+	}
+	if !knownClient {
+		risk.Score += newClientScore
+		risk.Reasons = append(risk.Reasons, ReasonNewClient)
+	}
+	if !knownSubnet {
+		risk.Score += newSubnetScore
+		risk.Reasons = append(risk.Reasons, ReasonNewSubnet)
+	}
+
+	if a.failureWindow > 0 {
+		failures, err := a.store.ListAuditEvents(ctx, models.AuditQuery{
+			Type:     models.AuditLogin,
+			TargetID: &userID,
+			Result:   models.AuditFailure,
+			From:     now.Add(-a.failureWindow),
+			Limit:    maxFailuresToReview,
+		})
+		if err != nil {
+			return risk, fmt.Errorf("failed to list recent login failures: %w", err)
+		}
+		failureTotal := 0
+		for _, failure := range failures {
+			// Отказы из-за статуса учётной записи или самой оценки риска не говорят о подборе пароля.
+			if failure.Reason == "wrong_password" {
+				failureTotal += failureScore
+			}
+		}
+		if failureTotal > 0 {
+			risk.Score += min(failureTotal, maxFailureScore)
+			risk.Reasons = append(risk.Reasons, ReasonRecentFailures)
+		}
+	}
+
+	risk.Decision = a.decide(risk.Score)
+	if risk.Decision == models.LoginBlock && !hasReason(risk.Reasons, ReasonRecentFailures) {
+		// Отказ только за новое устройство был бы вечным: устройство запоминается лишь после входа.
+		// Неудачные попытки со временем выходят из окна, а без них вход подтверждается кодом из письма.
+		risk.Decision = models.LoginStepUp
+	}
+	return risk, nil
+}
+
+// decide выбирает самое строгое решение, порог которого достигнут. Нулевой порог выключен.
+func (a *Assessor) decide(score int) models.LoginDecision {
+	reached := func(threshold int) bool {
+		return threshold > 0 && score >= threshold
+	}
+	switch {
+	case reached(a.blockScore):
+		return models.LoginBlock
+	case reached(a.stepUpScore):
+		return models.LoginStepUp
+	case reached(a.notifyScore):
+		return models.LoginNotify
+	default:
+		return models.LoginAllow
+	}
+}
+
+func hasReason(reasons []string, reason string) bool {
+	for _, r := range reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package risk
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const (
+	firefoxLinux  = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	chromeWindows = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+)
+
+func TestClientFamily(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{firefoxLinux, "Firefox/Linux"},
+		{chromeWindows, "Chrome/Windows"},
+		{chromeWindows + " Edg/120.0.0.0", "Edge/Windows"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1", "Safari/iOS"},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36", "Chrome/Android"},
+		{"curl/8.4.0", "curl/Other"},
+		{"", "Unknown/Unknown"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ClientFamily(tt.userAgent), tt.userAgent)
+	}
+}
+
+func TestSubnet(t *testing.T) {
+	assert.Equal(t, "192.0.2.0/24", Subnet("192.0.2.77"))
+	assert.Equal(t, "192.0.2.0/24", Subnet("::ffff:192.0.2.1"))
+	assert.Equal(t, "2001:db8:1::/48", Subnet("2001:db8:1:2::5"))
+	assert.Equal(t, "not-an-ip", Subnet("not-an-ip"))
+}
+
+// riskStore - известные устройства и журнал в памяти.
+type riskStore struct {
+	*store.DeviceRegistry
+	*store.AuditLog
+}
+
+func TestAssessor_Assess(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	userID := 1
+	failure := models.AuditEvent{Type: models.AuditLogin, TargetID: &userID, Result: models.AuditFailure, Reason: "wrong_password"}
+
+	tests := []struct {
+		name         string
+		config       server_config.ServerConfig
+		failures     int
+		ip           string
+		userAgent    string
+		wantScore    int
+		wantDecision models.LoginDecision
+		wantReasons  []string
+		wantKnown    bool
+	}{
+		{
+			name:   "Test known device",
+			config: server_config.ServerConfig{RiskNotifyScore: 30, RiskFailureWindow: time.Hour},
+			ip:     "192.0.2.99", userAgent: firefoxLinux,
+			wantDecision: models.LoginAllow, wantKnown: true,
+		},
+		{
+			name:   "Test new subnet notifies",
+			config: server_config.ServerConfig{RiskNotifyScore: 30, RiskFailureWindow: time.Hour},
+			ip:     "198.51.100.1", userAgent: firefoxLinux,
+			wantScore: 30, wantDecision: models.LoginNotify, wantReasons: []string{ReasonNewSubnet},
+		},
+		{
+			name:   "Test new client and subnet require step up",
+			config: server_config.ServerConfig{RiskNotifyScore: 30, RiskStepUpScore: 60, RiskFailureWindow: time.Hour},
+			ip:     "198.51.100.1", userAgent: chromeWindows,
+			wantScore: 70, wantDecision: models.LoginStepUp, wantReasons: []string{ReasonNewClient, ReasonNewSubnet},
+		},
+		{
+			name:     "Test failures on a new device block",
+			config:   server_config.ServerConfig{RiskNotifyScore: 30, RiskStepUpScore: 60, RiskBlockScore: 90, RiskFailureWindow: time.Hour},
+			failures: 5,
+			ip:       "198.51.100.1", userAgent: chromeWindows,
+			wantScore: 100, wantDecision: models.LoginBlock, wantReasons: []string{ReasonNewClient, ReasonNewSubnet, ReasonRecentFailures},
+		},
+		{
+			name:   "Test new device without failures is not blocked",
+			config: server_config.ServerConfig{RiskNotifyScore: 30, RiskStepUpScore: 40, RiskBlockScore: 60, RiskFailureWindow: time.Hour},
+			ip:     "198.51.100.1", userAgent: chromeWindows,
+			wantScore: 70, wantDecision: models.LoginStepUp, wantReasons: []string{ReasonNewClient, ReasonNewSubnet},
+		},
+		{
+			name:     "Test failures alone stay below thresholds",
+			config:   server_config.ServerConfig{RiskNotifyScore: 30, RiskFailureWindow: time.Hour},
+			failures: 2,
+			ip:       "192.0.2.1", userAgent: firefoxLinux,
+			wantScore: 20, wantDecision: models.LoginAllow, wantReasons: []string{ReasonRecentFailures}, wantKnown: true,
+		},
+		{
+			name:     "Test disabled thresholds allow everything",
+			failures: 5,
+			ip:       "198.51.100.1", userAgent: chromeWindows,
+			wantScore: 70, wantDecision: models.LoginAllow, wantReasons: []string{ReasonNewClient, ReasonNewSubnet},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := riskStore{DeviceRegistry: store.NewDeviceRegistry(), AuditLog: store.NewAuditLog()}
+			_, err := s.TouchDevice(ctx, models.Device{UserID: userID, ClientFamily: "Firefox/Linux", Subnet: "192.0.2.0/24", LastSeenAt: now})
+			require.NoError(t, err)
+			for i := 0; i < tt.failures; i++ {
+				_, err := s.AppendAuditEvent(ctx, failure)
+				require.NoError(t, err)
+			}
+
+			risk, err := NewAssessor(s, &tt.config).Assess(ctx, userID, tt.ip, tt.userAgent)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantScore, risk.Score)
+			assert.Equal(t, tt.wantDecision, risk.Decision)
+			assert.Equal(t, tt.wantReasons, risk.Reasons)
+			assert.Equal(t, tt.wantKnown, risk.Device.ID != 0)
+			assert.Equal(t, tt.ip, risk.Device.LastIP)
+		})
+	}
+}
+
+func TestAssessor_FirstLogin(t *testing.T) {
+	s := riskStore{DeviceRegistry: store.NewDeviceRegistry(), AuditLog: store.NewAuditLog()}
+	risk, err := NewAssessor(s, &server_config.ServerConfig{RiskNotifyScore: 1}).Assess(context.Background(), 1, "192.0.2.1", chromeWindows)
+	require.NoError(t, err)
+	assert.Zero(t, risk.Score, "there is nothing to compare the first device with")
+	assert.Equal(t, models.LoginAllow, risk.Decision)
+	assert.Equal(t, "Chrome/Windows", risk.Device.ClientFamily)
+}
@@ -0,0 +1,55 @@
+package server_config
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestServerConfig_ParseArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "defaults"},
+		{name: "memory above uint32", args: []string{"-argon2-memory", "4294967296"}, wantErr: "argon2 memory"},
+		{name: "iterations above uint32", args: []string{"-argon2-iterations", "4294967296"}, wantErr: "argon2 iterations"},
+		{name: "parallelism above uint8", args: []string{"-argon2-parallelism", "256"}, wantErr: "argon2 parallelism"},
+		{name: "zero parallelism", args: []string{"-argon2-parallelism", "0"}, wantErr: "argon2 parallelism"},
+		{name: "key length above uint32", args: []string{"-argon2-key-length", "4294967296"}, wantErr: "argon2 key length"},
+		{name: "parallelism from env", env: map[string]string{"ARGON2_PARALLELISM": "300"}, wantErr: "argon2 parallelism"},
+		{name: "bad uint env", env: map[string]string{"ARGON2_MEMORY": "64MiB"}, wantErr: "ARGON2_MEMORY"},
+		{name: "bad int env", env: map[string]string{"DB_MAX_CONNS": "ten"}, wantErr: "DB_MAX_CONNS"},
+		{name: "bad duration env", env: map[string]string{"DB_READ_TIMEOUT": "5"}, wantErr: "DB_READ_TIMEOUT"},
+		{name: "bad bool env", env: map[string]string{"PURGE_ANONYMIZE": "maybe"}, wantErr: "PURGE_ANONYMIZE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			c := &ServerConfig{}
+			err := c.ParseArgs(flag.NewFlagSet("test", flag.ContinueOnError), tt.args)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, uint32(64*1024), c.Argon2Memory)
+			assert.Equal(t, uint8(2), c.Argon2Parallelism)
+		})
+	}
+
+	t.Run("every bad env is reported", func(t *testing.T) {
+		t.Setenv("ARGON2_MEMORY", "x")
+		t.Setenv("WEBHOOK_TIMEOUT", "y")
+		err := (&ServerConfig{}).ParseArgs(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ARGON2_MEMORY")
+		assert.Contains(t, err.Error(), "WEBHOOK_TIMEOUT")
+	})
+}
@@ -0,0 +1,176 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Журнал безопасности. Каждая запись хранит хэш предыдущей и свой собственный, посчитанный от
+// prev_hash и полей записи. Исправленная задним числом строка перестаёт сходиться со своим хэшем,
+// а удалённая из середины рвёт ссылку prev_hash у следующей. Отрезанный хвост цепочка не покажет,
+// от этого защищает только запрет на UPDATE, DELETE и TRUNCATE в самой таблице.
+// AuditLog повторяет те же правила в памяти.
+
+// auditVerifyBatch - сколько записей читается за раз при проверке цепочки.
+const auditVerifyBatch = 1000
+
+// Ограничения длины из таблицы audit_events. Длинные значения обрезаются до записи,
+// чтобы хэш считался от того, что действительно сохранится.
+var auditFieldLimits = struct{ ip, userAgent, reason, requestID int }{64, 512, 256, 64}
+
+// prepareAuditEvent заполняет время и обрезает поля перед подсчётом хэша.
+// Время округляется до микросекунд - точнее его не хранит postgres.
+func prepareAuditEvent(event models.AuditEvent) models.AuditEvent {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	event.OccurredAt = event.OccurredAt.UTC().Truncate(time.Microsecond)
+	event.IP = truncateRunes(event.IP, auditFieldLimits.ip)
+	event.UserAgent = truncateRunes(event.UserAgent, auditFieldLimits.userAgent)
+	event.Reason = truncateRunes(event.Reason, auditFieldLimits.reason)
+	event.RequestID = truncateRunes(event.RequestID, auditFieldLimits.requestID)
+	return event
+}
+
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit])
+}
+
+// AuditHash считает хэш записи, сцеплённый с хэшем предыдущей. Id в хэш не входит: его выдаёт база
+// уже при вставке, а порядок записей и так закреплён ссылками prev_hash.
+func AuditHash(prevHash []byte, event models.AuditEvent) []byte {
+	optionalID := func(id *int) string {
+		if id == nil {
+			return ""
+		}
+		return strconv.Itoa(*id)
+	}
+	h := sha256.New()
+	// Каждое поле предваряется длиной, чтобы границы между полями нельзя было сдвинуть.
+	for _, field := range []string{
+		string(prevHash),
+		event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		string(event.Type),
+		optionalID(event.ActorID),
+		optionalID(event.TargetID),
+		event.IP,
+		event.UserAgent,
+		string(event.Result),
+		event.Reason,
+		event.RequestID,
+	} {
+		binary.Write(h, binary.BigEndian, uint32(len(field)))
+		h.Write([]byte(field))
+	}
+	return h.Sum(nil)
+}
+
+// AuditReader - источник записей журнала для проверки цепочки.
+type AuditReader interface {
+	ListAuditEvents(ctx context.Context, query models.AuditQuery) (events []models.AuditEvent, err error)
+}
+
+// VerifyAuditChain проходит весь журнал по порядку и проверяет, что каждая запись ссылается на
+// предыдущую и что её хэш сходится с содержимым.
+func VerifyAuditChain(ctx context.Context, reader AuditReader) (models.AuditVerification, error) {
+	result := models.AuditVerification{Valid: true}
+	var (
+		prevHash []byte
+		afterID  int64
+	)
+	for {
+		events, err := reader.ListAuditEvents(ctx, models.AuditQuery{AfterID: afterID, Limit: auditVerifyBatch})
+		if err != nil {
+			return result, fmt.Errorf("failed to read audit events: %w", err)
+		}
+		for _, event := range events {
+			result.Checked++
+			if !bytes.Equal(event.PrevHash, prevHash) || !bytes.Equal(event.Hash, AuditHash(prevHash, event)) {
+				result.Valid = false
+				result.BrokenAtID = event.ID
+				return result, nil
+			}
+			prevHash = event.Hash
+			afterID = event.ID
+		}
+		if len(events) < auditVerifyBatch {
+			return result, nil
+		}
+	}
+}
+
+// auditMatches сообщает, подходит ли запись под фильтры запроса.
+func auditMatches(event models.AuditEvent, query models.AuditQuery) bool {
+	sameID := func(filter, id *int) bool {
+		return filter == nil || (id != nil && *id == *filter)
+	}
+	switch {
+	case event.ID <= query.AfterID:
+		return false
+	case query.Type != "" && event.Type != query.Type:
+		return false
+	case query.Result != "" && event.Result != query.Result:
+		return false
+	case !sameID(query.ActorID, event.ActorID), !sameID(query.TargetID, event.TargetID):
+		return false
+	case query.UserID != nil && !sameID(query.UserID, event.ActorID) && !sameID(query.UserID, event.TargetID):
+		return false
+	case !query.From.IsZero() && event.OccurredAt.Before(query.From):
+		return false
+	case !query.To.IsZero() && !event.OccurredAt.Before(query.To):
+		return false
+	}
+	return true
+}
+
+// AuditLog - журнал безопасности в памяти.
+type AuditLog struct {
+	mu     sync.RWMutex
+	events []models.AuditEvent
+}
+
+func NewAuditLog() *AuditLog {
+	return &AuditLog{}
+}
+
+// AppendAuditEvent добавляет запись в конец цепочки.
+func (l *AuditLog) AppendAuditEvent(_ context.Context, event models.AuditEvent) (models.AuditEvent, error) {
+	event = prepareAuditEvent(event)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	event.ID = int64(len(l.events)) + 1
+	event.PrevHash = []byte{}
+	if len(l.events) > 0 {
+		event.PrevHash = l.events[len(l.events)-1].Hash
+	}
+	event.Hash = AuditHash(event.PrevHash, event)
+	l.events = append(l.events, event)
+	return event, nil
+}
+
+// ListAuditEvents возвращает страницу записей по возрастанию id.
+func (l *AuditLog) ListAuditEvents(_ context.Context, query models.AuditQuery) ([]models.AuditEvent, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var events []models.AuditEvent
+	for _, event := range l.events {
+		if query.Limit > 0 && len(events) == query.Limit {
+			break
+		}
+		if auditMatches(event, query) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
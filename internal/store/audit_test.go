@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// tamperedLog отдаёт записи журнала после подмены, как если бы их поправили в базе в обход триггера.
+type tamperedLog struct {
+	events []models.AuditEvent
+}
+
+func (l tamperedLog) ListAuditEvents(_ context.Context, query models.AuditQuery) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	for _, event := range l.events {
+		if event.ID > query.AfterID && len(events) < query.Limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func appendTestEvents(t *testing.T, l *AuditLog) []models.AuditEvent {
+	t.Helper()
+	var events []models.AuditEvent
+	for i, event := range []models.AuditEvent{
+		{Type: models.AuditRegistration, ActorID: intRef(1), TargetID: intRef(1), Result: models.AuditSuccess},
+		{Type: models.AuditLogin, TargetID: intRef(1), Result: models.AuditFailure, Reason: "wrong_password", IP: "10.0.0.1"},
+		{Type: models.AuditLogin, ActorID: intRef(1), TargetID: intRef(1), Result: models.AuditSuccess},
+		{Type: models.AuditRoleChange, ActorID: intRef(2), TargetID: intRef(1), Result: models.AuditSuccess, Reason: "admin"},
+	} {
+		event.OccurredAt = time.Date(2025, 1, 1, 0, i, 0, 0, time.UTC)
+		stored, err := l.AppendAuditEvent(context.Background(), event)
+		require.NoError(t, err)
+		events = append(events, stored)
+	}
+	return events
+}
+
+func intRef(id int) *int {
+	return &id
+}
+
+func TestAuditLog_Chain(t *testing.T) {
+	l := NewAuditLog()
+	events := appendTestEvents(t, l)
+
+	assert.Empty(t, events[0].PrevHash)
+	for i := 1; i < len(events); i++ {
+		assert.Equal(t, events[i-1].Hash, events[i].PrevHash)
+	}
+	verification, err := VerifyAuditChain(context.Background(), l)
+	require.NoError(t, err)
+	assert.Equal(t, models.AuditVerification{Checked: 4, Valid: true}, verification)
+}
+
+func TestVerifyAuditChain_Tampering(t *testing.T) {
+	tests := []struct {
+		name       string
+		tamper     func(events []models.AuditEvent) []models.AuditEvent
+		wantBroken int64
+	}{
+		{
+			name: "Test changed reason",
+			tamper: func(events []models.AuditEvent) []models.AuditEvent {
+				events[1].Reason = "ok"
+				return events
+			},
+			wantBroken: 2,
+		},
+		{
+			name: "Test changed result with recomputed own hash",
+			tamper: func(events []models.AuditEvent) []models.AuditEvent {
+				events[1].Result = models.AuditSuccess
+				events[1].Hash = AuditHash(events[1].PrevHash, events[1])
+				return events
+			},
+			wantBroken: 3,
+		},
+		{
+			name: "Test deleted row",
+			tamper: func(events []models.AuditEvent) []models.AuditEvent {
+				return append(events[:2], events[3:]...)
+			},
+			wantBroken: 4,
+		},
+		{
+			name: "Test actor removed",
+			tamper: func(events []models.AuditEvent) []models.AuditEvent {
+				events[3].ActorID = nil
+				return events
+			},
+			wantBroken: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := appendTestEvents(t, NewAuditLog())
+			verification, err := VerifyAuditChain(context.Background(), tamperedLog{events: tt.tamper(events)})
+			require.NoError(t, err)
+			assert.False(t, verification.Valid)
+			assert.Equal(t, tt.wantBroken, verification.BrokenAtID)
+		})
+	}
+}
+
+func TestAuditLog_List(t *testing.T) {
+	l := NewAuditLog()
+	appendTestEvents(t, l)
+
+	ids := func(query models.AuditQuery) []int64 {
+		events, err := l.ListAuditEvents(context.Background(), query)
+		require.NoError(t, err)
+		var ids []int64
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []int64{2, 3}, ids(models.AuditQuery{Type: models.AuditLogin}))
+	assert.Equal(t, []int64{2}, ids(models.AuditQuery{Result: models.AuditFailure}))
+	assert.Equal(t, []int64{4}, ids(models.AuditQuery{ActorID: intRef(2)}))
+	assert.Equal(t, []int64{1, 2, 3, 4}, ids(models.AuditQuery{TargetID: intRef(1)}))
+	assert.Equal(t, []int64{4}, ids(models.AuditQuery{UserID: intRef(2)}))
+	assert.Equal(t, []int64{2, 3}, ids(models.AuditQuery{
+		From: time.Date(2025, 1, 1, 0, 1, 0, 0, time.UTC),
+		To:   time.Date(2025, 1, 1, 0, 3, 0, 0, time.UTC),
+	}))
+	assert.Equal(t, []int64{3}, ids(models.AuditQuery{AfterID: 2, Limit: 1}))
+}
+
+func TestPrepareAuditEvent(t *testing.T) {
+	event := prepareAuditEvent(models.AuditEvent{
+		OccurredAt: time.Date(2025, 1, 1, 3, 0, 0, 123456789, time.FixedZone("MSK", 3*60*60)),
+		UserAgent:  string(make([]rune, 600)),
+	})
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 123456000, time.UTC), event.OccurredAt)
+	assert.Len(t, []rune(event.UserAgent), 512)
+}
+
+func TestDBStore_AuditChain(t *testing.T) {
+	s := newTestDBStore(t)
+	ctx := context.Background()
+
+	// Журнал нельзя очистить, поэтому новые записи проверяются вместе с оставшимися от прошлых прогонов.
+	before, err := VerifyAuditChain(ctx, s)
+	require.NoError(t, err)
+	for _, event := range []models.AuditEvent{
+		{Type: models.AuditLogin, TargetID: intRef(1), Result: models.AuditFailure, Reason: "wrong_password"},
+		{Type: models.AuditLogin, ActorID: intRef(1), TargetID: intRef(1), Result: models.AuditSuccess, UserAgent: "тест"},
+	} {
+		_, err := s.AppendAuditEvent(ctx, event)
+		require.NoError(t, err)
+	}
+	after, err := VerifyAuditChain(ctx, s)
+	require.NoError(t, err)
+	assert.True(t, after.Valid)
+	assert.Equal(t, before.Checked+2, after.Checked)
+
+	_, err = s.dbConn.ExecContext(ctx, `UPDATE audit_events SET reason = 'ok'`)
+	assert.Error(t, err, "audit_events must reject updates")
+	_, err = s.dbConn.ExecContext(ctx, `DELETE FROM audit_events`)
+	assert.Error(t, err, "audit_events must reject deletes")
+}
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// AuthStateVersion - версия состояния входа в памяти. В памяти экземпляр сервера один, так что версия
+// нужна только для совместимости с хранилищем в базе.
+type AuthStateVersion struct {
+	version atomic.Int64
+}
+
+func NewAuthStateVersion() *AuthStateVersion {
+	return &AuthStateVersion{}
+}
+
+// GetAuthStateVersion возвращает текущую версию.
+func (v *AuthStateVersion) GetAuthStateVersion(_ context.Context) (int64, error) {
+	return v.version.Load(), nil
+}
+
+// BumpAuthStateVersion увеличивает версию.
+func (v *AuthStateVersion) BumpAuthStateVersion(_ context.Context) error {
+	v.version.Add(1)
+	return nil
+}
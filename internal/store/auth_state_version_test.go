@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// authStateVersionStore - общее у AuthStateVersion и DBStore.
+type authStateVersionStore interface {
+	GetAuthStateVersion(ctx context.Context) (int64, error)
+	BumpAuthStateVersion(ctx context.Context) error
+}
+
+func runAuthStateVersionSuite(t *testing.T, s authStateVersionStore) {
+	ctx := context.Background()
+	before, err := s.GetAuthStateVersion(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.BumpAuthStateVersion(ctx))
+	require.NoError(t, s.BumpAuthStateVersion(ctx))
+	after, err := s.GetAuthStateVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, before+2, after)
+}
+
+func TestAuthStateVersion(t *testing.T) {
+	runAuthStateVersionSuite(t, NewAuthStateVersion())
+}
+
+func TestDBStore_AuthStateVersion(t *testing.T) {
+	runAuthStateVersionSuite(t, newTestDBStore(t))
+}
+
+func TestSQLiteStore_AuthStateVersion(t *testing.T) {
+	runAuthStateVersionSuite(t, newTestSQLiteStore(t))
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := withTimeout(context.Background(), 0)
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok, "zero timeout leaves the context without a deadline")
+
+	ctx, cancel = withTimeout(context.Background(), time.Minute)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	// Более ранний срок запроса сохраняется.
+	parent, parentCancel := context.WithTimeout(context.Background(), time.Second)
+	defer parentCancel()
+	ctx, cancel = withTimeout(parent, time.Minute)
+	defer cancel()
+	deadline, _ = ctx.Deadline()
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, time.Second)
+}
+
+func TestDBStore_ContextAbortsQueries(t *testing.T) {
+	s := newTestDBStore(t)
+
+	t.Run("canceled request", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := s.ListUsers(ctx, models.UserListQuery{Limit: 10})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("client leaves during a query", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := s.dbConn.ExecContext(ctx, `SELECT pg_sleep(5)`)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("read timeout", func(t *testing.T) {
+		s.c.DBReadTimeout = 50 * time.Millisecond
+		defer func() { s.c.DBReadTimeout = 0 }()
+		ctx, cancel := s.readCtx(context.Background())
+		defer cancel()
+		start := time.Now()
+		_, err := s.dbConn.ExecContext(ctx, `SELECT pg_sleep(5)`)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+}
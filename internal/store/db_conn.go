@@ -0,0 +1,241 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// database - то, через что DBStore выполняет запросы. У Postgres это пул pgxpool без database/sql,
+// у SQLite - *sql.DB. Ошибки и результаты у обоих в терминах database/sql: sql.ErrNoRows, sql.Result,
+// так что код запросов от базы не зависит.
+type database interface {
+	queryer
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (dbTx, error)
+	Close() error
+}
+
+// dbTx - транзакция database.
+type dbTx interface {
+	queryer
+	Commit() error
+	Rollback() error
+}
+
+// queryer - общее у database и dbTx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (dbRows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) rowScanner
+}
+
+// dbRows - результат запроса из нескольких строк.
+type dbRows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+	Close() error
+}
+
+// sqlConn - database поверх database/sql, для SQLite.
+type sqlConn struct {
+	db *sql.DB
+}
+
+func (c sqlConn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return c.db.ExecContext(ctx, query, args...)
+}
+
+func (c sqlConn) QueryContext(ctx context.Context, query string, args ...any) (dbRows, error) {
+	return c.db.QueryContext(ctx, query, args...)
+}
+
+func (c sqlConn) QueryRowContext(ctx context.Context, query string, args ...any) rowScanner {
+	return c.db.QueryRowContext(ctx, query, args...)
+}
+
+func (c sqlConn) BeginTx(ctx context.Context, opts *sql.TxOptions) (dbTx, error) {
+	tx, err := c.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return sqlTx{tx: tx}, nil
+}
+
+func (c sqlConn) Close() error {
+	return c.db.Close()
+}
+
+type sqlTx struct {
+	tx *sql.Tx
+}
+
+func (t sqlTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, query, args...)
+}
+
+func (t sqlTx) QueryContext(ctx context.Context, query string, args ...any) (dbRows, error) {
+	return t.tx.QueryContext(ctx, query, args...)
+}
+
+func (t sqlTx) QueryRowContext(ctx context.Context, query string, args ...any) rowScanner {
+	return t.tx.QueryRowContext(ctx, query, args...)
+}
+
+func (t sqlTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t sqlTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// pgxConn - database прямо на pgxpool.Pool: запросы берут соединение из пула и возвращают его,
+// так что настройки и статистика пула относятся ко всем запросам сервера.
+type pgxConn struct {
+	pool *pgxpool.Pool
+}
+
+func (c pgxConn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return pgxExec(c.pool.Exec(ctx, query, args...))
+}
+
+func (c pgxConn) QueryContext(ctx context.Context, query string, args ...any) (dbRows, error) {
+	return pgxQuery(c.pool.Query(ctx, query, args...))
+}
+
+func (c pgxConn) QueryRowContext(ctx context.Context, query string, args ...any) rowScanner {
+	return pgxRow{row: c.pool.QueryRow(ctx, query, args...)}
+}
+
+func (c pgxConn) BeginTx(ctx context.Context, opts *sql.TxOptions) (dbTx, error) {
+	txOptions, err := pgxTxOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := c.pool.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, err
+	}
+	return pgxTx{ctx: ctx, tx: tx}, nil
+}
+
+func (c pgxConn) Close() error {
+	c.pool.Close()
+	return nil
+}
+
+// pgxTxOptions переводит параметры транзакции database/sql в параметры pgx.
+func pgxTxOptions(opts *sql.TxOptions) (pgx.TxOptions, error) {
+	var txOptions pgx.TxOptions
+	if opts == nil {
+		return txOptions, nil
+	}
+	switch opts.Isolation {
+	case sql.LevelDefault:
+	case sql.LevelReadUncommitted:
+		txOptions.IsoLevel = pgx.ReadUncommitted
+	case sql.LevelReadCommitted:
+		txOptions.IsoLevel = pgx.ReadCommitted
+	case sql.LevelRepeatableRead, sql.LevelSnapshot:
+		txOptions.IsoLevel = pgx.RepeatableRead
+	case sql.LevelSerializable:
+		txOptions.IsoLevel = pgx.Serializable
+	default:
+		return txOptions, fmt.Errorf("unsupported isolation level %s", opts.Isolation)
+	}
+	if opts.ReadOnly {
+		txOptions.AccessMode = pgx.ReadOnly
+	}
+	return txOptions, nil
+}
+
+// pgxTx - транзакция pgx. Как и в database/sql, она живёт в контексте, с которым начата:
+// Commit и Rollback выполняются в нём же.
+type pgxTx struct {
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+func (t pgxTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return pgxExec(t.tx.Exec(ctx, query, args...))
+}
+
+func (t pgxTx) QueryContext(ctx context.Context, query string, args ...any) (dbRows, error) {
+	return pgxQuery(t.tx.Query(ctx, query, args...))
+}
+
+func (t pgxTx) QueryRowContext(ctx context.Context, query string, args ...any) rowScanner {
+	return pgxRow{row: t.tx.QueryRow(ctx, query, args...)}
+}
+
+func (t pgxTx) Commit() error {
+	return t.tx.Commit(t.ctx)
+}
+
+func (t pgxTx) Rollback() error {
+	return t.tx.Rollback(t.ctx)
+}
+
+func pgxExec(tag pgconn.CommandTag, err error) (sql.Result, error) {
+	if err != nil {
+		return nil, err
+	}
+	return pgxResult(tag), nil
+}
+
+func pgxQuery(rows pgx.Rows, err error) (dbRows, error) {
+	if err != nil {
+		return nil, err
+	}
+	return pgxRows{rows: rows}, nil
+}
+
+// pgxResult - sql.Result по итогу команды pgx.
+type pgxResult pgconn.CommandTag
+
+func (r pgxResult) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId is not supported by Postgres, use RETURNING")
+}
+
+func (r pgxResult) RowsAffected() (int64, error) {
+	return pgconn.CommandTag(r).RowsAffected(), nil
+}
+
+type pgxRows struct {
+	rows pgx.Rows
+}
+
+func (r pgxRows) Next() bool {
+	return r.rows.Next()
+}
+
+func (r pgxRows) Scan(dest ...any) error {
+	return r.rows.Scan(dest...)
+}
+
+func (r pgxRows) Err() error {
+	return r.rows.Err()
+}
+
+func (r pgxRows) Close() error {
+	r.rows.Close()
+	return r.rows.Err()
+}
+
+// pgxRow отдаёт отсутствие строки как sql.ErrNoRows, которую и проверяют запросы.
+type pgxRow struct {
+	row pgx.Row
+}
+
+func (r pgxRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sql.ErrNoRows
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPgxTxOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    *sql.TxOptions
+		want    pgx.TxOptions
+		wantErr bool
+	}{
+		{name: "nil", opts: nil, want: pgx.TxOptions{}},
+		{name: "default", opts: &sql.TxOptions{}, want: pgx.TxOptions{}},
+		{
+			name: "serializable read only",
+			opts: &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true},
+			want: pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly},
+		},
+		{name: "snapshot", opts: &sql.TxOptions{Isolation: sql.LevelSnapshot}, want: pgx.TxOptions{IsoLevel: pgx.RepeatableRead}},
+		{name: "linearizable", opts: &sql.TxOptions{Isolation: sql.LevelLinearizable}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pgxTxOptions(tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// fakePgxRow отвечает на Scan заданной ошибкой.
+type fakePgxRow struct {
+	err error
+}
+
+func (r fakePgxRow) Scan(...any) error {
+	return r.err
+}
+
+func TestPgxRow_Scan(t *testing.T) {
+	err := pgxRow{row: fakePgxRow{err: pgx.ErrNoRows}}.Scan()
+	assert.ErrorIs(t, err, sql.ErrNoRows, "queries check for sql.ErrNoRows")
+
+	other := errors.New("boom")
+	assert.ErrorIs(t, pgxRow{row: fakePgxRow{err: other}}.Scan(), other)
+}
+
+func TestDBStore_QueriesUsePool(t *testing.T) {
+	s := newTestDBStore(t)
+	ctx := context.Background()
+	before := s.PoolStats().AcquireCount
+
+	_, err := s.GetAuthStateVersion(ctx)
+	require.NoError(t, err)
+	tx, err := s.dbConn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	assert.Equal(t, before+2, s.PoolStats().AcquireCount, "every query acquires a pgxpool connection")
+}
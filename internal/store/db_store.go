@@ -1,7 +1,7 @@
 package store
 
 import (
-	"database/sql"
+	"context"
 	"embed"
 	"errors"
 	"fmt"
@@ -10,34 +10,107 @@ import (
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
 )
 
 type DBStore struct {
-	dbConn *sql.DB
-	c      *server_config.ServerConfig
-	l      *logger.ZapLog
+	// У Postgres запросы идут прямо через pgxpool (pgxConn), и его настройки (DBMaxConns, DBMinConns,
+	// время жизни и простоя, проверки) и статистика - это и есть пул сервера. У SQLite pool nil.
+	dbConn  database
+	pool    *pgxpool.Pool
+	dialect sqlDialect
+	c       *server_config.ServerConfig
+	l       *logger.ZapLog
+	hasher  *passwordHasher
+}
+
+// sqlDialect - база, с которой работает DBStore. Запросы у Postgres и SQLite общие,
+// кроме мест, где SQLite не умеет того же: они ветвятся по диалекту.
+type sqlDialect int
+
+const (
+	dialectPostgres sqlDialect = iota
+	dialectSQLite
+)
+
+// forUpdate блокирует выбранные строки до конца транзакции, а со skipLocked пропускает строки,
+// занятые другим экземпляром сервера. SQLite и так пишет под блокировкой всего файла, там это не нужно.
+func (s sqlDialect) forUpdate(skipLocked bool) string {
+	switch {
+	case s == dialectSQLite:
+		return ""
+	case skipLocked:
+		return "FOR UPDATE SKIP LOCKED"
+	default:
+		return "FOR UPDATE"
+	}
 }
 
 func (d DBStore) DBConnClose() (err error) {
+	if d.pool != nil {
+		currentPool.CompareAndSwap(d.pool, nil)
+	}
 	if err := d.dbConn.Close(); err != nil {
 		return fmt.Errorf("failed to properly close the DB connection %w", err)
 	}
 	return nil
 }
 
+// readCtx ограничивает время чтения настройкой DBReadTimeout, нулевое значение - без ограничения.
+func (d DBStore) readCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, d.c.DBReadTimeout)
+}
+
+// writeCtx ограничивает время изменения вместе с его транзакцией настройкой DBWriteTimeout.
+func (d DBStore) writeCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, d.c.DBWriteTimeout)
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// PoolStats возвращает текущее состояние пула соединений, у SQLite - пустое.
+func (d DBStore) PoolStats() PoolStats {
+	if d.pool == nil {
+		return PoolStats{}
+	}
+	return newPoolStats(d.pool.Stat())
+}
+
 func NewDBStore(c *server_config.ServerConfig, l *logger.ZapLog) (*DBStore, error) {
-	db, err := sql.Open("pgx", c.DBDSN)
+	hasher, err := newPasswordHasher(c)
 	if err != nil {
-		return &DBStore{}, fmt.Errorf("%w", errors.New("sql.open failed in case to create store"))
+		return nil, fmt.Errorf("invalid password hashing parameters: %w", err)
+	}
+	poolConfig, err := newPoolConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DB pool: %w", err)
+	}
+	// База может подниматься вместе с сервером, поэтому даём ей несколько попыток.
+	if err := pingWithRetry(context.Background(), pool, c.DBPingAttempts, c.DBPingBackoff); err != nil {
+		pool.Close()
+		return nil, err
 	}
 	if err := runMigrations(c.DBDSN); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("failed to run DB migrations: %w", err)
 	}
+	currentPool.Store(pool)
 	return &DBStore{
-		dbConn: db,
+		dbConn: pgxConn{pool: pool},
+		pool:   pool,
 		c:      c,
 		l:      l,
+		hasher: hasher,
 	}, nil
 }
 
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/login_policy"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// likeEscaper экранирует спецсимволы LIKE, чтобы префикс искался буквально.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListUsers возвращает страницу пользователей по возрастанию id.
+func (d DBStore) ListUsers(ctx context.Context, query models.UserListQuery) (users []models.User, err error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	var (
+		conditions = []string{"id > $1"}
+		args       = []any{query.AfterID}
+	)
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if query.Status != "" {
+		where("status = ?", query.Status)
+	}
+	if !query.CreatedFrom.IsZero() {
+		where("created_at >= ?", query.CreatedFrom)
+	}
+	if !query.CreatedTo.IsZero() {
+		where("created_at < ?", query.CreatedTo)
+	}
+	if query.LoginPrefix != "" {
+		where(`login_normalized LIKE ? ESCAPE '\'`, likeEscaper.Replace(login_policy.Normalize(query.LoginPrefix))+"%")
+	}
+	args = append(args, query.Limit)
+
+	rows, err := d.dbConn.QueryContext(ctx,
+		`SELECT `+userColumns+` FROM users
+         WHERE `+strings.Join(conditions, " AND ")+`
+         ORDER BY id
+         LIMIT $`+strconv.Itoa(len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", dbError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", dbError(err))
+		}
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", dbError(err))
+	}
+	return users, nil
+}
+
+// SetUserRole меняет роль пользователя.
+func (d DBStore) SetUserRole(ctx context.Context, userID int, role models.UserRole) (*models.User, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	if !role.Valid() {
+		return nil, fmt.Errorf("unknown user role %q", role)
+	}
+	tx, err := d.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", dbError(err))
+	}
+	defer tx.Rollback()
+
+	user, err := scanUser(tx.QueryRowContext(ctx,
+		`UPDATE users SET role = $1, updated_at = $2 WHERE id = $3 RETURNING `+userColumns,
+		role,
+		time.Now(),
+		userID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to set user role: %w", dbError(err))
+	}
+	if err := insertOutboxEvent(ctx, tx, NewDomainEvent(models.EventUserRoleChanged, user)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit user role: %w", dbError(err))
+	}
+	return user, nil
+}
+
+// RevokeSessions делает недействительными все токены, выданные пользователю до этого момента.
+func (d DBStore) RevokeSessions(ctx context.Context, userID int) error {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	tx, err := d.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", dbError(err))
+	}
+	defer tx.Rollback()
+
+	user, err := scanUser(tx.QueryRowContext(ctx,
+		`UPDATE users SET tokens_valid_after = $1 WHERE id = $2 RETURNING `+userColumns,
+		time.Now(),
+		userID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", dbError(err))
+	}
+	if err := insertOutboxEvent(ctx, tx, NewDomainEvent(models.EventUserSessionsRevoked, user)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit sessions revoke: %w", dbError(err))
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"strconv"
+	"strings"
+)
+
+// auditChainLock - ключ advisory-блокировки, под которой записи добавляются в цепочку по одной.
+const auditChainLock = 0x61756469 // "audi"
+
+const auditColumns = `id, occurred_at, event_type, actor_id, target_id, ip, user_agent, result, reason, request_id, prev_hash, hash`
+
+// AppendAuditEvent добавляет запись в журнал. Вставки идут строго по очереди,
+// иначе две записи сослались бы на один и тот же prev_hash.
+func (d DBStore) AppendAuditEvent(ctx context.Context, event models.AuditEvent) (models.AuditEvent, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	event = prepareAuditEvent(event)
+
+	tx, err := d.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return event, fmt.Errorf("failed to begin transaction: %w", dbError(err))
+	}
+	defer tx.Rollback()
+
+	// В SQLite транзакция и так начинается с блокировки записи.
+	if d.dialect == dialectPostgres {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, auditChainLock); err != nil {
+			return event, fmt.Errorf("failed to lock audit chain: %w", dbError(err))
+		}
+	}
+	event.PrevHash = []byte{}
+	err = tx.QueryRowContext(ctx, `SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&event.PrevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return event, fmt.Errorf("failed to get last audit hash: %w", dbError(err))
+	}
+	event.Hash = AuditHash(event.PrevHash, event)
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO audit_events (occurred_at, event_type, actor_id, target_id, ip, user_agent, result, reason, request_id, prev_hash, hash)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+         RETURNING id`,
+		event.OccurredAt,
+		event.Type,
+		event.ActorID,
+		event.TargetID,
+		event.IP,
+		event.UserAgent,
+		event.Result,
+		event.Reason,
+		event.RequestID,
+		event.PrevHash,
+		event.Hash,
+	).Scan(&event.ID)
+	if err != nil {
+		return event, fmt.Errorf("failed to insert audit event: %w", dbError(err))
+	}
+	if err := tx.Commit(); err != nil {
+		return event, fmt.Errorf("failed to commit audit event: %w", dbError(err))
+	}
+	return event, nil
+}
+
+// ListAuditEvents возвращает страницу журнала по возрастанию id.
+func (d DBStore) ListAuditEvents(ctx context.Context, query models.AuditQuery) (events []models.AuditEvent, err error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	var (
+		conditions = []string{"id > $1"}
+		args       = []any{query.AfterID}
+	)
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if query.Type != "" {
+		where("event_type = ?", query.Type)
+	}
+	if query.Result != "" {
+		where("result = ?", query.Result)
+	}
+	if query.ActorID != nil {
+		where("actor_id = ?", *query.ActorID)
+	}
+	if query.TargetID != nil {
+		where("target_id = ?", *query.TargetID)
+	}
+	if query.UserID != nil {
+		where("(actor_id = ? OR target_id = ?)", *query.UserID)
+	}
+	if !query.From.IsZero() {
+		where("occurred_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		where("occurred_at < ?", query.To)
+	}
+	args = append(args, query.Limit)
+
+	rows, err := d.dbConn.QueryContext(ctx,
+		`SELECT `+auditColumns+` FROM audit_events
+         WHERE `+strings.Join(conditions, " AND ")+`
+         ORDER BY id
+         LIMIT $`+strconv.Itoa(len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", dbError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			event             models.AuditEvent
+			actorID, targetID sql.NullInt32
+		)
+		err := rows.Scan(
+			&event.ID,
+			&event.OccurredAt,
+			&event.Type,
+			&actorID,
+			&targetID,
+			&event.IP,
+			&event.UserAgent,
+			&event.Result,
+			&event.Reason,
+			&event.RequestID,
+			&event.PrevHash,
+			&event.Hash,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", dbError(err))
+		}
+		event.OccurredAt = event.OccurredAt.UTC()
+		if actorID.Valid {
+			id := int(actorID.Int32)
+			event.ActorID = &id
+		}
+		if targetID.Valid {
+			id := int(targetID.Int32)
+			event.TargetID = &id
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", dbError(err))
+	}
+	return events, nil
+}
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// GetAuthStateVersion возвращает версию состояния входа: она растёт, когда на любом экземпляре сервера
+// меняют статус пользователя или ограничения по адресам, и по ней экземпляры сбрасывают кэш проверки токенов.
+func (d DBStore) GetAuthStateVersion(ctx context.Context) (version int64, err error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	if err := d.dbConn.QueryRowContext(ctx, `SELECT version FROM auth_state_version WHERE id = 1`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get auth state version: %w", dbError(err))
+	}
+	return version, nil
+}
+
+// BumpAuthStateVersion увеличивает версию состояния входа.
+func (d DBStore) BumpAuthStateVersion(ctx context.Context) error {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	if _, err := d.dbConn.ExecContext(ctx, `UPDATE auth_state_version SET version = version + 1 WHERE id = 1`); err != nil {
+		return fmt.Errorf("failed to bump auth state version: %w", dbError(err))
+	}
+	return nil
+}
@@ -2,154 +2,145 @@ package store
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
+	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/login_policy"
 	"github.com/eampleev23/raya-backend.git/internal/models"
-	"golang.org/x/crypto/argon2"
 	"strings"
 	"time"
 )
 
-type Argon2Params struct {
-	Memory      uint32
-	Iterations  uint32
-	Parallelism uint8
-	SaltLength  uint32
-	KeyLength   uint32
-}
+// loginSkeletonLock - класс advisory-блокировок, под которыми регистрируются логины с одинаковым скелетом.
+const loginSkeletonLock = 0x6c6f6769 // "logi"
 
-var (
-	DefaultArgon2Params = Argon2Params{
-		Memory:      64 * 1024, // 64 mb
-		Iterations:  3,
-		Parallelism: 2,
-		SaltLength:  16,
-		KeyLength:   32,
-	}
-)
+func (d DBStore) CreateUser(ctx context.Context, req models.UserRegReq) (newUser *models.User, err error) {
+	// Выделяем память под модель пользователя.
+	newUser = &models.User{}
 
-func generateRandomBytes(length uint32) ([]byte, error) {
-	b := make([]byte, length)
-	_, err := rand.Read(b)
+	// Хэшируем пароль с солью по параметрам из конфигурации. Таймаут записи начинается после хэширования:
+	// ожидание в очереди хэширования ограничено своим таймаутом и должно заканчиваться ErrHashingOverloaded,
+	// а не истечением срока запроса к базе.
+	encodedHash, b64Salt, err := d.hasher.hash(ctx, req.Password)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate random bytes: %w", err)
+		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
-	return b, nil
-}
 
-// Заводим функцию как переменную для использования в тестах.
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
 
-var (
-	VerifyPassword = func(password, encodedHash string) (match bool, err error) {
-		// Распаковываем параметры из хэша
-		p, salt, hash, err := decodeHash(encodedHash)
-		if err != nil {
-			return false, fmt.Errorf("failed to decode hash: %w", err)
-		}
-
-		// Derive the key from the other password using the same parameters
-		otherHash := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	// Текущее время для created_at и updated_at
+	now := time.Now()
 
-		// Check that the contents of the hashed passwords are identical
-		if len(hash) != len(otherHash) {
-			return false, nil
-		}
-		for i := range hash {
-			if hash[i] != otherHash[i] {
-				return false, nil
-			}
-		}
-		return true, nil
+	role := req.Role
+	if role == "" {
+		role = models.UserRoleUser
 	}
-)
-
-func decodeHash(encodedHash string) (p *Argon2Params, salt, hash []byte, err error) {
-	vals := strings.Split(encodedHash, "$")
-	if len(vals) != 6 {
-		return nil, nil, nil, fmt.Errorf("invalid hash format")
+	if !role.Valid() {
+		return nil, fmt.Errorf("unknown user role %q", role)
 	}
 
-	var version int
-	_, err = fmt.Sscanf(vals[2], "v=%d", &version)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("incompatible argon2 version: %w", err)
-	}
+	// Логины сравниваются в канонической форме, а показываются так, как их ввёл пользователь.
+	login := strings.TrimSpace(req.Login)
+	normalized := login_policy.Normalize(login)
+	skeleton := login_policy.Skeleton(normalized)
 
-	p = &Argon2Params{}
-	_, err = fmt.Sscanf(vals[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
+	tx, err := d.dbConn.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("incompatible argon2 parameters: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", dbError(err))
+	}
+	defer tx.Rollback()
+
+	// Уникального индекса на скелет нет, поэтому регистрации с одним скелетом идут по очереди:
+	// иначе две параллельные вставки похожих логинов не увидели бы друг друга.
+	// В SQLite транзакция и так начинается с блокировки записи.
+	if d.dialect == dialectPostgres {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2))`, loginSkeletonLock, skeleton); err != nil {
+			return nil, fmt.Errorf("failed to lock login skeleton: %w", dbError(err))
+		}
 	}
 
-	salt, err = base64.RawStdEncoding.DecodeString(vals[4])
+	// Вставляем пользователя в БД, если нет другого логина с тем же «скелетом».
+	// Точный дубль нормализованного логина отсекает уникальный индекс.
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO users
+         (login, login_normalized, login_skeleton, password_hash, salt, role, created_at, updated_at)
+         SELECT $1, $2, $3, $4, $5, $6, $7, $8
+         WHERE NOT EXISTS (
+             SELECT 1 FROM users WHERE login_skeleton = $3 AND login_normalized <> $2
+         )
+         RETURNING id, status, role`,
+		login,
+		normalized,
+		skeleton,
+		encodedHash,
+		b64Salt,
+		role,
+		now,
+		now,
+	).Scan(&newUser.ID, &newUser.Status, &newUser.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLoginConfusable
+	}
+	if isUniqueViolation(err) {
+		// Точный дубль нормализованного логина: других уникальных колонок у новой строки нет.
+		return nil, ErrLoginTaken
+	}
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to decode salt: %w", err)
+		return nil, fmt.Errorf("failed to create new user: %w", dbError(err))
 	}
-	p.SaltLength = uint32(len(salt))
+	newUser.Login = login
+	newUser.CreatedAt = now
+	newUser.UpdatedAt = now
+	newUser.PasswordHash = encodedHash
 
-	hash, err = base64.RawStdEncoding.DecodeString(vals[5])
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to decode hash: %w", err)
+	if err := insertOutboxEvent(ctx, tx, NewDomainEvent(models.EventUserRegistered, newUser)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit new user: %w", dbError(err))
 	}
-	p.KeyLength = uint32(len(hash))
-	return p, salt, hash, nil
+	return newUser, nil
 }
 
-func (d DBStore) CreateUser(ctx context.Context, req models.UserRegReq) (newUser *models.User, err error) {
-
-	// Выделяем память под модель пользователя.
-	newUser = &models.User{}
+// ImportUser переносит пользователя из прежней системы вместе с его хэшем пароля.
+// Если пользователь с таким логином уже есть, он не перезаписывается и imported будет false.
+func (d DBStore) ImportUser(ctx context.Context, user models.User) (imported bool, err error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
 
-	// Генерируем соль.
-	salt, err := generateRandomBytes(DefaultArgon2Params.SaltLength)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	if err := ValidateHash(user.PasswordHash); err != nil {
+		return false, fmt.Errorf("invalid password hash for %q: %w", user.Login, err)
 	}
 
-	// Хэшируем пароль с солью
-	hash := argon2.IDKey(
-		[]byte(req.Password),
-		salt,
-		DefaultArgon2Params.Iterations,
-		DefaultArgon2Params.Memory,
-		DefaultArgon2Params.Parallelism,
-		DefaultArgon2Params.KeyLength,
-	)
-
-	// Подгатавливаем данные для сохранения
-	b64Salt := base64.RawStdEncoding.EncodeToString(salt)
-	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
-
-	encodedHash := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
-		argon2.Version,
-		DefaultArgon2Params.Memory,
-		DefaultArgon2Params.Iterations,
-		DefaultArgon2Params.Parallelism,
-		b64Salt, b64Hash)
-
-	// Текущее время для created_at и updated_at
 	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+
+	login := strings.TrimSpace(user.Login)
+	normalized := login_policy.Normalize(login)
 
-	// Вставляем пользователя в БД.
-	err = d.dbConn.QueryRow(
-		`INSERT INTO users 
-         (login, password_hash, salt, created_at, updated_at) 
-         VALUES ($1, $2, $3, $4, $5) 
+	// Соль у унаследованных хэшей зашита в сам хэш, отдельно её не храним.
+	var id int
+	err = d.dbConn.QueryRowContext(ctx,
+		`INSERT INTO users
+         (login, login_normalized, login_skeleton, password_hash, salt, created_at, updated_at)
+         VALUES ($1, $2, $3, $4, '', $5, $6)
+         ON CONFLICT DO NOTHING
          RETURNING id`,
-		req.Login,
-		encodedHash,
-		b64Salt,
-		now,
+		login,
+		normalized,
+		login_policy.Skeleton(normalized),
+		user.PasswordHash,
+		user.CreatedAt,
 		now,
-	).Scan(&newUser.ID)
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to create new user: %w", err)
+		return false, fmt.Errorf("failed to import user: %w", dbError(err))
 	}
-	newUser.Login = req.Login
-	newUser.CreatedAt = now
-	newUser.UpdatedAt = now
-	newUser.PasswordHash = encodedHash
-
-	return newUser, nil
+	return true, nil
 }
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"strconv"
+	"time"
+)
+
+// DeleteUser мягко удаляет учётную запись: войти в неё нельзя, выданные токены отозваны,
+// но до окончательной очистки её можно восстановить через RestoreUser.
+func (d DBStore) DeleteUser(ctx context.Context, userID int, reason string) (*models.User, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	return d.SetUserStatus(ctx, userID, models.UserStatusDeleted, reason)
+}
+
+// RestoreUser возвращает удалённую учётную запись в статус, который был у неё до удаления,
+// если её удалили не раньше deletedAfter. Отозванные при удалении токены остаются недействительными.
+func (d DBStore) RestoreUser(ctx context.Context, userID int, deletedAfter time.Time) (user *models.User, err error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	tx, err := d.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", dbError(err))
+	}
+	defer tx.Rollback()
+
+	var (
+		status    models.UserStatus
+		deletedAt sql.NullTime
+		purgedAt  sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx,
+		`SELECT status, deleted_at, purged_at FROM users WHERE id = $1 `+d.dialect.forUpdate(false),
+		userID,
+	).Scan(&status, &deletedAt, &purgedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user status: %w", dbError(err))
+	}
+	if status != models.UserStatusDeleted {
+		return nil, fmt.Errorf("%s -> %s: %w", status, models.UserStatusActive, ErrInvalidStatusTransition)
+	}
+	if purgedAt.Valid || !deletedAt.Valid || deletedAt.Time.Before(deletedAfter) {
+		return nil, ErrRestoreExpired
+	}
+
+	now := time.Now()
+	user, err = scanUser(tx.QueryRowContext(ctx,
+		`UPDATE users SET status = COALESCE(status_before_deletion, 'active'),
+             status_reason = COALESCE(status_reason_before_deletion, ''),
+             status_changed_at = $1, updated_at = $1, deleted_at = NULL,
+             status_before_deletion = NULL, status_reason_before_deletion = NULL
+         WHERE id = $2
+         RETURNING `+userColumns,
+		now,
+		userID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore user: %w", dbError(err))
+	}
+	if err := insertOutboxEvent(ctx, tx, NewDomainEvent(models.EventUserRestored, user)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit user restore: %w", dbError(err))
+	}
+	return user, nil
+}
+
+// PurgeDeletedUsers окончательно удаляет до limit учётных записей, удалённых раньше deletedBefore.
+// Зависимые данные удаляются каскадно, события outbox и доставки вебхуков с логином - явно.
+// С anonymize строка остаётся, но из неё стираются
+// все персональные данные, а логин заменяется на deleted#<id>, чтобы освободить прежний.
+// Символ # не проходит политику логинов, так что заглушка не совпадёт с настоящим логином.
+// Строки, которые сейчас обрабатывает другой экземпляр сервера, пропускаются.
+func (d DBStore) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time, limit int, anonymize bool) (purged int, err error) {
+	tx, err := d.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", dbError(err))
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id FROM users
+         WHERE status = 'deleted' AND purged_at IS NULL AND deleted_at < $1
+         ORDER BY deleted_at
+         LIMIT $2 `+d.dialect.forUpdate(true),
+		deletedBefore,
+		limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select users to purge: %w", dbError(err))
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan user id: %w", dbError(err))
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to select users to purge: %w", dbError(err))
+	}
+
+	now := time.Now()
+	for _, id := range ids {
+		if anonymize {
+			placeholder := "deleted#" + strconv.Itoa(id)
+			_, err = tx.ExecContext(ctx,
+				`UPDATE users SET
+                     login = $1, login_normalized = $1, login_skeleton = $1,
+                     password_hash = '', salt = '',
+                     display_name = '', email = '', phone = '', locale = '', timezone = '', avatar_url = '',
+                     status_reason = '', purged_at = $2, updated_at = $2
+                 WHERE id = $3`,
+				placeholder,
+				now,
+				id,
+			)
+			// В письмах, уведомлениях безопасности и устройствах остаются адрес почты и IP, их тоже удаляем.
+			for _, table := range []string{"notifications", "security_events", "devices", "login_challenges", "export_jobs"} {
+				if err == nil {
+					_, err = tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, id)
+				}
+			}
+		} else {
+			_, err = tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+		}
+		// В событиях outbox и доставках вебхуков остаётся логин, а внешнего ключа на users у них нет,
+		// поэтому каскад их не удаляет ни при одном способе.
+		for _, table := range []string{"outbox", "webhook_deliveries"} {
+			if err == nil {
+				_, err = tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, id)
+			}
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge user %d: %w", id, dbError(err))
+		}
+		// Логин к этому моменту уже стёрт, в событии остаётся только id.
+		user := &models.User{ID: id, Status: models.UserStatusDeleted}
+		if err := insertOutboxEvent(ctx, tx, NewDomainEvent(models.EventUserPurged, user)); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purge: %w", dbError(err))
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+)
+
+// ListDevices возвращает устройства пользователя по порядку появления.
+func (d DBStore) ListDevices(ctx context.Context, userID int) (devices []models.Device, err error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	rows, err := d.dbConn.QueryContext(ctx,
+		`SELECT id, user_id, client_family, subnet, last_ip, user_agent, first_seen_at, last_seen_at FROM devices
+         WHERE user_id = $1
+         ORDER BY id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", dbError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, *device)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", dbError(err))
+	}
+	return devices, nil
+}
+
+// TouchDevice отмечает вход с устройства: новое добавляется, у известного обновляются адрес, клиент и время.
+func (d DBStore) TouchDevice(ctx context.Context, device models.Device) (models.Device, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	device = prepareDevice(device)
+	row := d.dbConn.QueryRowContext(ctx,
+		`INSERT INTO devices (user_id, client_family, subnet, last_ip, user_agent, first_seen_at, last_seen_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $6)
+         ON CONFLICT (user_id, client_family, subnet) DO UPDATE
+             SET last_ip = EXCLUDED.last_ip, user_agent = EXCLUDED.user_agent, last_seen_at = EXCLUDED.last_seen_at
+         RETURNING id, user_id, client_family, subnet, last_ip, user_agent, first_seen_at, last_seen_at`,
+		device.UserID,
+		device.ClientFamily,
+		device.Subnet,
+		device.LastIP,
+		device.UserAgent,
+		device.LastSeenAt,
+	)
+	stored, err := scanDevice(row)
+	if err != nil {
+		return models.Device{}, err
+	}
+	return *stored, nil
+}
+
+// DeleteDevice забывает устройство пользователя.
+func (d DBStore) DeleteDevice(ctx context.Context, userID int, id int64) error {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	result, err := d.dbConn.ExecContext(ctx, `DELETE FROM devices WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete device: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", dbError(err))
+	}
+	if rows == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+func scanDevice(row rowScanner) (*models.Device, error) {
+	var device models.Device
+	err := row.Scan(
+		&device.ID,
+		&device.UserID,
+		&device.ClientFamily,
+		&device.Subnet,
+		&device.LastIP,
+		&device.UserAgent,
+		&device.FirstSeenAt,
+		&device.LastSeenAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan device: %w", dbError(err))
+	}
+	return &device, nil
+}
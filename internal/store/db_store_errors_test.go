@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestDBError(t *testing.T) {
+	plain := errors.New("syntax error")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "unique violation", err: &pgconn.PgError{Code: pgerrcode.UniqueViolation}, want: ErrConstraint},
+		{name: "foreign key violation", err: &pgconn.PgError{Code: pgerrcode.ForeignKeyViolation}, want: ErrConstraint},
+		{name: "serialization failure", err: &pgconn.PgError{Code: pgerrcode.SerializationFailure}, want: ErrConflict},
+		{name: "deadlock", err: &pgconn.PgError{Code: pgerrcode.DeadlockDetected}, want: ErrConflict},
+		{name: "too many connections", err: &pgconn.PgError{Code: pgerrcode.TooManyConnections}, want: ErrUnavailable},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: pgerrcode.AdminShutdown}, want: ErrUnavailable},
+		{name: "broken connection", err: fmt.Errorf("failed to list users: %w", driver.ErrBadConn), want: ErrUnavailable},
+		{name: "not null is a bug, not a conflict", err: &pgconn.PgError{Code: pgerrcode.NotNullViolation}, want: nil},
+		{name: "plain error", err: plain, want: nil},
+		{name: "deadline stays a deadline", err: context.DeadlineExceeded, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dbError(tt.err)
+			assert.ErrorIs(t, got, tt.err, "the driver error stays in the chain")
+			if tt.want == nil {
+				assert.Equal(t, tt.err, got)
+				return
+			}
+			assert.ErrorIs(t, got, tt.want)
+			assert.False(t, errors.Is(got, ErrConflict) && errors.Is(got, ErrConstraint), "retryable or not, never both")
+		})
+	}
+}
+
+func TestSQLiteStore_DomainErrors(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	_, err := s.TouchDevice(context.Background(), models.Device{UserID: 42, ClientFamily: "Firefox/Linux", Subnet: "192.0.2.0/24", LastSeenAt: time.Now()})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrConstraint, "a device of a missing user breaks the foreign key")
+	assert.NotErrorIs(t, err, ErrConflict, "retrying will not help")
+}
+
+// Ошибки записи в outbox классифицируются так же, как остальные: её делают почти все изменения.
+func TestSQLiteStore_OutboxErrors(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	event := NewDomainEvent(models.EventUserLoggedIn, &models.User{ID: 1, Login: "anna"})
+	require.NoError(t, insertOutboxEvent(context.Background(), s.dbConn, event))
+	err := insertOutboxEvent(context.Background(), s.dbConn, event)
+	assert.ErrorIs(t, err, ErrConstraint, "the event id is unique")
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sort"
+	"time"
+)
+
+const exportJobColumns = `id, user_id, status, created_at, lease_until, expires_at`
+
+// EnqueueExportJob ставит выгрузку в очередь. Если у пользователя уже собирается архив, возвращается он;
+// если сборки ждут maxPending выгрузок других пользователей - ErrExportQueueFull.
+func (d DBStore) EnqueueExportJob(ctx context.Context, job models.ExportJob, maxPending int) (models.ExportJob, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	pending, err := d.pendingExportJob(ctx, job.UserID)
+	if err == nil {
+		return *pending, nil
+	}
+	if !errors.Is(err, ErrExportJobNotFound) {
+		return models.ExportJob{}, err
+	}
+	var queued int
+	if err := d.dbConn.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM export_jobs WHERE status = 'pending'`).Scan(&queued); err != nil {
+		return models.ExportJob{}, fmt.Errorf("failed to count export jobs: %w", dbError(err))
+	}
+	if queued >= maxPending {
+		return models.ExportJob{}, ErrExportQueueFull
+	}
+	stored, err := scanExportJob(d.dbConn.QueryRowContext(ctx,
+		`INSERT INTO export_jobs (id, user_id, status, created_at, lease_until)
+         VALUES ($1, $2, 'pending', $3, $3)
+         RETURNING `+exportJobColumns,
+		job.ID,
+		job.UserID,
+		job.CreatedAt,
+	))
+	if isUniqueViolation(err) {
+		// Параллельный запрос того же пользователя успел первым.
+		pending, err := d.pendingExportJob(ctx, job.UserID)
+		if err != nil {
+			return models.ExportJob{}, err
+		}
+		return *pending, nil
+	}
+	if err != nil {
+		return models.ExportJob{}, fmt.Errorf("failed to enqueue export job: %w", dbError(err))
+	}
+	return *stored, nil
+}
+
+func (d DBStore) pendingExportJob(ctx context.Context, userID int) (*models.ExportJob, error) {
+	job, err := scanExportJob(d.dbConn.QueryRowContext(ctx,
+		`SELECT `+exportJobColumns+` FROM export_jobs WHERE user_id = $1 AND status = 'pending'`, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExportJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get export job: %w", dbError(err))
+	}
+	return job, nil
+}
+
+// GetLastExportJob возвращает последнюю выгрузку пользователя.
+func (d DBStore) GetLastExportJob(ctx context.Context, userID int) (*models.ExportJob, error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	job, err := scanExportJob(d.dbConn.QueryRowContext(ctx,
+		`SELECT `+exportJobColumns+` FROM export_jobs
+         WHERE user_id = $1
+         ORDER BY created_at DESC, id DESC
+         LIMIT 1`,
+		userID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExportJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get export job: %w", dbError(err))
+	}
+	return job, nil
+}
+
+// ClaimExportJobs забирает выгрузки, которые ждут сборки, и закрепляет их за собой до leaseUntil.
+// Если экземпляр упадёт посреди сборки, выгрузку после аренды соберёт другой.
+func (d DBStore) ClaimExportJobs(ctx context.Context, now, leaseUntil time.Time, limit int) (jobs []models.ExportJob, err error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	claim := `WITH claimed AS (
+             UPDATE export_jobs SET lease_until = $2
+             WHERE id IN (
+                 SELECT id FROM export_jobs
+                 WHERE status = 'pending' AND lease_until <= $1
+                 ORDER BY lease_until, created_at
+                 LIMIT $3
+                 FOR UPDATE SKIP LOCKED
+             )
+             RETURNING ` + exportJobColumns + `
+         )
+         SELECT * FROM claimed ORDER BY created_at`
+	if d.dialect == dialectSQLite {
+		// SQLite не принимает UPDATE внутри WITH, выгрузки упорядочиваются после чтения.
+		claim = `UPDATE export_jobs SET lease_until = $2
+         WHERE id IN (
+             SELECT id FROM export_jobs
+             WHERE status = 'pending' AND lease_until <= $1
+             ORDER BY lease_until, created_at
+             LIMIT $3
+         )
+         RETURNING ` + exportJobColumns
+	}
+	rows, err := d.dbConn.QueryContext(ctx,
+		claim,
+		now,
+		leaseUntil,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim export jobs: %w", dbError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		job, err := scanExportJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan export job: %w", dbError(err))
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim export jobs: %w", dbError(err))
+	}
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	return jobs, nil
+}
+
+// CompleteExportJob сохраняет итог сборки: статус, срок хранения и, для готовой выгрузки, архив.
+func (d DBStore) CompleteExportJob(ctx context.Context, job models.ExportJob, archive []byte) error {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	if job.Status != models.ExportReady {
+		archive = nil
+	}
+	result, err := d.dbConn.ExecContext(ctx,
+		`UPDATE export_jobs SET status = $1, expires_at = $2, archive = $3 WHERE id = $4`,
+		job.Status,
+		job.ExpiresAt,
+		archive,
+		job.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to complete export job: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", dbError(err))
+	}
+	if rows == 0 {
+		// Пользователя удалили, пока собирался архив.
+		return ErrExportJobNotFound
+	}
+	return nil
+}
+
+// GetExportArchive возвращает собранный архив выгрузки.
+func (d DBStore) GetExportArchive(ctx context.Context, id string) (archive []byte, err error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	err = d.dbConn.QueryRowContext(ctx,
+		`SELECT archive FROM export_jobs WHERE id = $1 AND status = 'ready'`, id).Scan(&archive)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExportJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get export archive: %w", dbError(err))
+	}
+	return archive, nil
+}
+
+// DeleteExpiredExportJobs удаляет собранные и неудавшиеся выгрузки, срок хранения которых истёк до before.
+func (d DBStore) DeleteExpiredExportJobs(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	result, err := d.dbConn.ExecContext(ctx,
+		`DELETE FROM export_jobs WHERE status <> 'pending' AND expires_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired export jobs: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", dbError(err))
+	}
+	return int(rows), nil
+}
+
+func scanExportJob(row rowScanner) (*models.ExportJob, error) {
+	var (
+		job       models.ExportJob
+		expiresAt sql.NullTime
+	)
+	err := row.Scan(
+		&job.ID,
+		&job.UserID,
+		&job.Status,
+		&job.CreatedAt,
+		&job.LeaseUntil,
+		&expiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		job.ExpiresAt = expiresAt.Time
+	}
+	return &job, nil
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+)
+
+const ipRuleColumns = `id, user_id, role, cidr, action, comment, created_at`
+
+// CreateIPRule сохраняет правило. Для несуществующего пользователя возвращается ErrUserNotFound.
+func (d DBStore) CreateIPRule(ctx context.Context, rule models.IPRule) (models.IPRule, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	var role sql.NullString
+	if rule.Role != "" {
+		role = sql.NullString{String: string(rule.Role), Valid: true}
+	}
+	row := d.dbConn.QueryRowContext(ctx,
+		`INSERT INTO ip_rules (user_id, role, cidr, action, comment)
+         SELECT $1, $2, $3, $4, $5
+         WHERE CAST($1 AS INT) IS NULL OR EXISTS (SELECT 1 FROM users WHERE id = $1)
+         RETURNING `+ipRuleColumns,
+		rule.UserID,
+		role,
+		rule.CIDR,
+		rule.Action,
+		rule.Comment,
+	)
+	created, err := scanIPRule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.IPRule{}, ErrUserNotFound
+	}
+	if err != nil {
+		return models.IPRule{}, err
+	}
+	return *created, nil
+}
+
+// ListIPRules возвращает правила по порядку создания.
+func (d DBStore) ListIPRules(ctx context.Context, query models.IPRuleQuery) (rules []models.IPRule, err error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	var role sql.NullString
+	if query.Role != "" {
+		role = sql.NullString{String: string(query.Role), Valid: true}
+	}
+	rows, err := d.dbConn.QueryContext(ctx,
+		`SELECT `+ipRuleColumns+` FROM ip_rules
+         WHERE (CAST($1 AS INT) IS NULL OR user_id = $1) AND (CAST($2 AS VARCHAR) IS NULL OR role = $2)
+         ORDER BY id`,
+		query.UserID,
+		role,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ip rules: %w", dbError(err))
+	}
+	defer rows.Close()
+	return scanIPRules(rows)
+}
+
+// DeleteIPRule удаляет правило и возвращает его.
+func (d DBStore) DeleteIPRule(ctx context.Context, id int) (*models.IPRule, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	row := d.dbConn.QueryRowContext(ctx, `DELETE FROM ip_rules WHERE id = $1 RETURNING `+ipRuleColumns, id)
+	rule, err := scanIPRule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrIPRuleNotFound
+	}
+	return rule, err
+}
+
+// userIPRules возвращает правила, действующие на пользователя и его роль.
+func (d DBStore) userIPRules(ctx context.Context, userID int, role models.UserRole) ([]models.IPRule, error) {
+	rows, err := d.dbConn.QueryContext(ctx,
+		`SELECT `+ipRuleColumns+` FROM ip_rules WHERE user_id = $1 OR role = $2 ORDER BY id`,
+		userID,
+		role,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user ip rules: %w", dbError(err))
+	}
+	defer rows.Close()
+	return scanIPRules(rows)
+}
+
+func scanIPRules(rows dbRows) (rules []models.IPRule, err error) {
+	for rows.Next() {
+		rule, err := scanIPRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate ip rules: %w", dbError(err))
+	}
+	return rules, nil
+}
+
+func scanIPRule(row rowScanner) (*models.IPRule, error) {
+	var (
+		rule   models.IPRule
+		userID sql.NullInt64
+		role   sql.NullString
+	)
+	err := row.Scan(&rule.ID, &userID, &role, &rule.CIDR, &rule.Action, &rule.Comment, &rule.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan ip rule: %w", dbError(err))
+	}
+	if userID.Valid {
+		id := int(userID.Int64)
+		rule.UserID = &id
+	}
+	rule.Role = models.UserRole(role.String)
+	return &rule, nil
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"time"
+)
+
+// PutLoginChallenge выдаёт устройству код, заменяя прежний, и заодно удаляет истёкшие коды.
+func (d DBStore) PutLoginChallenge(ctx context.Context, challenge models.LoginChallenge, now time.Time) error {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	if _, err := d.dbConn.ExecContext(ctx, `DELETE FROM login_challenges WHERE expires_at < $1`, now.UTC()); err != nil {
+		return fmt.Errorf("failed to delete expired login challenges: %w", dbError(err))
+	}
+	_, err := d.dbConn.ExecContext(ctx,
+		`INSERT INTO login_challenges (user_id, client_family, subnet, code_hash, attempts, expires_at)
+         VALUES ($1, $2, $3, $4, 0, $5)
+         ON CONFLICT (user_id, client_family, subnet) DO UPDATE
+             SET code_hash = EXCLUDED.code_hash, attempts = 0, expires_at = EXCLUDED.expires_at`,
+		challenge.UserID,
+		challenge.ClientFamily,
+		challenge.Subnet,
+		challenge.CodeHash,
+		challenge.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to put login challenge: %w", dbError(err))
+	}
+	return nil
+}
+
+// CheckLoginChallenge сверяет код устройства. Подошедший код гасится тем же запросом, что его нашёл,
+// поэтому два одновременных входа с одним кодом не пройдут оба; неверный код считается попыткой.
+func (d DBStore) CheckLoginChallenge(ctx context.Context, challenge models.LoginChallenge, now time.Time) (passed bool, err error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	result, err := d.dbConn.ExecContext(ctx,
+		`DELETE FROM login_challenges
+         WHERE user_id = $1 AND client_family = $2 AND subnet = $3
+           AND code_hash = $4 AND attempts < $5 AND expires_at > $6`,
+		challenge.UserID,
+		challenge.ClientFamily,
+		challenge.Subnet,
+		challenge.CodeHash,
+		MaxLoginChallengeAttempts,
+		now.UTC(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to check login challenge: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", dbError(err))
+	}
+	if rows > 0 {
+		return true, nil
+	}
+	_, err = d.dbConn.ExecContext(ctx,
+		`UPDATE login_challenges SET attempts = attempts + 1
+         WHERE user_id = $1 AND client_family = $2 AND subnet = $3 AND expires_at > $4`,
+		challenge.UserID,
+		challenge.ClientFamily,
+		challenge.Subnet,
+		now.UTC(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to count login challenge attempt: %w", dbError(err))
+	}
+	return false, nil
+}
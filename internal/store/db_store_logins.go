@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/login_policy"
+	"sort"
+)
+
+// LoginDrift - пользователь, чья сохранённая каноническая форма логина отличается от вычисленной приложением.
+type LoginDrift struct {
+	UserID             int
+	Login              string
+	StoredNormalized   string
+	ComputedNormalized string
+	ComputedSkeleton   string
+}
+
+// LoginReconciliation - итог сверки логинов с текущими правилами нормализации.
+type LoginReconciliation struct {
+	Drifted    []LoginDrift
+	Collisions map[string][]string // Каноническая форма -> совпадающие в ней логины.
+	Confusable map[string][]string // Скелет -> разные канонические формы, которые выглядят одинаково.
+	Updated    int
+}
+
+// ReconcileLogins пересчитывает канонические формы и скелеты всех логинов и сообщает о расхождениях и коллизиях.
+// При apply расходящиеся записи обновляются, кроме тех, что сталкиваются с другими логинами.
+func (d DBStore) ReconcileLogins(ctx context.Context, apply bool) (report LoginReconciliation, err error) {
+	rows, err := d.dbConn.QueryContext(ctx,
+		`SELECT id, login, login_normalized, login_skeleton FROM users ORDER BY id`)
+	if err != nil {
+		return report, fmt.Errorf("failed to select logins: %w", dbError(err))
+	}
+	defer rows.Close()
+
+	byNormalized := make(map[string][]string)
+	bySkeleton := make(map[string]map[string]struct{})
+	for rows.Next() {
+		var (
+			id                          int
+			login, normalized, skeleton string
+		)
+		if err := rows.Scan(&id, &login, &normalized, &skeleton); err != nil {
+			return report, fmt.Errorf("failed to scan login: %w", dbError(err))
+		}
+		computed := login_policy.Normalize(login)
+		computedSkeleton := login_policy.Skeleton(computed)
+		if computed != normalized || computedSkeleton != skeleton {
+			report.Drifted = append(report.Drifted, LoginDrift{
+				UserID:             id,
+				Login:              login,
+				StoredNormalized:   normalized,
+				ComputedNormalized: computed,
+				ComputedSkeleton:   computedSkeleton,
+			})
+		}
+		byNormalized[computed] = append(byNormalized[computed], fmt.Sprintf("%s (id %d)", login, id))
+		if bySkeleton[computedSkeleton] == nil {
+			bySkeleton[computedSkeleton] = make(map[string]struct{})
+		}
+		bySkeleton[computedSkeleton][computed] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("failed to iterate logins: %w", dbError(err))
+	}
+
+	report.Collisions = make(map[string][]string)
+	for normalized, logins := range byNormalized {
+		if len(logins) > 1 {
+			report.Collisions[normalized] = logins
+		}
+	}
+	report.Confusable = make(map[string][]string)
+	for skeleton, forms := range bySkeleton {
+		if len(forms) > 1 {
+			for form := range forms {
+				report.Confusable[skeleton] = append(report.Confusable[skeleton], form)
+			}
+			sort.Strings(report.Confusable[skeleton])
+		}
+	}
+
+	if !apply {
+		return report, nil
+	}
+	for _, drift := range report.Drifted {
+		if _, collides := report.Collisions[drift.ComputedNormalized]; collides {
+			continue
+		}
+		_, err := d.dbConn.ExecContext(ctx,
+			`UPDATE users SET login_normalized = $1, login_skeleton = $2 WHERE id = $3`,
+			drift.ComputedNormalized, drift.ComputedSkeleton, drift.UserID)
+		if err != nil {
+			return report, fmt.Errorf("failed to update login of user %d: %w", drift.UserID, dbError(err))
+		}
+		report.Updated++
+	}
+	return report, nil
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sort"
+	"time"
+)
+
+const notificationColumns = `id, dedup_key, user_id, kind, locale, recipient, data, status, attempts,
+    next_attempt_at, last_error, created_at, sent_at`
+
+// EnqueueNotification ставит письмо в очередь. Письмо с тем же DedupKey повторно не ставится,
+// так что повтор события из outbox не даёт второго письма.
+func (d DBStore) EnqueueNotification(ctx context.Context, n models.Notification) (enqueued bool, err error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	data, err := json.Marshal(n.Data)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode notification data: %w", err)
+	}
+	result, err := d.dbConn.ExecContext(ctx,
+		`INSERT INTO notifications (dedup_key, user_id, kind, locale, recipient, data, next_attempt_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7)
+         ON CONFLICT (dedup_key) DO NOTHING`,
+		n.DedupKey,
+		n.UserID,
+		n.Kind,
+		n.Locale,
+		n.Recipient,
+		string(data),
+		time.Now(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue notification: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", dbError(err))
+	}
+	return rows > 0, nil
+}
+
+// ClaimNotifications забирает письма, которым пора уходить, и откладывает их до leaseUntil.
+// Если отправитель упадёт, не записав результат, письмо само вернётся в очередь после аренды.
+func (d DBStore) ClaimNotifications(ctx context.Context, now, leaseUntil time.Time, limit int) (notifications []models.Notification, err error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	claim := `WITH claimed AS (
+             UPDATE notifications SET next_attempt_at = $2
+             WHERE id IN (
+                 SELECT id FROM notifications
+                 WHERE status = 'pending' AND next_attempt_at <= $1
+                 ORDER BY next_attempt_at, id
+                 LIMIT $3
+                 FOR UPDATE SKIP LOCKED
+             )
+             RETURNING ` + notificationColumns + `
+         )
+         SELECT * FROM claimed ORDER BY id`
+	if d.dialect == dialectSQLite {
+		// SQLite не принимает UPDATE внутри WITH, письма упорядочиваются после чтения.
+		claim = `UPDATE notifications SET next_attempt_at = $2
+         WHERE id IN (
+             SELECT id FROM notifications
+             WHERE status = 'pending' AND next_attempt_at <= $1
+             ORDER BY next_attempt_at, id
+             LIMIT $3
+         )
+         RETURNING ` + notificationColumns
+	}
+	rows, err := d.dbConn.QueryContext(ctx,
+		claim,
+		now,
+		leaseUntil,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim notifications: %w", dbError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", dbError(err))
+		}
+		notifications = append(notifications, *n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim notifications: %w", dbError(err))
+	}
+	sort.Slice(notifications, func(i, j int) bool { return notifications[i].ID < notifications[j].ID })
+	return notifications, nil
+}
+
+// RecordNotificationResult сохраняет итог попытки отправки.
+func (d DBStore) RecordNotificationResult(ctx context.Context, n models.Notification) error {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	result, err := d.dbConn.ExecContext(ctx,
+		`UPDATE notifications
+         SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, sent_at = $5
+         WHERE id = $6`,
+		n.Status,
+		n.Attempts,
+		n.NextAttemptAt,
+		truncateRunes(n.LastError, 1024),
+		n.SentAt,
+		n.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update notification: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", dbError(err))
+	}
+	if rows == 0 {
+		// Пользователя удалили, пока шла отправка.
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// GetNotificationOptOuts возвращает виды писем, от которых пользователь отказался.
+func (d DBStore) GetNotificationOptOuts(ctx context.Context, userID int) (kinds []models.NotificationKind, err error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	rows, err := d.dbConn.QueryContext(ctx,
+		`SELECT kind FROM notification_opt_outs WHERE user_id = $1 ORDER BY kind`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification opt-outs: %w", dbError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var kind models.NotificationKind
+		if err := rows.Scan(&kind); err != nil {
+			return nil, fmt.Errorf("failed to scan notification opt-out: %w", dbError(err))
+		}
+		kinds = append(kinds, kind)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get notification opt-outs: %w", dbError(err))
+	}
+	return kinds, nil
+}
+
+// SetNotificationOptOut отписывает пользователя от писем вида kind или подписывает обратно.
+func (d DBStore) SetNotificationOptOut(ctx context.Context, userID int, kind models.NotificationKind, optOut bool) error {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	var err error
+	if optOut {
+		_, err = d.dbConn.ExecContext(ctx,
+			`INSERT INTO notification_opt_outs (user_id, kind) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, kind)
+	} else {
+		_, err = d.dbConn.ExecContext(ctx,
+			`DELETE FROM notification_opt_outs WHERE user_id = $1 AND kind = $2`, userID, kind)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set notification opt-out: %w", dbError(err))
+	}
+	return nil
+}
+
+func scanNotification(row rowScanner) (*models.Notification, error) {
+	var (
+		n      models.Notification
+		data   string
+		sentAt sql.NullTime
+	)
+	err := row.Scan(
+		&n.ID,
+		&n.DedupKey,
+		&n.UserID,
+		&n.Kind,
+		&n.Locale,
+		&n.Recipient,
+		&data,
+		&n.Status,
+		&n.Attempts,
+		&n.NextAttemptAt,
+		&n.LastError,
+		&n.CreatedAt,
+		&sentAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(data), &n.Data); err != nil {
+		return nil, fmt.Errorf("failed to decode notification data: %w", err)
+	}
+	if sentAt.Valid {
+		n.SentAt = &sentAt.Time
+	}
+	return &n, nil
+}
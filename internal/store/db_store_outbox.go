@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sort"
+	"time"
+)
+
+// Длина last_error в outbox.
+const maxOutboxError = 1024
+
+const outboxColumns = `id, payload, attempts, next_attempt_at, last_error, created_at, published_at, dead_at`
+
+// AppendOutboxEvent пишет событие, не привязанное к изменению данных, например о входе.
+func (d DBStore) AppendOutboxEvent(ctx context.Context, event models.DomainEvent) error {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	return insertOutboxEvent(ctx, d.dbConn, event)
+}
+
+// ClaimOutboxEvents забирает до limit неразосланных событий по порядку id и откладывает их до leaseUntil.
+// Строки не остаются заблокированными, пока события уходят получателям: аренда сразу фиксируется,
+// а итог записывает RecordOutboxResult. Занятые другим экземпляром сервера строки пропускаются.
+// Если сервер упадёт после рассылки, но до записи итога, событие уйдёт ещё раз после аренды:
+// доставка как минимум однократная.
+func (d DBStore) ClaimOutboxEvents(ctx context.Context, now, leaseUntil time.Time, limit int) (events []models.OutboxEvent, err error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	claim := `WITH claimed AS (
+             UPDATE outbox SET next_attempt_at = $2
+             WHERE id IN (
+                 SELECT id FROM outbox
+                 WHERE published_at IS NULL AND dead_at IS NULL AND next_attempt_at <= $1
+                 ORDER BY id
+                 LIMIT $3
+                 FOR UPDATE SKIP LOCKED
+             )
+             RETURNING ` + outboxColumns + `
+         )
+         SELECT * FROM claimed ORDER BY id`
+	if d.dialect == dialectSQLite {
+		// SQLite не принимает UPDATE внутри WITH, события упорядочиваются после чтения.
+		claim = `UPDATE outbox SET next_attempt_at = $2
+         WHERE id IN (
+             SELECT id FROM outbox
+             WHERE published_at IS NULL AND dead_at IS NULL AND next_attempt_at <= $1
+             ORDER BY id
+             LIMIT $3
+         )
+         RETURNING ` + outboxColumns
+	}
+	rows, err := d.dbConn.QueryContext(ctx,
+		claim,
+		now,
+		leaseUntil,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", dbError(err))
+	}
+	var broken []models.OutboxEvent
+	for rows.Next() {
+		event, err := scanOutboxEvent(rows)
+		var decodeErr *outboxDecodeError
+		if errors.As(err, &decodeErr) {
+			broken = append(broken, decodeErr.event)
+			continue
+		}
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan outbox event: %w", dbError(err))
+		}
+		events = append(events, *event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", dbError(err))
+	}
+	// Событие, которое не разобрать, не разошлётся и со следующей попытки.
+	for _, event := range broken {
+		event.DeadAt = &now
+		if err := d.RecordOutboxResult(ctx, event); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+// RecordOutboxResult сохраняет итог рассылки события.
+func (d DBStore) RecordOutboxResult(ctx context.Context, event models.OutboxEvent) error {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	_, err := d.dbConn.ExecContext(ctx,
+		`UPDATE outbox
+         SET attempts = $1, last_error = $2, next_attempt_at = $3, published_at = $4, dead_at = $5
+         WHERE id = $6`,
+		event.Attempts,
+		truncateRunes(event.LastError, maxOutboxError),
+		event.NextAttemptAt,
+		event.PublishedAt,
+		event.DeadAt,
+		event.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update outbox event %d: %w", event.ID, dbError(err))
+	}
+	return nil
+}
+
+// DeletePublishedOutboxEvents удаляет события, разосланные раньше before.
+func (d DBStore) DeletePublishedOutboxEvents(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	result, err := d.dbConn.ExecContext(ctx,
+		`DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete published outbox events: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", dbError(err))
+	}
+	return int(rows), nil
+}
+
+// outboxDecodeError - строка outbox прочитана, но событие в ней не разобрать.
+type outboxDecodeError struct {
+	event models.OutboxEvent
+}
+
+func (e *outboxDecodeError) Error() string {
+	return e.event.LastError
+}
+
+func scanOutboxEvent(row rowScanner) (*models.OutboxEvent, error) {
+	var (
+		event       models.OutboxEvent
+		payload     string
+		publishedAt sql.NullTime
+		deadAt      sql.NullTime
+	)
+	err := row.Scan(
+		&event.ID,
+		&payload,
+		&event.Attempts,
+		&event.NextAttemptAt,
+		&event.LastError,
+		&event.CreatedAt,
+		&publishedAt,
+		&deadAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if publishedAt.Valid {
+		event.PublishedAt = &publishedAt.Time
+	}
+	if deadAt.Valid {
+		event.DeadAt = &deadAt.Time
+	}
+	if err := json.Unmarshal([]byte(payload), &event.Event); err != nil {
+		event.LastError = fmt.Sprintf("failed to decode domain event: %v", err)
+		return nil, &outboxDecodeError{event: event}
+	}
+	return &event, nil
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// IncrementRateLimit засчитывает запрос в окно, начавшееся в windowStart, и возвращает число запросов
+// в этом окне и в предыдущем. Всё делается одним запросом, так что экземпляры сервера не теряют счёт.
+// Окно старше сохранённого засчитывается в сохранённое, window_start назад не сдвигается, см. shiftRateLimitCounter.
+func (d DBStore) IncrementRateLimit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (current, previous int, err error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	err = d.dbConn.QueryRowContext(ctx,
+		`INSERT INTO rate_limits (key, window_start, current, previous)
+         VALUES ($1, $2, 1, 0)
+         ON CONFLICT (key) DO UPDATE SET
+             previous     = CASE
+                                WHEN rate_limits.window_start >= $2 THEN rate_limits.previous
+                                WHEN rate_limits.window_start = $3 THEN rate_limits.current
+                                ELSE 0
+                            END,
+             current      = CASE WHEN rate_limits.window_start >= $2 THEN rate_limits.current + 1 ELSE 1 END,
+             window_start = CASE WHEN rate_limits.window_start > $2 THEN rate_limits.window_start ELSE $2 END
+         RETURNING current, previous`,
+		key,
+		windowStart.UTC(),
+		windowStart.Add(-window).UTC(),
+	).Scan(&current, &previous)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to increment rate limit: %w", dbError(err))
+	}
+	return current, previous, nil
+}
+
+// DeleteStaleRateLimits удаляет ключи, окно которых началось раньше before.
+func (d DBStore) DeleteStaleRateLimits(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	result, err := d.dbConn.ExecContext(ctx, `DELETE FROM rate_limits WHERE window_start < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale rate limits: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", dbError(err))
+	}
+	return int(rows), nil
+}
@@ -2,31 +2,108 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/login_policy"
 	"github.com/eampleev23/raya-backend.git/internal/models"
 )
 
-func (d DBStore) GetUserByLogin(ctx context.Context, userLoginReq models.UserLoginReq) (userModelResponse *models.User, err error) {
+// userColumns - колонки, из которых собирается models.User, в порядке сканирования scanUser.
+const userColumns = `id, login, password_hash, salt,
+       display_name, email, phone, locale, timezone, avatar_url,
+       role, status, status_reason, status_changed_at, deleted_at,
+       created_at, updated_at`
+
+// rowScanner - общее у строки и строк результата, см. queryer.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser разбирает строку с колонками userColumns; extra - колонки, выбранные после них.
+func scanUser(row rowScanner, extra ...any) (*models.User, error) {
+	user := &models.User{}
+	dest := []any{
+		&user.ID,
+		&user.Login,
+		&user.PasswordHash,
+		&user.Salt,
+		&user.DisplayName,
+		&user.Email,
+		&user.Phone,
+		&user.Locale,
+		&user.Timezone,
+		&user.AvatarURL,
+		&user.Role,
+		&user.Status,
+		&user.StatusReason,
+		&user.StatusChangedAt,
+		&user.DeletedAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
 
-	userModelResponse = &models.User{}
+func (d DBStore) GetUserByLogin(ctx context.Context, userLoginReq models.UserLoginReq) (userModelResponse *models.User, err error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
 
-	// Получаем данные по логину.
+	// Получаем данные по логину в канонической форме.
 	row := d.dbConn.QueryRowContext(ctx,
-		`SELECT id, login, password_hash, salt FROM users WHERE login = $1 LIMIT 1`,
-		userLoginReq.Login,
+		`SELECT `+userColumns+` FROM users WHERE login_normalized = $1 LIMIT 1`,
+		login_policy.Normalize(userLoginReq.Login),
 	)
 
 	// Разбираем результат.
-	err = row.Scan(
-		&userModelResponse.ID,
-		&userModelResponse.Login,
-		&userModelResponse.PasswordHash,
-		&userModelResponse.Salt,
-	)
-
+	userModelResponse, err = scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
 	if err != nil {
-		return userModelResponse, fmt.Errorf("faild to get user by login and password like this %w", err)
+		return &models.User{}, fmt.Errorf("faild to get user by login and password like this %w", dbError(err))
 	}
 
 	return userModelResponse, err
 }
+
+// GetUserByID возвращает пользователя по идентификатору или ErrUserNotFound.
+func (d DBStore) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	row := d.dbConn.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, userID)
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by id: %w", dbError(err))
+	}
+	return user, nil
+}
+
+// GetUserAuthState возвращает статус учётной записи и ограничения по адресам для проверки токена.
+func (d DBStore) GetUserAuthState(ctx context.Context, userID int) (state models.UserAuthState, err error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	var tokensValidAfter sql.NullTime
+	err = d.dbConn.QueryRowContext(ctx,
+		`SELECT role, status, status_changed_at, tokens_valid_after FROM users WHERE id = $1`,
+		userID,
+	).Scan(&state.Role, &state.Status, &state.StatusChangedAt, &tokensValidAfter)
+	state.TokensValidAfter = tokensValidAfter.Time
+	if errors.Is(err, sql.ErrNoRows) {
+		return state, ErrUserNotFound
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to get user auth state: %w", dbError(err))
+	}
+	state.IPRules, err = d.userIPRules(ctx, userID, state.Role)
+	if err != nil {
+		return state, err
+	}
+	return state, nil
+}
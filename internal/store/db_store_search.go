@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+)
+
+// SearchUsers ищет пользователей по trigram-индексам, правила релевантности описаны в search.go.
+func (d DBStore) SearchUsers(ctx context.Context, query models.UserSearchQuery) (results []models.UserSearchResult, err error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	q, err := normalizeSearchQuery(query.Query)
+	if err != nil {
+		return nil, err
+	}
+	if d.dialect == dialectSQLite {
+		return d.searchUsersInMemory(ctx, query)
+	}
+	contains := "%" + likeEscaper.Replace(q) + "%"
+	prefix := likeEscaper.Replace(q) + "%"
+
+	// Без курсора подставляем позицию, раньше которой ничего нет.
+	afterRank, afterID := float32(1e9), 0
+	if query.After != nil {
+		afterRank, afterID = query.After.Rank, query.After.ID
+	}
+
+	rows, err := d.dbConn.QueryContext(ctx,
+		`SELECT `+userColumns+`, rank FROM (
+             SELECT *, (GREATEST(
+                     similarity(login_normalized, $1),
+                     similarity(lower(display_name), $1),
+                     similarity(lower(email), $1)
+                 ) + CASE WHEN login_normalized LIKE $3 THEN $4::real ELSE 0::real END)::real AS rank
+             FROM users
+             WHERE login_normalized % $1 OR lower(display_name) % $1 OR lower(email) % $1
+                OR login_normalized LIKE $2 OR lower(display_name) LIKE $2 OR lower(email) LIKE $2
+         ) found
+         WHERE rank < $5::real OR (rank = $5::real AND id > $6)
+         ORDER BY rank DESC, id
+         LIMIT $7`,
+		q,
+		contains,
+		prefix,
+		loginPrefixBoost,
+		afterRank,
+		afterID,
+		query.Limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", dbError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rank float32
+		user, err := scanUser(rows, &rank)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", dbError(err))
+		}
+		results = append(results, models.UserSearchResult{User: *user, Rank: rank})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", dbError(err))
+	}
+	return results, nil
+}
+
+// searchUsersInMemory ранжирует пользователей в памяти: в SQLite нет pg_trgm.
+// Установки на SQLite небольшие, так что полный просмотр таблицы им по силам.
+func (d DBStore) searchUsersInMemory(ctx context.Context, query models.UserSearchQuery) ([]models.UserSearchResult, error) {
+	rows, err := d.dbConn.QueryContext(ctx, `SELECT `+userColumns+` FROM users`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", dbError(err))
+	}
+	defer rows.Close()
+	idx := NewUserIndex()
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", dbError(err))
+		}
+		idx.Put(*user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", dbError(err))
+	}
+	return idx.SearchUsers(ctx, query)
+}
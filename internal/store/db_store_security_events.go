@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+)
+
+// AppendSecurityEvent сохраняет уведомление пользователя. Повтор того же события из outbox пропускается.
+func (d DBStore) AppendSecurityEvent(ctx context.Context, event models.SecurityEvent) (appended bool, err error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	event = prepareSecurityEvent(event)
+	result, err := d.dbConn.ExecContext(ctx,
+		`INSERT INTO security_events (event_id, user_id, type, occurred_at, ip, user_agent)
+         VALUES ($1, $2, $3, $4, $5, $6)
+         ON CONFLICT (event_id) DO NOTHING`,
+		event.EventID,
+		event.UserID,
+		event.Type,
+		event.OccurredAt,
+		event.IP,
+		event.UserAgent,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert security event: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", dbError(err))
+	}
+	return rows > 0, nil
+}
+
+// ListSecurityEvents возвращает до limit уведомлений пользователя с id больше afterID по порядку.
+func (d DBStore) ListSecurityEvents(ctx context.Context, userID int, afterID int64, limit int) (events []models.SecurityEvent, err error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	rows, err := d.dbConn.QueryContext(ctx,
+		`SELECT id, event_id, user_id, type, occurred_at, ip, user_agent FROM security_events
+         WHERE user_id = $1 AND id > $2
+         ORDER BY id
+         LIMIT $3`,
+		userID,
+		afterID,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list security events: %w", dbError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var event models.SecurityEvent
+		err := rows.Scan(&event.ID, &event.EventID, &event.UserID, &event.Type, &event.OccurredAt, &event.IP, &event.UserAgent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan security event: %w", dbError(err))
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list security events: %w", dbError(err))
+	}
+	return events, nil
+}
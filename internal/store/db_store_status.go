@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"time"
+)
+
+// SetUserStatus переводит учётную запись в новый статус, если такой переход разрешён.
+// При удалении запоминаются время удаления и прежний статус, а все выданные токены отзываются.
+// Строка блокируется на время проверки, чтобы два параллельных перехода не проскочили мимо правил.
+func (d DBStore) SetUserStatus(ctx context.Context, userID int, status models.UserStatus, reason string) (user *models.User, err error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	if !status.Valid() {
+		return nil, fmt.Errorf("unknown user status %q: %w", status, ErrInvalidStatusTransition)
+	}
+
+	tx, err := d.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", dbError(err))
+	}
+	defer tx.Rollback()
+
+	var current models.UserStatus
+	err = tx.QueryRowContext(ctx, `SELECT status FROM users WHERE id = $1 `+d.dialect.forUpdate(false), userID).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user status: %w", dbError(err))
+	}
+	if !current.CanTransitionTo(status) {
+		return nil, fmt.Errorf("%s -> %s: %w", current, status, ErrInvalidStatusTransition)
+	}
+
+	now := time.Now()
+	user, err = scanUser(tx.QueryRowContext(ctx,
+		`UPDATE users SET status = $1, status_reason = $2, status_changed_at = $3, updated_at = $3,
+             deleted_at = CASE WHEN $1 = 'deleted' THEN $3 ELSE deleted_at END,
+             status_before_deletion = CASE WHEN $1 = 'deleted' THEN status ELSE status_before_deletion END,
+             status_reason_before_deletion = CASE WHEN $1 = 'deleted' THEN status_reason ELSE status_reason_before_deletion END,
+             tokens_valid_after = CASE WHEN $1 = 'deleted' THEN $3 ELSE tokens_valid_after END
+         WHERE id = $4
+         RETURNING `+userColumns,
+		status,
+		reason,
+		now,
+		userID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user status: %w", dbError(err))
+	}
+	eventType := models.EventUserStatusChanged
+	if status == models.UserStatusDeleted {
+		eventType = models.EventUserDeleted
+	}
+	if err := insertOutboxEvent(ctx, tx, NewDomainEvent(eventType, user)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit user status: %w", dbError(err))
+	}
+	return user, nil
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"time"
+)
+
+// UpdatePassword задаёт пользователю новый пароль и сообщает об этом событием user.password_changed.
+func (d DBStore) UpdatePassword(ctx context.Context, userID int, password string) (err error) {
+	return d.setPassword(ctx, userID, password, true)
+}
+
+// RehashPassword пересчитывает хэш того же пароля с текущими параметрами и перцем.
+// Пароль для пользователя не меняется, поэтому событие не пишется.
+func (d DBStore) RehashPassword(ctx context.Context, userID int, password string) (err error) {
+	return d.setPassword(ctx, userID, password, false)
+}
+
+// setPassword хэширует пароль и только потом отсчитывает таймаут записи: ожидание в очереди хэширования
+// не должно съедать время запроса к базе и превращать ErrHashingOverloaded в таймаут.
+func (d DBStore) setPassword(ctx context.Context, userID int, password string, changed bool) (err error) {
+	encodedHash, b64Salt, err := d.hasher.hash(ctx, password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+
+	tx, err := d.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", dbError(err))
+	}
+	defer tx.Rollback()
+
+	user, err := scanUser(tx.QueryRowContext(ctx,
+		`UPDATE users SET password_hash = $1, salt = $2, updated_at = $3 WHERE id = $4 RETURNING `+userColumns,
+		encodedHash,
+		b64Salt,
+		time.Now(),
+		userID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update password hash: %w", dbError(err))
+	}
+	if changed {
+		if err := insertOutboxEvent(ctx, tx, NewDomainEvent(models.EventUserPasswordChanged, user)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit password: %w", dbError(err))
+	}
+	return nil
+}
+
+// UpdateUserProfile обновляет переданные поля профиля и возвращает пользователя целиком.
+// Поля, равные nil, остаются как есть.
+func (d DBStore) UpdateUserProfile(ctx context.Context, userID int, patch models.UserProfilePatch) (*models.User, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	tx, err := d.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", dbError(err))
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx,
+		`UPDATE users SET
+             display_name = COALESCE($1, display_name),
+             email = COALESCE($2, email),
+             phone = COALESCE($3, phone),
+             locale = COALESCE($4, locale),
+             timezone = COALESCE($5, timezone),
+             avatar_url = COALESCE($6, avatar_url),
+             updated_at = $7
+         WHERE id = $8
+         RETURNING `+userColumns,
+		patch.DisplayName,
+		patch.Email,
+		patch.Phone,
+		patch.Locale,
+		patch.Timezone,
+		patch.AvatarURL,
+		time.Now(),
+		userID,
+	)
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user profile: %w", dbError(err))
+	}
+	if err := insertOutboxEvent(ctx, tx, NewDomainEvent(models.EventUserProfileUpdated, user)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit user profile: %w", dbError(err))
+	}
+	return user, nil
+}
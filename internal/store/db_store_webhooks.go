@@ -0,0 +1,361 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const webhookDeliveryColumns = `d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+    d.next_attempt_at, d.last_error, d.created_at, d.delivered_at`
+
+// webhookDeliveryReturning - те же колонки без псевдонима таблицы: в RETURNING SQLite его не понимает.
+var webhookDeliveryReturning = strings.ReplaceAll(webhookDeliveryColumns, "d.", "")
+
+// CreateWebhookSubscription сохраняет подписку вместе с секретом для подписи.
+func (d DBStore) CreateWebhookSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	if sub.EventTypes == nil {
+		sub.EventTypes = []models.WebhookEventType{}
+	}
+	eventTypes, err := json.Marshal(sub.EventTypes)
+	if err != nil {
+		return sub, fmt.Errorf("failed to encode event types: %w", err)
+	}
+	err = d.dbConn.QueryRowContext(ctx,
+		`INSERT INTO webhook_subscriptions (url, secret, event_types) VALUES ($1, $2, $3) RETURNING id, created_at`,
+		sub.URL,
+		sub.Secret,
+		string(eventTypes),
+	).Scan(&sub.ID, &sub.CreatedAt)
+	if err != nil {
+		return sub, fmt.Errorf("failed to create webhook subscription: %w", dbError(err))
+	}
+	return sub, nil
+}
+
+// ListWebhookSubscriptions возвращает все подписки без секретов.
+func (d DBStore) ListWebhookSubscriptions(ctx context.Context) (subs []models.WebhookSubscription, err error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	rows, err := d.dbConn.QueryContext(ctx,
+		`SELECT id, url, event_types, created_at FROM webhook_subscriptions ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", dbError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sub        models.WebhookSubscription
+			eventTypes []byte
+		)
+		if err := rows.Scan(&sub.ID, &sub.URL, &eventTypes, &sub.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", dbError(err))
+		}
+		if err := json.Unmarshal(eventTypes, &sub.EventTypes); err != nil {
+			return nil, fmt.Errorf("failed to decode event types: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", dbError(err))
+	}
+	return subs, nil
+}
+
+// DeleteWebhookSubscription удаляет подписку вместе с её доставками.
+func (d DBStore) DeleteWebhookSubscription(ctx context.Context, id int) error {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	result, err := d.dbConn.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", dbError(err))
+	}
+	if rows == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// subscribedTo - условие, что подписка получает события типа $2. В SQLite event_types хранится JSON-текстом.
+func (d DBStore) subscribedTo() string {
+	if d.dialect == dialectSQLite {
+		return `event_types = '[]' OR EXISTS (SELECT 1 FROM json_each(event_types) WHERE value = $2)`
+	}
+	return `event_types = '[]'::jsonb OR event_types @> jsonb_build_array($2::text)`
+}
+
+// EnqueueWebhookDeliveries ставит событие в очередь всем подписчикам, которые на него подписаны.
+// Повторная постановка того же события пропускается, так что relay может безопасно повторять рассылку.
+func (d DBStore) EnqueueWebhookDeliveries(ctx context.Context, event models.WebhookEvent, payload []byte) (int, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	result, err := d.dbConn.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, next_attempt_at, user_id)
+         SELECT id, $1, $2, $3, $4, $5 FROM webhook_subscriptions
+         WHERE `+d.subscribedTo()+`
+         ON CONFLICT (subscription_id, event_id) DO NOTHING`,
+		event.ID,
+		event.Type,
+		string(payload),
+		time.Now(),
+		event.Data.UserID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue webhook deliveries: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", dbError(err))
+	}
+	return int(rows), nil
+}
+
+// ClaimWebhookDeliveries забирает доставки, которым пора уходить, и откладывает их до leaseUntil.
+// Если отправитель упадёт, не записав результат, доставка сама вернётся в очередь после аренды.
+func (d DBStore) ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) (deliveries []models.WebhookDelivery, err error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	claim := `WITH claimed AS (
+             UPDATE webhook_deliveries d SET next_attempt_at = $2
+             FROM webhook_subscriptions s
+             WHERE s.id = d.subscription_id AND d.id IN (
+                 SELECT id FROM webhook_deliveries
+                 WHERE status = 'pending' AND next_attempt_at <= $1
+                 ORDER BY next_attempt_at, id
+                 LIMIT $3
+                 FOR UPDATE SKIP LOCKED
+             )
+             RETURNING ` + webhookDeliveryColumns + `, s.url, s.secret
+         )
+         SELECT * FROM claimed ORDER BY id`
+	if d.dialect == dialectSQLite {
+		// SQLite не принимает UPDATE внутри WITH, а в RETURNING не знает псевдонимов и колонок других таблиц.
+		// Адрес и секрет подписки достаются подзапросами, порядок наводится после чтения.
+		claim = `UPDATE webhook_deliveries SET next_attempt_at = $2
+         WHERE id IN (
+             SELECT id FROM webhook_deliveries
+             WHERE status = 'pending' AND next_attempt_at <= $1
+             ORDER BY next_attempt_at, id
+             LIMIT $3
+         )
+         RETURNING ` + webhookDeliveryReturning + `,
+             (SELECT s.url FROM webhook_subscriptions s WHERE s.id = webhook_deliveries.subscription_id),
+             (SELECT s.secret FROM webhook_subscriptions s WHERE s.id = webhook_deliveries.subscription_id)`
+	}
+	rows, err := d.dbConn.QueryContext(ctx,
+		claim,
+		now,
+		leaseUntil,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", dbError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", dbError(err))
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", dbError(err))
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries, nil
+}
+
+// RecordWebhookAttempt пишет попытку в журнал доставки и сохраняет новое состояние доставки.
+func (d DBStore) RecordWebhookAttempt(ctx context.Context, delivery models.WebhookDelivery, attempt models.WebhookAttempt) error {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	tx, err := d.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", dbError(err))
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO webhook_delivery_attempts (delivery_id, attempted_at, status_code, error, duration_ms)
+         VALUES ($1, $2, $3, $4, $5)`,
+		delivery.ID,
+		attempt.AttemptedAt,
+		attempt.StatusCode,
+		truncateRunes(attempt.Error, 1024),
+		attempt.DurationMS,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook attempt: %w", dbError(err))
+	}
+	result, err := tx.ExecContext(ctx,
+		`UPDATE webhook_deliveries
+         SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, delivered_at = $5
+         WHERE id = $6`,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		truncateRunes(delivery.LastError, 1024),
+		delivery.DeliveredAt,
+		delivery.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", dbError(err))
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		// Подписку удалили, пока шла отправка.
+		return ErrWebhookDeliveryNotFound
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit webhook attempt: %w", dbError(err))
+	}
+	return nil
+}
+
+// ListWebhookDeliveries возвращает страницу доставок по возрастанию id.
+func (d DBStore) ListWebhookDeliveries(ctx context.Context, query models.WebhookDeliveryQuery) (deliveries []models.WebhookDelivery, err error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	var (
+		conditions = []string{"d.id > $1"}
+		args       = []any{query.AfterID}
+	)
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if query.SubscriptionID != 0 {
+		where("d.subscription_id = ?", query.SubscriptionID)
+	}
+	if query.Status != "" {
+		where("d.status = ?", query.Status)
+	}
+	args = append(args, query.Limit)
+
+	rows, err := d.dbConn.QueryContext(ctx,
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries d
+         WHERE `+strings.Join(conditions, " AND ")+`
+         ORDER BY d.id
+         LIMIT $`+strconv.Itoa(len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", dbError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", dbError(err))
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", dbError(err))
+	}
+	return deliveries, nil
+}
+
+// GetWebhookDelivery возвращает доставку и журнал её попыток.
+func (d DBStore) GetWebhookDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, []models.WebhookAttempt, error) {
+	ctx, cancel := d.readCtx(ctx)
+	defer cancel()
+	delivery, err := scanWebhookDelivery(d.dbConn.QueryRowContext(ctx,
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries d WHERE d.id = $1`, id), false)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get webhook delivery: %w", dbError(err))
+	}
+
+	rows, err := d.dbConn.QueryContext(ctx,
+		`SELECT id, delivery_id, attempted_at, status_code, error, duration_ms
+         FROM webhook_delivery_attempts WHERE delivery_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list webhook attempts: %w", dbError(err))
+	}
+	defer rows.Close()
+	var attempts []models.WebhookAttempt
+	for rows.Next() {
+		var attempt models.WebhookAttempt
+		err := rows.Scan(&attempt.ID, &attempt.DeliveryID, &attempt.AttemptedAt, &attempt.StatusCode, &attempt.Error, &attempt.DurationMS)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan webhook attempt: %w", dbError(err))
+		}
+		attempts = append(attempts, attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to list webhook attempts: %w", dbError(err))
+	}
+	return delivery, attempts, nil
+}
+
+// ReplayWebhookDelivery возвращает доставку в очередь с чистым счётчиком попыток.
+// Журнал прежних попыток сохраняется.
+func (d DBStore) ReplayWebhookDelivery(ctx context.Context, id int64, now time.Time) (*models.WebhookDelivery, error) {
+	ctx, cancel := d.writeCtx(ctx)
+	defer cancel()
+	delivery, err := scanWebhookDelivery(d.dbConn.QueryRowContext(ctx,
+		`UPDATE webhook_deliveries
+         SET status = 'pending', attempts = 0, next_attempt_at = $1, last_error = '', delivered_at = NULL
+         WHERE id = $2
+         RETURNING `+webhookDeliveryReturning,
+		now,
+		id,
+	), false)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay webhook delivery: %w", dbError(err))
+	}
+	return delivery, nil
+}
+
+// scanWebhookDelivery читает строку с колонками webhookDeliveryColumns,
+// а с withTarget - ещё адрес и секрет подписки.
+func scanWebhookDelivery(row rowScanner, withTarget bool) (*models.WebhookDelivery, error) {
+	var (
+		delivery    models.WebhookDelivery
+		payload     string
+		deliveredAt sql.NullTime
+	)
+	dest := []any{
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&deliveredAt,
+	}
+	if withTarget {
+		dest = append(dest, &delivery.URL, &delivery.Secret)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	delivery.Payload = json.RawMessage(payload)
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return &delivery, nil
+}
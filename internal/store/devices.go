@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sync"
+	"time"
+)
+
+// DeviceRegistry - известные устройства пользователей в памяти.
+type DeviceRegistry struct {
+	mu      sync.Mutex
+	devices []models.Device
+	nextID  int64
+}
+
+func NewDeviceRegistry() *DeviceRegistry {
+	return &DeviceRegistry{}
+}
+
+// ListDevices возвращает устройства пользователя по порядку появления.
+func (r *DeviceRegistry) ListDevices(_ context.Context, userID int) ([]models.Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var devices []models.Device
+	for _, device := range r.devices {
+		if device.UserID == userID {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+// TouchDevice отмечает вход с устройства: новое добавляется, у известного обновляются адрес, клиент и время.
+func (r *DeviceRegistry) TouchDevice(_ context.Context, device models.Device) (models.Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	device = prepareDevice(device)
+	for i, stored := range r.devices {
+		if stored.UserID == device.UserID && stored.ClientFamily == device.ClientFamily && stored.Subnet == device.Subnet {
+			stored.LastIP = device.LastIP
+			stored.UserAgent = device.UserAgent
+			stored.LastSeenAt = device.LastSeenAt
+			r.devices[i] = stored
+			return stored, nil
+		}
+	}
+	r.nextID++
+	device.ID = r.nextID
+	device.FirstSeenAt = device.LastSeenAt
+	r.devices = append(r.devices, device)
+	return device, nil
+}
+
+// DeleteDevice забывает устройство пользователя, следующий вход с него снова будет с нового устройства.
+func (r *DeviceRegistry) DeleteDevice(_ context.Context, userID int, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, device := range r.devices {
+		if device.ID == id && device.UserID == userID {
+			r.devices = append(r.devices[:i], r.devices[i+1:]...)
+			return nil
+		}
+	}
+	return ErrDeviceNotFound
+}
+
+// prepareDevice приводит устройство к виду, в котором его хранит БД.
+func prepareDevice(device models.Device) models.Device {
+	device.ClientFamily = truncateRunes(device.ClientFamily, 64)
+	device.LastIP = truncateRunes(device.LastIP, 64)
+	device.UserAgent = truncateRunes(device.UserAgent, 512)
+	device.LastSeenAt = device.LastSeenAt.UTC().Truncate(time.Microsecond)
+	return device
+}
+
+// forgetUser удаляет устройства пользователя, как каскад в базе.
+func (r *DeviceRegistry) forgetUser(userID int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.devices[:0]
+	for _, device := range r.devices {
+		if device.UserID != userID {
+			kept = append(kept, device)
+		}
+	}
+	r.devices = kept
+}
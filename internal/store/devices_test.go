@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// deviceStore - общее у DeviceRegistry и DBStore.
+type deviceStore interface {
+	ListDevices(ctx context.Context, userID int) ([]models.Device, error)
+	TouchDevice(ctx context.Context, device models.Device) (models.Device, error)
+	DeleteDevice(ctx context.Context, userID int, id int64) error
+}
+
+func runDeviceSuite(t *testing.T, s deviceStore) {
+	ctx := context.Background()
+	first := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	laptop, err := s.TouchDevice(ctx, models.Device{
+		UserID: 1, ClientFamily: "Firefox/Linux", Subnet: "10.0.0.0/24", LastIP: "10.0.0.5", UserAgent: "Firefox 120", LastSeenAt: first,
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, laptop.ID)
+	assert.Equal(t, first, laptop.FirstSeenAt.UTC())
+
+	// Тот же клиент из той же подсети - то же устройство.
+	again, err := s.TouchDevice(ctx, models.Device{
+		UserID: 1, ClientFamily: "Firefox/Linux", Subnet: "10.0.0.0/24", LastIP: "10.0.0.7", UserAgent: "Firefox 121", LastSeenAt: first.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, laptop.ID, again.ID)
+	assert.Equal(t, first, again.FirstSeenAt.UTC())
+	assert.Equal(t, first.Add(time.Hour), again.LastSeenAt.UTC())
+	assert.Equal(t, "10.0.0.7", again.LastIP)
+
+	phone, err := s.TouchDevice(ctx, models.Device{UserID: 1, ClientFamily: "Safari/iOS", Subnet: "10.0.0.0/24", LastSeenAt: first})
+	require.NoError(t, err)
+	_, err = s.TouchDevice(ctx, models.Device{UserID: 2, ClientFamily: "Firefox/Linux", Subnet: "10.0.0.0/24", LastSeenAt: first})
+	require.NoError(t, err)
+
+	devices, err := s.ListDevices(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	assert.Equal(t, laptop.ID, devices[0].ID)
+	assert.Equal(t, "Firefox 121", devices[0].UserAgent)
+	assert.Equal(t, phone.ID, devices[1].ID)
+
+	assert.ErrorIs(t, s.DeleteDevice(ctx, 2, phone.ID), ErrDeviceNotFound, "devices of other users are not deleted")
+	require.NoError(t, s.DeleteDevice(ctx, 1, phone.ID))
+	assert.ErrorIs(t, s.DeleteDevice(ctx, 1, phone.ID), ErrDeviceNotFound)
+	devices, err = s.ListDevices(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, devices, 1)
+}
+
+func TestDeviceRegistry(t *testing.T) {
+	runDeviceSuite(t, NewDeviceRegistry())
+}
+
+func TestDBStore_Devices(t *testing.T) {
+	s := newTestDBStore(t)
+	seedUsers(t, s, []models.User{{ID: 1, Login: "anna"}, {ID: 2, Login: "boris"}})
+	runDeviceSuite(t, s)
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ExportJobQueue - выгрузки персональных данных в памяти, по тем же правилам, что и в базе.
+type ExportJobQueue struct {
+	mu       sync.Mutex
+	jobs     []models.ExportJob
+	archives map[string][]byte
+}
+
+func NewExportJobQueue() *ExportJobQueue {
+	return &ExportJobQueue{archives: make(map[string][]byte)}
+}
+
+func (q *ExportJobQueue) EnqueueExportJob(_ context.Context, job models.ExportJob, maxPending int) (models.ExportJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pending := 0
+	for _, stored := range q.jobs {
+		if stored.Status != models.ExportPending {
+			continue
+		}
+		if stored.UserID == job.UserID {
+			return stored, nil
+		}
+		pending++
+	}
+	if pending >= maxPending {
+		return models.ExportJob{}, ErrExportQueueFull
+	}
+	job.Status = models.ExportPending
+	job.LeaseUntil = job.CreatedAt
+	job.ExpiresAt = time.Time{}
+	q.jobs = append(q.jobs, job)
+	return job, nil
+}
+
+func (q *ExportJobQueue) GetLastExportJob(_ context.Context, userID int) (*models.ExportJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := len(q.jobs) - 1; i >= 0; i-- {
+		if q.jobs[i].UserID == userID {
+			job := q.jobs[i]
+			return &job, nil
+		}
+	}
+	return nil, ErrExportJobNotFound
+}
+
+func (q *ExportJobQueue) ClaimExportJobs(_ context.Context, now, leaseUntil time.Time, limit int) ([]models.ExportJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var due []int
+	for i, job := range q.jobs {
+		if job.Status == models.ExportPending && !job.LeaseUntil.After(now) {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(a, b int) bool { return q.jobs[due[a]].LeaseUntil.Before(q.jobs[due[b]].LeaseUntil) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	claimed := make([]models.ExportJob, 0, len(due))
+	for _, i := range due {
+		q.jobs[i].LeaseUntil = leaseUntil
+		claimed = append(claimed, q.jobs[i])
+	}
+	return claimed, nil
+}
+
+func (q *ExportJobQueue) CompleteExportJob(_ context.Context, job models.ExportJob, archive []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := range q.jobs {
+		if q.jobs[i].ID != job.ID {
+			continue
+		}
+		q.jobs[i].Status = job.Status
+		q.jobs[i].ExpiresAt = job.ExpiresAt
+		if job.Status == models.ExportReady {
+			q.archives[job.ID] = archive
+		}
+		return nil
+	}
+	return ErrExportJobNotFound
+}
+
+func (q *ExportJobQueue) GetExportArchive(_ context.Context, id string) ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	archive, ok := q.archives[id]
+	if !ok {
+		return nil, ErrExportJobNotFound
+	}
+	return archive, nil
+}
+
+func (q *ExportJobQueue) DeleteExpiredExportJobs(_ context.Context, before time.Time) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kept := q.jobs[:0]
+	deleted := 0
+	for _, job := range q.jobs {
+		if job.Status != models.ExportPending && job.ExpiresAt.Before(before) {
+			delete(q.archives, job.ID)
+			deleted++
+			continue
+		}
+		kept = append(kept, job)
+	}
+	q.jobs = kept
+	return deleted, nil
+}
+
+// forgetUser удаляет выгрузки пользователя, как каскад в базе.
+func (q *ExportJobQueue) forgetUser(userID int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kept := q.jobs[:0]
+	for _, job := range q.jobs {
+		if job.UserID == userID {
+			delete(q.archives, job.ID)
+			continue
+		}
+		kept = append(kept, job)
+	}
+	q.jobs = kept
+}
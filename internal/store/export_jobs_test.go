@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// exportJobStore - общее у ExportJobQueue и DBStore.
+type exportJobStore interface {
+	EnqueueExportJob(ctx context.Context, job models.ExportJob, maxPending int) (models.ExportJob, error)
+	GetLastExportJob(ctx context.Context, userID int) (*models.ExportJob, error)
+	ClaimExportJobs(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.ExportJob, error)
+	CompleteExportJob(ctx context.Context, job models.ExportJob, archive []byte) error
+	GetExportArchive(ctx context.Context, id string) ([]byte, error)
+	DeleteExpiredExportJobs(ctx context.Context, before time.Time) (int, error)
+}
+
+func runExportJobSuite(t *testing.T, s exportJobStore) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	_, err := s.GetLastExportJob(ctx, 1)
+	assert.ErrorIs(t, err, ErrExportJobNotFound)
+
+	anna, err := s.EnqueueExportJob(ctx, models.ExportJob{ID: "a1", UserID: 1, CreatedAt: now}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportPending, anna.Status)
+	// Пока выгрузка ждёт сборки, новая не заводится.
+	again, err := s.EnqueueExportJob(ctx, models.ExportJob{ID: "a2", UserID: 1, CreatedAt: now}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "a1", again.ID)
+	_, err = s.EnqueueExportJob(ctx, models.ExportJob{ID: "b1", UserID: 2, CreatedAt: now.Add(time.Second)}, 1)
+	assert.ErrorIs(t, err, ErrExportQueueFull)
+	_, err = s.EnqueueExportJob(ctx, models.ExportJob{ID: "b1", UserID: 2, CreatedAt: now.Add(time.Second)}, 2)
+	require.NoError(t, err)
+
+	claimed, err := s.ClaimExportJobs(ctx, now.Add(time.Minute), now.Add(time.Hour), 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "a1", claimed[0].ID)
+	// Арендованную выгрузку не берут повторно, пока аренда не кончилась.
+	claimed, err = s.ClaimExportJobs(ctx, now.Add(time.Minute), now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "b1", claimed[0].ID)
+	claimed, err = s.ClaimExportJobs(ctx, now.Add(2*time.Hour), now.Add(3*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Len(t, claimed, 2, "expired leases return to the queue")
+
+	_, err = s.GetExportArchive(ctx, "a1")
+	assert.ErrorIs(t, err, ErrExportJobNotFound, "pending exports have no archive")
+	require.NoError(t, s.CompleteExportJob(ctx, models.ExportJob{ID: "a1", Status: models.ExportReady, ExpiresAt: now.Add(24 * time.Hour)}, []byte("zip")))
+	require.NoError(t, s.CompleteExportJob(ctx, models.ExportJob{ID: "b1", Status: models.ExportFailed, ExpiresAt: now.Add(time.Hour)}, []byte("partial")))
+	assert.ErrorIs(t, s.CompleteExportJob(ctx, models.ExportJob{ID: "zz", Status: models.ExportReady}, nil), ErrExportJobNotFound)
+
+	archive, err := s.GetExportArchive(ctx, "a1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("zip"), archive)
+	_, err = s.GetExportArchive(ctx, "b1")
+	assert.ErrorIs(t, err, ErrExportJobNotFound)
+	last, err := s.GetLastExportJob(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportReady, last.Status)
+	assert.Equal(t, now.Add(24*time.Hour), last.ExpiresAt.UTC())
+
+	// Готовая выгрузка не мешает запросить новую.
+	next, err := s.EnqueueExportJob(ctx, models.ExportJob{ID: "a3", UserID: 1, CreatedAt: now.Add(time.Hour)}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "a3", next.ID)
+	last, err = s.GetLastExportJob(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "a3", last.ID)
+
+	deleted, err := s.DeleteExpiredExportJobs(ctx, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted, "only the failed export has expired")
+	deleted, err = s.DeleteExpiredExportJobs(ctx, now.Add(48*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted, "pending exports are kept")
+	_, err = s.GetExportArchive(ctx, "a1")
+	assert.ErrorIs(t, err, ErrExportJobNotFound)
+}
+
+func TestExportJobQueue(t *testing.T) {
+	runExportJobSuite(t, NewExportJobQueue())
+}
+
+func TestDBStore_ExportJobs(t *testing.T) {
+	s := newTestDBStore(t)
+	seedUsers(t, s, []models.User{{ID: 1, Login: "anna"}, {ID: 2, Login: "boris"}})
+	runExportJobSuite(t, s)
+}
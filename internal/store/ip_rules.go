@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sync"
+	"time"
+)
+
+// IPRuleSet - ограничения по адресам в памяти.
+type IPRuleSet struct {
+	mu     sync.Mutex
+	rules  []models.IPRule
+	nextID int
+}
+
+func NewIPRuleSet() *IPRuleSet {
+	return &IPRuleSet{}
+}
+
+// CreateIPRule сохраняет правило. Существование пользователя проверяет тот, кто владеет пользователями.
+func (s *IPRuleSet) CreateIPRule(_ context.Context, rule models.IPRule) (models.IPRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	rule.ID = s.nextID
+	rule.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	s.rules = append(s.rules, rule)
+	return rule, nil
+}
+
+// ListIPRules возвращает правила по порядку создания.
+func (s *IPRuleSet) ListIPRules(_ context.Context, query models.IPRuleQuery) ([]models.IPRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rules []models.IPRule
+	for _, rule := range s.rules {
+		if query.UserID != nil && (rule.UserID == nil || *rule.UserID != *query.UserID) {
+			continue
+		}
+		if query.Role != "" && rule.Role != query.Role {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// DeleteIPRule удаляет правило и возвращает его, чтобы вызывающий знал, чьи ограничения поменялись.
+func (s *IPRuleSet) DeleteIPRule(_ context.Context, id int) (*models.IPRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rule := range s.rules {
+		if rule.ID == id {
+			s.rules = append(s.rules[:i], s.rules[i+1:]...)
+			return &rule, nil
+		}
+	}
+	return nil, ErrIPRuleNotFound
+}
+
+// RulesFor возвращает правила, действующие на пользователя userID с ролью role.
+func (s *IPRuleSet) RulesFor(userID int, role models.UserRole) []models.IPRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rules []models.IPRule
+	for _, rule := range s.rules {
+		if (rule.UserID != nil && *rule.UserID == userID) || (rule.Role != "" && rule.Role == role) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// forgetUser удаляет правила пользователя, как каскад в базе.
+func (s *IPRuleSet) forgetUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.rules[:0]
+	for _, rule := range s.rules {
+		if rule.UserID == nil || *rule.UserID != userID {
+			kept = append(kept, rule)
+		}
+	}
+	s.rules = kept
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// ipRuleStore - общее у IPRuleSet и DBStore.
+type ipRuleStore interface {
+	CreateIPRule(ctx context.Context, rule models.IPRule) (models.IPRule, error)
+	ListIPRules(ctx context.Context, query models.IPRuleQuery) ([]models.IPRule, error)
+	DeleteIPRule(ctx context.Context, id int) (*models.IPRule, error)
+}
+
+func runIPRuleSuite(t *testing.T, s ipRuleStore) {
+	ctx := context.Background()
+	anna := 1
+	office, err := s.CreateIPRule(ctx, models.IPRule{UserID: &anna, CIDR: "198.51.100.0/24", Action: models.IPRuleAllow, Comment: "office"})
+	require.NoError(t, err)
+	assert.NotZero(t, office.ID)
+	assert.False(t, office.CreatedAt.IsZero())
+	admins, err := s.CreateIPRule(ctx, models.IPRule{Role: models.UserRoleAdmin, CIDR: "10.0.0.0/8", Action: models.IPRuleAllow})
+	require.NoError(t, err)
+	_, err = s.CreateIPRule(ctx, models.IPRule{Role: models.UserRoleUser, CIDR: "192.0.2.0/24", Action: models.IPRuleDeny})
+	require.NoError(t, err)
+
+	all, err := s.ListIPRules(ctx, models.IPRuleQuery{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, office.ID, all[0].ID)
+	assert.Equal(t, "office", all[0].Comment)
+	require.NotNil(t, all[0].UserID)
+	assert.Equal(t, anna, *all[0].UserID)
+	assert.Empty(t, all[0].Role)
+
+	byUser, err := s.ListIPRules(ctx, models.IPRuleQuery{UserID: &anna})
+	require.NoError(t, err)
+	require.Len(t, byUser, 1)
+	assert.Equal(t, office.ID, byUser[0].ID)
+	byRole, err := s.ListIPRules(ctx, models.IPRuleQuery{Role: models.UserRoleAdmin})
+	require.NoError(t, err)
+	require.Len(t, byRole, 1)
+	assert.Nil(t, byRole[0].UserID)
+	assert.Equal(t, models.IPRuleAllow, byRole[0].Action)
+
+	deleted, err := s.DeleteIPRule(ctx, admins.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserRoleAdmin, deleted.Role)
+	_, err = s.DeleteIPRule(ctx, admins.ID)
+	assert.ErrorIs(t, err, ErrIPRuleNotFound)
+}
+
+func TestIPRuleSet(t *testing.T) {
+	s := NewIPRuleSet()
+	runIPRuleSuite(t, s)
+	rules := s.RulesFor(1, models.UserRoleUser)
+	require.Len(t, rules, 2, "own rule and the rule of the role")
+	assert.Empty(t, s.RulesFor(2, models.UserRoleAdmin))
+}
+
+func TestDBStore_IPRules(t *testing.T) {
+	s := newTestDBStore(t)
+	seedUsers(t, s, []models.User{{ID: 1, Login: "anna"}})
+	runIPRuleSuite(t, s)
+
+	state, err := s.GetUserAuthState(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Len(t, state.IPRules, 2, "own rule and the rule of the role")
+
+	missing := 42
+	_, err = s.CreateIPRule(context.Background(), models.IPRule{UserID: &missing, CIDR: "10.0.0.0/8", Action: models.IPRuleDeny})
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
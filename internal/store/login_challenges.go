@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sync"
+	"time"
+)
+
+// MaxLoginChallengeAttempts - сколько раз можно ввести неверный код, прежде чем он перестанет подходить.
+// Новый код приходит с новым письмом, так что подбирать его приходится под присмотром владельца почты.
+const MaxLoginChallengeAttempts = 5
+
+// loginChallengeKey - устройство, которому выдан код.
+type loginChallengeKey struct {
+	userID       int
+	clientFamily string
+	subnet       string
+}
+
+// LoginChallengeSet - коды подтверждения входа в памяти.
+type LoginChallengeSet struct {
+	mu         sync.Mutex
+	challenges map[loginChallengeKey]models.LoginChallenge
+}
+
+func NewLoginChallengeSet() *LoginChallengeSet {
+	return &LoginChallengeSet{challenges: make(map[loginChallengeKey]models.LoginChallenge)}
+}
+
+// PutLoginChallenge выдаёт устройству код, заменяя прежний, и заодно забывает истёкшие коды.
+func (s *LoginChallengeSet) PutLoginChallenge(_ context.Context, challenge models.LoginChallenge, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, stored := range s.challenges {
+		if stored.ExpiresAt.Before(now) {
+			delete(s.challenges, key)
+		}
+	}
+	challenge.Attempts = 0
+	s.challenges[loginChallengeKey{challenge.UserID, challenge.ClientFamily, challenge.Subnet}] = challenge
+	return nil
+}
+
+// CheckLoginChallenge сверяет код устройства. Подошедший код сразу гасится, неверный считается попыткой.
+func (s *LoginChallengeSet) CheckLoginChallenge(_ context.Context, challenge models.LoginChallenge, now time.Time) (passed bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := loginChallengeKey{challenge.UserID, challenge.ClientFamily, challenge.Subnet}
+	stored, ok := s.challenges[key]
+	if !ok || !stored.ExpiresAt.After(now) {
+		return false, nil
+	}
+	if stored.Attempts < MaxLoginChallengeAttempts && stored.CodeHash == challenge.CodeHash {
+		delete(s.challenges, key)
+		return true, nil
+	}
+	stored.Attempts++
+	s.challenges[key] = stored
+	return false, nil
+}
+
+// forgetUser удаляет коды пользователя, как каскад в базе.
+func (s *LoginChallengeSet) forgetUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.challenges {
+		if key.userID == userID {
+			delete(s.challenges, key)
+		}
+	}
+}
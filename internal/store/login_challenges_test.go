@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// loginChallengeStore - общее у LoginChallengeSet и DBStore.
+type loginChallengeStore interface {
+	PutLoginChallenge(ctx context.Context, challenge models.LoginChallenge, now time.Time) error
+	CheckLoginChallenge(ctx context.Context, challenge models.LoginChallenge, now time.Time) (bool, error)
+}
+
+func runLoginChallengeSuite(t *testing.T, s loginChallengeStore) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	laptop := models.LoginChallenge{UserID: 1, ClientFamily: "Firefox/Linux", Subnet: "10.0.0.0/24", ExpiresAt: now.Add(10 * time.Minute)}
+	withCode := func(challenge models.LoginChallenge, codeHash string) models.LoginChallenge {
+		challenge.CodeHash = codeHash
+		return challenge
+	}
+
+	require.NoError(t, s.PutLoginChallenge(ctx, withCode(laptop, "old"), now))
+	require.NoError(t, s.PutLoginChallenge(ctx, withCode(laptop, "right"), now))
+	passed, err := s.CheckLoginChallenge(ctx, withCode(laptop, "old"), now)
+	require.NoError(t, err)
+	assert.False(t, passed, "a new code replaces the previous one")
+	other := laptop
+	other.UserID = 2
+	passed, err = s.CheckLoginChallenge(ctx, withCode(other, "right"), now)
+	require.NoError(t, err)
+	assert.False(t, passed, "codes of other users do not match")
+
+	passed, err = s.CheckLoginChallenge(ctx, withCode(laptop, "right"), now)
+	require.NoError(t, err)
+	assert.True(t, passed)
+	passed, err = s.CheckLoginChallenge(ctx, withCode(laptop, "right"), now)
+	require.NoError(t, err)
+	assert.False(t, passed, "a code works once")
+
+	t.Run("expired", func(t *testing.T) {
+		require.NoError(t, s.PutLoginChallenge(ctx, withCode(laptop, "right"), now))
+		passed, err := s.CheckLoginChallenge(ctx, withCode(laptop, "right"), laptop.ExpiresAt)
+		require.NoError(t, err)
+		assert.False(t, passed)
+	})
+
+	t.Run("attempts", func(t *testing.T) {
+		require.NoError(t, s.PutLoginChallenge(ctx, withCode(laptop, "right"), now))
+		for i := 0; i < MaxLoginChallengeAttempts; i++ {
+			passed, err := s.CheckLoginChallenge(ctx, withCode(laptop, "wrong"), now)
+			require.NoError(t, err)
+			assert.False(t, passed)
+		}
+		passed, err := s.CheckLoginChallenge(ctx, withCode(laptop, "right"), now)
+		require.NoError(t, err)
+		assert.False(t, passed, "the code stops working after too many wrong attempts")
+
+		// Новое письмо - новый код и новые попытки.
+		require.NoError(t, s.PutLoginChallenge(ctx, withCode(laptop, "next"), now))
+		passed, err = s.CheckLoginChallenge(ctx, withCode(laptop, "next"), now)
+		require.NoError(t, err)
+		assert.True(t, passed)
+	})
+}
+
+func TestLoginChallengeSet(t *testing.T) {
+	runLoginChallengeSuite(t, NewLoginChallengeSet())
+}
+
+func TestDBStore_LoginChallenges(t *testing.T) {
+	s := newTestDBStore(t)
+	seedUsers(t, s, []models.User{{ID: 1, Login: "anna"}, {ID: 2, Login: "boris"}})
+	runLoginChallengeSuite(t, s)
+}
@@ -0,0 +1,512 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/login_policy"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"go.uber.org/zap"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemStore - хранилище целиком в памяти, чтобы запускать сервер без Postgres.
+// Пользователи хранятся здесь и подчиняются тем же правилам, что и в базе: уникальный логин
+// в канонической форме, запрет похожих логинов, id по порядку, события в outbox вместе с изменением.
+// Остальное лежит в тех же реализациях в памяти, что используются в тестах.
+// С заданным StorageSnapshot данные загружаются из JSON-файла при запуске и сохраняются в него раз
+// в StorageSnapshotInterval и при закрытии.
+type MemStore struct {
+	*AuditLog
+	*WebhookQueue
+	*Outbox
+	*SecurityEventLog
+	*NotificationQueue
+	*DeviceRegistry
+	*LoginChallengeSet
+	*ExportJobQueue
+	*IPRuleSet
+	*RateLimitCounters
+	*AuthStateVersion
+
+	l            *logger.ZapLog
+	hasher       *passwordHasher
+	snapshotPath string
+	// saveMu не даёт периодическому сохранению и сохранению при закрытии писать снимок одновременно.
+	saveMu        sync.Mutex
+	stopSnapshots chan struct{}
+	snapshotsDone chan struct{}
+	stopOnce      sync.Once
+
+	mu         sync.RWMutex
+	users      map[int]*memUser
+	lastUserID int
+}
+
+// memUser - пользователь вместе с колонками, которых нет в models.User.
+type memUser struct {
+	user             models.User
+	loginNormalized  string
+	loginSkeleton    string
+	tokensValidAfter time.Time
+	purgedAt         *time.Time
+	// Статус и причина до удаления, в них учётная запись возвращается при восстановлении.
+	statusBeforeDeletion       models.UserStatus
+	statusReasonBeforeDeletion string
+}
+
+func NewMemStore(c *server_config.ServerConfig, l *logger.ZapLog) (*MemStore, error) {
+	hasher, err := newPasswordHasher(c)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password hashing parameters: %w", err)
+	}
+	m := &MemStore{
+		AuditLog:          NewAuditLog(),
+		WebhookQueue:      NewWebhookQueue(),
+		Outbox:            NewOutbox(),
+		SecurityEventLog:  NewSecurityEventLog(),
+		NotificationQueue: NewNotificationQueue(),
+		DeviceRegistry:    NewDeviceRegistry(),
+		LoginChallengeSet: NewLoginChallengeSet(),
+		ExportJobQueue:    NewExportJobQueue(),
+		IPRuleSet:         NewIPRuleSet(),
+		RateLimitCounters: NewRateLimitCounters(),
+		AuthStateVersion:  NewAuthStateVersion(),
+
+		l:            l,
+		hasher:       hasher,
+		snapshotPath: c.StorageSnapshot,
+		users:        make(map[int]*memUser),
+	}
+	if m.snapshotPath != "" {
+		if err := m.loadSnapshot(); err != nil {
+			return nil, err
+		}
+		if c.StorageSnapshotInterval > 0 {
+			m.stopSnapshots = make(chan struct{})
+			m.snapshotsDone = make(chan struct{})
+			go m.saveSnapshots(c.StorageSnapshotInterval)
+		}
+	}
+	return m, nil
+}
+
+// DBConnClose останавливает периодическое сохранение и сохраняет снимок в последний раз, если он включён.
+func (m *MemStore) DBConnClose() error {
+	if m.snapshotPath == "" {
+		return nil
+	}
+	if m.stopSnapshots != nil {
+		m.stopOnce.Do(func() { close(m.stopSnapshots) })
+		<-m.snapshotsDone
+	}
+	return m.saveSnapshot()
+}
+
+// saveSnapshots сохраняет снимок раз в interval, чтобы падение процесса теряло изменения только за interval,
+// а не за всё время работы. Ошибка записи не останавливает сохранение: место на диске может освободиться.
+func (m *MemStore) saveSnapshots(interval time.Duration) {
+	defer close(m.snapshotsDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stopSnapshots:
+			return
+		case <-ticker.C:
+		}
+		if err := m.saveSnapshot(); err != nil {
+			m.l.ZL.Error("failed to save storage snapshot", zap.Error(err))
+		}
+	}
+}
+
+// emit пишет событие об изменении пользователя. Вызывается под m.mu, как в базе - в той же транзакции.
+func (m *MemStore) emit(ctx context.Context, eventType models.DomainEventType, user *models.User) {
+	m.AppendOutboxEvent(ctx, NewDomainEvent(eventType, user))
+}
+
+// findByLogin ищет пользователя по логину в канонической форме. Вызывается под m.mu.
+func (m *MemStore) findByLogin(normalized string) *memUser {
+	for _, stored := range m.users {
+		if stored.loginNormalized == normalized {
+			return stored
+		}
+	}
+	return nil
+}
+
+func (m *MemStore) CreateUser(ctx context.Context, req models.UserRegReq) (*models.User, error) {
+	role := req.Role
+	if role == "" {
+		role = models.UserRoleUser
+	}
+	if !role.Valid() {
+		return nil, fmt.Errorf("unknown user role %q", role)
+	}
+	encodedHash, b64Salt, err := m.hasher.hash(ctx, req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	login := strings.TrimSpace(req.Login)
+	normalized := login_policy.Normalize(login)
+	skeleton := login_policy.Skeleton(normalized)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.findByLogin(normalized) != nil {
+		return nil, ErrLoginTaken
+	}
+	for _, stored := range m.users {
+		if stored.loginSkeleton == skeleton {
+			return nil, ErrLoginConfusable
+		}
+	}
+	now := time.Now()
+	m.lastUserID++
+	stored := &memUser{
+		user: models.User{
+			ID:              m.lastUserID,
+			Login:           login,
+			PasswordHash:    encodedHash,
+			Salt:            b64Salt,
+			Role:            role,
+			Status:          models.UserStatusActive,
+			StatusChangedAt: now,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		},
+		loginNormalized: normalized,
+		loginSkeleton:   skeleton,
+	}
+	m.users[stored.user.ID] = stored
+	user := stored.user
+	m.emit(ctx, models.EventUserRegistered, &user)
+	return &user, nil
+}
+
+// ImportUser переносит пользователя из прежней системы вместе с его хэшем пароля.
+// Если пользователь с таким логином уже есть, он не перезаписывается и imported будет false.
+func (m *MemStore) ImportUser(_ context.Context, user models.User) (bool, error) {
+	if err := ValidateHash(user.PasswordHash); err != nil {
+		return false, fmt.Errorf("invalid password hash for %q: %w", user.Login, err)
+	}
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	login := strings.TrimSpace(user.Login)
+	normalized := login_policy.Normalize(login)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.findByLogin(normalized) != nil {
+		return false, nil
+	}
+	m.lastUserID++
+	m.users[m.lastUserID] = &memUser{
+		user: models.User{
+			ID:              m.lastUserID,
+			Login:           login,
+			PasswordHash:    user.PasswordHash,
+			Role:            models.UserRoleUser,
+			Status:          models.UserStatusActive,
+			StatusChangedAt: now,
+			CreatedAt:       user.CreatedAt,
+			UpdatedAt:       now,
+		},
+		loginNormalized: normalized,
+		loginSkeleton:   login_policy.Skeleton(normalized),
+	}
+	return true, nil
+}
+
+func (m *MemStore) GetUserByLogin(_ context.Context, req models.UserLoginReq) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stored := m.findByLogin(login_policy.Normalize(req.Login))
+	if stored == nil {
+		return nil, ErrUserNotFound
+	}
+	user := stored.user
+	return &user, nil
+}
+
+// GetUserByID возвращает пользователя по идентификатору или ErrUserNotFound.
+func (m *MemStore) GetUserByID(_ context.Context, userID int) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stored, ok := m.users[userID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	user := stored.user
+	return &user, nil
+}
+
+// GetUserAuthState возвращает статус учётной записи и ограничения по адресам для проверки токена.
+func (m *MemStore) GetUserAuthState(_ context.Context, userID int) (models.UserAuthState, error) {
+	m.mu.RLock()
+	stored, ok := m.users[userID]
+	if !ok {
+		m.mu.RUnlock()
+		return models.UserAuthState{}, ErrUserNotFound
+	}
+	state := models.UserAuthState{
+		Role:             stored.user.Role,
+		Status:           stored.user.Status,
+		StatusChangedAt:  stored.user.StatusChangedAt,
+		TokensValidAfter: stored.tokensValidAfter,
+	}
+	m.mu.RUnlock()
+	state.IPRules = m.RulesFor(userID, state.Role)
+	return state, nil
+}
+
+// update меняет пользователя под блокировкой и пишет событие eventType, если оно задано.
+func (m *MemStore) update(ctx context.Context, userID int, eventType models.DomainEventType, change func(stored *memUser) error) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.users[userID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	// Изменение применяется к копии, чтобы отказ не оставил пользователя наполовину изменённым.
+	changed := *stored
+	if err := change(&changed); err != nil {
+		return nil, err
+	}
+	*stored = changed
+	user := stored.user
+	if eventType != "" {
+		m.emit(ctx, eventType, &user)
+	}
+	return &user, nil
+}
+
+// UpdatePassword задаёт пользователю новый пароль и сообщает об этом событием user.password_changed.
+func (m *MemStore) UpdatePassword(ctx context.Context, userID int, password string) error {
+	return m.setPassword(ctx, userID, password, models.EventUserPasswordChanged)
+}
+
+// RehashPassword пересчитывает хэш того же пароля с текущими параметрами и перцем.
+func (m *MemStore) RehashPassword(ctx context.Context, userID int, password string) error {
+	return m.setPassword(ctx, userID, password, "")
+}
+
+func (m *MemStore) setPassword(ctx context.Context, userID int, password string, eventType models.DomainEventType) error {
+	encodedHash, b64Salt, err := m.hasher.hash(ctx, password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	_, err = m.update(ctx, userID, eventType, func(stored *memUser) error {
+		stored.user.PasswordHash = encodedHash
+		stored.user.Salt = b64Salt
+		stored.user.UpdatedAt = time.Now()
+		return nil
+	})
+	return err
+}
+
+// UpdateUserProfile обновляет переданные поля профиля, поля, равные nil, остаются как есть.
+func (m *MemStore) UpdateUserProfile(ctx context.Context, userID int, patch models.UserProfilePatch) (*models.User, error) {
+	return m.update(ctx, userID, models.EventUserProfileUpdated, func(stored *memUser) error {
+		set := func(dst *string, src *string) {
+			if src != nil {
+				*dst = *src
+			}
+		}
+		set(&stored.user.DisplayName, patch.DisplayName)
+		set(&stored.user.Email, patch.Email)
+		set(&stored.user.Phone, patch.Phone)
+		set(&stored.user.Locale, patch.Locale)
+		set(&stored.user.Timezone, patch.Timezone)
+		set(&stored.user.AvatarURL, patch.AvatarURL)
+		stored.user.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+// SetUserStatus переводит учётную запись в новый статус, если такой переход разрешён.
+// При удалении запоминается время удаления и отзываются все выданные токены.
+func (m *MemStore) SetUserStatus(ctx context.Context, userID int, status models.UserStatus, reason string) (*models.User, error) {
+	if !status.Valid() {
+		return nil, fmt.Errorf("unknown user status %q: %w", status, ErrInvalidStatusTransition)
+	}
+	eventType := models.EventUserStatusChanged
+	if status == models.UserStatusDeleted {
+		eventType = models.EventUserDeleted
+	}
+	return m.update(ctx, userID, eventType, func(stored *memUser) error {
+		current := stored.user.Status
+		if !current.CanTransitionTo(status) {
+			return fmt.Errorf("%s -> %s: %w", current, status, ErrInvalidStatusTransition)
+		}
+		now := time.Now()
+		if status == models.UserStatusDeleted {
+			stored.statusBeforeDeletion = stored.user.Status
+			stored.statusReasonBeforeDeletion = stored.user.StatusReason
+		}
+		stored.user.Status = status
+		stored.user.StatusReason = reason
+		stored.user.StatusChangedAt = now
+		stored.user.UpdatedAt = now
+		if status == models.UserStatusDeleted {
+			stored.user.DeletedAt = &now
+			stored.tokensValidAfter = now
+		}
+		return nil
+	})
+}
+
+// DeleteUser мягко удаляет учётную запись, до окончательной очистки её можно восстановить.
+func (m *MemStore) DeleteUser(ctx context.Context, userID int, reason string) (*models.User, error) {
+	return m.SetUserStatus(ctx, userID, models.UserStatusDeleted, reason)
+}
+
+// RestoreUser возвращает удалённую учётную запись в прежний статус, если её удалили не раньше deletedAfter.
+func (m *MemStore) RestoreUser(ctx context.Context, userID int, deletedAfter time.Time) (*models.User, error) {
+	return m.update(ctx, userID, models.EventUserRestored, func(stored *memUser) error {
+		if stored.user.Status != models.UserStatusDeleted {
+			return fmt.Errorf("%s -> %s: %w", stored.user.Status, models.UserStatusActive, ErrInvalidStatusTransition)
+		}
+		if stored.purgedAt != nil || stored.user.DeletedAt == nil || stored.user.DeletedAt.Before(deletedAfter) {
+			return ErrRestoreExpired
+		}
+		now := time.Now()
+		stored.user.Status = models.UserStatusActive
+		if stored.statusBeforeDeletion != "" {
+			stored.user.Status = stored.statusBeforeDeletion
+		}
+		stored.user.StatusReason = stored.statusReasonBeforeDeletion
+		stored.user.StatusChangedAt = now
+		stored.user.UpdatedAt = now
+		stored.user.DeletedAt = nil
+		stored.statusBeforeDeletion = ""
+		stored.statusReasonBeforeDeletion = ""
+		return nil
+	})
+}
+
+// PurgeDeletedUsers окончательно удаляет до limit учётных записей, удалённых раньше deletedBefore,
+// вместе с зависимыми данными. С anonymize запись остаётся под логином deleted#<id> без персональных данных.
+func (m *MemStore) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time, limit int, anonymize bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []*memUser
+	for _, stored := range m.users {
+		if stored.user.Status == models.UserStatusDeleted && stored.purgedAt == nil &&
+			stored.user.DeletedAt != nil && stored.user.DeletedAt.Before(deletedBefore) {
+			due = append(due, stored)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].user.DeletedAt.Before(*due[j].user.DeletedAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	now := time.Now()
+	for _, stored := range due {
+		id := stored.user.ID
+		m.SecurityEventLog.forgetUser(id)
+		m.NotificationQueue.forgetUser(id)
+		m.DeviceRegistry.forgetUser(id)
+		m.LoginChallengeSet.forgetUser(id)
+		m.ExportJobQueue.forgetUser(id)
+		m.Outbox.forgetUser(id)
+		m.WebhookQueue.forgetUser(id)
+		if anonymize {
+			placeholder := "deleted#" + strconv.Itoa(id)
+			stored.user = models.User{
+				ID:              id,
+				Login:           placeholder,
+				Role:            stored.user.Role,
+				Status:          stored.user.Status,
+				StatusChangedAt: stored.user.StatusChangedAt,
+				DeletedAt:       stored.user.DeletedAt,
+				CreatedAt:       stored.user.CreatedAt,
+				UpdatedAt:       now,
+			}
+			stored.loginNormalized = placeholder
+			stored.loginSkeleton = placeholder
+			stored.purgedAt = &now
+		} else {
+			m.IPRuleSet.forgetUser(id)
+			delete(m.users, id)
+		}
+		m.emit(ctx, models.EventUserPurged, &models.User{ID: id, Status: models.UserStatusDeleted})
+	}
+	return len(due), nil
+}
+
+// ListUsers возвращает страницу пользователей по возрастанию id.
+func (m *MemStore) ListUsers(_ context.Context, query models.UserListQuery) ([]models.User, error) {
+	prefix := login_policy.Normalize(query.LoginPrefix)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var users []models.User
+	for _, stored := range m.users {
+		user := stored.user
+		if user.ID <= query.AfterID ||
+			(query.Status != "" && user.Status != query.Status) ||
+			(!query.CreatedFrom.IsZero() && user.CreatedAt.Before(query.CreatedFrom)) ||
+			(!query.CreatedTo.IsZero() && !user.CreatedAt.Before(query.CreatedTo)) ||
+			!strings.HasPrefix(stored.loginNormalized, prefix) {
+			continue
+		}
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	if len(users) > query.Limit {
+		users = users[:query.Limit]
+	}
+	return users, nil
+}
+
+// SetUserRole меняет роль пользователя.
+func (m *MemStore) SetUserRole(ctx context.Context, userID int, role models.UserRole) (*models.User, error) {
+	if !role.Valid() {
+		return nil, fmt.Errorf("unknown user role %q", role)
+	}
+	return m.update(ctx, userID, models.EventUserRoleChanged, func(stored *memUser) error {
+		stored.user.Role = role
+		stored.user.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+// RevokeSessions делает недействительными все токены, выданные пользователю до этого момента.
+func (m *MemStore) RevokeSessions(ctx context.Context, userID int) error {
+	_, err := m.update(ctx, userID, models.EventUserSessionsRevoked, func(stored *memUser) error {
+		stored.tokensValidAfter = time.Now()
+		return nil
+	})
+	return err
+}
+
+// SearchUsers ищет пользователей по тем же правилам релевантности, что и база.
+func (m *MemStore) SearchUsers(ctx context.Context, query models.UserSearchQuery) ([]models.UserSearchResult, error) {
+	idx := NewUserIndex()
+	m.mu.RLock()
+	for _, stored := range m.users {
+		idx.Put(stored.user)
+	}
+	m.mu.RUnlock()
+	return idx.SearchUsers(ctx, query)
+}
+
+// CreateIPRule сохраняет правило; правило для несуществующего пользователя не создаётся, как и в базе.
+func (m *MemStore) CreateIPRule(ctx context.Context, rule models.IPRule) (models.IPRule, error) {
+	if rule.UserID != nil {
+		if _, err := m.GetUserByID(ctx, *rule.UserID); err != nil {
+			return models.IPRule{}, err
+		}
+	}
+	return m.IPRuleSet.CreateIPRule(ctx, rule)
+}
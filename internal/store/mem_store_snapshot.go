@@ -0,0 +1,208 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// memSnapshot - то, что хранилище в памяти переживает между запусками. Очереди (outbox, доставки вебхуков,
+// письма) и счётчики лимитов в снимок не попадают: после перезапуска они начинаются заново.
+type memSnapshot struct {
+	SavedAt              time.Time                         `json:"saved_at"`
+	LastUserID           int                               `json:"last_user_id"`
+	Users                []snapshotUser                    `json:"users"`
+	AuditEvents          []models.AuditEvent               `json:"audit_events"`
+	LastWebhookID        int                               `json:"last_webhook_id"`
+	WebhookSubscriptions []models.WebhookSubscription      `json:"webhook_subscriptions"`
+	LastDeviceID         int64                             `json:"last_device_id"`
+	Devices              []snapshotDevice                  `json:"devices"`
+	LastIPRuleID         int                               `json:"last_ip_rule_id"`
+	IPRules              []models.IPRule                   `json:"ip_rules"`
+	NotificationOptOuts  map[int][]models.NotificationKind `json:"notification_opt_outs"`
+	LastSecurityEventID  int64                             `json:"last_security_event_id"`
+	SecurityEvents       []snapshotSecurityEvent           `json:"security_events"`
+}
+
+// Поля, которые модели не отдают в JSON, в снимке сохраняются явно.
+
+type snapshotUser struct {
+	models.User
+	PasswordHash     string     `json:"password_hash"`
+	Salt             string     `json:"salt"`
+	LoginNormalized  string     `json:"login_normalized"`
+	LoginSkeleton    string     `json:"login_skeleton"`
+	TokensValidAfter time.Time  `json:"tokens_valid_after"`
+	PurgedAt         *time.Time `json:"purged_at,omitempty"`
+
+	StatusBeforeDeletion       models.UserStatus `json:"status_before_deletion,omitempty"`
+	StatusReasonBeforeDeletion string            `json:"status_reason_before_deletion,omitempty"`
+}
+
+type snapshotDevice struct {
+	models.Device
+	UserID int `json:"user_id"`
+}
+
+type snapshotSecurityEvent struct {
+	models.SecurityEvent
+	EventID string `json:"event_id"`
+	UserID  int    `json:"user_id"`
+}
+
+// saveSnapshot записывает снимок во временный файл и подменяет им прежний, чтобы сбой посреди записи его не испортил.
+func (m *MemStore) saveSnapshot() error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	data, err := json.MarshalIndent(m.snapshot(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode storage snapshot: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.snapshotPath), filepath.Base(m.snapshotPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create storage snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write storage snapshot: %w", err)
+	}
+	// Без Sync после падения системы переименованный файл мог бы оказаться пустым.
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write storage snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write storage snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.snapshotPath); err != nil {
+		return fmt.Errorf("failed to replace storage snapshot: %w", err)
+	}
+	return nil
+}
+
+// snapshot собирает снимок. Блокировки берутся в том же порядке, что и при изменениях: сначала пользователи.
+func (m *MemStore) snapshot() memSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snap := memSnapshot{SavedAt: time.Now(), LastUserID: m.lastUserID}
+	for id := 1; id <= m.lastUserID; id++ {
+		stored, ok := m.users[id]
+		if !ok {
+			continue
+		}
+		snap.Users = append(snap.Users, snapshotUser{
+			User:             stored.user,
+			PasswordHash:     stored.user.PasswordHash,
+			Salt:             stored.user.Salt,
+			LoginNormalized:  stored.loginNormalized,
+			LoginSkeleton:    stored.loginSkeleton,
+			TokensValidAfter: stored.tokensValidAfter,
+			PurgedAt:         stored.purgedAt,
+
+			StatusBeforeDeletion:       stored.statusBeforeDeletion,
+			StatusReasonBeforeDeletion: stored.statusReasonBeforeDeletion,
+		})
+	}
+
+	m.AuditLog.mu.RLock()
+	snap.AuditEvents = append(snap.AuditEvents, m.AuditLog.events...)
+	m.AuditLog.mu.RUnlock()
+
+	m.WebhookQueue.mu.Lock()
+	snap.LastWebhookID = m.WebhookQueue.lastSubID
+	snap.WebhookSubscriptions = append(snap.WebhookSubscriptions, m.WebhookQueue.subscriptions...)
+	m.WebhookQueue.mu.Unlock()
+
+	m.DeviceRegistry.mu.Lock()
+	snap.LastDeviceID = m.DeviceRegistry.nextID
+	for _, device := range m.DeviceRegistry.devices {
+		snap.Devices = append(snap.Devices, snapshotDevice{Device: device, UserID: device.UserID})
+	}
+	m.DeviceRegistry.mu.Unlock()
+
+	m.IPRuleSet.mu.Lock()
+	snap.LastIPRuleID = m.IPRuleSet.nextID
+	snap.IPRules = append(snap.IPRules, m.IPRuleSet.rules...)
+	m.IPRuleSet.mu.Unlock()
+
+	m.NotificationQueue.mu.Lock()
+	snap.NotificationOptOuts = make(map[int][]models.NotificationKind)
+	for userID, kinds := range m.NotificationQueue.optOuts {
+		for kind := range kinds {
+			snap.NotificationOptOuts[userID] = append(snap.NotificationOptOuts[userID], kind)
+		}
+	}
+	m.NotificationQueue.mu.Unlock()
+
+	m.SecurityEventLog.mu.Lock()
+	snap.LastSecurityEventID = m.SecurityEventLog.lastID
+	for _, event := range m.SecurityEventLog.events {
+		snap.SecurityEvents = append(snap.SecurityEvents,
+			snapshotSecurityEvent{SecurityEvent: event, EventID: event.EventID, UserID: event.UserID})
+	}
+	m.SecurityEventLog.mu.Unlock()
+	return snap
+}
+
+// loadSnapshot читает снимок, если он есть. Отсутствие файла - это первый запуск, а не ошибка.
+func (m *MemStore) loadSnapshot() error {
+	data, err := os.ReadFile(m.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read storage snapshot: %w", err)
+	}
+	var snap memSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to decode storage snapshot %s: %w", m.snapshotPath, err)
+	}
+
+	m.lastUserID = snap.LastUserID
+	for _, record := range snap.Users {
+		user := record.User
+		user.PasswordHash = record.PasswordHash
+		user.Salt = record.Salt
+		m.users[user.ID] = &memUser{
+			user:             user,
+			loginNormalized:  record.LoginNormalized,
+			loginSkeleton:    record.LoginSkeleton,
+			tokensValidAfter: record.TokensValidAfter,
+			purgedAt:         record.PurgedAt,
+
+			statusBeforeDeletion:       record.StatusBeforeDeletion,
+			statusReasonBeforeDeletion: record.StatusReasonBeforeDeletion,
+		}
+	}
+	m.AuditLog.events = snap.AuditEvents
+	m.WebhookQueue.lastSubID = snap.LastWebhookID
+	m.WebhookQueue.subscriptions = snap.WebhookSubscriptions
+	m.DeviceRegistry.nextID = snap.LastDeviceID
+	for _, record := range snap.Devices {
+		device := record.Device
+		device.UserID = record.UserID
+		m.DeviceRegistry.devices = append(m.DeviceRegistry.devices, device)
+	}
+	m.IPRuleSet.nextID = snap.LastIPRuleID
+	m.IPRuleSet.rules = snap.IPRules
+	for userID, kinds := range snap.NotificationOptOuts {
+		m.NotificationQueue.optOuts[userID] = make(map[models.NotificationKind]bool)
+		for _, kind := range kinds {
+			m.NotificationQueue.optOuts[userID][kind] = true
+		}
+	}
+	m.SecurityEventLog.lastID = snap.LastSecurityEventID
+	for _, record := range snap.SecurityEvents {
+		event := record.SecurityEvent
+		event.EventID = record.EventID
+		event.UserID = record.UserID
+		m.SecurityEventLog.events = append(m.SecurityEventLog.events, event)
+	}
+	m.l.ZL.Info("memory storage loaded from snapshot")
+	return nil
+}
@@ -0,0 +1,314 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestMemStore(t *testing.T, snapshotPath string) *MemStore {
+	t.Helper()
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	c := &server_config.ServerConfig{StorageSnapshot: snapshotPath}
+	newTestHasher(t, c)
+	s, err := NewMemStore(c, l)
+	require.NoError(t, err)
+	return s
+}
+
+// runUserStoreSuite проверяет правила, общие для пользователей в базе и в памяти.
+func runUserStoreSuite(t *testing.T, s Store) {
+	ctx := context.Background()
+	const password = "Str0ng-enough-pass"
+
+	anna, err := s.CreateUser(ctx, models.UserRegReq{Login: "Anna", Password: password})
+	require.NoError(t, err)
+	assert.Equal(t, "Anna", anna.Login)
+	assert.Equal(t, models.UserStatusActive, anna.Status)
+	assert.Equal(t, models.UserRoleUser, anna.Role)
+	assert.False(t, anna.CreatedAt.IsZero())
+	boris, err := s.CreateUser(ctx, models.UserRegReq{Login: "boris", Password: password})
+	require.NoError(t, err)
+	assert.Greater(t, boris.ID, anna.ID, "ids grow in order")
+
+	t.Run("login conflicts", func(t *testing.T) {
+		_, err := s.CreateUser(ctx, models.UserRegReq{Login: "ANNA", Password: password})
+		assert.ErrorIs(t, err, ErrLoginTaken)
+		_, err = s.CreateUser(ctx, models.UserRegReq{Login: "bоris", Password: password}) // кириллическая о
+		assert.ErrorIs(t, err, ErrLoginConfusable)
+	})
+
+	t.Run("role on creation", func(t *testing.T) {
+		admin, err := s.CreateUser(ctx, models.UserRegReq{Login: "root", Password: password, Role: models.UserRoleAdmin})
+		require.NoError(t, err)
+		assert.Equal(t, models.UserRoleAdmin, admin.Role)
+		state, err := s.GetUserAuthState(ctx, admin.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.UserRoleAdmin, state.Role)
+		_, err = s.CreateUser(ctx, models.UserRegReq{Login: "nobody", Password: password, Role: "superuser"})
+		assert.Error(t, err)
+	})
+
+	t.Run("lookup", func(t *testing.T) {
+		found, err := s.GetUserByLogin(ctx, models.UserLoginReq{Login: "anna"})
+		require.NoError(t, err)
+		assert.Equal(t, anna.ID, found.ID)
+		ok, err := s.VerifyPassword(ctx, password, found.PasswordHash)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		_, err = s.GetUserByLogin(ctx, models.UserLoginReq{Login: "nobody"})
+		assert.ErrorIs(t, err, ErrUserNotFound)
+		_, err = s.GetUserByID(ctx, 4242)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+
+		users, err := s.ListUsers(ctx, models.UserListQuery{LoginPrefix: "BO", Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, boris.ID, users[0].ID)
+	})
+
+	t.Run("sessions and status", func(t *testing.T) {
+		require.NoError(t, s.RevokeSessions(ctx, anna.ID))
+		state, err := s.GetUserAuthState(ctx, anna.ID)
+		require.NoError(t, err)
+		assert.False(t, state.TokensValidAfter.IsZero())
+
+		_, err = s.SetUserStatus(ctx, anna.ID, models.UserStatusSuspended, "spam")
+		require.NoError(t, err)
+		deleted, err := s.DeleteUser(ctx, anna.ID, "by request")
+		require.NoError(t, err)
+		require.NotNil(t, deleted.DeletedAt)
+		_, err = s.DeleteUser(ctx, anna.ID, "again")
+		assert.ErrorIs(t, err, ErrInvalidStatusTransition)
+
+		_, err = s.RestoreUser(ctx, anna.ID, time.Now().Add(time.Hour))
+		assert.ErrorIs(t, err, ErrRestoreExpired)
+		restored, err := s.RestoreUser(ctx, anna.ID, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, models.UserStatusSuspended, restored.Status, "restore returns the status from before the deletion")
+		assert.Equal(t, "spam", restored.StatusReason)
+		assert.Nil(t, restored.DeletedAt)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		deleted, err := s.DeleteUser(ctx, boris.ID, "by request")
+		require.NoError(t, err)
+		sub, err := s.CreateWebhookSubscription(ctx, models.WebhookSubscription{URL: "https://a.example/hook", Secret: "a"})
+		require.NoError(t, err)
+		event := models.WebhookEvent{
+			ID: "deleted-boris", Type: models.WebhookUserDeleted, OccurredAt: time.Now(),
+			Data: models.WebhookUserData{UserID: boris.ID, Login: deleted.Login},
+		}
+		payload, err := json.Marshal(event)
+		require.NoError(t, err)
+		_, err = s.EnqueueWebhookDeliveries(ctx, event, payload)
+		require.NoError(t, err)
+
+		purged, err := s.PurgeDeletedUsers(ctx, time.Now().Add(time.Second), 10, true)
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+		deliveries, err := s.ListWebhookDeliveries(ctx, models.WebhookDeliveryQuery{SubscriptionID: sub.ID, Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, deliveries, "webhook payloads with the login are removed")
+		user, err := s.GetUserByID(ctx, boris.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "deleted#"+strconv.Itoa(boris.ID), user.Login)
+		_, err = s.RestoreUser(ctx, boris.ID, time.Time{})
+		assert.ErrorIs(t, err, ErrRestoreExpired)
+		// Логин освободился.
+		_, err = s.CreateUser(ctx, models.UserRegReq{Login: "boris", Password: password})
+		assert.NoError(t, err)
+	})
+
+	t.Run("outbox", func(t *testing.T) {
+		var types, purgedTypes []models.DomainEventType
+		relayOutbox(t, s, time.Now().Add(time.Second), 100, func(_ context.Context, event models.DomainEvent) error {
+			switch event.UserID {
+			case anna.ID:
+				types = append(types, event.Type)
+			case boris.ID:
+				purgedTypes = append(purgedTypes, event.Type)
+			}
+			return nil
+		})
+		assert.Equal(t, []models.DomainEventType{models.EventUserPurged}, purgedTypes,
+			"events with the login of a purged user are removed, only the purge itself is left")
+		assert.Equal(t, []models.DomainEventType{
+			models.EventUserRegistered, models.EventUserSessionsRevoked, models.EventUserStatusChanged,
+			models.EventUserDeleted, models.EventUserRestored,
+		}, types)
+	})
+}
+
+// runConcurrentConfusableSuite регистрирует одновременно логины, которые выглядят одинаково:
+// пройти должна ровно одна регистрация.
+func runConcurrentConfusableSuite(t *testing.T, s Store) {
+	logins := []string{"boris", "bоris", "bοris", "bоrіs"} // латиница, кириллическая о, греческая ο, кириллические о и і
+	var wg sync.WaitGroup
+	errs := make([]error, len(logins))
+	for i, login := range logins {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = s.CreateUser(context.Background(), models.UserRegReq{Login: login, Password: "Str0ng-enough-pass"})
+		}()
+	}
+	wg.Wait()
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrLoginConfusable)
+	}
+	assert.Equal(t, 1, created)
+}
+
+func TestMemStore_Users(t *testing.T) {
+	runUserStoreSuite(t, newTestMemStore(t, ""))
+}
+
+func TestDBStore_Users(t *testing.T) {
+	runUserStoreSuite(t, newTestDBStore(t))
+}
+
+func TestMemStore_ConcurrentConfusableRegistration(t *testing.T) {
+	runConcurrentConfusableSuite(t, newTestMemStore(t, ""))
+}
+
+func TestDBStore_ConcurrentConfusableRegistration(t *testing.T) {
+	runConcurrentConfusableSuite(t, newTestDBStore(t))
+}
+
+func TestMemStore_Search(t *testing.T) {
+	runUserSearchSuite(t, func(t *testing.T, users []models.User) userSearcher {
+		s := newTestMemStore(t, "")
+		for _, user := range users {
+			s.users[user.ID] = &memUser{user: user}
+		}
+		return s
+	})
+}
+
+func TestMemStore_IPRules(t *testing.T) {
+	s := newTestMemStore(t, "")
+	_, err := s.CreateUser(context.Background(), models.UserRegReq{Login: "anna", Password: "Str0ng-enough-pass"})
+	require.NoError(t, err)
+	runIPRuleSuite(t, s)
+
+	missing := 42
+	_, err = s.CreateIPRule(context.Background(), models.IPRule{UserID: &missing, CIDR: "10.0.0.0/8", Action: models.IPRuleDeny})
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestMemStore_ConcurrentRegistration(t *testing.T) {
+	s := newTestMemStore(t, "")
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Половина регистраций претендует на один и тот же логин.
+			login := "user" + strconv.Itoa(i%10)
+			_, errs[i] = s.CreateUser(context.Background(), models.UserRegReq{Login: login, Password: "Str0ng-enough-pass"})
+		}()
+	}
+	wg.Wait()
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	assert.Equal(t, 10, failed)
+	users, err := s.ListUsers(context.Background(), models.UserListQuery{Limit: 100})
+	require.NoError(t, err)
+	require.Len(t, users, 10)
+	for i, user := range users {
+		assert.Equal(t, i+1, user.ID, "ids are unique and without gaps")
+	}
+}
+
+func TestMemStore_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "raya.json")
+
+	s := newTestMemStore(t, path)
+	anna, err := s.CreateUser(ctx, models.UserRegReq{Login: "anna", Password: "Str0ng-enough-pass"})
+	require.NoError(t, err)
+	require.NoError(t, s.RevokeSessions(ctx, anna.ID))
+	_, err = s.AppendAuditEvent(ctx, models.AuditEvent{Type: models.AuditLogin, TargetID: &anna.ID, Result: models.AuditSuccess})
+	require.NoError(t, err)
+	device, err := s.TouchDevice(ctx, models.Device{UserID: anna.ID, ClientFamily: "Firefox/Linux", Subnet: "192.0.2.0/24", LastSeenAt: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, s.SetNotificationOptOut(ctx, anna.ID, models.NotificationNewDeviceLogin, true))
+	_, err = s.AppendSecurityEvent(ctx, models.SecurityEvent{EventID: "e1", UserID: anna.ID, Type: models.EventUserLoggedIn, OccurredAt: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, s.DBConnClose())
+
+	restored := newTestMemStore(t, path)
+	user, err := restored.GetUserByLogin(ctx, models.UserLoginReq{Login: "ANNA"})
+	require.NoError(t, err)
+	assert.Equal(t, anna.ID, user.ID)
+	ok, err := restored.VerifyPassword(ctx, "Str0ng-enough-pass", user.PasswordHash)
+	require.NoError(t, err)
+	assert.True(t, ok, "password hash survives the restart")
+	state, err := restored.GetUserAuthState(ctx, anna.ID)
+	require.NoError(t, err)
+	assert.False(t, state.TokensValidAfter.IsZero())
+
+	verification, err := VerifyAuditChain(ctx, restored)
+	require.NoError(t, err)
+	assert.True(t, verification.Valid)
+	devices, err := restored.ListDevices(ctx, anna.ID)
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, device.ID, devices[0].ID)
+	optOuts, err := restored.GetNotificationOptOuts(ctx, anna.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []models.NotificationKind{models.NotificationNewDeviceLogin}, optOuts)
+	appended, err := restored.AppendSecurityEvent(ctx, models.SecurityEvent{EventID: "e1", UserID: anna.ID, Type: models.EventUserLoggedIn})
+	require.NoError(t, err)
+	assert.False(t, appended, "duplicates are still recognized")
+
+	// Счётчики id продолжаются, а не начинаются заново.
+	boris, err := restored.CreateUser(ctx, models.UserRegReq{Login: "boris", Password: "Str0ng-enough-pass"})
+	require.NoError(t, err)
+	assert.Equal(t, anna.ID+1, boris.ID)
+}
+
+// Снимок сохраняется и во время работы: после падения без DBConnClose теряются только последние изменения.
+func TestMemStore_PeriodicSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "raya.json")
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	c := &server_config.ServerConfig{StorageSnapshot: path, StorageSnapshotInterval: 10 * time.Millisecond}
+	newTestHasher(t, c)
+	s, err := NewMemStore(c, l)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.DBConnClose() })
+
+	anna, err := s.CreateUser(ctx, models.UserRegReq{Login: "anna", Password: "Str0ng-enough-pass"})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		restored := newTestMemStore(t, path)
+		user, err := restored.GetUserByLogin(ctx, models.UserLoginReq{Login: "anna"})
+		return err == nil && user.ID == anna.ID
+	}, 5*time.Second, 10*time.Millisecond, "the user is in the snapshot without closing the store")
+
+	require.NoError(t, s.DBConnClose())
+	assert.NoError(t, s.DBConnClose(), "closing twice only saves again")
+}
@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS user_login_skeleton;
+DROP INDEX IF EXISTS user_login_normalized_unique;
+ALTER TABLE users DROP COLUMN IF EXISTS login_skeleton;
+ALTER TABLE users DROP COLUMN IF EXISTS login_normalized;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,40 @@
+BEGIN TRANSACTION;
+
+-- Каноническая форма логина (NFKC + регистр) и его «скелет» с заменой похожих букв на латинские.
+-- Приложение считает обе колонки само (login_policy.Normalize и Skeleton), здесь заполняем их для
+-- существующих пользователей; lower() совпадает со свёрткой регистра для всех букв, кроме единичных
+-- вроде ß, такие логины выравнивает команда normalize-logins.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS login_normalized VARCHAR(200);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS login_skeleton VARCHAR(200);
+
+UPDATE users
+SET login_normalized = lower(normalize(btrim(login), NFKC))
+WHERE login_normalized IS NULL;
+
+UPDATE users
+SET login_skeleton = translate(login_normalized,
+                               'асԁеһіјкӏорԛѕухԝαεικνορτυχγıɡ',
+                               'acdehijklopqsyxwaeikvoptuxyig')
+WHERE login_skeleton IS NULL;
+
+-- Логины, совпадающие после нормализации, нужно развести до создания уникального индекса. Каноническую форму
+-- сохраняет пользователь, зарегистрированный первым, к формам остальных дописывается их id. Пока их логины
+-- не переименованы, войти они не смогут; команда normalize-logins показывает их среди коллизий.
+UPDATE users
+SET login_normalized = users.login_normalized || '#' || users.id
+FROM (SELECT id, row_number() OVER (PARTITION BY login_normalized ORDER BY id) AS n
+      FROM users) AS ranked
+WHERE ranked.id = users.id
+  AND ranked.n > 1;
+
+ALTER TABLE users ALTER COLUMN login_normalized SET NOT NULL;
+ALTER TABLE users ALTER COLUMN login_skeleton SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_login_normalized_unique
+    ON users
+    USING btree (login_normalized);
+
+CREATE INDEX IF NOT EXISTS user_login_skeleton
+    ON users
+    USING btree (login_skeleton);
+COMMIT;
\ No newline at end of file
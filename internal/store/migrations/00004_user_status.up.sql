@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active'
+    CONSTRAINT user_status_known CHECK (status IN ('pending', 'active', 'suspended', 'locked', 'deleted'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP NOT NULL DEFAULT now();
+
+COMMIT;
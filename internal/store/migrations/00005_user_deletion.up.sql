@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS purged_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_valid_after TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS user_pending_purge ON users (deleted_at)
+    WHERE status = 'deleted' AND purged_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS user_created_at;
+DROP INDEX IF EXISTS user_login_normalized_prefix;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'user'
+    CONSTRAINT user_role_known CHECK (role IN ('user', 'admin'));
+
+-- Поиск по префиксу логина в списке пользователей.
+CREATE INDEX IF NOT EXISTS user_login_normalized_prefix ON users (login_normalized text_pattern_ops);
+CREATE INDEX IF NOT EXISTS user_created_at ON users (created_at);
+
+COMMIT;
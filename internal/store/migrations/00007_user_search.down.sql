@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS user_email_trgm;
+DROP INDEX IF EXISTS user_display_name_trgm;
+DROP INDEX IF EXISTS user_login_trgm;
+
+COMMIT;
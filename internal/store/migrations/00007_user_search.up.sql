@@ -0,0 +1,9 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+BEGIN TRANSACTION;
+
+CREATE INDEX IF NOT EXISTS user_login_trgm ON users USING gin (login_normalized gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS user_display_name_trgm ON users USING gin (lower(display_name) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS user_email_trgm ON users USING gin (lower(email) gin_trgm_ops);
+
+COMMIT;
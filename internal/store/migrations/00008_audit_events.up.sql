@@ -0,0 +1,44 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS audit_events
+(
+    id          BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    occurred_at TIMESTAMPTZ  NOT NULL,
+    event_type  VARCHAR(64)  NOT NULL,
+    actor_id    INT,
+    target_id   INT,
+    ip          VARCHAR(64)  NOT NULL DEFAULT '',
+    user_agent  VARCHAR(512) NOT NULL DEFAULT '',
+    result      VARCHAR(16)  NOT NULL CONSTRAINT audit_result_known CHECK (result IN ('success', 'failure')),
+    reason      VARCHAR(256) NOT NULL DEFAULT '',
+    request_id  VARCHAR(64)  NOT NULL DEFAULT '',
+    prev_hash   BYTEA        NOT NULL,
+    hash        BYTEA        NOT NULL
+);
+
+-- Ссылок на users нет намеренно: журнал переживает окончательное удаление пользователя.
+CREATE INDEX IF NOT EXISTS audit_events_occurred_at ON audit_events (occurred_at);
+CREATE INDEX IF NOT EXISTS audit_events_actor_id ON audit_events (actor_id) WHERE actor_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS audit_events_target_id ON audit_events (target_id) WHERE target_id IS NOT NULL;
+
+-- Журнал только пополняется: правка, удаление и очистка таблицы запрещены.
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_modify
+    BEFORE UPDATE OR DELETE
+    ON audit_events
+    FOR EACH ROW
+EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE
+    ON audit_events
+    FOR EACH STATEMENT
+EXECUTE FUNCTION audit_events_append_only();
+
+COMMIT;
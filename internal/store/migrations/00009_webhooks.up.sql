@@ -0,0 +1,45 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions
+(
+    id          INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    url         VARCHAR(2048) NOT NULL,
+    secret      VARCHAR(128)  NOT NULL,
+    -- Пустой массив - подписка на все события.
+    event_types JSONB         NOT NULL DEFAULT '[]',
+    created_at  TIMESTAMPTZ   NOT NULL DEFAULT now()
+);
+
+-- Тело хранится как текст, а не jsonb: подпись считается по байтам, которые уходят получателю.
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    subscription_id INT           NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id        VARCHAR(64)   NOT NULL,
+    event_type      VARCHAR(64)   NOT NULL,
+    payload         TEXT          NOT NULL,
+    status          VARCHAR(16)   NOT NULL DEFAULT 'pending'
+        CONSTRAINT webhook_delivery_status_known CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts        INT           NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ   NOT NULL,
+    last_error      VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription ON webhook_deliveries (subscription_id, id);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts
+(
+    id           BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    delivery_id  BIGINT        NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+    attempted_at TIMESTAMPTZ   NOT NULL,
+    status_code  INT           NOT NULL DEFAULT 0,
+    error        VARCHAR(1024) NOT NULL DEFAULT '',
+    duration_ms  BIGINT        NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_attempts_delivery ON webhook_delivery_attempts (delivery_id, id);
+
+COMMIT;
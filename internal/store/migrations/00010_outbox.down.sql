@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS webhook_deliveries_event;
+DROP TABLE IF EXISTS outbox;
+
+COMMIT;
@@ -0,0 +1,23 @@
+BEGIN TRANSACTION;
+
+-- События пишутся той же транзакцией, что и изменение, а рассылаются отдельно.
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id              BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    event_id        VARCHAR(64)   NOT NULL UNIQUE,
+    event_type      VARCHAR(64)   NOT NULL,
+    payload         TEXT          NOT NULL,
+    attempts        INT           NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ   NOT NULL,
+    last_error      VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ   NOT NULL,
+    published_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_published_at ON outbox (published_at) WHERE published_at IS NOT NULL;
+
+-- Повторная рассылка того же события не должна порождать вторую доставку вебхука.
+CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_event ON webhook_deliveries (subscription_id, event_id);
+
+COMMIT;
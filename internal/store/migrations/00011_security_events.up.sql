@@ -0,0 +1,17 @@
+BEGIN TRANSACTION;
+
+-- Уведомления пользователя о входах, смене пароля и отзыве сессий. Id задаёт порядок в потоке событий.
+CREATE TABLE IF NOT EXISTS security_events
+(
+    id          BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    event_id    VARCHAR(64)  NOT NULL UNIQUE,
+    user_id     INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type        VARCHAR(64)  NOT NULL,
+    occurred_at TIMESTAMPTZ  NOT NULL,
+    ip          VARCHAR(64)  NOT NULL DEFAULT '',
+    user_agent  VARCHAR(512) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS security_events_user ON security_events (user_id, id);
+
+COMMIT;
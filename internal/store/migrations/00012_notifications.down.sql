@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS notification_opt_outs;
+DROP TABLE IF EXISTS notifications;
+
+COMMIT;
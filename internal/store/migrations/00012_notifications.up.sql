@@ -0,0 +1,32 @@
+BEGIN TRANSACTION;
+
+-- Очередь писем. Текст собирается из шаблона при отправке, здесь хранятся только подстановки.
+CREATE TABLE IF NOT EXISTS notifications
+(
+    id              BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    dedup_key       VARCHAR(128)  NOT NULL UNIQUE,
+    user_id         INT           NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind            VARCHAR(32)   NOT NULL,
+    locale          VARCHAR(16)   NOT NULL DEFAULT '',
+    recipient       VARCHAR(320)  NOT NULL,
+    data            TEXT          NOT NULL DEFAULT '{}',
+    status          VARCHAR(16)   NOT NULL DEFAULT 'pending'
+        CONSTRAINT notification_status_known CHECK (status IN ('pending', 'sent', 'dead')),
+    attempts        INT           NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ   NOT NULL,
+    last_error      VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    sent_at         TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS notifications_due ON notifications (next_attempt_at, id) WHERE status = 'pending';
+
+-- Виды писем, от которых пользователь отказался.
+CREATE TABLE IF NOT EXISTS notification_opt_outs
+(
+    user_id INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind    VARCHAR(32) NOT NULL,
+    PRIMARY KEY (user_id, kind)
+);
+
+COMMIT;
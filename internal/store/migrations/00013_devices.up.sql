@@ -0,0 +1,17 @@
+BEGIN TRANSACTION;
+
+-- Устройства, с которых пользователи уже входили. Устройство - это семейство клиента и подсеть адреса.
+CREATE TABLE IF NOT EXISTS devices
+(
+    id            BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id       INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    client_family VARCHAR(64)  NOT NULL,
+    subnet        VARCHAR(64)  NOT NULL,
+    last_ip       VARCHAR(64)  NOT NULL DEFAULT '',
+    user_agent    VARCHAR(512) NOT NULL DEFAULT '',
+    first_seen_at TIMESTAMPTZ  NOT NULL,
+    last_seen_at  TIMESTAMPTZ  NOT NULL,
+    UNIQUE (user_id, client_family, subnet)
+);
+
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN TRANSACTION;
+
+-- Ограничения по адресам для отдельных пользователей и целых ролей.
+CREATE TABLE IF NOT EXISTS ip_rules
+(
+    id         INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id    INT REFERENCES users (id) ON DELETE CASCADE,
+    role       VARCHAR(32),
+    cidr       VARCHAR(64)  NOT NULL,
+    action     VARCHAR(8)   NOT NULL
+        CONSTRAINT ip_rule_action_known CHECK (action IN ('allow', 'deny')),
+    comment    VARCHAR(200) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    CONSTRAINT ip_rule_single_scope CHECK ((user_id IS NULL) <> (role IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS ip_rules_user ON ip_rules (user_id) WHERE user_id IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+-- Счётчики запросов для общего на все экземпляры сервера ограничения частоты.
+-- Хранятся текущее и предыдущее окно: по ним считается скользящее окно.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits
+(
+    key          VARCHAR(256) PRIMARY KEY,
+    window_start TIMESTAMPTZ  NOT NULL,
+    current      INT          NOT NULL,
+    previous     INT          NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users DROP COLUMN IF EXISTS status_reason_before_deletion;
+ALTER TABLE users DROP COLUMN IF EXISTS status_before_deletion;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+-- Статус и его причина до удаления: восстановление возвращает учётную запись в прежнее состояние,
+-- чтобы заблокированный администратором пользователь не разблокировал себя удалением и восстановлением.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_before_deletion VARCHAR(16);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_reason_before_deletion TEXT;
+
+COMMIT;
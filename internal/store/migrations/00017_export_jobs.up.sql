@@ -0,0 +1,21 @@
+BEGIN TRANSACTION;
+
+-- Выгрузки персональных данных. Архив хранится вместе с заданием, чтобы его мог отдать любой экземпляр сервера.
+CREATE TABLE IF NOT EXISTS export_jobs
+(
+    id          VARCHAR(32) PRIMARY KEY,
+    user_id     INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status      VARCHAR(16) NOT NULL DEFAULT 'pending'
+        CONSTRAINT export_job_status_known CHECK (status IN ('pending', 'ready', 'failed')),
+    created_at  TIMESTAMPTZ NOT NULL,
+    lease_until TIMESTAMPTZ NOT NULL,
+    expires_at  TIMESTAMPTZ,
+    archive     BYTEA
+);
+
+-- У пользователя собирается не больше одной выгрузки за раз.
+CREATE UNIQUE INDEX IF NOT EXISTS export_jobs_one_pending ON export_jobs (user_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS export_jobs_due ON export_jobs (lease_until) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS export_jobs_user ON export_jobs (user_id, created_at);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+
+-- Событие, которое не удалось разослать за отведённое число попыток, больше не повторяется.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS dead_at TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS outbox_pending;
+CREATE INDEX IF NOT EXISTS outbox_pending ON outbox (id) WHERE published_at IS NULL AND dead_at IS NULL;
+
+COMMIT;
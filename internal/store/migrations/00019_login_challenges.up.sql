@@ -0,0 +1,17 @@
+BEGIN TRANSACTION;
+
+-- Коды подтверждения входа с новых устройств. Код хранится только хэшем, на устройство - один код.
+CREATE TABLE IF NOT EXISTS login_challenges
+(
+    user_id       INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    client_family VARCHAR(64)  NOT NULL,
+    subnet        VARCHAR(64)  NOT NULL,
+    code_hash     VARCHAR(64)  NOT NULL,
+    attempts      INT          NOT NULL DEFAULT 0,
+    expires_at    TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (user_id, client_family, subnet)
+);
+
+CREATE INDEX IF NOT EXISTS login_challenges_expires ON login_challenges (expires_at);
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+-- Версия состояния входа - одна строка. Экземпляры сервера следят за ней, чтобы сбросить кэш проверки токенов,
+-- когда статус или ограничения по адресам поменяли на другом экземпляре.
+CREATE TABLE IF NOT EXISTS auth_state_version
+(
+    id      INT    PRIMARY KEY CHECK (id = 1),
+    version BIGINT NOT NULL
+);
+
+INSERT INTO auth_state_version (id, version) VALUES (1, 0) ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS webhook_deliveries_user;
+DROP INDEX IF EXISTS outbox_user;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS user_id;
+ALTER TABLE outbox DROP COLUMN IF EXISTS user_id;
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN TRANSACTION;
+
+-- Чьё это событие. В payload есть логин, поэтому строки outbox и доставок вебхуков удаляются
+-- вместе с пользователем, а найти их можно только по этой колонке: внешнего ключа на users у них нет,
+-- события переживают обычное удаление записи.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS user_id INT;
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS user_id INT;
+
+UPDATE outbox SET user_id = (payload::jsonb ->> 'user_id')::INT WHERE user_id IS NULL;
+UPDATE webhook_deliveries SET user_id = (payload::jsonb -> 'data' ->> 'user_id')::INT WHERE user_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS outbox_user ON outbox (user_id);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_user ON webhook_deliveries (user_id);
+
+COMMIT;
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS rate_limits;
+DROP TABLE IF EXISTS ip_rules;
+DROP TABLE IF EXISTS devices;
+DROP TABLE IF EXISTS notification_opt_outs;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS security_events;
+DROP TABLE IF EXISTS outbox;
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS users;
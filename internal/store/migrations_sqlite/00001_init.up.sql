@@ -0,0 +1,203 @@
+-- Схема для SQLite повторяет то, к чему приходят миграции Postgres, одним шагом.
+-- Транзакцию вокруг файла открывает сам migrate. Время пишется текстом в UTC в формате,
+-- который сортируется так же, как сами моменты времени, поэтому умолчания задаются в нём же.
+
+CREATE TABLE users
+(
+    id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+    login              VARCHAR(200)  NOT NULL UNIQUE,
+    login_normalized   VARCHAR(200)  NOT NULL UNIQUE,
+    login_skeleton     VARCHAR(200)  NOT NULL,
+    password_hash      VARCHAR(255)  NOT NULL,
+    salt               VARCHAR(255)  NOT NULL,
+    display_name       VARCHAR(100)  NOT NULL DEFAULT '',
+    email              VARCHAR(254)  NOT NULL DEFAULT '',
+    phone              VARCHAR(16)   NOT NULL DEFAULT '',
+    locale             VARCHAR(35)   NOT NULL DEFAULT '',
+    timezone           VARCHAR(64)   NOT NULL DEFAULT '',
+    avatar_url         VARCHAR(2048) NOT NULL DEFAULT '',
+    role               VARCHAR(16)   NOT NULL DEFAULT 'user'
+        CONSTRAINT user_role_known CHECK (role IN ('user', 'admin')),
+    status             VARCHAR(16)   NOT NULL DEFAULT 'active'
+        CONSTRAINT user_status_known CHECK (status IN ('pending', 'active', 'suspended', 'locked', 'deleted')),
+    status_reason      TEXT          NOT NULL DEFAULT '',
+    status_changed_at  TIMESTAMP     NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    deleted_at         TIMESTAMP,
+    purged_at          TIMESTAMP,
+    tokens_valid_after TIMESTAMP,
+    created_at         TIMESTAMP     DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    updated_at         TIMESTAMP     DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX user_login_skeleton ON users (login_skeleton);
+CREATE INDEX user_created_at ON users (created_at);
+CREATE INDEX user_pending_purge ON users (deleted_at) WHERE status = 'deleted' AND purged_at IS NULL;
+
+CREATE TABLE audit_events
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    occurred_at TIMESTAMP    NOT NULL,
+    event_type  VARCHAR(64)  NOT NULL,
+    actor_id    INT,
+    target_id   INT,
+    ip          VARCHAR(64)  NOT NULL DEFAULT '',
+    user_agent  VARCHAR(512) NOT NULL DEFAULT '',
+    result      VARCHAR(16)  NOT NULL CONSTRAINT audit_result_known CHECK (result IN ('success', 'failure')),
+    reason      VARCHAR(256) NOT NULL DEFAULT '',
+    request_id  VARCHAR(64)  NOT NULL DEFAULT '',
+    prev_hash   BLOB         NOT NULL,
+    hash        BLOB         NOT NULL
+);
+
+CREATE INDEX audit_events_occurred_at ON audit_events (occurred_at);
+CREATE INDEX audit_events_actor_id ON audit_events (actor_id) WHERE actor_id IS NOT NULL;
+CREATE INDEX audit_events_target_id ON audit_events (target_id) WHERE target_id IS NOT NULL;
+
+-- Журнал только пополняется.
+CREATE TRIGGER audit_events_no_update
+    BEFORE UPDATE
+    ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER audit_events_no_delete
+    BEFORE DELETE
+    ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TABLE webhook_subscriptions
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    url         VARCHAR(2048) NOT NULL,
+    secret      VARCHAR(128)  NOT NULL,
+    -- JSON-массив типов событий, пустой - подписка на все события.
+    event_types TEXT          NOT NULL DEFAULT '[]',
+    created_at  TIMESTAMP     NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE TABLE webhook_deliveries
+(
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    subscription_id INT           NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id        VARCHAR(64)   NOT NULL,
+    event_type      VARCHAR(64)   NOT NULL,
+    payload         TEXT          NOT NULL,
+    status          VARCHAR(16)   NOT NULL DEFAULT 'pending'
+        CONSTRAINT webhook_delivery_status_known CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts        INT           NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP     NOT NULL,
+    last_error      VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at      TIMESTAMP     NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    delivered_at    TIMESTAMP
+);
+
+CREATE INDEX webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_subscription ON webhook_deliveries (subscription_id, id);
+CREATE UNIQUE INDEX webhook_deliveries_event ON webhook_deliveries (subscription_id, event_id);
+
+CREATE TABLE webhook_delivery_attempts
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    delivery_id  INTEGER       NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+    attempted_at TIMESTAMP     NOT NULL,
+    status_code  INT           NOT NULL DEFAULT 0,
+    error        VARCHAR(1024) NOT NULL DEFAULT '',
+    duration_ms  BIGINT        NOT NULL DEFAULT 0
+);
+
+CREATE INDEX webhook_delivery_attempts_delivery ON webhook_delivery_attempts (delivery_id, id);
+
+CREATE TABLE outbox
+(
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id        VARCHAR(64)   NOT NULL UNIQUE,
+    event_type      VARCHAR(64)   NOT NULL,
+    payload         TEXT          NOT NULL,
+    attempts        INT           NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP     NOT NULL,
+    last_error      VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at      TIMESTAMP     NOT NULL,
+    published_at    TIMESTAMP
+);
+
+CREATE INDEX outbox_pending ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX outbox_published_at ON outbox (published_at) WHERE published_at IS NOT NULL;
+
+CREATE TABLE security_events
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id    VARCHAR(64)  NOT NULL UNIQUE,
+    user_id     INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type        VARCHAR(64)  NOT NULL,
+    occurred_at TIMESTAMP    NOT NULL,
+    ip          VARCHAR(64)  NOT NULL DEFAULT '',
+    user_agent  VARCHAR(512) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX security_events_user ON security_events (user_id, id);
+
+CREATE TABLE notifications
+(
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    dedup_key       VARCHAR(128)  NOT NULL UNIQUE,
+    user_id         INT           NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind            VARCHAR(32)   NOT NULL,
+    locale          VARCHAR(16)   NOT NULL DEFAULT '',
+    recipient       VARCHAR(320)  NOT NULL,
+    data            TEXT          NOT NULL DEFAULT '{}',
+    status          VARCHAR(16)   NOT NULL DEFAULT 'pending'
+        CONSTRAINT notification_status_known CHECK (status IN ('pending', 'sent', 'dead')),
+    attempts        INT           NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP     NOT NULL,
+    last_error      VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at      TIMESTAMP     NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    sent_at         TIMESTAMP
+);
+
+CREATE INDEX notifications_due ON notifications (next_attempt_at, id) WHERE status = 'pending';
+
+CREATE TABLE notification_opt_outs
+(
+    user_id INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind    VARCHAR(32) NOT NULL,
+    PRIMARY KEY (user_id, kind)
+);
+
+CREATE TABLE devices
+(
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id       INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    client_family VARCHAR(64)  NOT NULL,
+    subnet        VARCHAR(64)  NOT NULL,
+    last_ip       VARCHAR(64)  NOT NULL DEFAULT '',
+    user_agent    VARCHAR(512) NOT NULL DEFAULT '',
+    first_seen_at TIMESTAMP    NOT NULL,
+    last_seen_at  TIMESTAMP    NOT NULL,
+    UNIQUE (user_id, client_family, subnet)
+);
+
+CREATE TABLE ip_rules
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INT REFERENCES users (id) ON DELETE CASCADE,
+    role       VARCHAR(32),
+    cidr       VARCHAR(64)  NOT NULL,
+    action     VARCHAR(8)   NOT NULL
+        CONSTRAINT ip_rule_action_known CHECK (action IN ('allow', 'deny')),
+    comment    VARCHAR(200) NOT NULL DEFAULT '',
+    created_at TIMESTAMP    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    CONSTRAINT ip_rule_single_scope CHECK ((user_id IS NULL) <> (role IS NULL))
+);
+
+CREATE INDEX ip_rules_user ON ip_rules (user_id) WHERE user_id IS NOT NULL;
+
+CREATE TABLE rate_limits
+(
+    key          VARCHAR(256) PRIMARY KEY,
+    window_start TIMESTAMP    NOT NULL,
+    current      INT          NOT NULL,
+    previous     INT          NOT NULL
+);
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN status_reason_before_deletion;
+ALTER TABLE users DROP COLUMN status_before_deletion;
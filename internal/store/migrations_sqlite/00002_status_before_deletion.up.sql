@@ -0,0 +1,3 @@
+-- Статус и его причина до удаления, см. миграцию Postgres 00016.
+ALTER TABLE users ADD COLUMN status_before_deletion VARCHAR(16);
+ALTER TABLE users ADD COLUMN status_reason_before_deletion TEXT;
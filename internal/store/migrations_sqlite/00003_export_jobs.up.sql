@@ -0,0 +1,16 @@
+-- Выгрузки персональных данных, см. миграцию Postgres 00017.
+CREATE TABLE export_jobs
+(
+    id          VARCHAR(32) PRIMARY KEY,
+    user_id     INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status      VARCHAR(16) NOT NULL DEFAULT 'pending'
+        CONSTRAINT export_job_status_known CHECK (status IN ('pending', 'ready', 'failed')),
+    created_at  TIMESTAMP   NOT NULL,
+    lease_until TIMESTAMP   NOT NULL,
+    expires_at  TIMESTAMP,
+    archive     BLOB
+);
+
+CREATE UNIQUE INDEX export_jobs_one_pending ON export_jobs (user_id) WHERE status = 'pending';
+CREATE INDEX export_jobs_due ON export_jobs (lease_until) WHERE status = 'pending';
+CREATE INDEX export_jobs_user ON export_jobs (user_id, created_at);
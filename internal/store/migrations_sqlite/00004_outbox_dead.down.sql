@@ -0,0 +1,4 @@
+DROP INDEX outbox_pending;
+CREATE INDEX outbox_pending ON outbox (id) WHERE published_at IS NULL;
+
+ALTER TABLE outbox DROP COLUMN dead_at;
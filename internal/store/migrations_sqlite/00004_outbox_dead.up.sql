@@ -0,0 +1,5 @@
+-- Исчерпавшие попытки события outbox, см. миграцию Postgres 00018.
+ALTER TABLE outbox ADD COLUMN dead_at TIMESTAMP;
+
+DROP INDEX outbox_pending;
+CREATE INDEX outbox_pending ON outbox (id) WHERE published_at IS NULL AND dead_at IS NULL;
@@ -0,0 +1,13 @@
+-- Коды подтверждения входа с новых устройств, см. миграцию Postgres 00019.
+CREATE TABLE login_challenges
+(
+    user_id       INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    client_family VARCHAR(64)  NOT NULL,
+    subnet        VARCHAR(64)  NOT NULL,
+    code_hash     VARCHAR(64)  NOT NULL,
+    attempts      INT          NOT NULL DEFAULT 0,
+    expires_at    TIMESTAMP    NOT NULL,
+    PRIMARY KEY (user_id, client_family, subnet)
+);
+
+CREATE INDEX login_challenges_expires ON login_challenges (expires_at);
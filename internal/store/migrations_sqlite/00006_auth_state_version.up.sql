@@ -0,0 +1,8 @@
+-- Версия состояния входа, см. миграцию Postgres 00020.
+CREATE TABLE auth_state_version
+(
+    id      INT    PRIMARY KEY CHECK (id = 1),
+    version BIGINT NOT NULL
+);
+
+INSERT INTO auth_state_version (id, version) VALUES (1, 0);
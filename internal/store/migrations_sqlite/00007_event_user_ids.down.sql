@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS webhook_deliveries_user;
+DROP INDEX IF EXISTS outbox_user;
+ALTER TABLE webhook_deliveries DROP COLUMN user_id;
+ALTER TABLE outbox DROP COLUMN user_id;
@@ -0,0 +1,9 @@
+-- Чьё это событие, см. миграцию Postgres 00021.
+ALTER TABLE outbox ADD COLUMN user_id INT;
+ALTER TABLE webhook_deliveries ADD COLUMN user_id INT;
+
+UPDATE outbox SET user_id = json_extract(payload, '$.user_id');
+UPDATE webhook_deliveries SET user_id = json_extract(payload, '$.data.user_id');
+
+CREATE INDEX outbox_user ON outbox (user_id);
+CREATE INDEX webhook_deliveries_user ON webhook_deliveries (user_id);
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NotificationQueue - очередь писем и отказы от них в памяти, по тем же правилам, что и в базе.
+type NotificationQueue struct {
+	mu            sync.Mutex
+	notifications []models.Notification
+	optOuts       map[int]map[models.NotificationKind]bool
+	lastID        int64
+}
+
+func NewNotificationQueue() *NotificationQueue {
+	return &NotificationQueue{optOuts: make(map[int]map[models.NotificationKind]bool)}
+}
+
+func (q *NotificationQueue) EnqueueNotification(_ context.Context, n models.Notification) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, stored := range q.notifications {
+		if stored.DedupKey == n.DedupKey {
+			return false, nil
+		}
+	}
+	now := time.Now()
+	q.lastID++
+	n.ID = q.lastID
+	n.Status = models.NotificationPending
+	n.Attempts = 0
+	n.NextAttemptAt = now
+	n.CreatedAt = now
+	q.notifications = append(q.notifications, n)
+	return true, nil
+}
+
+func (q *NotificationQueue) ClaimNotifications(_ context.Context, now, leaseUntil time.Time, limit int) ([]models.Notification, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var due []int
+	for i, n := range q.notifications {
+		if n.Status == models.NotificationPending && !n.NextAttemptAt.After(now) {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(a, b int) bool {
+		return q.notifications[due[a]].NextAttemptAt.Before(q.notifications[due[b]].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	claimed := make([]models.Notification, 0, len(due))
+	for _, i := range due {
+		q.notifications[i].NextAttemptAt = leaseUntil
+		claimed = append(claimed, q.notifications[i])
+	}
+	return claimed, nil
+}
+
+func (q *NotificationQueue) RecordNotificationResult(_ context.Context, n models.Notification) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := range q.notifications {
+		if q.notifications[i].ID != n.ID {
+			continue
+		}
+		stored := &q.notifications[i]
+		stored.Status = n.Status
+		stored.Attempts = n.Attempts
+		stored.NextAttemptAt = n.NextAttemptAt
+		stored.LastError = n.LastError
+		stored.SentAt = n.SentAt
+		return nil
+	}
+	return ErrNotificationNotFound
+}
+
+func (q *NotificationQueue) GetNotificationOptOuts(_ context.Context, userID int) ([]models.NotificationKind, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var kinds []models.NotificationKind
+	for kind := range q.optOuts[userID] {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	return kinds, nil
+}
+
+func (q *NotificationQueue) SetNotificationOptOut(_ context.Context, userID int, kind models.NotificationKind, optOut bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !optOut {
+		delete(q.optOuts[userID], kind)
+		return nil
+	}
+	if q.optOuts[userID] == nil {
+		q.optOuts[userID] = make(map[models.NotificationKind]bool)
+	}
+	q.optOuts[userID][kind] = true
+	return nil
+}
+
+// Notifications возвращает все письма очереди по порядку, для проверок.
+func (q *NotificationQueue) Notifications() []models.Notification {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]models.Notification(nil), q.notifications...)
+}
+
+// forgetUser удаляет письма пользователя и его отказы от писем, как каскад в базе.
+func (q *NotificationQueue) forgetUser(userID int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kept := q.notifications[:0]
+	for _, n := range q.notifications {
+		if n.UserID != userID {
+			kept = append(kept, n)
+		}
+	}
+	q.notifications = kept
+	delete(q.optOuts, userID)
+}
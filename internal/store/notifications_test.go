@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// notificationStore - общее у NotificationQueue и DBStore.
+type notificationStore interface {
+	EnqueueNotification(ctx context.Context, n models.Notification) (bool, error)
+	ClaimNotifications(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.Notification, error)
+	RecordNotificationResult(ctx context.Context, n models.Notification) error
+	GetNotificationOptOuts(ctx context.Context, userID int) ([]models.NotificationKind, error)
+	SetNotificationOptOut(ctx context.Context, userID int, kind models.NotificationKind, optOut bool) error
+}
+
+func runNotificationQueueSuite(t *testing.T, s notificationStore) {
+	ctx := context.Background()
+	welcome := models.Notification{
+		DedupKey: "e1:welcome", UserID: 1, Kind: models.NotificationWelcome, Locale: "ru",
+		Recipient: "anna@example.com", Data: map[string]string{"Login": "anna"},
+	}
+	enqueued, err := s.EnqueueNotification(ctx, welcome)
+	require.NoError(t, err)
+	assert.True(t, enqueued)
+	enqueued, err = s.EnqueueNotification(ctx, welcome)
+	require.NoError(t, err)
+	assert.False(t, enqueued, "the same dedup key is enqueued once")
+	_, err = s.EnqueueNotification(ctx, models.Notification{
+		DedupKey: "e2:password_changed", UserID: 2, Kind: models.NotificationPasswordChanged, Recipient: "boris@example.com",
+	})
+	require.NoError(t, err)
+
+	now := time.Now().Add(time.Second)
+	claimed, err := s.ClaimNotifications(ctx, now, now.Add(time.Minute), 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "anna@example.com", claimed[0].Recipient)
+	assert.Equal(t, map[string]string{"Login": "anna"}, claimed[0].Data)
+	assert.Equal(t, models.NotificationPending, claimed[0].Status)
+	rest, err := s.ClaimNotifications(ctx, now, now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+	assert.Equal(t, 2, rest[0].UserID)
+	expired, err := s.ClaimNotifications(ctx, now.Add(2*time.Minute), now.Add(3*time.Minute), 10)
+	require.NoError(t, err)
+	assert.Len(t, expired, 2, "a lost lease returns notifications to the queue")
+
+	sent := claimed[0]
+	sentAt := now
+	sent.Status, sent.Attempts, sent.SentAt = models.NotificationSent, 1, &sentAt
+	require.NoError(t, s.RecordNotificationResult(ctx, sent))
+	due, err := s.ClaimNotifications(ctx, now.Add(time.Hour), now.Add(2*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1, "sent notifications are not claimed again")
+	assert.Equal(t, 2, due[0].UserID)
+	sent.ID = 999
+	assert.ErrorIs(t, s.RecordNotificationResult(ctx, sent), ErrNotificationNotFound)
+
+	require.NoError(t, s.SetNotificationOptOut(ctx, 1, models.NotificationWelcome, true))
+	require.NoError(t, s.SetNotificationOptOut(ctx, 1, models.NotificationNewDeviceLogin, true))
+	require.NoError(t, s.SetNotificationOptOut(ctx, 1, models.NotificationNewDeviceLogin, true))
+	require.NoError(t, s.SetNotificationOptOut(ctx, 1, models.NotificationWelcome, false))
+	kinds, err := s.GetNotificationOptOuts(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []models.NotificationKind{models.NotificationNewDeviceLogin}, kinds)
+	kinds, err = s.GetNotificationOptOuts(ctx, 2)
+	require.NoError(t, err)
+	assert.Empty(t, kinds)
+}
+
+func TestNotificationQueue(t *testing.T) {
+	runNotificationQueueSuite(t, NewNotificationQueue())
+}
+
+func TestDBStore_Notifications(t *testing.T) {
+	s := newTestDBStore(t)
+	seedUsers(t, s, []models.User{{ID: 1, Login: "anna"}, {ID: 2, Login: "boris"}})
+	runNotificationQueueSuite(t, s)
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sync"
+	"time"
+)
+
+// Transactional outbox: изменение учётной записи и событие о нём сохраняются одной транзакцией,
+// поэтому событие не теряется при падении между ними и не уходит, если изменение откатилось.
+// Рассылкой занимается отдельный relay: он забирает события через ClaimOutboxEvents и записывает итог через RecordOutboxResult. Outbox повторяет те же правила в памяти.
+
+// execer - общее у database и dbTx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// NewDomainEvent готовит событие о пользователе с новым id.
+func NewDomainEvent(eventType models.DomainEventType, user *models.User) models.DomainEvent {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return models.DomainEvent{
+		ID:         hex.EncodeToString(buf),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC().Truncate(time.Microsecond),
+		UserID:     user.ID,
+		Login:      user.Login,
+		Status:     user.Status,
+		Role:       user.Role,
+	}
+}
+
+// insertOutboxEvent пишет событие в outbox. Вызывается внутри транзакции изменения.
+func insertOutboxEvent(ctx context.Context, ex execer, event models.DomainEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode domain event: %w", err)
+	}
+	_, err = ex.ExecContext(ctx,
+		`INSERT INTO outbox (event_id, event_type, payload, next_attempt_at, created_at, user_id) VALUES ($1, $2, $3, $4, $4, $5)`,
+		event.ID,
+		event.Type,
+		string(payload),
+		event.OccurredAt,
+		event.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert outbox event: %w", dbError(err))
+	}
+	return nil
+}
+
+// Outbox - outbox в памяти.
+type Outbox struct {
+	mu     sync.Mutex
+	events []models.OutboxEvent
+	lastID int64
+}
+
+func NewOutbox() *Outbox {
+	return &Outbox{}
+}
+
+// AppendOutboxEvent добавляет событие, не привязанное к изменению данных, например о входе.
+func (o *Outbox) AppendOutboxEvent(_ context.Context, event models.DomainEvent) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lastID++
+	o.events = append(o.events, models.OutboxEvent{
+		ID:            o.lastID,
+		Event:         event,
+		NextAttemptAt: event.OccurredAt,
+		CreatedAt:     event.OccurredAt,
+	})
+	return nil
+}
+
+// ClaimOutboxEvents забирает до limit неразосланных событий по порядку id и откладывает их до leaseUntil.
+func (o *Outbox) ClaimOutboxEvents(_ context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboxEvent, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var claimed []models.OutboxEvent
+	for i := range o.events {
+		event := &o.events[i]
+		if len(claimed) == limit {
+			break
+		}
+		if event.PublishedAt != nil || event.DeadAt != nil || event.NextAttemptAt.After(now) {
+			continue
+		}
+		event.NextAttemptAt = leaseUntil
+		claimed = append(claimed, *event)
+	}
+	return claimed, nil
+}
+
+// RecordOutboxResult сохраняет итог рассылки события.
+func (o *Outbox) RecordOutboxResult(_ context.Context, event models.OutboxEvent) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := range o.events {
+		if o.events[i].ID != event.ID {
+			continue
+		}
+		o.events[i].Attempts = event.Attempts
+		o.events[i].LastError = event.LastError
+		o.events[i].NextAttemptAt = event.NextAttemptAt
+		o.events[i].PublishedAt = event.PublishedAt
+		o.events[i].DeadAt = event.DeadAt
+		return nil
+	}
+	return nil
+}
+
+// DeletePublishedOutboxEvents удаляет события, разосланные раньше before.
+func (o *Outbox) DeletePublishedOutboxEvents(_ context.Context, before time.Time) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	kept := o.events[:0]
+	for _, event := range o.events {
+		if event.PublishedAt == nil || !event.PublishedAt.Before(before) {
+			kept = append(kept, event)
+		}
+	}
+	deleted := len(o.events) - len(kept)
+	o.events = kept
+	return deleted, nil
+}
+
+// forgetUser удаляет события пользователя, как при окончательном удалении в базе.
+func (o *Outbox) forgetUser(userID int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	kept := o.events[:0]
+	for _, event := range o.events {
+		if event.Event.UserID != userID {
+			kept = append(kept, event)
+		}
+	}
+	o.events = kept
+}
+
+// OutboxEvents возвращает все события outbox по порядку, для проверок.
+func (o *Outbox) OutboxEvents() []models.OutboxEvent {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]models.OutboxEvent(nil), o.events...)
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type outboxStore interface {
+	AppendOutboxEvent(ctx context.Context, event models.DomainEvent) error
+	ClaimOutboxEvents(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboxEvent, error)
+	RecordOutboxResult(ctx context.Context, event models.OutboxEvent) error
+	DeletePublishedOutboxEvents(ctx context.Context, before time.Time) (int, error)
+}
+
+// collect - обработчик, который запоминает события и отказывает тем, чей id есть в fail.
+type collect struct {
+	seen []string
+	fail map[string]bool
+}
+
+func (c *collect) handle(_ context.Context, event models.DomainEvent) error {
+	c.seen = append(c.seen, event.ID)
+	if c.fail[event.ID] {
+		return errors.New("sink is down")
+	}
+	return nil
+}
+
+// relayOutbox забирает до limit событий и записывает итог handle, как это делает outbox.Relay:
+// неудачное событие откладывается на attempts минут.
+func relayOutbox(t *testing.T, s outboxStore, now time.Time, limit int, handle func(context.Context, models.DomainEvent) error) (relayed int) {
+	t.Helper()
+	ctx := context.Background()
+	events, err := s.ClaimOutboxEvents(ctx, now, now.Add(time.Hour), limit)
+	require.NoError(t, err)
+	for _, event := range events {
+		event.Attempts++
+		if err := handle(ctx, event.Event); err != nil {
+			event.LastError = err.Error()
+			event.NextAttemptAt = now.Add(time.Duration(event.Attempts) * time.Minute)
+		} else {
+			event.LastError = ""
+			event.PublishedAt = &now
+			relayed++
+		}
+		require.NoError(t, s.RecordOutboxResult(ctx, event))
+	}
+	return relayed
+}
+
+func runOutboxSuite(t *testing.T, s outboxStore) {
+	ctx := context.Background()
+	user := &models.User{ID: 1, Login: "anna"}
+	first := NewDomainEvent(models.EventUserLoggedIn, user)
+	second := NewDomainEvent(models.EventUserLoggedIn, user)
+	third := NewDomainEvent(models.EventUserLoggedIn, user)
+	for _, event := range []models.DomainEvent{first, second, third} {
+		require.NoError(t, s.AppendOutboxEvent(ctx, event))
+	}
+	fourth := NewDomainEvent(models.EventUserLoggedIn, user)
+	require.NoError(t, s.AppendOutboxEvent(ctx, fourth))
+	now := time.Now().Add(time.Second)
+
+	// События идут по порядку, неудачное откладывается, остальные считаются разосланными.
+	c := &collect{fail: map[string]bool{second.ID: true}}
+	assert.Equal(t, 1, relayOutbox(t, s, now, 2, c.handle))
+	assert.Equal(t, []string{first.ID, second.ID}, c.seen)
+
+	// Забранное, но ещё не разосланное событие другой экземпляр не получит, пока не кончится аренда.
+	claimed, err := s.ClaimOutboxEvents(ctx, now, now.Add(time.Hour), 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, third.ID, claimed[0].Event.ID)
+	assert.Equal(t, first.UserID, claimed[0].Event.UserID)
+
+	c = &collect{}
+	assert.Equal(t, 1, relayOutbox(t, s, now, 10, c.handle))
+	assert.Equal(t, []string{fourth.ID}, c.seen, "failed and leased events wait")
+
+	c = &collect{}
+	assert.Equal(t, 2, relayOutbox(t, s, now.Add(2*time.Hour), 10, c.handle))
+	assert.Equal(t, []string{second.ID, third.ID}, c.seen)
+
+	// Мёртвое событие больше не забирается.
+	dead := NewDomainEvent(models.EventUserLoggedIn, user)
+	require.NoError(t, s.AppendOutboxEvent(ctx, dead))
+	claimed, err = s.ClaimOutboxEvents(ctx, now.Add(2*time.Hour), now.Add(3*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	deadAt := now.Add(2 * time.Hour)
+	claimed[0].Attempts++
+	claimed[0].LastError = "sink is down"
+	claimed[0].DeadAt = &deadAt
+	require.NoError(t, s.RecordOutboxResult(ctx, claimed[0]))
+	claimed, err = s.ClaimOutboxEvents(ctx, now.Add(4*time.Hour), now.Add(5*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	// Разосланные удаляются только после срока хранения.
+	deleted, err := s.DeletePublishedOutboxEvents(ctx, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+	deleted, err = s.DeletePublishedOutboxEvents(ctx, now.Add(5*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 4, deleted, "dead events are kept")
+}
+
+func TestOutbox(t *testing.T) {
+	runOutboxSuite(t, NewOutbox())
+}
+
+func TestDBStore_Outbox(t *testing.T) {
+	runOutboxSuite(t, newTestDBStore(t))
+}
+
+func TestDBStore_MutationsWriteOutbox(t *testing.T) {
+	s := newTestDBStore(t)
+	ctx := context.Background()
+
+	user, err := s.CreateUser(ctx, models.UserRegReq{Login: "anna", Password: "Str0ng-enough-pass"})
+	require.NoError(t, err)
+	_, err = s.SetUserRole(ctx, user.ID, models.UserRoleAdmin)
+	require.NoError(t, err)
+	_, err = s.DeleteUser(ctx, user.ID, "test")
+	require.NoError(t, err)
+	// Откатившееся изменение события не оставляет.
+	_, err = s.DeleteUser(ctx, user.ID, "test")
+	require.ErrorIs(t, err, ErrInvalidStatusTransition)
+
+	var types []models.DomainEventType
+	relayOutbox(t, s, time.Now().Add(time.Second), 10, func(_ context.Context, event models.DomainEvent) error {
+		assert.Equal(t, user.ID, event.UserID)
+		types = append(types, event.Type)
+		return nil
+	})
+	assert.Equal(t, []models.DomainEventType{
+		models.EventUserRegistered, models.EventUserRoleChanged, models.EventUserDeleted,
+	}, types)
+}
@@ -0,0 +1,244 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// NewArgon2Params собирает параметры хэширования из конфигурации сервера и проверяет их.
+func NewArgon2Params(c *server_config.ServerConfig) (Argon2Params, error) {
+	p := Argon2Params{
+		Memory:      c.Argon2Memory,
+		Iterations:  c.Argon2Iterations,
+		Parallelism: c.Argon2Parallelism,
+		SaltLength:  c.Argon2SaltLength,
+		KeyLength:   c.Argon2KeyLength,
+	}
+	if err := p.Validate(); err != nil {
+		return Argon2Params{}, err
+	}
+	return p, nil
+}
+
+// Validate проверяет, что с параметрами можно посчитать хэш.
+func (p Argon2Params) Validate() error {
+	if err := p.validateCost(); err != nil {
+		return err
+	}
+	switch {
+	case p.SaltLength < 8:
+		return errors.New("argon2 salt length must be at least 8 bytes")
+	case p.KeyLength < 16:
+		return errors.New("argon2 key length must be at least 16 bytes")
+	}
+	return nil
+}
+
+// validateCost проверяет параметры, от которых зависят память и время проверки пароля. Они действуют и на
+// импортированные хэши: иначе один хэш с огромным m занимал бы гигабайты при каждом входе пользователя.
+func (p Argon2Params) validateCost() error {
+	switch {
+	case p.Parallelism == 0:
+		return errors.New("argon2 parallelism must be positive")
+	case p.Parallelism > maxArgon2Parallelism:
+		return fmt.Errorf("argon2 parallelism must be at most %d", maxArgon2Parallelism)
+	case p.Iterations == 0:
+		return errors.New("argon2 iterations must be positive")
+	case p.Iterations > maxArgon2Iterations:
+		return fmt.Errorf("argon2 iterations must be at most %d", maxArgon2Iterations)
+	case p.Memory < 8*uint32(p.Parallelism):
+		return fmt.Errorf("argon2 memory must be at least %d KiB for parallelism %d", 8*uint32(p.Parallelism), p.Parallelism)
+	case p.Memory > maxArgon2Memory:
+		return fmt.Errorf("argon2 memory must be at most %d KiB", maxArgon2Memory)
+	}
+	return nil
+}
+
+func generateRandomBytes(length uint32) ([]byte, error) {
+	b := make([]byte, length)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return b, nil
+}
+
+// passwordHasher хэширует и проверяет пароли, не выходя за бюджет памяти на одновременные вычисления.
+type passwordHasher struct {
+	params  Argon2Params
+	limiter *hashLimiter
+	peppers *pepperRing
+}
+
+// newPasswordHasher собирает хэшер по конфигурации сервера.
+func newPasswordHasher(c *server_config.ServerConfig) (*passwordHasher, error) {
+	params, err := NewArgon2Params(c)
+	if err != nil {
+		return nil, err
+	}
+	peppers, err := newPepperRing(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load password peppers: %w", err)
+	}
+	h := &passwordHasher{params: params, peppers: peppers}
+	if c.HashMemoryBudgetMiB > 0 {
+		h.limiter = newHashLimiter(int64(c.HashMemoryBudgetMiB)*1024, c.HashQueueSize, c.HashQueueTimeout)
+	}
+	return h, nil
+}
+
+// hash хэширует пароль со случайной солью и возвращает хэш в закодированном виде вместе с солью.
+func (h *passwordHasher) hash(ctx context.Context, password string) (encodedHash, b64Salt string, err error) {
+	p := h.params
+	pepperID := h.peppers.current()
+	input, err := h.peppers.apply(pepperID, password)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Генерируем соль.
+	salt, err := generateRandomBytes(p.SaltLength)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	release, err := h.limiter.acquire(ctx, p.Memory)
+	if err != nil {
+		return "", "", err
+	}
+	// Хэшируем пароль с солью
+	hash := argon2.IDKey(input, salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	release()
+
+	// Подгатавливаем данные для сохранения
+	b64Salt = base64.RawStdEncoding.EncodeToString(salt)
+	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
+
+	// Идентификатор перца кладём в параметры, как keyid в формате PHC.
+	keyID := ""
+	if pepperID != "" {
+		keyID = ",keyid=" + pepperID
+	}
+	encodedHash = fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d%s$%s$%s",
+		argon2.Version,
+		p.Memory,
+		p.Iterations,
+		p.Parallelism,
+		keyID,
+		b64Salt, b64Hash)
+	return encodedHash, b64Salt, nil
+}
+
+// verify сверяет пароль с закодированным хэшем, параметры берутся из самого хэша.
+func (h *passwordHasher) verify(ctx context.Context, password, encodedHash string) (match bool, err error) {
+	if detectHashScheme(encodedHash) != schemeArgon2id {
+		return h.verifyLegacy(ctx, password, encodedHash)
+	}
+
+	// Распаковываем параметры из хэша
+	p, salt, hash, pepperID, err := decodeHash(encodedHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode hash: %w", err)
+	}
+	input, err := h.peppers.apply(pepperID, password)
+	if err != nil {
+		return false, err
+	}
+
+	release, err := h.limiter.acquire(ctx, p.Memory)
+	if err != nil {
+		return false, err
+	}
+	// Derive the key from the other password using the same parameters
+	otherHash := argon2.IDKey(input, salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	release()
+
+	// Check that the contents of the hashed passwords are identical
+	return subtle.ConstantTimeCompare(hash, otherHash) == 1, nil
+}
+
+// needsRehash сообщает, что хэш посчитан не с текущими параметрами или перцем и его стоит пересчитать.
+func (h *passwordHasher) needsRehash(encodedHash string) bool {
+	switch detectHashScheme(encodedHash) {
+	case schemeArgon2id:
+	case schemeUnknown:
+		return false
+	default:
+		// Унаследованные форматы всегда переводим на argon2id.
+		return true
+	}
+	p, _, _, pepperID, err := decodeHash(encodedHash)
+	if err != nil {
+		return false
+	}
+	return *p != h.params || pepperID != h.peppers.current()
+}
+
+// VerifyPassword сверяет пароль с хэшем пользователя тем же хэшером, которым хранилище считает новые хэши.
+func (d *DBStore) VerifyPassword(ctx context.Context, password, encodedHash string) (match bool, err error) {
+	return d.hasher.verify(ctx, password, encodedHash)
+}
+
+// NeedsRehash проверяет хэш после успешного логина: устаревший хэш пересчитывается из введённого пароля.
+func (d *DBStore) NeedsRehash(encodedHash string) bool {
+	return d.hasher.needsRehash(encodedHash)
+}
+
+func (m *MemStore) VerifyPassword(ctx context.Context, password, encodedHash string) (match bool, err error) {
+	return m.hasher.verify(ctx, password, encodedHash)
+}
+
+func (m *MemStore) NeedsRehash(encodedHash string) bool {
+	return m.hasher.needsRehash(encodedHash)
+}
+
+func decodeHash(encodedHash string) (p *Argon2Params, salt, hash []byte, pepperID string, err error) {
+	vals := strings.Split(encodedHash, "$")
+	if len(vals) != 6 || vals[1] != "argon2id" {
+		return nil, nil, nil, "", fmt.Errorf("invalid hash format")
+	}
+
+	var version int
+	_, err = fmt.Sscanf(vals[2], "v=%d", &version)
+	if err != nil {
+		return nil, nil, nil, "", fmt.Errorf("incompatible argon2 version: %w", err)
+	}
+
+	p = &Argon2Params{}
+	params, keyID, _ := strings.Cut(vals[3], ",keyid=")
+	_, err = fmt.Sscanf(params, "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
+	if err != nil {
+		return nil, nil, nil, "", fmt.Errorf("incompatible argon2 parameters: %w", err)
+	}
+	if err := p.validateCost(); err != nil {
+		return nil, nil, nil, "", fmt.Errorf("unsupported argon2 parameters: %w", err)
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(vals[4])
+	if err != nil {
+		return nil, nil, nil, "", fmt.Errorf("failed to decode salt: %w", err)
+	}
+	p.SaltLength = uint32(len(salt))
+
+	hash, err = base64.RawStdEncoding.DecodeString(vals[5])
+	if err != nil {
+		return nil, nil, nil, "", fmt.Errorf("failed to decode hash: %w", err)
+	}
+	p.KeyLength = uint32(len(hash))
+	return p, salt, hash, keyID, nil
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"time"
+)
+
+// CalibrationOptions - ограничения, под которые подбираются параметры argon2id.
+type CalibrationOptions struct {
+	TargetLatency time.Duration // Желаемое время вычисления одного хэша.
+	MaxMemory     uint32        // Бюджет памяти на один хэш в KiB.
+	Parallelism   uint8
+	SaltLength    uint32
+	KeyLength     uint32
+	Samples       int    // Сколько замеров усреднять для каждой пробы.
+	MaxIterations uint32 // Верхняя граница числа итераций.
+}
+
+// CalibrationResult - подобранные параметры и измеренное с ними время хэширования.
+type CalibrationResult struct {
+	Params  Argon2Params
+	Latency time.Duration
+}
+
+// measureArgon2 - функция замера, заведена переменной для подмены в тестах.
+var measureArgon2 = func(p Argon2Params, samples int) time.Duration {
+	password := []byte("calibration-password")
+	salt := make([]byte, p.SaltLength)
+	var total time.Duration
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+		argon2.IDKey(password, salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		total += time.Since(start)
+	}
+	return total / time.Duration(samples)
+}
+
+// CalibrateArgon2 подбирает параметры argon2id на текущей машине.
+// Сначала берётся максимальная память из бюджета: если даже одна итерация не укладывается в целевое время,
+// память уменьшается вдвое. Затем число итераций увеличивается, пока хэш укладывается в целевое время.
+func CalibrateArgon2(opts CalibrationOptions) (CalibrationResult, error) {
+	if opts.TargetLatency <= 0 {
+		return CalibrationResult{}, errors.New("target latency must be positive")
+	}
+	if opts.Samples <= 0 {
+		opts.Samples = 1
+	}
+	if opts.MaxIterations == 0 {
+		opts.MaxIterations = 64
+	}
+
+	p := Argon2Params{
+		Memory:      opts.MaxMemory,
+		Iterations:  1,
+		Parallelism: opts.Parallelism,
+		SaltLength:  opts.SaltLength,
+		KeyLength:   opts.KeyLength,
+	}
+	if err := p.Validate(); err != nil {
+		return CalibrationResult{}, fmt.Errorf("invalid calibration options: %w", err)
+	}
+	minMemory := 8 * uint32(p.Parallelism)
+
+	// Подбираем память под одну итерацию.
+	latency := measureArgon2(p, opts.Samples)
+	for latency > opts.TargetLatency && p.Memory/2 >= minMemory {
+		p.Memory /= 2
+		latency = measureArgon2(p, opts.Samples)
+	}
+	if latency > opts.TargetLatency {
+		return CalibrationResult{Params: p, Latency: latency},
+			fmt.Errorf("target latency %s is unreachable on this machine, minimal latency is %s", opts.TargetLatency, latency)
+	}
+
+	// Время растёт линейно от числа итераций, поэтому сначала оцениваем его, а затем уточняем замером.
+	if latency <= 0 {
+		latency = time.Nanosecond
+	}
+	estimate := uint32(opts.TargetLatency / latency)
+	if estimate > opts.MaxIterations {
+		estimate = opts.MaxIterations
+	}
+	for estimate > 1 {
+		candidate := p
+		candidate.Iterations = estimate
+		candidateLatency := measureArgon2(candidate, opts.Samples)
+		if candidateLatency <= opts.TargetLatency {
+			p, latency = candidate, candidateLatency
+			break
+		}
+		estimate--
+	}
+	return CalibrationResult{Params: p, Latency: latency}, nil
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"strconv"
+	"strings"
+)
+
+// Хэши, перенесённые из прежней системы. Они только проверяются, а после успешного логина
+// пересчитываются в argon2id (см. NeedsRehash).
+
+// hashScheme - формат закодированного хэша, определяется по префиксу.
+type hashScheme int
+
+const (
+	schemeUnknown hashScheme = iota
+	schemeArgon2id
+	schemeBcrypt
+	schemeScrypt       // $scrypt$ln=..,r=..,p=..$salt$hash (passlib)
+	schemePBKDF2SHA256 // $pbkdf2-sha256$rounds$salt$hash (passlib)
+	schemeDjangoPBKDF2 // pbkdf2_sha256$rounds$salt$hash (Django)
+)
+
+// Защита от хэшей с заведомо неподъёмными параметрами.
+const (
+	maxScryptLogN       = 22
+	maxPBKDF2Iterations = 10_000_000
+	// Бюджет памяти на хэширование один хэш не превысит: ограничитель пускает его одного, но целиком.
+	maxArgon2Memory      = 1 << 20 // KiB, то есть 1 GiB.
+	maxArgon2Iterations  = 64
+	maxArgon2Parallelism = 64
+)
+
+func detectHashScheme(encodedHash string) hashScheme {
+	switch {
+	case strings.HasPrefix(encodedHash, "$argon2id$"):
+		return schemeArgon2id
+	case strings.HasPrefix(encodedHash, "$2a$"), strings.HasPrefix(encodedHash, "$2b$"), strings.HasPrefix(encodedHash, "$2y$"):
+		return schemeBcrypt
+	case strings.HasPrefix(encodedHash, "$scrypt$"):
+		return schemeScrypt
+	case strings.HasPrefix(encodedHash, "$pbkdf2-sha256$"):
+		return schemePBKDF2SHA256
+	case strings.HasPrefix(encodedHash, "pbkdf2_sha256$"):
+		return schemeDjangoPBKDF2
+	}
+	return schemeUnknown
+}
+
+// ValidateHash проверяет, что хэш в поддерживаемом формате и его можно будет проверить при логине.
+func ValidateHash(encodedHash string) error {
+	var err error
+	switch detectHashScheme(encodedHash) {
+	case schemeArgon2id:
+		_, _, _, _, err = decodeHash(encodedHash)
+	case schemeBcrypt:
+		_, err = bcrypt.Cost([]byte(encodedHash))
+	case schemeScrypt:
+		_, err = decodeScryptHash(encodedHash)
+	case schemePBKDF2SHA256, schemeDjangoPBKDF2:
+		_, err = decodePBKDF2Hash(encodedHash)
+	default:
+		err = errors.New("unsupported hash format")
+	}
+	return err
+}
+
+// verifyLegacy проверяет пароль по хэшу одного из унаследованных форматов.
+func (h *passwordHasher) verifyLegacy(ctx context.Context, password, encodedHash string) (match bool, err error) {
+	switch detectHashScheme(encodedHash) {
+	case schemeBcrypt:
+		// bcrypt расходует около 4 KiB.
+		release, err := h.limiter.acquire(ctx, 4)
+		if err != nil {
+			return false, err
+		}
+		defer release()
+		err = bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to verify bcrypt hash: %w", err)
+		}
+		return true, nil
+
+	case schemeScrypt:
+		s, err := decodeScryptHash(encodedHash)
+		if err != nil {
+			return false, fmt.Errorf("failed to decode hash: %w", err)
+		}
+		release, err := h.limiter.acquire(ctx, uint32(128*s.n*s.r/1024))
+		if err != nil {
+			return false, err
+		}
+		defer release()
+		otherHash, err := scrypt.Key([]byte(password), s.salt, s.n, s.r, s.p, len(s.hash))
+		if err != nil {
+			return false, fmt.Errorf("failed to compute scrypt hash: %w", err)
+		}
+		return subtle.ConstantTimeCompare(s.hash, otherHash) == 1, nil
+
+	case schemePBKDF2SHA256, schemeDjangoPBKDF2:
+		p, err := decodePBKDF2Hash(encodedHash)
+		if err != nil {
+			return false, fmt.Errorf("failed to decode hash: %w", err)
+		}
+		otherHash := pbkdf2.Key([]byte(password), p.salt, p.iterations, len(p.hash), sha256.New)
+		return subtle.ConstantTimeCompare(p.hash, otherHash) == 1, nil
+	}
+	return false, errors.New("unsupported hash format")
+}
+
+type scryptHash struct {
+	n, r, p    int
+	salt, hash []byte
+}
+
+func decodeScryptHash(encodedHash string) (*scryptHash, error) {
+	vals := strings.Split(encodedHash, "$")
+	if len(vals) != 5 {
+		return nil, errors.New("invalid scrypt hash format")
+	}
+	var logN, r, p int
+	if _, err := fmt.Sscanf(vals[2], "ln=%d,r=%d,p=%d", &logN, &r, &p); err != nil {
+		return nil, fmt.Errorf("incompatible scrypt parameters: %w", err)
+	}
+	if logN < 1 || logN > maxScryptLogN || r < 1 || p < 1 {
+		return nil, errors.New("scrypt parameters out of range")
+	}
+	salt, err := decodeAB64(vals[3])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode salt: %w", err)
+	}
+	hash, err := decodeAB64(vals[4])
+	if err != nil || len(hash) == 0 {
+		return nil, fmt.Errorf("failed to decode hash: %w", err)
+	}
+	return &scryptHash{n: 1 << logN, r: r, p: p, salt: salt, hash: hash}, nil
+}
+
+type pbkdf2Hash struct {
+	iterations int
+	salt, hash []byte
+}
+
+func decodePBKDF2Hash(encodedHash string) (*pbkdf2Hash, error) {
+	// passlib: $pbkdf2-sha256$rounds$salt$hash, Django: pbkdf2_sha256$rounds$salt$hash.
+	django := detectHashScheme(encodedHash) == schemeDjangoPBKDF2
+	vals := strings.Split(strings.TrimPrefix(encodedHash, "$"), "$")
+	if len(vals) != 4 {
+		return nil, errors.New("invalid pbkdf2 hash format")
+	}
+	iterations, err := strconv.Atoi(vals[1])
+	if err != nil || iterations < 1 || iterations > maxPBKDF2Iterations {
+		return nil, errors.New("pbkdf2 iterations out of range")
+	}
+	h := &pbkdf2Hash{iterations: iterations}
+	if django {
+		// Django хранит соль как есть, а хэш в обычном base64.
+		h.salt = []byte(vals[2])
+		h.hash, err = base64.StdEncoding.DecodeString(vals[3])
+	} else {
+		h.salt, err = decodeAB64(vals[2])
+		if err == nil {
+			h.hash, err = decodeAB64(vals[3])
+		}
+	}
+	if err != nil || len(h.hash) == 0 {
+		return nil, fmt.Errorf("failed to decode pbkdf2 hash: %w", err)
+	}
+	return h, nil
+}
+
+// decodeAB64 декодирует «adapted base64» из passlib: «.» вместо «+» и без выравнивания.
+func decodeAB64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.ReplaceAll(s, ".", "+"))
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"strings"
+	"testing"
+)
+
+func encodeAB64(b []byte) string {
+	return strings.ReplaceAll(base64.RawStdEncoding.EncodeToString(b), "+", ".")
+}
+
+func legacyHashes(t *testing.T, password string) map[string]string {
+	t.Helper()
+	salt := []byte("legacy-salt-1234")
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	scryptKey, err := scrypt.Key([]byte(password), salt, 1<<4, 8, 1, 32)
+	require.NoError(t, err)
+
+	pbkdf2Key := pbkdf2.Key([]byte(password), salt, 1000, 32, sha256.New)
+
+	return map[string]string{
+		"bcrypt":        string(bcryptHash),
+		"scrypt":        fmt.Sprintf("$scrypt$ln=4,r=8,p=1$%s$%s", encodeAB64(salt), encodeAB64(scryptKey)),
+		"pbkdf2-sha256": fmt.Sprintf("$pbkdf2-sha256$1000$%s$%s", encodeAB64(salt), encodeAB64(pbkdf2Key)),
+		"django":        fmt.Sprintf("pbkdf2_sha256$1000$%s$%s", salt, base64.StdEncoding.EncodeToString(pbkdf2Key)),
+	}
+}
+
+func TestVerifyLegacyHashes(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHasher(t, &server_config.ServerConfig{})
+
+	for name, encodedHash := range legacyHashes(t, "old-secret") {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, ValidateHash(encodedHash))
+
+			match, err := h.verify(ctx, "old-secret", encodedHash)
+			require.NoError(t, err)
+			assert.True(t, match)
+
+			match, err = h.verify(ctx, "wrong", encodedHash)
+			require.NoError(t, err)
+			assert.False(t, match)
+
+			// После успешного логина хэш переводится на argon2id.
+			assert.True(t, h.needsRehash(encodedHash))
+		})
+	}
+}
+
+func TestValidateHash(t *testing.T) {
+	tests := []struct {
+		name        string
+		encodedHash string
+	}{
+		{"unknown format", "$md5$abc"},
+		{"plain text", "password"},
+		{"broken bcrypt", "$2b$10$short"},
+		{"scrypt with huge N", "$scrypt$ln=40,r=8,p=1$c2FsdA$aGFzaA"},
+		{"pbkdf2 without rounds", "$pbkdf2-sha256$x$c2FsdA$aGFzaA"},
+		{"truncated argon2", "$argon2id$v=19$m=16,t=1,p=1$c2FsdA"},
+		{"argon2 with huge memory", "$argon2id$v=19$m=4194304,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA"},
+		{"argon2 with huge iterations", "$argon2id$v=19$m=65536,t=100000,p=1$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA"},
+		{"argon2 with huge parallelism", "$argon2id$v=19$m=65536,t=1,p=255$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA"},
+		{"argon2 without parallelism", "$argon2id$v=19$m=65536,t=1,p=0$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, ValidateHash(tt.encodedHash))
+		})
+	}
+}
+
+// Хэш с параметрами за пределами ограничений не импортируется: проверка пароля по нему заняла бы
+// гигабайты памяти при каждом входе.
+func TestImportUser_CostlyArgon2(t *testing.T) {
+	costly := models.User{Login: "anna", PasswordHash: "$argon2id$v=19$m=4194304,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA"}
+	stores := map[string]Store{"memory": newTestMemStore(t, ""), "sqlite": newTestSQLiteStore(t)}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			imported, err := s.ImportUser(context.Background(), costly)
+			assert.ErrorContains(t, err, "argon2 memory must be at most")
+			assert.False(t, imported)
+			_, err = s.GetUserByLogin(context.Background(), models.UserLoginReq{Login: "anna"})
+			assert.ErrorIs(t, err, ErrUserNotFound)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"golang.org/x/sync/semaphore"
+	"sync/atomic"
+	"time"
+)
+
+// ErrHashingOverloaded - очередь на хэширование паролей переполнена или ожидание в ней истекло.
+var ErrHashingOverloaded = errors.New("password hashing is overloaded")
+
+// hashMetrics - метрики очереди хэширования, отдаются через /debug/vars.
+var hashMetrics = expvar.NewMap("password_hashing")
+
+// hashLimiter ограничивает суммарную память, которую одновременно занимают вычисления argon2.
+// Каждое вычисление занимает в семафоре столько единиц, сколько KiB памяти ему нужно.
+type hashLimiter struct {
+	sem      *semaphore.Weighted
+	budget   int64 // KiB
+	maxQueue int64
+	timeout  time.Duration
+	waiting  atomic.Int64
+}
+
+func newHashLimiter(budgetKiB int64, maxQueue int, timeout time.Duration) *hashLimiter {
+	return &hashLimiter{
+		sem:      semaphore.NewWeighted(budgetKiB),
+		budget:   budgetKiB,
+		maxQueue: int64(maxQueue),
+		timeout:  timeout,
+	}
+}
+
+// acquire резервирует память под одно вычисление хэша и возвращает функцию освобождения.
+// Если очередь заполнена или ожидание превысило таймаут, возвращает ErrHashingOverloaded.
+func (l *hashLimiter) acquire(ctx context.Context, memoryKiB uint32) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	// Хэш дороже всего бюджета всё равно должен иметь шанс посчитаться, пусть и в одиночку.
+	weight := min(max(int64(memoryKiB), 1), l.budget)
+
+	if l.sem.TryAcquire(weight) {
+		return l.releaseFunc(weight), nil
+	}
+
+	if l.waiting.Add(1) > l.maxQueue {
+		l.waiting.Add(-1)
+		hashMetrics.Add("rejected_total", 1)
+		return nil, ErrHashingOverloaded
+	}
+	hashMetrics.Add("queue_depth", 1)
+	start := time.Now()
+
+	waitCtx, cancel := context.WithTimeout(ctx, l.timeout)
+	err = l.sem.Acquire(waitCtx, weight)
+	cancel()
+
+	l.waiting.Add(-1)
+	hashMetrics.Add("queue_depth", -1)
+	hashMetrics.Add("waits_total", 1)
+	hashMetrics.AddFloat("wait_seconds_total", time.Since(start).Seconds())
+
+	if err != nil {
+		// Клиент ушёл сам - это не перегрузка.
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		hashMetrics.Add("rejected_total", 1)
+		return nil, ErrHashingOverloaded
+	}
+	return l.releaseFunc(weight), nil
+}
+
+func (l *hashLimiter) releaseFunc(weight int64) func() {
+	hashMetrics.Add("in_flight_kib", weight)
+	return func() {
+		hashMetrics.Add("in_flight_kib", -weight)
+		l.sem.Release(weight)
+	}
+}
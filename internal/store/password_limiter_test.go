@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestHashLimiter(t *testing.T) {
+	t.Run("nil limiter does not limit", func(t *testing.T) {
+		var l *hashLimiter
+		release, err := l.acquire(context.Background(), 64*1024)
+		require.NoError(t, err)
+		release()
+	})
+
+	t.Run("waits for released memory", func(t *testing.T) {
+		l := newHashLimiter(100, 1, time.Second)
+		release, err := l.acquire(context.Background(), 100)
+		require.NoError(t, err)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			release()
+		}()
+		release2, err := l.acquire(context.Background(), 100)
+		require.NoError(t, err)
+		release2()
+	})
+
+	t.Run("times out in queue", func(t *testing.T) {
+		l := newHashLimiter(100, 1, 10*time.Millisecond)
+		release, err := l.acquire(context.Background(), 100)
+		require.NoError(t, err)
+		defer release()
+
+		_, err = l.acquire(context.Background(), 50)
+		assert.ErrorIs(t, err, ErrHashingOverloaded)
+	})
+
+	t.Run("rejects when queue is full", func(t *testing.T) {
+		l := newHashLimiter(100, 0, time.Second)
+		release, err := l.acquire(context.Background(), 100)
+		require.NoError(t, err)
+		defer release()
+
+		start := time.Now()
+		_, err = l.acquire(context.Background(), 50)
+		assert.ErrorIs(t, err, ErrHashingOverloaded)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("hash bigger than budget still runs alone", func(t *testing.T) {
+		l := newHashLimiter(100, 1, time.Second)
+		release, err := l.acquire(context.Background(), 1000)
+		require.NoError(t, err)
+		release()
+	})
+
+	t.Run("cancelled client is not an overload", func(t *testing.T) {
+		l := newHashLimiter(100, 1, time.Second)
+		release, err := l.acquire(context.Background(), 100)
+		require.NoError(t, err)
+		defer release()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = l.acquire(ctx, 50)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"os"
+	"strings"
+)
+
+// ErrUnknownPepper - хэш посчитан с перцем, которого нет в конфигурации, проверить пароль нельзя.
+var ErrUnknownPepper = errors.New("password hash uses an unknown pepper")
+
+// minPepperLength - перец короче 16 байт почти не добавляет стойкости.
+const minPepperLength = 16
+
+// pepperRing - набор серверных секретов («перцев»), которые подмешиваются в пароль перед argon2.
+// Перцы хранятся только в конфигурации, в БД попадает лишь идентификатор перца в составе хэша.
+// Новые хэши считаются с текущим перцем, старые проверяются тем, с которым были посчитаны.
+type pepperRing struct {
+	currentID string
+	secrets   map[string][]byte
+}
+
+// newPepperRing собирает перцы из конфигурации: текущий задаётся напрямую, прежние - файлом.
+func newPepperRing(c *server_config.ServerConfig) (*pepperRing, error) {
+	ring := &pepperRing{secrets: make(map[string][]byte)}
+	if c.PepperFile != "" {
+		if err := ring.loadFile(c.PepperFile); err != nil {
+			return nil, err
+		}
+	}
+	if c.Pepper != "" {
+		if err := ring.add(c.PepperID, c.Pepper); err != nil {
+			return nil, err
+		}
+	}
+	if c.PepperID != "" {
+		if _, ok := ring.secrets[c.PepperID]; !ok {
+			return nil, fmt.Errorf("current pepper %q is not configured", c.PepperID)
+		}
+		ring.currentID = c.PepperID
+	}
+	return ring, nil
+}
+
+// loadFile читает файл вида "<id>:<secret>" по строке на перец, пустые строки и строки с # пропускаются.
+func (r *pepperRing) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open pepper file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, secret, ok := strings.Cut(line, ":")
+		if !ok {
+			return fmt.Errorf("pepper file line %d: expected <id>:<secret>", lineNum)
+		}
+		if err := r.add(strings.TrimSpace(id), strings.TrimSpace(secret)); err != nil {
+			return fmt.Errorf("pepper file line %d: %w", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read pepper file: %w", err)
+	}
+	return nil
+}
+
+func (r *pepperRing) add(id, secret string) error {
+	if id == "" || strings.ContainsAny(id, "$,=: \t") {
+		return fmt.Errorf("invalid pepper id %q", id)
+	}
+	if len(secret) < minPepperLength {
+		return fmt.Errorf("pepper %q must be at least %d bytes long", id, minPepperLength)
+	}
+	if existing, ok := r.secrets[id]; ok && string(existing) != secret {
+		return fmt.Errorf("pepper %q is configured twice with different secrets", id)
+	}
+	r.secrets[id] = []byte(secret)
+	return nil
+}
+
+// apply подмешивает перец в пароль. Пустой id означает хэш без перца.
+func (r *pepperRing) apply(id, password string) ([]byte, error) {
+	if id == "" {
+		return []byte(password), nil
+	}
+	if r == nil {
+		return nil, ErrUnknownPepper
+	}
+	secret, ok := r.secrets[id]
+	if !ok {
+		return nil, ErrUnknownPepper
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(password))
+	return mac.Sum(nil), nil
+}
+
+func (r *pepperRing) current() string {
+	if r == nil {
+		return ""
+	}
+	return r.currentID
+}
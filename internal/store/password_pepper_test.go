@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestHasher(t *testing.T, c *server_config.ServerConfig) *passwordHasher {
+	t.Helper()
+	c.Argon2Memory = testArgon2Params.Memory
+	c.Argon2Iterations = testArgon2Params.Iterations
+	c.Argon2Parallelism = testArgon2Params.Parallelism
+	c.Argon2SaltLength = testArgon2Params.SaltLength
+	c.Argon2KeyLength = testArgon2Params.KeyLength
+	h, err := newPasswordHasher(c)
+	require.NoError(t, err)
+	return h
+}
+
+func TestPepperedHash(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHasher(t, &server_config.ServerConfig{PepperID: "k1", Pepper: "first-pepper-secret"})
+
+	encodedHash, _, err := h.hash(ctx, "secret")
+	require.NoError(t, err)
+	assert.Contains(t, encodedHash, ",keyid=k1$")
+	assert.False(t, h.needsRehash(encodedHash))
+
+	match, err := h.verify(ctx, "secret", encodedHash)
+	require.NoError(t, err)
+	assert.True(t, match)
+
+	// Без перца тот же пароль не подходит: утёкшей таблицы users недостаточно.
+	unpeppered := newTestHasher(t, &server_config.ServerConfig{})
+	_, err = unpeppered.verify(ctx, "secret", encodedHash)
+	assert.ErrorIs(t, err, ErrUnknownPepper)
+}
+
+func TestPepperRotation(t *testing.T) {
+	ctx := context.Background()
+	legacy := newTestHasher(t, &server_config.ServerConfig{})
+	old := newTestHasher(t, &server_config.ServerConfig{PepperID: "k1", Pepper: "first-pepper-secret"})
+
+	legacyHash, _, err := legacy.hash(ctx, "secret")
+	require.NoError(t, err)
+	oldHash, _, err := old.hash(ctx, "secret")
+	require.NoError(t, err)
+
+	pepperFile := filepath.Join(t.TempDir(), "peppers")
+	require.NoError(t, os.WriteFile(pepperFile, []byte("# retired\nk1:first-pepper-secret\n"), 0o600))
+	current := newTestHasher(t, &server_config.ServerConfig{
+		PepperID:   "k2",
+		Pepper:     "second-pepper-secret",
+		PepperFile: pepperFile,
+	})
+
+	for _, encodedHash := range []string{legacyHash, oldHash} {
+		match, err := current.verify(ctx, "secret", encodedHash)
+		require.NoError(t, err)
+		assert.True(t, match)
+		assert.True(t, current.needsRehash(encodedHash))
+	}
+
+	newHash, _, err := current.hash(ctx, "secret")
+	require.NoError(t, err)
+	assert.Contains(t, newHash, ",keyid=k2$")
+	assert.False(t, current.needsRehash(newHash))
+}
+
+func TestNewPepperRingErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		conf server_config.ServerConfig
+	}{
+		{"pepper without id", server_config.ServerConfig{Pepper: "first-pepper-secret"}},
+		{"short pepper", server_config.ServerConfig{PepperID: "k1", Pepper: "short"}},
+		{"id without pepper", server_config.ServerConfig{PepperID: "k1"}},
+		{"invalid id", server_config.ServerConfig{PepperID: "k$1", Pepper: "first-pepper-secret"}},
+		{"missing file", server_config.ServerConfig{PepperFile: "/nonexistent/peppers"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPepperRing(&tt.conf)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// Облегчённые параметры, чтобы тесты не тратили по 64 MB на хэш.
+var testArgon2Params = Argon2Params{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestHashAndVerifyPassword(t *testing.T) {
+	h := &passwordHasher{params: testArgon2Params}
+	encodedHash, b64Salt, err := h.hash(context.Background(), "secret")
+	require.NoError(t, err)
+	assert.Contains(t, encodedHash, "$argon2id$v=19$m=64,t=1,p=1$"+b64Salt+"$")
+
+	match, err := h.verify(context.Background(), "secret", encodedHash)
+	require.NoError(t, err)
+	assert.True(t, match)
+
+	match, err = h.verify(context.Background(), "other", encodedHash)
+	require.NoError(t, err)
+	assert.False(t, match)
+
+	_, err = h.verify(context.Background(), "secret", "not-a-hash")
+	assert.Error(t, err)
+}
+
+func TestNewArgon2Params(t *testing.T) {
+	tests := []struct {
+		name      string
+		conf      server_config.ServerConfig
+		wantError bool
+	}{
+		{
+			name: "valid params",
+			conf: server_config.ServerConfig{
+				Argon2Memory: 64 * 1024, Argon2Iterations: 3, Argon2Parallelism: 2, Argon2SaltLength: 16, Argon2KeyLength: 32,
+			},
+		},
+		{
+			name: "zero parallelism",
+			conf: server_config.ServerConfig{
+				Argon2Memory: 64 * 1024, Argon2Iterations: 3, Argon2SaltLength: 16, Argon2KeyLength: 32,
+			},
+			wantError: true,
+		},
+		{
+			name: "not enough memory for parallelism",
+			conf: server_config.ServerConfig{
+				Argon2Memory: 8, Argon2Iterations: 3, Argon2Parallelism: 2, Argon2SaltLength: 16, Argon2KeyLength: 32,
+			},
+			wantError: true,
+		},
+		{
+			name: "short salt",
+			conf: server_config.ServerConfig{
+				Argon2Memory: 64 * 1024, Argon2Iterations: 3, Argon2Parallelism: 2, Argon2SaltLength: 4, Argon2KeyLength: 32,
+			},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewArgon2Params(&tt.conf)
+			if tt.wantError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.conf.Argon2Memory, p.Memory)
+			assert.Equal(t, tt.conf.Argon2Iterations, p.Iterations)
+		})
+	}
+}
+
+func TestCalibrateArgon2(t *testing.T) {
+	// Модель времени: на итерацию 1 мс за каждые 1024 KiB памяти плюс 1 мс накладных расходов.
+	originalMeasure := measureArgon2
+	defer func() { measureArgon2 = originalMeasure }()
+	measureArgon2 = func(p Argon2Params, samples int) time.Duration {
+		return time.Duration(p.Iterations) * time.Duration(p.Memory/1024+1) * time.Millisecond
+	}
+
+	tests := []struct {
+		name           string
+		opts           CalibrationOptions
+		wantMemory     uint32
+		wantIterations uint32
+		wantError      bool
+	}{
+		{
+			name:           "iterations grow up to target",
+			opts:           CalibrationOptions{TargetLatency: 200 * time.Millisecond, MaxMemory: 64 * 1024},
+			wantMemory:     64 * 1024,
+			wantIterations: 3,
+		},
+		{
+			name:           "memory shrinks when one iteration is too slow",
+			opts:           CalibrationOptions{TargetLatency: 20 * time.Millisecond, MaxMemory: 64 * 1024},
+			wantMemory:     16 * 1024,
+			wantIterations: 1,
+		},
+		{
+			name:           "iterations are capped",
+			opts:           CalibrationOptions{TargetLatency: time.Second, MaxMemory: 1024, MaxIterations: 10},
+			wantMemory:     1024,
+			wantIterations: 10,
+		},
+		{
+			name:      "unreachable target",
+			opts:      CalibrationOptions{TargetLatency: time.Nanosecond, MaxMemory: 64 * 1024},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Parallelism = 1
+			tt.opts.SaltLength = 16
+			tt.opts.KeyLength = 32
+			result, err := CalibrateArgon2(tt.opts)
+			if tt.wantError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMemory, result.Params.Memory)
+			assert.Equal(t, tt.wantIterations, result.Params.Iterations)
+			assert.LessOrEqual(t, result.Latency, tt.opts.TargetLatency)
+		})
+	}
+}
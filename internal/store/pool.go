@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sync/atomic"
+	"time"
+)
+
+// pingTimeout - сколько ждём ответа базы на одну попытку при запуске.
+const pingTimeout = 5 * time.Second
+
+// currentPool - пул, чья статистика отдаётся через /debug/vars.
+var currentPool atomic.Pointer[pgxpool.Pool]
+
+func init() {
+	expvar.Publish("db_pool", expvar.Func(func() any {
+		pool := currentPool.Load()
+		if pool == nil {
+			return nil
+		}
+		return newPoolStats(pool.Stat())
+	}))
+}
+
+// PoolStats - снимок состояния пула соединений для метрик.
+type PoolStats struct {
+	MaxConns                int32 `json:"max_conns"`
+	TotalConns              int32 `json:"total_conns"`
+	IdleConns               int32 `json:"idle_conns"`
+	AcquiredConns           int32 `json:"acquired_conns"`
+	ConstructingConns       int32 `json:"constructing_conns"`
+	AcquireCount            int64 `json:"acquire_count"`
+	AcquireDurationMs       int64 `json:"acquire_duration_ms"`
+	EmptyAcquireCount       int64 `json:"empty_acquire_count"`
+	CanceledAcquireCount    int64 `json:"canceled_acquire_count"`
+	NewConnsCount           int64 `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64 `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64 `json:"max_idle_destroy_count"`
+}
+
+func newPoolStats(s *pgxpool.Stat) PoolStats {
+	return PoolStats{
+		MaxConns:                s.MaxConns(),
+		TotalConns:              s.TotalConns(),
+		IdleConns:               s.IdleConns(),
+		AcquiredConns:           s.AcquiredConns(),
+		ConstructingConns:       s.ConstructingConns(),
+		AcquireCount:            s.AcquireCount(),
+		AcquireDurationMs:       s.AcquireDuration().Milliseconds(),
+		EmptyAcquireCount:       s.EmptyAcquireCount(),
+		CanceledAcquireCount:    s.CanceledAcquireCount(),
+		NewConnsCount:           s.NewConnsCount(),
+		MaxLifetimeDestroyCount: s.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     s.MaxIdleDestroyCount(),
+	}
+}
+
+// newPoolConfig разбирает строку подключения и применяет к ней заданные настройки пула.
+// Нулевые значения оставляют умолчания pgxpool.
+func newPoolConfig(c *server_config.ServerConfig) (*pgxpool.Config, error) {
+	config, err := pgxpool.ParseConfig(c.DBDSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DB connection string: %w", err)
+	}
+	if c.DBMaxConns > 0 {
+		config.MaxConns = int32(c.DBMaxConns)
+	}
+	if c.DBMinConns > 0 {
+		config.MinConns = int32(c.DBMinConns)
+	}
+	if config.MinConns > config.MaxConns {
+		return nil, fmt.Errorf("db min conns %d exceeds max conns %d", config.MinConns, config.MaxConns)
+	}
+	if c.DBMaxConnLifetime > 0 {
+		config.MaxConnLifetime = c.DBMaxConnLifetime
+	}
+	if c.DBMaxConnIdleTime > 0 {
+		config.MaxConnIdleTime = c.DBMaxConnIdleTime
+	}
+	if c.DBHealthCheckPeriod > 0 {
+		config.HealthCheckPeriod = c.DBHealthCheckPeriod
+	}
+	return config, nil
+}
+
+// pinger - то, что проверяется при запуске; *pgxpool.Pool.
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// pingWithRetry проверяет доступность базы до attempts раз. После первой неудачи ждёт backoff,
+// дальше пауза удваивается. Возвращает последнюю ошибку, если база так и не ответила.
+func pingWithRetry(ctx context.Context, p pinger, attempts int, backoff time.Duration) error {
+	attempts = max(attempts, 1)
+	var err error
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err = p.Ping(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			return fmt.Errorf("DB is unreachable after %d attempts: %w", attempts, err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("DB ping interrupted: %w", err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// rateLimitCounter - счётчики текущего и предыдущего окна одного ключа.
+type rateLimitCounter struct {
+	windowStart time.Time
+	current     int
+	previous    int
+}
+
+// RateLimitCounters - счётчики ограничения частоты в памяти, для одного экземпляра сервера.
+type RateLimitCounters struct {
+	mu       sync.Mutex
+	counters map[string]rateLimitCounter
+}
+
+func NewRateLimitCounters() *RateLimitCounters {
+	return &RateLimitCounters{counters: make(map[string]rateLimitCounter)}
+}
+
+// IncrementRateLimit засчитывает запрос в окно, начавшееся в windowStart, и возвращает число запросов
+// в этом окне и в предыдущем. Окно длиной window, более старые окна забываются.
+func (c *RateLimitCounters) IncrementRateLimit(_ context.Context, key string, windowStart time.Time, window time.Duration) (int, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counter := c.counters[key]
+	counter = shiftRateLimitCounter(counter, windowStart, window)
+	counter.current++
+	c.counters[key] = counter
+	return counter.current, counter.previous, nil
+}
+
+// DeleteStaleRateLimits забывает ключи, окно которых началось раньше before.
+func (c *RateLimitCounters) DeleteStaleRateLimits(_ context.Context, before time.Time) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	deleted := 0
+	for key, counter := range c.counters {
+		if counter.windowStart.Before(before) {
+			delete(c.counters, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// shiftRateLimitCounter переносит счётчик в окно windowStart: прошлое окно становится предыдущим,
+// а если с него прошло больше одного окна, счёт начинается с нуля. Запрос из окна старше сохранённого
+// (часы экземпляров расходятся или запрос пришёл на границе окон) засчитывается в сохранённое окно:
+// если бы он сбрасывал счёт, лимит обходился бы запросами на разные экземпляры.
+func shiftRateLimitCounter(counter rateLimitCounter, windowStart time.Time, window time.Duration) rateLimitCounter {
+	switch {
+	case !counter.windowStart.Before(windowStart):
+		return counter
+	case counter.windowStart.Equal(windowStart.Add(-window)):
+		return rateLimitCounter{windowStart: windowStart, previous: counter.current}
+	default:
+		return rateLimitCounter{windowStart: windowStart}
+	}
+}
+
+// RateLimitBackend - счётчики ограничения частоты запросов.
+type RateLimitBackend interface {
+	IncrementRateLimit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (current, previous int, err error)
+	DeleteStaleRateLimits(ctx context.Context, before time.Time) (deleted int, err error)
+}
+
+// NewRateLimitBackend выбирает, где считать запросы: memory - в памяти экземпляра, postgres - в общем хранилище s.
+func NewRateLimitBackend(kind string, s Store) (RateLimitBackend, error) {
+	switch kind {
+	case "", "memory":
+		return NewRateLimitCounters(), nil
+	case "postgres":
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", kind)
+	}
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// rateLimitStore - общее у RateLimitCounters и DBStore.
+type rateLimitStore interface {
+	IncrementRateLimit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int, int, error)
+	DeleteStaleRateLimits(ctx context.Context, before time.Time) (int, error)
+}
+
+func runRateLimitSuite(t *testing.T, s rateLimitStore) {
+	ctx := context.Background()
+	window := time.Minute
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	increment := func(key string, windowStart time.Time) (int, int) {
+		current, previous, err := s.IncrementRateLimit(ctx, key, windowStart, window)
+		require.NoError(t, err)
+		return current, previous
+	}
+
+	for i := 1; i <= 3; i++ {
+		current, previous := increment("login:ip:a", start)
+		assert.Equal(t, i, current)
+		assert.Zero(t, previous)
+	}
+	current, _ := increment("login:ip:b", start)
+	assert.Equal(t, 1, current, "keys are counted separately")
+
+	// Следующее окно помнит предыдущее, а через окно счёт начинается заново.
+	current, previous := increment("login:ip:a", start.Add(window))
+	assert.Equal(t, 1, current)
+	assert.Equal(t, 3, previous)
+	current, previous = increment("login:ip:a", start.Add(3*window))
+	assert.Equal(t, 1, current)
+	assert.Zero(t, previous)
+
+	// Запрос с экземпляра, чьи часы отстают, попадает в уже начатое окно, а не сбрасывает счёт.
+	current, previous = increment("login:ip:a", start.Add(2*window))
+	assert.Equal(t, 2, current)
+	assert.Zero(t, previous)
+	current, _ = increment("login:ip:a", start.Add(3*window))
+	assert.Equal(t, 3, current, "the window did not move back")
+
+	deleted, err := s.DeleteStaleRateLimits(ctx, start.Add(window))
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	current, previous = increment("login:ip:b", start.Add(window))
+	assert.Equal(t, 1, current)
+	assert.Zero(t, previous, "deleted counters start over")
+}
+
+func TestRateLimitCounters(t *testing.T) {
+	runRateLimitSuite(t, NewRateLimitCounters())
+}
+
+func TestSQLiteStore_RateLimits(t *testing.T) {
+	runRateLimitSuite(t, newTestSQLiteStore(t))
+}
+
+func TestDBStore_RateLimits(t *testing.T) {
+	s := newTestDBStore(t)
+	_, err := s.dbConn.ExecContext(context.Background(), `TRUNCATE rate_limits`)
+	require.NoError(t, err)
+	runRateLimitSuite(t, s)
+}
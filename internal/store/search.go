@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/login_policy"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Поиск пользователей по логину, отображаемому имени и почте. Релевантность считается как в pg_trgm:
+// доля общих триграмм, лучшая из трёх полей, плюс единица, если логин начинается с запроса.
+// Подходят пользователи, у которых сходство хотя бы одного поля не ниже порога или поле содержит запрос.
+// UserIndex повторяет те же правила в памяти, чтобы обе реализации проходили один набор тестов.
+
+// ErrSearchQueryTooShort - по одному символу искать бессмысленно, триграмм не хватит.
+var ErrSearchQueryTooShort = errors.New("search query is too short")
+
+const (
+	minSearchQueryLength = 2
+	// similarityThreshold - значение pg_trgm.similarity_threshold по умолчанию, его использует оператор %.
+	similarityThreshold float32 = 0.3
+	loginPrefixBoost    float32 = 1
+)
+
+// normalizeSearchQuery приводит запрос к той же форме, что и логины в login_normalized.
+func normalizeSearchQuery(query string) (string, error) {
+	q := login_policy.Normalize(query)
+	if utf8.RuneCountInString(q) < minSearchQueryLength {
+		return "", ErrSearchQueryTooShort
+	}
+	return q, nil
+}
+
+// trigrams разбивает строку на триграммы так же, как pg_trgm: слова из букв и цифр
+// дополняются двумя пробелами в начале и одним в конце.
+func trigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+// similarity - аналог similarity() из pg_trgm.
+func similarity(a, b map[string]struct{}) float32 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range a {
+		if _, ok := b[t]; ok {
+			common++
+		}
+	}
+	return float32(common) / float32(len(a)+len(b)-common)
+}
+
+// searchRank возвращает релевантность пользователя и подходит ли он под запрос.
+func searchRank(user models.User, q string, qTrigrams map[string]struct{}) (rank float32, match bool) {
+	fields := []string{
+		login_policy.Normalize(user.Login),
+		strings.ToLower(user.DisplayName),
+		strings.ToLower(user.Email),
+	}
+	for _, field := range fields {
+		sim := similarity(trigrams(field), qTrigrams)
+		if sim > rank {
+			rank = sim
+		}
+		if sim >= similarityThreshold || strings.Contains(field, q) {
+			match = true
+		}
+	}
+	if strings.HasPrefix(fields[0], q) {
+		rank += loginPrefixBoost
+	}
+	return rank, match
+}
+
+// after сообщает, что результат идёт в выдаче строго после курсора.
+func after(result models.UserSearchResult, cursor *models.SearchCursor) bool {
+	if cursor == nil {
+		return true
+	}
+	return result.Rank < cursor.Rank || (result.Rank == cursor.Rank && result.User.ID > cursor.ID)
+}
+
+// UserIndex - поиск пользователей в памяти, для хранилищ без pg_trgm и для тестов.
+type UserIndex struct {
+	mu    sync.RWMutex
+	users map[int]models.User
+}
+
+func NewUserIndex() *UserIndex {
+	return &UserIndex{users: make(map[int]models.User)}
+}
+
+// Put добавляет пользователя в индекс или обновляет его.
+func (idx *UserIndex) Put(user models.User) {
+	idx.mu.Lock()
+	idx.users[user.ID] = user
+	idx.mu.Unlock()
+}
+
+// Delete убирает пользователя из индекса.
+func (idx *UserIndex) Delete(userID int) {
+	idx.mu.Lock()
+	delete(idx.users, userID)
+	idx.mu.Unlock()
+}
+
+func (idx *UserIndex) SearchUsers(ctx context.Context, query models.UserSearchQuery) ([]models.UserSearchResult, error) {
+	q, err := normalizeSearchQuery(query.Query)
+	if err != nil {
+		return nil, err
+	}
+	qTrigrams := trigrams(q)
+
+	idx.mu.RLock()
+	var results []models.UserSearchResult
+	for _, user := range idx.users {
+		rank, match := searchRank(user, q, qTrigrams)
+		result := models.UserSearchResult{User: user, Rank: rank}
+		if match && after(result, query.After) {
+			results = append(results, result)
+		}
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].User.ID < results[j].User.ID
+	})
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	return results, nil
+}
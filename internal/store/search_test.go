@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type userSearcher interface {
+	SearchUsers(ctx context.Context, query models.UserSearchQuery) ([]models.UserSearchResult, error)
+}
+
+var searchFixture = []models.User{
+	{ID: 1, Login: "anna", DisplayName: "Анна Смирнова", Email: "anna@example.com"},
+	{ID: 2, Login: "annabel", DisplayName: "Annabel Lee", Email: "lee@example.org"},
+	{ID: 3, Login: "boris", DisplayName: "Борис", Email: "boris@mail.test"},
+	{ID: 4, Login: "joanna", DisplayName: "Joanna", Email: "jo@example.com"},
+	{ID: 5, Login: "petr", DisplayName: "Пётр", Email: "anna.petrova@example.com"},
+	{ID: 6, Login: "smirnov", DisplayName: "Иван Смирнов", Email: "ivan@example.com"},
+	{ID: 7, Login: "anya", DisplayName: "Anya", Email: "anya@example.org"},
+}
+
+// runUserSearchSuite - общие проверки для всех реализаций поиска.
+func runUserSearchSuite(t *testing.T, newSearcher func(t *testing.T, users []models.User) userSearcher) {
+	ctx := context.Background()
+	s := newSearcher(t, searchFixture)
+
+	search := func(t *testing.T, q string, limit int, after *models.SearchCursor) []models.UserSearchResult {
+		t.Helper()
+		results, err := s.SearchUsers(ctx, models.UserSearchQuery{Query: q, Limit: limit, After: after})
+		require.NoError(t, err)
+		return results
+	}
+	ids := func(results []models.UserSearchResult) []int {
+		var ids []int
+		for _, r := range results {
+			ids = append(ids, r.User.ID)
+		}
+		return ids
+	}
+
+	t.Run("exact login ranks first, then login prefix", func(t *testing.T) {
+		results := search(t, "Anna", 100, nil)
+		require.GreaterOrEqual(t, len(results), 4)
+		assert.Equal(t, []int{1, 2}, ids(results[:2]))
+		assert.ElementsMatch(t, []int{1, 2, 4, 5}, ids(results))
+	})
+
+	t.Run("results are ordered by rank then id", func(t *testing.T) {
+		results := search(t, "Смирнов", 100, nil)
+		assert.ElementsMatch(t, []int{1, 6}, ids(results))
+		for i := 1; i < len(results); i++ {
+			prev, cur := results[i-1], results[i]
+			assert.True(t, prev.Rank > cur.Rank || (prev.Rank == cur.Rank && prev.User.ID < cur.User.ID))
+		}
+		assert.Equal(t, 6, results[0].User.ID)
+	})
+
+	t.Run("search by email and display name", func(t *testing.T) {
+		assert.Equal(t, []int{3}, ids(search(t, "mail.test", 100, nil)))
+		assert.Equal(t, []int{2}, ids(search(t, "Lee", 100, nil)))
+	})
+
+	t.Run("similar but not contained", func(t *testing.T) {
+		results := search(t, "joana", 100, nil)
+		assert.Contains(t, ids(results), 4)
+	})
+
+	t.Run("no matches", func(t *testing.T) {
+		assert.Empty(t, search(t, "zzzz", 100, nil))
+	})
+
+	t.Run("query too short", func(t *testing.T) {
+		_, err := s.SearchUsers(ctx, models.UserSearchQuery{Query: " a ", Limit: 10})
+		assert.ErrorIs(t, err, ErrSearchQueryTooShort)
+	})
+
+	t.Run("pages follow the full result", func(t *testing.T) {
+		full := search(t, "an", 100, nil)
+		require.NotEmpty(t, full)
+
+		var paged []models.UserSearchResult
+		var cursor *models.SearchCursor
+		for i := 0; i <= len(full); i++ {
+			page := search(t, "an", 2, cursor)
+			paged = append(paged, page...)
+			if len(page) < 2 {
+				break
+			}
+			last := page[len(page)-1]
+			cursor = &models.SearchCursor{Rank: last.Rank, ID: last.User.ID}
+		}
+		assert.Equal(t, ids(full), ids(paged))
+	})
+}
+
+func TestUserIndex_Search(t *testing.T) {
+	runUserSearchSuite(t, func(t *testing.T, users []models.User) userSearcher {
+		idx := NewUserIndex()
+		for _, user := range users {
+			idx.Put(user)
+		}
+		return idx
+	})
+}
+
+func TestDBStore_Search(t *testing.T) {
+	runUserSearchSuite(t, func(t *testing.T, users []models.User) userSearcher {
+		s := newTestDBStore(t)
+		seedUsers(t, s, users)
+		return s
+	})
+}
+
+func TestSimilarity(t *testing.T) {
+	// Первая пара после тождественной - пример из документации pg_trgm.
+	tests := []struct {
+		a, b string
+		want float32
+	}{
+		{"word", "word", 1},
+		{"word", "two words", 0.36363637},
+		{"anna", "joanna", 0.33333334},
+		{"", "anna", 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, similarity(trigrams(tt.a), trigrams(tt.b)), "%q vs %q", tt.a, tt.b)
+	}
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sync"
+	"time"
+)
+
+// SecurityEventLog - уведомления пользователей о событиях безопасности в памяти.
+type SecurityEventLog struct {
+	mu     sync.Mutex
+	events []models.SecurityEvent
+	lastID int64
+}
+
+func NewSecurityEventLog() *SecurityEventLog {
+	return &SecurityEventLog{}
+}
+
+// AppendSecurityEvent сохраняет уведомление. Повтор того же события пропускается.
+func (l *SecurityEventLog) AppendSecurityEvent(_ context.Context, event models.SecurityEvent) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, stored := range l.events {
+		if stored.EventID == event.EventID {
+			return false, nil
+		}
+	}
+	event = prepareSecurityEvent(event)
+	l.lastID++
+	event.ID = l.lastID
+	l.events = append(l.events, event)
+	return true, nil
+}
+
+// ListSecurityEvents возвращает до limit уведомлений пользователя с id больше afterID по порядку.
+func (l *SecurityEventLog) ListSecurityEvents(_ context.Context, userID int, afterID int64, limit int) ([]models.SecurityEvent, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var events []models.SecurityEvent
+	for _, event := range l.events {
+		if len(events) == limit {
+			break
+		}
+		if event.UserID == userID && event.ID > afterID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// prepareSecurityEvent приводит уведомление к виду, в котором его хранит БД.
+func prepareSecurityEvent(event models.SecurityEvent) models.SecurityEvent {
+	event.OccurredAt = event.OccurredAt.UTC().Truncate(time.Microsecond)
+	event.IP = truncateRunes(event.IP, 64)
+	event.UserAgent = truncateRunes(event.UserAgent, 512)
+	return event
+}
+
+// forgetUser удаляет уведомления пользователя, как каскад в базе.
+func (l *SecurityEventLog) forgetUser(userID int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	kept := l.events[:0]
+	for _, event := range l.events {
+		if event.UserID != userID {
+			kept = append(kept, event)
+		}
+	}
+	l.events = kept
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type securityEventStore interface {
+	AppendSecurityEvent(ctx context.Context, event models.SecurityEvent) (bool, error)
+	ListSecurityEvents(ctx context.Context, userID int, afterID int64, limit int) ([]models.SecurityEvent, error)
+}
+
+func runSecurityEventSuite(t *testing.T, s securityEventStore) {
+	ctx := context.Background()
+	now := time.Now()
+	for i, userID := range []int{1, 2, 1, 1} {
+		appended, err := s.AppendSecurityEvent(ctx, models.SecurityEvent{
+			EventID: fmt.Sprintf("e%d", i), UserID: userID, Type: models.EventUserLoggedIn, OccurredAt: now,
+		})
+		require.NoError(t, err)
+		assert.True(t, appended)
+	}
+	appended, err := s.AppendSecurityEvent(ctx, models.SecurityEvent{EventID: "e0", UserID: 1, Type: models.EventUserLoggedIn, OccurredAt: now})
+	require.NoError(t, err)
+	assert.False(t, appended, "replayed outbox event is skipped")
+
+	events, err := s.ListSecurityEvents(ctx, 1, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "e0", events[0].EventID)
+	assert.Equal(t, "e2", events[1].EventID)
+	events, err = s.ListSecurityEvents(ctx, 1, events[1].ID, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "e3", events[0].EventID)
+}
+
+func TestSecurityEventLog(t *testing.T) {
+	runSecurityEventSuite(t, NewSecurityEventLog())
+}
+
+func TestDBStore_SecurityEvents(t *testing.T) {
+	s := newTestDBStore(t)
+	seedUsers(t, s, []models.User{{ID: 1, Login: "anna"}, {ID: 2, Login: "boris"}})
+	runSecurityEventSuite(t, s)
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/golang-migrate/migrate/v4"
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"modernc.org/sqlite"
+	"strings"
+	"time"
+)
+
+// SQLite - встроенная база для небольших установок без Postgres. Запросы те же, что и у Postgres,
+// отличия собраны в sqlDialect, а схема задаётся собственным набором миграций.
+
+const sqliteScheme = "sqlite://"
+
+// sqliteDefaults - параметры соединения. Внешние ключи нужны для каскадного удаления,
+// WAL позволяет читать во время записи, а транзакции сразу берут блокировку записи:
+// иначе две транзакции, начавшие с чтения, не смогли бы обе перейти к изменению.
+const sqliteDefaults = "_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)" +
+	"&_txlock=immediate&_time_format=sqlite"
+
+// IsSQLiteDSN сообщает, что DSN указывает на файл SQLite, а не на сервер Postgres.
+func IsSQLiteDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, sqliteScheme)
+}
+
+// sqliteDSN превращает sqlite:///путь/к/файлу.db?параметры в DSN драйвера.
+func sqliteDSN(dsn string) (string, error) {
+	path, query, _ := strings.Cut(strings.TrimPrefix(dsn, sqliteScheme), "?")
+	if path == "" {
+		return "", fmt.Errorf("no database file in DSN %q", dsn)
+	}
+	if query != "" {
+		query = "&" + query
+	}
+	return path + "?" + sqliteDefaults + query, nil
+}
+
+func NewSQLiteStore(c *server_config.ServerConfig, l *logger.ZapLog) (*DBStore, error) {
+	hasher, err := newPasswordHasher(c)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password hashing parameters: %w", err)
+	}
+	dsn, err := sqliteDSN(c.DBDSN)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(utcConnector{dsn: dsn})
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	if err := runSQLiteMigrations(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to run DB migrations: %w", err)
+	}
+	return &DBStore{
+		dbConn:  sqlConn{db: db},
+		dialect: dialectSQLite,
+		c:       c,
+		l:       l,
+		hasher:  hasher,
+	}, nil
+}
+
+//go:embed migrations_sqlite/*.sql
+var sqliteMigrationsDir embed.FS
+
+func runSQLiteMigrations(db *sql.DB) error {
+	src, err := iofs.New(sqliteMigrationsDir, "migrations_sqlite")
+	if err != nil {
+		return fmt.Errorf("failed to return an iofs driver: %w", err)
+	}
+	target, err := migratesqlite.WithInstance(db, &migratesqlite.Config{})
+	if err != nil {
+		return fmt.Errorf("failed to get a migrate driver: %w", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, "sqlite", target)
+	if err != nil {
+		return fmt.Errorf("failed to get a new migrate instance: %w", err)
+	}
+	if err := m.Up(); err != nil {
+		if !errors.Is(err, migrate.ErrNoChange) {
+			return fmt.Errorf("failed to apply migrations to the DB: %w", err)
+		}
+	}
+	return nil
+}
+
+// Время SQLite хранит текстом и сравнивает как строки. Это верно, только пока у всех значений один пояс,
+// поэтому соединения приводят параметры-время к UTC: сервер может работать и в поясе с переходом на летнее время.
+
+type utcConnector struct {
+	dsn string
+}
+
+func (u utcConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := (&sqlite.Driver{}).Open(u.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return utcConn{conn.(sqliteConn)}, nil
+}
+
+func (u utcConnector) Driver() driver.Driver {
+	return &sqlite.Driver{}
+}
+
+// sqliteConn - интерфейсы соединения драйвера, которыми пользуется database/sql.
+type sqliteConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+type utcConn struct {
+	sqliteConn
+}
+
+func (c utcConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.sqliteConn.ExecContext(ctx, query, inUTC(args))
+}
+
+func (c utcConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.sqliteConn.QueryContext(ctx, query, inUTC(args))
+}
+
+func inUTC(args []driver.NamedValue) []driver.NamedValue {
+	for i, arg := range args {
+		if t, ok := arg.Value.(time.Time); ok {
+			args[i].Value = t.UTC()
+		}
+	}
+	return args
+}
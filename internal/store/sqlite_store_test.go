@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestSQLiteStore создаёт хранилище в новом файле SQLite. Сервер базы не нужен, так что тесты не пропускаются.
+func newTestSQLiteStore(t *testing.T) *DBStore {
+	t.Helper()
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	c := &server_config.ServerConfig{DBDSN: "sqlite://" + filepath.Join(t.TempDir(), "raya.db")}
+	newTestHasher(t, c)
+	s, err := NewSQLiteStore(c, l)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.DBConnClose() })
+	return s
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	tests := []struct {
+		dsn     string
+		want    string
+		wantErr bool
+	}{
+		{dsn: "sqlite:///var/lib/raya/raya.db", want: "/var/lib/raya/raya.db?" + sqliteDefaults},
+		{dsn: "sqlite://raya.db", want: "raya.db?" + sqliteDefaults},
+		{dsn: "sqlite://raya.db?_pragma=synchronous(NORMAL)", want: "raya.db?" + sqliteDefaults + "&_pragma=synchronous(NORMAL)"},
+		{dsn: "sqlite://", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			got, err := sqliteDSN(tt.dsn)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	assert.True(t, IsSQLiteDSN("sqlite:///tmp/raya.db"))
+	assert.False(t, IsSQLiteDSN("postgresql://localhost:5432/raya"))
+}
+
+func TestSQLiteStore_Users(t *testing.T) {
+	runUserStoreSuite(t, newTestSQLiteStore(t))
+}
+
+func TestSQLiteStore_ConcurrentConfusableRegistration(t *testing.T) {
+	runConcurrentConfusableSuite(t, newTestSQLiteStore(t))
+}
+
+func TestSQLiteStore_Search(t *testing.T) {
+	runUserSearchSuite(t, func(t *testing.T, users []models.User) userSearcher {
+		s := newTestSQLiteStore(t)
+		seedUsers(t, s, users)
+		return s
+	})
+}
+
+func TestSQLiteStore_AuditChain(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	for _, event := range []models.AuditEvent{
+		{Type: models.AuditLogin, TargetID: intRef(1), Result: models.AuditFailure, Reason: "wrong_password"},
+		{Type: models.AuditLogin, ActorID: intRef(1), TargetID: intRef(1), Result: models.AuditSuccess, UserAgent: "тест"},
+	} {
+		_, err := s.AppendAuditEvent(ctx, event)
+		require.NoError(t, err)
+	}
+	verification, err := VerifyAuditChain(ctx, s)
+	require.NoError(t, err)
+	assert.True(t, verification.Valid)
+	assert.Equal(t, 2, verification.Checked)
+
+	_, err = s.dbConn.ExecContext(ctx, `UPDATE audit_events SET reason = 'ok'`)
+	assert.Error(t, err, "audit_events must reject updates")
+	_, err = s.dbConn.ExecContext(ctx, `DELETE FROM audit_events`)
+	assert.Error(t, err, "audit_events must reject deletes")
+}
+
+func TestSQLiteStore_Queues(t *testing.T) {
+	t.Run("webhooks", func(t *testing.T) {
+		runWebhookQueueSuite(t, newTestSQLiteStore(t))
+	})
+	t.Run("outbox", func(t *testing.T) {
+		runOutboxSuite(t, newTestSQLiteStore(t))
+	})
+	t.Run("outbox handler writes to the store", func(t *testing.T) {
+		s := newTestSQLiteStore(t)
+		ctx := context.Background()
+		user, err := s.CreateUser(ctx, models.UserRegReq{Login: "anna", Password: "Str0ng-enough-pass"})
+		require.NoError(t, err)
+		relayed := relayOutbox(t, s, time.Now().Add(time.Second), 10, func(ctx context.Context, event models.DomainEvent) error {
+			_, err := s.AppendSecurityEvent(ctx, models.SecurityEvent{EventID: event.ID, UserID: event.UserID, Type: event.Type, OccurredAt: event.OccurredAt})
+			return err
+		})
+		assert.Equal(t, 1, relayed)
+		events, err := s.ListSecurityEvents(ctx, user.ID, 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+	t.Run("notifications", func(t *testing.T) {
+		s := newTestSQLiteStore(t)
+		seedUsers(t, s, []models.User{{ID: 1, Login: "anna"}, {ID: 2, Login: "boris"}})
+		runNotificationQueueSuite(t, s)
+	})
+	t.Run("rate limits", func(t *testing.T) {
+		runRateLimitSuite(t, newTestSQLiteStore(t))
+	})
+}
+
+func TestSQLiteStore_UserData(t *testing.T) {
+	newSeeded := func(t *testing.T) *DBStore {
+		s := newTestSQLiteStore(t)
+		seedUsers(t, s, []models.User{{ID: 1, Login: "anna"}, {ID: 2, Login: "boris"}})
+		return s
+	}
+	t.Run("devices", func(t *testing.T) {
+		runDeviceSuite(t, newSeeded(t))
+	})
+	t.Run("login challenges", func(t *testing.T) {
+		runLoginChallengeSuite(t, newSeeded(t))
+	})
+	t.Run("security events", func(t *testing.T) {
+		runSecurityEventSuite(t, newSeeded(t))
+	})
+	t.Run("export jobs", func(t *testing.T) {
+		runExportJobSuite(t, newSeeded(t))
+	})
+	t.Run("ip rules", func(t *testing.T) {
+		s := newSeeded(t)
+		runIPRuleSuite(t, s)
+		missing := 42
+		_, err := s.CreateIPRule(context.Background(), models.IPRule{UserID: &missing, CIDR: "10.0.0.0/8", Action: models.IPRuleDeny})
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+}
+
+func TestSQLiteStore_ConcurrentRegistration(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			login := "user" + string(rune('a'+i%10))
+			_, errs[i] = s.CreateUser(context.Background(), models.UserRegReq{Login: login, Password: "Str0ng-enough-pass"})
+		}()
+	}
+	wg.Wait()
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	assert.Equal(t, 10, failed, "a taken login is rejected, other writers wait for the lock instead of failing")
+}
+
+// Время в SQLite сравнивается как текст, поэтому моменты из разных поясов должны упорядочиваться верно.
+func TestSQLiteStore_TimeZones(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	user, err := s.CreateUser(ctx, models.UserRegReq{Login: "anna", Password: "Str0ng-enough-pass"})
+	require.NoError(t, err)
+	_, err = s.DeleteUser(ctx, user.ID, "test")
+	require.NoError(t, err)
+
+	// В Москве на три часа больше, но момент тот же - на секунду позже удаления.
+	later := time.Now().Add(time.Second).In(time.FixedZone("MSK", 3*60*60))
+	earlier := time.Now().Add(-time.Hour).In(time.FixedZone("UTC-5", -5*60*60))
+	purged, err := s.PurgeDeletedUsers(ctx, earlier, 10, true)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+	purged, err = s.PurgeDeletedUsers(ctx, later, 10, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+}
+
+// Ожидание в очереди хэширования не входит в таймаут записи: иначе клиент получал бы таймаут базы
+// вместо ErrHashingOverloaded, а пароль, дождавшийся очереди, не успевал бы записаться.
+func TestSQLiteStore_WriteTimeoutStartsAfterHashing(t *testing.T) {
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	c := &server_config.ServerConfig{
+		DBDSN:               "sqlite://" + filepath.Join(t.TempDir(), "raya.db"),
+		DBWriteTimeout:      200 * time.Millisecond,
+		HashMemoryBudgetMiB: 1,
+		HashQueueSize:       1,
+		HashQueueTimeout:    5 * time.Second,
+	}
+	newTestHasher(t, c)
+	s, err := NewSQLiteStore(c, l)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.DBConnClose() })
+	ctx := context.Background()
+	user, err := s.CreateUser(ctx, models.UserRegReq{Login: "anna", Password: "Str0ng-enough-pass"})
+	require.NoError(t, err)
+
+	// Занимаем весь бюджет и отпускаем его позже, чем истёк бы таймаут записи.
+	hold := func() {
+		release, err := s.hasher.limiter.acquire(ctx, 1024)
+		require.NoError(t, err)
+		time.AfterFunc(400*time.Millisecond, release)
+	}
+	hold()
+	_, err = s.CreateUser(ctx, models.UserRegReq{Login: "boris", Password: "Str0ng-enough-pass"})
+	assert.NoError(t, err)
+	hold()
+	assert.NoError(t, s.UpdatePassword(ctx, user.ID, "An0ther-strong-pass"))
+}
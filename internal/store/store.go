@@ -8,22 +8,125 @@ import (
 	"github.com/eampleev23/raya-backend.git/internal/models"
 	"github.com/eampleev23/raya-backend.git/internal/server_config"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"time"
 )
 
 // Ошибки хранилища
 
 var (
 	ErrUserNotFound = errors.New("user not found")
+	// ErrLoginTaken - пользователь с таким логином уже есть.
+	ErrLoginTaken = errors.New("login is already taken")
+	// ErrConflict - изменение столкнулось с параллельным (сериализация, взаимоблокировка, занятая блокировка),
+	// его можно повторить.
+	ErrConflict = errors.New("conflicting change")
+	// ErrConstraint - изменение нарушило ограничение базы: повторило уникальное значение или сослалось
+	// на запись, которой нет. Повтор того же запроса снова его нарушит.
+	ErrConstraint = errors.New("constraint violation")
+	// ErrUnavailable - база недоступна: нет соединения, она перегружена или останавливается.
+	ErrUnavailable = errors.New("storage is unavailable")
+	// ErrLoginConfusable - логин выглядит так же, как уже занятый, хотя и отличается символами.
+	ErrLoginConfusable = errors.New("login is confusable with an existing one")
+	// ErrInvalidStatusTransition - из текущего статуса пользователя нельзя перейти в запрошенный.
+	ErrInvalidStatusTransition = errors.New("invalid user status transition")
+	// ErrRestoreExpired - срок, в течение которого удалённую учётную запись можно восстановить, истёк.
+	ErrRestoreExpired = errors.New("account restore period has expired")
+	// ErrWebhookNotFound - подписки на вебхуки с таким id нет.
+	ErrWebhookNotFound = errors.New("webhook subscription not found")
+	// ErrWebhookDeliveryNotFound - доставки вебхука с таким id нет.
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	// ErrNotificationNotFound - письма с таким id в очереди нет.
+	ErrNotificationNotFound = errors.New("notification not found")
+	// ErrDeviceNotFound - у пользователя нет устройства с таким id.
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrIPRuleNotFound - правила с таким id нет.
+	ErrIPRuleNotFound = errors.New("ip rule not found")
+	// ErrExportJobNotFound - выгрузки с таким id нет, она не готова или у пользователя нет выгрузок.
+	ErrExportJobNotFound = errors.New("export job not found")
+	// ErrExportQueueFull - в очереди на сборку слишком много выгрузок.
+	ErrExportQueueFull = errors.New("export queue is full")
 )
 
 type Store interface {
 	DBConnClose() (err error)
 	CreateUser(ctx context.Context, userRegReq models.UserRegReq) (newUser *models.User, err error)
 	GetUserByLogin(ctx context.Context, userLoginReq models.UserLoginReq) (userModelResponse *models.User, err error)
+	UpdatePassword(ctx context.Context, userID int, password string) (err error)
+	RehashPassword(ctx context.Context, userID int, password string) (err error)
+	VerifyPassword(ctx context.Context, password, encodedHash string) (match bool, err error)
+	NeedsRehash(encodedHash string) bool
+	ImportUser(ctx context.Context, user models.User) (imported bool, err error)
+	GetUserByID(ctx context.Context, userID int) (user *models.User, err error)
+	UpdateUserProfile(ctx context.Context, userID int, patch models.UserProfilePatch) (user *models.User, err error)
+	GetUserAuthState(ctx context.Context, userID int) (state models.UserAuthState, err error)
+	GetAuthStateVersion(ctx context.Context) (version int64, err error)
+	BumpAuthStateVersion(ctx context.Context) (err error)
+	SetUserStatus(ctx context.Context, userID int, status models.UserStatus, reason string) (user *models.User, err error)
+	DeleteUser(ctx context.Context, userID int, reason string) (user *models.User, err error)
+	RestoreUser(ctx context.Context, userID int, deletedAfter time.Time) (user *models.User, err error)
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time, limit int, anonymize bool) (purged int, err error)
+	ListUsers(ctx context.Context, query models.UserListQuery) (users []models.User, err error)
+	SetUserRole(ctx context.Context, userID int, role models.UserRole) (user *models.User, err error)
+	RevokeSessions(ctx context.Context, userID int) (err error)
+	SearchUsers(ctx context.Context, query models.UserSearchQuery) (results []models.UserSearchResult, err error)
+	AppendAuditEvent(ctx context.Context, event models.AuditEvent) (stored models.AuditEvent, err error)
+	ListAuditEvents(ctx context.Context, query models.AuditQuery) (events []models.AuditEvent, err error)
+	CreateWebhookSubscription(ctx context.Context, sub models.WebhookSubscription) (created models.WebhookSubscription, err error)
+	ListWebhookSubscriptions(ctx context.Context) (subs []models.WebhookSubscription, err error)
+	DeleteWebhookSubscription(ctx context.Context, id int) (err error)
+	EnqueueWebhookDeliveries(ctx context.Context, event models.WebhookEvent, payload []byte) (enqueued int, err error)
+	ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) (deliveries []models.WebhookDelivery, err error)
+	RecordWebhookAttempt(ctx context.Context, delivery models.WebhookDelivery, attempt models.WebhookAttempt) (err error)
+	ListWebhookDeliveries(ctx context.Context, query models.WebhookDeliveryQuery) (deliveries []models.WebhookDelivery, err error)
+	GetWebhookDelivery(ctx context.Context, id int64) (delivery *models.WebhookDelivery, attempts []models.WebhookAttempt, err error)
+	ReplayWebhookDelivery(ctx context.Context, id int64, now time.Time) (delivery *models.WebhookDelivery, err error)
+	AppendOutboxEvent(ctx context.Context, event models.DomainEvent) (err error)
+	ClaimOutboxEvents(ctx context.Context, now, leaseUntil time.Time, limit int) (events []models.OutboxEvent, err error)
+	RecordOutboxResult(ctx context.Context, event models.OutboxEvent) (err error)
+	DeletePublishedOutboxEvents(ctx context.Context, before time.Time) (deleted int, err error)
+	AppendSecurityEvent(ctx context.Context, event models.SecurityEvent) (appended bool, err error)
+	ListSecurityEvents(ctx context.Context, userID int, afterID int64, limit int) (events []models.SecurityEvent, err error)
+	EnqueueNotification(ctx context.Context, n models.Notification) (enqueued bool, err error)
+	ClaimNotifications(ctx context.Context, now, leaseUntil time.Time, limit int) (notifications []models.Notification, err error)
+	RecordNotificationResult(ctx context.Context, n models.Notification) (err error)
+	GetNotificationOptOuts(ctx context.Context, userID int) (kinds []models.NotificationKind, err error)
+	SetNotificationOptOut(ctx context.Context, userID int, kind models.NotificationKind, optOut bool) (err error)
+	ListDevices(ctx context.Context, userID int) (devices []models.Device, err error)
+	TouchDevice(ctx context.Context, device models.Device) (stored models.Device, err error)
+	DeleteDevice(ctx context.Context, userID int, id int64) (err error)
+	PutLoginChallenge(ctx context.Context, challenge models.LoginChallenge, now time.Time) (err error)
+	CheckLoginChallenge(ctx context.Context, challenge models.LoginChallenge, now time.Time) (passed bool, err error)
+	CreateIPRule(ctx context.Context, rule models.IPRule) (created models.IPRule, err error)
+	ListIPRules(ctx context.Context, query models.IPRuleQuery) (rules []models.IPRule, err error)
+	DeleteIPRule(ctx context.Context, id int) (deleted *models.IPRule, err error)
+	EnqueueExportJob(ctx context.Context, job models.ExportJob, maxPending int) (queued models.ExportJob, err error)
+	GetLastExportJob(ctx context.Context, userID int) (job *models.ExportJob, err error)
+	ClaimExportJobs(ctx context.Context, now, leaseUntil time.Time, limit int) (jobs []models.ExportJob, err error)
+	CompleteExportJob(ctx context.Context, job models.ExportJob, archive []byte) (err error)
+	GetExportArchive(ctx context.Context, id string) (archive []byte, err error)
+	DeleteExpiredExportJobs(ctx context.Context, before time.Time) (deleted int, err error)
+	IncrementRateLimit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (current, previous int, err error)
+	DeleteStaleRateLimits(ctx context.Context, before time.Time) (deleted int, err error)
 }
 
 func NewStorage(serv_conf *server_config.ServerConfig, logger *logger.ZapLog) (Store, error) {
-	s, err := NewDBStore(serv_conf, logger)
+	switch serv_conf.Storage {
+	case "", "postgres":
+	case "memory":
+		m, err := NewMemStore(serv_conf, logger)
+		if err != nil {
+			return nil, fmt.Errorf("error creating new memory store: %w", err)
+		}
+		logger.ZL.Warn("Using memory storage, data is not shared between instances")
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q, expected postgres or memory", serv_conf.Storage)
+	}
+	newStore := NewDBStore
+	if IsSQLiteDSN(serv_conf.DBDSN) {
+		newStore = NewSQLiteStore
+	}
+	s, err := newStore(serv_conf, logger)
 	if err != nil {
 		return nil, fmt.Errorf("error creating new db store: %w", err)
 	}
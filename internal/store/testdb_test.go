@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/login_policy"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+// newTestDBStore подключается к базе из TEST_DATABASE_URI и очищает её. Без переменной тест пропускается.
+// Базу стоит завести отдельную: все данные в ней удаляются.
+func newTestDBStore(t *testing.T) *DBStore {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URI")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	c := &server_config.ServerConfig{DBDSN: dsn}
+	newTestHasher(t, c)
+	s, err := NewDBStore(c, l)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.DBConnClose() })
+
+	_, err = s.dbConn.ExecContext(context.Background(), `TRUNCATE users, webhook_subscriptions, outbox RESTART IDENTITY CASCADE`)
+	require.NoError(t, err)
+	return s
+}
+
+// seedUsers вставляет пользователей с заданными id в обход CreateUser.
+func seedUsers(t *testing.T, s *DBStore, users []models.User) {
+	t.Helper()
+	// В SQLite id можно задать и так.
+	overriding := "OVERRIDING SYSTEM VALUE"
+	if s.dialect == dialectSQLite {
+		overriding = ""
+	}
+	for _, user := range users {
+		normalized := login_policy.Normalize(user.Login)
+		_, err := s.dbConn.ExecContext(context.Background(),
+			`INSERT INTO users (id, login, login_normalized, login_skeleton, password_hash, salt, display_name, email)
+             `+overriding+`
+             VALUES ($1, $2, $3, $4, '', '', $5, $6)`,
+			user.ID, user.Login, normalized, login_policy.Skeleton(normalized), user.DisplayName, user.Email,
+		)
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,237 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WebhookQueue - подписки на вебхуки и очередь доставок в памяти, по тем же правилам, что и в базе.
+type WebhookQueue struct {
+	mu             sync.Mutex
+	subscriptions  []models.WebhookSubscription
+	deliveries     []models.WebhookDelivery
+	attempts       []models.WebhookAttempt
+	lastSubID      int
+	lastDeliveryID int64
+}
+
+func NewWebhookQueue() *WebhookQueue {
+	return &WebhookQueue{}
+}
+
+func (q *WebhookQueue) CreateWebhookSubscription(_ context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.lastSubID++
+	sub.ID = q.lastSubID
+	sub.CreatedAt = time.Now()
+	if sub.EventTypes == nil {
+		sub.EventTypes = []models.WebhookEventType{}
+	}
+	q.subscriptions = append(q.subscriptions, sub)
+	return sub, nil
+}
+
+func (q *WebhookQueue) ListWebhookSubscriptions(_ context.Context) ([]models.WebhookSubscription, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var subs []models.WebhookSubscription
+	for _, sub := range q.subscriptions {
+		sub.Secret = ""
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func (q *WebhookQueue) DeleteWebhookSubscription(_ context.Context, id int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, sub := range q.subscriptions {
+		if sub.ID != id {
+			continue
+		}
+		q.subscriptions = append(q.subscriptions[:i], q.subscriptions[i+1:]...)
+		kept := q.deliveries[:0]
+		for _, delivery := range q.deliveries {
+			if delivery.SubscriptionID != id {
+				kept = append(kept, delivery)
+			}
+		}
+		q.deliveries = kept
+		return nil
+	}
+	return ErrWebhookNotFound
+}
+
+func (q *WebhookQueue) EnqueueWebhookDeliveries(_ context.Context, event models.WebhookEvent, payload []byte) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	enqueued := 0
+	for _, sub := range q.subscriptions {
+		if !sub.Accepts(event.Type) || q.hasDelivery(sub.ID, event.ID) {
+			continue
+		}
+		q.lastDeliveryID++
+		q.deliveries = append(q.deliveries, models.WebhookDelivery{
+			ID:             q.lastDeliveryID,
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        json.RawMessage(payload),
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			UserID:         event.Data.UserID,
+		})
+		enqueued++
+	}
+	return enqueued, nil
+}
+
+// hasDelivery сообщает, что событие уже поставлено подписчику. Вызывается под q.mu.
+func (q *WebhookQueue) hasDelivery(subID int, eventID string) bool {
+	for _, delivery := range q.deliveries {
+		if delivery.SubscriptionID == subID && delivery.EventID == eventID {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *WebhookQueue) ClaimWebhookDeliveries(_ context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var due []int
+	for i, delivery := range q.deliveries {
+		if delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(a, b int) bool {
+		return q.deliveries[due[a]].NextAttemptAt.Before(q.deliveries[due[b]].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	claimed := make([]models.WebhookDelivery, 0, len(due))
+	for _, i := range due {
+		q.deliveries[i].NextAttemptAt = leaseUntil
+		delivery := q.deliveries[i]
+		for _, sub := range q.subscriptions {
+			if sub.ID == delivery.SubscriptionID {
+				delivery.URL, delivery.Secret = sub.URL, sub.Secret
+			}
+		}
+		claimed = append(claimed, delivery)
+	}
+	return claimed, nil
+}
+
+func (q *WebhookQueue) RecordWebhookAttempt(_ context.Context, delivery models.WebhookDelivery, attempt models.WebhookAttempt) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := q.deliveryIndex(delivery.ID)
+	if i < 0 {
+		return ErrWebhookDeliveryNotFound
+	}
+	attempt.ID = int64(len(q.attempts)) + 1
+	attempt.DeliveryID = delivery.ID
+	q.attempts = append(q.attempts, attempt)
+
+	stored := &q.deliveries[i]
+	stored.Status = delivery.Status
+	stored.Attempts = delivery.Attempts
+	stored.NextAttemptAt = delivery.NextAttemptAt
+	stored.LastError = delivery.LastError
+	stored.DeliveredAt = delivery.DeliveredAt
+	return nil
+}
+
+func (q *WebhookQueue) ListWebhookDeliveries(_ context.Context, query models.WebhookDeliveryQuery) ([]models.WebhookDelivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range q.deliveries {
+		if query.Limit > 0 && len(deliveries) == query.Limit {
+			break
+		}
+		if delivery.ID <= query.AfterID ||
+			(query.SubscriptionID != 0 && delivery.SubscriptionID != query.SubscriptionID) ||
+			(query.Status != "" && delivery.Status != query.Status) {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+func (q *WebhookQueue) GetWebhookDelivery(_ context.Context, id int64) (*models.WebhookDelivery, []models.WebhookAttempt, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := q.deliveryIndex(id)
+	if i < 0 {
+		return nil, nil, ErrWebhookDeliveryNotFound
+	}
+	delivery := q.deliveries[i]
+	var attempts []models.WebhookAttempt
+	for _, attempt := range q.attempts {
+		if attempt.DeliveryID == id {
+			attempts = append(attempts, attempt)
+		}
+	}
+	return &delivery, attempts, nil
+}
+
+func (q *WebhookQueue) ReplayWebhookDelivery(_ context.Context, id int64, now time.Time) (*models.WebhookDelivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := q.deliveryIndex(id)
+	if i < 0 {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	stored := &q.deliveries[i]
+	stored.Status = models.WebhookDeliveryPending
+	stored.Attempts = 0
+	stored.NextAttemptAt = now
+	stored.LastError = ""
+	stored.DeliveredAt = nil
+	delivery := *stored
+	return &delivery, nil
+}
+
+func (q *WebhookQueue) deliveryIndex(id int64) int {
+	for i, delivery := range q.deliveries {
+		if delivery.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// forgetUser удаляет доставки событий пользователя вместе с их попытками, как при окончательном удалении в базе.
+func (q *WebhookQueue) forgetUser(userID int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	forgotten := make(map[int64]bool)
+	kept := q.deliveries[:0]
+	for _, delivery := range q.deliveries {
+		if delivery.UserID == userID {
+			forgotten[delivery.ID] = true
+			continue
+		}
+		kept = append(kept, delivery)
+	}
+	q.deliveries = kept
+	keptAttempts := q.attempts[:0]
+	for _, attempt := range q.attempts {
+		if !forgotten[attempt.DeliveryID] {
+			keptAttempts = append(keptAttempts, attempt)
+		}
+	}
+	q.attempts = keptAttempts
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// webhookStore - общее у WebhookQueue и DBStore.
+type webhookStore interface {
+	CreateWebhookSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error)
+	ListWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, id int) error
+	EnqueueWebhookDeliveries(ctx context.Context, event models.WebhookEvent, payload []byte) (int, error)
+	ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, delivery models.WebhookDelivery, attempt models.WebhookAttempt) error
+	ListWebhookDeliveries(ctx context.Context, query models.WebhookDeliveryQuery) ([]models.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, []models.WebhookAttempt, error)
+	ReplayWebhookDelivery(ctx context.Context, id int64, now time.Time) (*models.WebhookDelivery, error)
+}
+
+func runWebhookQueueSuite(t *testing.T, s webhookStore) {
+	ctx := context.Background()
+	all, err := s.CreateWebhookSubscription(ctx, models.WebhookSubscription{URL: "https://a.example/hook", Secret: "a"})
+	require.NoError(t, err)
+	deletions, err := s.CreateWebhookSubscription(ctx, models.WebhookSubscription{
+		URL: "https://b.example/hook", Secret: "b", EventTypes: []models.WebhookEventType{models.WebhookUserDeleted},
+	})
+	require.NoError(t, err)
+
+	subs, err := s.ListWebhookSubscriptions(ctx)
+	require.NoError(t, err)
+	require.Len(t, subs, 2)
+	assert.Empty(t, subs[0].Secret)
+	assert.Equal(t, []models.WebhookEventType{models.WebhookUserDeleted}, subs[1].EventTypes)
+
+	login := models.WebhookEvent{ID: "e1", Type: models.WebhookUserLoggedIn}
+	n, err := s.EnqueueWebhookDeliveries(ctx, login, []byte(`{"id":"e1"}`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "only the catch-all subscription wants logins")
+	deleted := models.WebhookEvent{ID: "e2", Type: models.WebhookUserDeleted}
+	n, err = s.EnqueueWebhookDeliveries(ctx, deleted, []byte(`{"id":"e2"}`))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	// Повторная рассылка того же события новых доставок не создаёт.
+	n, err = s.EnqueueWebhookDeliveries(ctx, deleted, []byte(`{"id":"e2"}`))
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// Забранные доставки не выдаются повторно до конца аренды.
+	now := time.Now().Add(time.Second)
+	claimed, err := s.ClaimWebhookDeliveries(ctx, now, now.Add(time.Minute), 2)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	assert.Equal(t, all.URL, claimed[0].URL)
+	assert.Equal(t, "a", claimed[0].Secret)
+	assert.JSONEq(t, `{"id":"e1"}`, string(claimed[0].Payload))
+	rest, err := s.ClaimWebhookDeliveries(ctx, now, now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+	assert.Equal(t, deletions.ID, rest[0].SubscriptionID)
+	expired, err := s.ClaimWebhookDeliveries(ctx, now.Add(2*time.Minute), now.Add(3*time.Minute), 10)
+	require.NoError(t, err)
+	assert.Len(t, expired, 3, "a lost lease returns deliveries to the queue")
+
+	failed := claimed[0]
+	failed.Attempts, failed.LastError, failed.NextAttemptAt = 1, "boom", now.Add(time.Hour)
+	require.NoError(t, s.RecordWebhookAttempt(ctx, failed, models.WebhookAttempt{AttemptedAt: now, StatusCode: 500, Error: "boom"}))
+	delivered := claimed[1]
+	deliveredAt := now
+	delivered.Status, delivered.Attempts, delivered.DeliveredAt = models.WebhookDeliveryDelivered, 1, &deliveredAt
+	require.NoError(t, s.RecordWebhookAttempt(ctx, delivered, models.WebhookAttempt{AttemptedAt: now, StatusCode: 200}))
+
+	got, attempts, err := s.GetWebhookDelivery(ctx, failed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.Attempts)
+	assert.Equal(t, "boom", got.LastError)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, 500, attempts[0].StatusCode)
+
+	pending, err := s.ListWebhookDeliveries(ctx, models.WebhookDeliveryQuery{SubscriptionID: all.ID, Status: models.WebhookDeliveryPending, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, failed.ID, pending[0].ID)
+	page, err := s.ListWebhookDeliveries(ctx, models.WebhookDeliveryQuery{AfterID: failed.ID, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, delivered.ID, page[0].ID)
+
+	replayed, err := s.ReplayWebhookDelivery(ctx, delivered.ID, now)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDeliveryPending, replayed.Status)
+	assert.Zero(t, replayed.Attempts)
+	assert.Nil(t, replayed.DeliveredAt)
+	_, err = s.ReplayWebhookDelivery(ctx, 999, now)
+	assert.ErrorIs(t, err, ErrWebhookDeliveryNotFound)
+
+	require.NoError(t, s.DeleteWebhookSubscription(ctx, all.ID))
+	assert.ErrorIs(t, s.DeleteWebhookSubscription(ctx, all.ID), ErrWebhookNotFound)
+	_, _, err = s.GetWebhookDelivery(ctx, failed.ID)
+	assert.ErrorIs(t, err, ErrWebhookDeliveryNotFound, "deliveries are removed with their subscription")
+}
+
+func TestWebhookQueue(t *testing.T) {
+	runWebhookQueueSuite(t, NewWebhookQueue())
+}
+
+func TestDBStore_Webhooks(t *testing.T) {
+	runWebhookQueueSuite(t, newTestDBStore(t))
+}
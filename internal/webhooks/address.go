@@ -0,0 +1,84 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+)
+
+// ErrForbiddenAddress - адрес вебхука ведёт во внутреннюю сеть: на сам сервер, в частную подсеть
+// или к сервису метаданных облака. Такие адреса закрыты, чтобы подпиской нельзя было достучаться
+// до того, что снаружи недоступно.
+var ErrForbiddenAddress = errors.New("webhook url points to a private or local address")
+
+// forbiddenPrefixes - подсети, которые не покрывают проверки netip.Addr: общий адрес провайдера
+// (там же метаданные Alibaba Cloud 100.100.100.200) и «эта сеть» 0.0.0.0/8.
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// forbiddenAddr сообщает, что на адрес вебхуки не отправляются. Сюда попадают loopback, частные
+// подсети (в том числе fd00:ec2::254 метаданных AWS), link-local с 169.254.169.254 и multicast.
+func forbiddenAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range forbiddenPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHost разрешает имя хоста и отказывает, если хоть один из его адресов закрыт.
+func (d *Dispatcher) checkHost(ctx context.Context, host string) error {
+	if d.allowPrivate {
+		return nil
+	}
+	addrs, err := d.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("%w: failed to resolve %q: %v", ErrInvalidURL, host, err)
+	}
+	for _, addr := range addrs {
+		if forbiddenAddr(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenAddress, host, addr)
+		}
+	}
+	return nil
+}
+
+// dialControl проверяет адрес перед самим соединением: имя могли перенаправить на внутренний адрес
+// уже после проверки подписки.
+func (d *Dispatcher) dialControl(_, address string, _ syscall.RawConn) error {
+	if d.allowPrivate {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	if forbiddenAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// newTransport - транспорт без прокси из окружения, который соединяется только с открытыми адресами.
+func (d *Dispatcher) newTransport() *http.Transport {
+	dialer := &net.Dialer{Timeout: d.timeout, Control: d.dialControl}
+	return &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   d.timeout,
+		ResponseHeaderTimeout: d.timeout,
+		MaxIdleConnsPerHost:   d.batchSize,
+	}
+}
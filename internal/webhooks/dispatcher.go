@@ -0,0 +1,277 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Вебхуки: события о пользователях приходят из outbox и ставятся в очередь каждому подписчику отдельно, а Run рассылает их
+// и при неудаче повторяет с растущей паузой. Когда попытки кончаются, доставка переходит в dead
+// и уходит повторно только по запросу администратора.
+
+// Store - то, что нужно рассылке от хранилища.
+type Store interface {
+	CreateWebhookSubscription(ctx context.Context, sub models.WebhookSubscription) (created models.WebhookSubscription, err error)
+	EnqueueWebhookDeliveries(ctx context.Context, event models.WebhookEvent, payload []byte) (enqueued int, err error)
+	ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) (deliveries []models.WebhookDelivery, err error)
+	RecordWebhookAttempt(ctx context.Context, delivery models.WebhookDelivery, attempt models.WebhookAttempt) (err error)
+	ReplayWebhookDelivery(ctx context.Context, id int64, now time.Time) (delivery *models.WebhookDelivery, err error)
+}
+
+// Ошибки проверки подписки.
+var (
+	ErrInvalidURL       = errors.New("webhook url must be an absolute http(s) url")
+	ErrUnknownEventType = errors.New("unknown webhook event type")
+)
+
+// Сколько ответа получателя дочитывается, чтобы соединение можно было использовать снова.
+// Сам ответ никуда не сохраняется: в журнал доставки попадает только код.
+const maxResponseDrain = 4 << 10
+
+// resolver - откуда берутся адреса хоста вебхука.
+type resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+type Dispatcher struct {
+	store        Store
+	logger       *logger.ZapLog
+	client       *http.Client
+	timeout      time.Duration
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	retryBase    time.Duration
+	retryMax     time.Duration
+	allowPrivate bool // Разрешить адреса во внутренней сети, например для получателя на той же машине.
+	resolver     resolver
+	now          func() time.Time
+}
+
+func NewDispatcher(s Store, c *server_config.ServerConfig, l *logger.ZapLog) *Dispatcher {
+	d := &Dispatcher{
+		store:        s,
+		logger:       l,
+		timeout:      c.WebhookTimeout,
+		pollInterval: c.WebhookPollInterval,
+		batchSize:    c.WebhookBatchSize,
+		maxAttempts:  c.WebhookMaxAttempts,
+		retryBase:    c.WebhookRetryBase,
+		retryMax:     c.WebhookRetryMax,
+		allowPrivate: c.WebhookAllowPrivate,
+		resolver:     net.DefaultResolver,
+		now:          time.Now,
+	}
+	if d.timeout <= 0 {
+		d.timeout = 10 * time.Second
+	}
+	if d.batchSize <= 0 {
+		d.batchSize = 20
+	}
+	if d.maxAttempts <= 0 {
+		d.maxAttempts = 1
+	}
+	if d.retryBase <= 0 {
+		d.retryBase = 30 * time.Second
+	}
+	if d.retryMax < d.retryBase {
+		d.retryMax = d.retryBase
+	}
+	d.client = &http.Client{
+		Transport: d.newTransport(),
+		Timeout:   d.timeout,
+		// Перенаправления не выполняются: подписчик должен указать точный адрес.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return d
+}
+
+// CreateSubscription проверяет подписку, выдаёт ей секрет и сохраняет. Секрет есть только в результате.
+func (d *Dispatcher) CreateSubscription(ctx context.Context, rawURL string, eventTypes []models.WebhookEventType) (models.WebhookSubscription, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return models.WebhookSubscription{}, ErrInvalidURL
+	}
+	for _, eventType := range eventTypes {
+		if !eventType.Valid() {
+			return models.WebhookSubscription{}, fmt.Errorf("%w %q", ErrUnknownEventType, eventType)
+		}
+	}
+	if err := d.checkHost(ctx, parsed.Hostname()); err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	sub, err := d.store.CreateWebhookSubscription(ctx, models.WebhookSubscription{
+		URL:        parsed.String(),
+		EventTypes: eventTypes,
+		Secret:     "whsec_" + randomHex(24),
+	})
+	if err != nil {
+		return sub, fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return sub, nil
+}
+
+// Name - имя получателя outbox.
+func (d *Dispatcher) Name() string {
+	return "webhooks"
+}
+
+// Publish ставит событие из outbox в очередь всем подписчикам на него. События, которых нет среди
+// вебхуков, пропускаются. Id вебхука совпадает с id события, поэтому повторная рассылка дублей не создаёт.
+func (d *Dispatcher) Publish(ctx context.Context, domainEvent models.DomainEvent) error {
+	eventType := models.WebhookEventType(domainEvent.Type)
+	if !eventType.Valid() {
+		return nil
+	}
+	event := models.WebhookEvent{
+		ID:         domainEvent.ID,
+		Type:       eventType,
+		OccurredAt: domainEvent.OccurredAt.UTC(),
+		Data:       models.WebhookUserData{UserID: domainEvent.UserID, Login: domainEvent.Login},
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+	if _, err := d.store.EnqueueWebhookDeliveries(ctx, event, payload); err != nil {
+		return fmt.Errorf("failed to enqueue webhook event: %w", err)
+	}
+	return nil
+}
+
+// Replay отправляет доставку заново, в том числе уже доставленную или исчерпавшую попытки.
+func (d *Dispatcher) Replay(ctx context.Context, deliveryID int64) (*models.WebhookDelivery, error) {
+	return d.store.ReplayWebhookDelivery(ctx, deliveryID, d.now())
+}
+
+// Run рассылает очередь раз в pollInterval, пока не отменён ctx. При нулевом pollInterval сразу возвращается.
+func (d *Dispatcher) Run(ctx context.Context) {
+	if d.pollInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			d.logger.ZL.Error("failed to dispatch webhooks", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce отправляет все доставки, которым пора уходить, пачками по batchSize.
+func (d *Dispatcher) RunOnce(ctx context.Context) (sent int, err error) {
+	for {
+		now := d.now()
+		// Пока идёт отправка, доставка арендована; упавший отправитель отдаст её после аренды.
+		deliveries, err := d.store.ClaimWebhookDeliveries(ctx, now, now.Add(2*d.timeout), d.batchSize)
+		if err != nil {
+			return sent, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+		}
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func(delivery models.WebhookDelivery) {
+				defer wg.Done()
+				d.deliver(ctx, delivery)
+			}(delivery)
+		}
+		wg.Wait()
+		sent += len(deliveries)
+		if len(deliveries) < d.batchSize || ctx.Err() != nil {
+			return sent, nil
+		}
+	}
+}
+
+// deliver делает одну попытку и записывает её итог.
+func (d *Dispatcher) deliver(ctx context.Context, delivery models.WebhookDelivery) {
+	started := d.now()
+	attempt := models.WebhookAttempt{AttemptedAt: started}
+	attempt.StatusCode, attempt.Error = d.send(ctx, delivery, started)
+	attempt.DurationMS = d.now().Sub(started).Milliseconds()
+
+	delivery.Attempts++
+	delivery.LastError = attempt.Error
+	switch {
+	case attempt.Error == "":
+		delivered := d.now()
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &delivered
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.Status = models.WebhookDeliveryDead
+		d.logger.ZL.Warn("webhook delivery is dead",
+			zap.Int64("delivery_id", delivery.ID), zap.Int("attempts", delivery.Attempts), zap.String("error", attempt.Error))
+	default:
+		delivery.NextAttemptAt = d.now().Add(d.retryDelay(delivery.Attempts))
+	}
+	if err := d.store.RecordWebhookAttempt(ctx, delivery, attempt); err != nil {
+		d.logger.ZL.Error("failed to record webhook attempt", zap.Int64("delivery_id", delivery.ID), zap.Error(err))
+	}
+}
+
+// send отправляет подписанный запрос и возвращает код ответа и ошибку для журнала.
+func (d *Dispatcher) send(ctx context.Context, delivery models.WebhookDelivery, now time.Time) (statusCode int, errMsg string) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "failed to build request: " + err.Error()
+	}
+	timestamp := now.Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "raya-webhooks/1")
+	request.Header.Set(HeaderEventID, delivery.EventID)
+	request.Header.Set(HeaderEventType, string(delivery.EventType))
+	request.Header.Set(HeaderTimestamp, fmt.Sprint(timestamp))
+	request.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxResponseDrain))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Sprintf("unexpected status %d", response.StatusCode)
+	}
+	return response.StatusCode, ""
+}
+
+// retryDelay - пауза после attempts неудачных попыток: retryBase, дальше вдвое больше, но не дольше retryMax.
+func (d *Dispatcher) retryDelay(attempts int) time.Duration {
+	delay := d.retryBase
+	for i := 1; i < attempts && delay < d.retryMax; i++ {
+		delay *= 2
+	}
+	if delay > d.retryMax {
+		delay = d.retryMax
+	}
+	return delay
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,396 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/eampleev23/raya-backend.git/internal/logger"
+	"github.com/eampleev23/raya-backend.git/internal/models"
+	"github.com/eampleev23/raya-backend.git/internal/server_config"
+	"github.com/eampleev23/raya-backend.git/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver - тестовый получатель вебхуков, проверяющий подпись.
+type receiver struct {
+	mu       sync.Mutex
+	secret   string
+	status   int
+	received []receivedHook
+}
+
+type receivedHook struct {
+	eventType string
+	body      string
+	signErr   error
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.received = append(rc.received, receivedHook{
+		eventType: r.Header.Get(HeaderEventType),
+		body:      string(body),
+		signErr:   Verify(rc.secret, r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, 5*time.Minute, time.Now()),
+	})
+	w.WriteHeader(rc.status)
+	w.Write([]byte("receiver says hi"))
+}
+
+func (rc *receiver) setStatus(status int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.status = status
+}
+
+func (rc *receiver) hooks() []receivedHook {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]receivedHook(nil), rc.received...)
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestDispatcher(t *testing.T, queue *store.WebhookQueue) (*Dispatcher, *fakeClock) {
+	t.Helper()
+	l, err := logger.NewZapLogger("info")
+	require.NoError(t, err)
+	d := NewDispatcher(queue, &server_config.ServerConfig{
+		WebhookTimeout:     time.Second,
+		WebhookBatchSize:   10,
+		WebhookMaxAttempts: 3,
+		WebhookRetryBase:   time.Minute,
+		WebhookRetryMax:    time.Hour,
+		// Тестовые получатели слушают 127.0.0.1.
+		WebhookAllowPrivate: true,
+	}, l)
+	// Доставки ставятся в очередь по настоящему времени, поэтому часы начинают с него.
+	clock := &fakeClock{now: time.Now().Add(time.Second)}
+	d.now = clock.Now
+	return d, clock
+}
+
+func subscribe(t *testing.T, d *Dispatcher, rc *receiver, url string, eventTypes ...models.WebhookEventType) models.WebhookSubscription {
+	t.Helper()
+	sub, err := d.CreateSubscription(context.Background(), url, eventTypes)
+	require.NoError(t, err)
+	rc.secret = sub.Secret
+	return sub
+}
+
+func TestDispatcher_Delivered(t *testing.T) {
+	queue := store.NewWebhookQueue()
+	d, _ := newTestDispatcher(t, queue)
+	rc := &receiver{status: http.StatusNoContent}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	subscribe(t, d, rc, server.URL)
+
+	require.NoError(t, d.Publish(context.Background(), store.NewDomainEvent(models.EventUserRegistered, &models.User{ID: 7, Login: "petr"})))
+	sent, err := d.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	hooks := rc.hooks()
+	require.Len(t, hooks, 1)
+	assert.NoError(t, hooks[0].signErr)
+	assert.Equal(t, "user.registered", hooks[0].eventType)
+	assert.JSONEq(t, `{"user_id": 7, "login": "petr"}`, string(mustData(t, hooks[0].body)))
+
+	delivery, attempts, err := queue.GetWebhookDelivery(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDeliveryDelivered, delivery.Status)
+	assert.NotNil(t, delivery.DeliveredAt)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, http.StatusNoContent, attempts[0].StatusCode)
+
+	// Доставленное повторно не уходит.
+	sent, err = d.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+}
+
+func mustData(t *testing.T, body string) []byte {
+	t.Helper()
+	var event struct {
+		Data any `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &event))
+	data, err := json.Marshal(event.Data)
+	require.NoError(t, err)
+	return data
+}
+
+func TestDispatcher_RetriesThenDeadThenReplay(t *testing.T) {
+	queue := store.NewWebhookQueue()
+	d, clock := newTestDispatcher(t, queue)
+	rc := &receiver{status: http.StatusInternalServerError}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	subscribe(t, d, rc, server.URL)
+	ctx := context.Background()
+
+	require.NoError(t, d.Publish(ctx, store.NewDomainEvent(models.EventUserDeleted, &models.User{ID: 1, Login: "anna"})))
+
+	// Первая попытка неудачна, следующая только через retryBase.
+	_, err := d.RunOnce(ctx)
+	require.NoError(t, err)
+	delivery, _, err := queue.GetWebhookDelivery(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, clock.Now().Add(time.Minute), delivery.NextAttemptAt)
+	assert.Equal(t, "unexpected status 500", delivery.LastError)
+	assert.NotContains(t, delivery.LastError, "receiver says hi", "the response body is not stored")
+
+	clock.Advance(59 * time.Second)
+	sent, err := d.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sent, "retry must wait for the backoff")
+
+	// Вторая попытка через минуту, третья ещё через две - и доставка сдаётся.
+	clock.Advance(time.Second)
+	_, err = d.RunOnce(ctx)
+	require.NoError(t, err)
+	clock.Advance(2 * time.Minute)
+	_, err = d.RunOnce(ctx)
+	require.NoError(t, err)
+
+	delivery, attempts, err := queue.GetWebhookDelivery(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDeliveryDead, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Len(t, attempts, 3)
+	assert.Len(t, rc.hooks(), 3)
+
+	clock.Advance(24 * time.Hour)
+	sent, err = d.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sent, "dead deliveries are not retried")
+
+	// Получатель починился - администратор отправляет доставку повторно.
+	rc.setStatus(http.StatusOK)
+	_, err = d.Replay(ctx, 1)
+	require.NoError(t, err)
+	sent, err = d.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	delivery, attempts, err = queue.GetWebhookDelivery(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDeliveryDelivered, delivery.Status)
+	assert.Len(t, attempts, 4, "attempt log survives replay")
+	hooks := rc.hooks()
+	assert.Equal(t, hooks[0].body, hooks[3].body, "replay sends the same event")
+}
+
+func TestDispatcher_EventFilter(t *testing.T) {
+	queue := store.NewWebhookQueue()
+	d, _ := newTestDispatcher(t, queue)
+	all, deletions := &receiver{status: http.StatusOK}, &receiver{status: http.StatusOK}
+	allServer, deletionsServer := httptest.NewServer(all), httptest.NewServer(deletions)
+	defer allServer.Close()
+	defer deletionsServer.Close()
+	subscribe(t, d, all, allServer.URL)
+	subscribe(t, d, deletions, deletionsServer.URL, models.WebhookUserDeleted)
+
+	ctx := context.Background()
+	require.NoError(t, d.Publish(ctx, store.NewDomainEvent(models.EventUserLoggedIn, &models.User{ID: 1})))
+	require.NoError(t, d.Publish(ctx, store.NewDomainEvent(models.EventUserDeleted, &models.User{ID: 1})))
+	_, err := d.RunOnce(ctx)
+	require.NoError(t, err)
+
+	assert.Len(t, all.hooks(), 2)
+	require.Len(t, deletions.hooks(), 1)
+	assert.Equal(t, "user.deleted", deletions.hooks()[0].eventType)
+}
+
+func TestDispatcher_Unreachable(t *testing.T) {
+	queue := store.NewWebhookQueue()
+	d, _ := newTestDispatcher(t, queue)
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	_, err := d.CreateSubscription(context.Background(), url, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, d.Publish(context.Background(), store.NewDomainEvent(models.EventUserLoggedIn, &models.User{ID: 1})))
+	_, err = d.RunOnce(context.Background())
+	require.NoError(t, err)
+
+	_, attempts, err := queue.GetWebhookDelivery(context.Background(), 1)
+	require.NoError(t, err)
+	require.Len(t, attempts, 1)
+	assert.Zero(t, attempts[0].StatusCode)
+	assert.NotEmpty(t, attempts[0].Error)
+}
+
+func TestDispatcher_CreateSubscription(t *testing.T) {
+	d, _ := newTestDispatcher(t, store.NewWebhookQueue())
+	tests := []struct {
+		name       string
+		url        string
+		eventTypes []models.WebhookEventType
+		wantErr    error
+	}{
+		{name: "Test valid", url: "https://example.com/hook", eventTypes: []models.WebhookEventType{models.WebhookUserDeleted}},
+		{name: "Test relative url", url: "/hook", wantErr: ErrInvalidURL},
+		{name: "Test unsupported scheme", url: "ftp://example.com/hook", wantErr: ErrInvalidURL},
+		{name: "Test unknown event", url: "https://example.com/hook", eventTypes: []models.WebhookEventType{"user.sneezed"}, wantErr: ErrUnknownEventType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, err := d.CreateSubscription(context.Background(), tt.url, tt.eventTypes)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, sub.Secret)
+			assert.NotZero(t, sub.ID)
+		})
+	}
+}
+
+// fakeResolver отдаёт заранее заданные адреса вместо DNS.
+type fakeResolver map[string][]netip.Addr
+
+func (f fakeResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	addrs, ok := f[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addrs, nil
+}
+
+func TestDispatcher_ForbiddenAddresses(t *testing.T) {
+	d, _ := newTestDispatcher(t, store.NewWebhookQueue())
+	d.allowPrivate = false
+	d.resolver = fakeResolver{
+		"hooks.example.com":    {netip.MustParseAddr("203.0.113.10")},
+		"internal.example.com": {netip.MustParseAddr("203.0.113.10"), netip.MustParseAddr("10.0.0.5")},
+		"localhost":            {netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("::1")},
+		"203.0.113.10":         {netip.MustParseAddr("203.0.113.10")},
+		"169.254.169.254":      {netip.MustParseAddr("169.254.169.254")},
+		"::ffff:127.0.0.1":     {netip.MustParseAddr("::ffff:127.0.0.1")},
+		"fd00:ec2::254":        {netip.MustParseAddr("fd00:ec2::254")},
+		"100.100.100.200":      {netip.MustParseAddr("100.100.100.200")},
+	}
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{name: "Test public host", url: "https://hooks.example.com/hook"},
+		{name: "Test public ip", url: "https://203.0.113.10/hook"},
+		{name: "Test one private address", url: "https://internal.example.com/hook", wantErr: ErrForbiddenAddress},
+		{name: "Test localhost", url: "http://localhost:8080/hook", wantErr: ErrForbiddenAddress},
+		{name: "Test cloud metadata", url: "http://169.254.169.254/latest/meta-data/", wantErr: ErrForbiddenAddress},
+		{name: "Test mapped loopback", url: "http://[::ffff:127.0.0.1]/hook", wantErr: ErrForbiddenAddress},
+		{name: "Test aws ipv6 metadata", url: "http://[fd00:ec2::254]/hook", wantErr: ErrForbiddenAddress},
+		{name: "Test shared address space", url: "http://100.100.100.200/hook", wantErr: ErrForbiddenAddress},
+		{name: "Test unresolvable host", url: "https://nowhere.example.com/hook", wantErr: ErrInvalidURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := d.CreateSubscription(context.Background(), tt.url, nil)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDispatcher_ForbiddenAddressAtDial(t *testing.T) {
+	queue := store.NewWebhookQueue()
+	d, _ := newTestDispatcher(t, queue)
+	rc := &receiver{status: http.StatusNoContent}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	subscribe(t, d, rc, server.URL)
+
+	// Имя прошло проверку при подписке, а потом стало указывать на внутренний адрес.
+	d.allowPrivate = false
+	require.NoError(t, d.Publish(context.Background(), store.NewDomainEvent(models.EventUserLoggedIn, &models.User{ID: 1})))
+	_, err := d.RunOnce(context.Background())
+	require.NoError(t, err)
+
+	assert.Empty(t, rc.hooks(), "the request does not reach the internal address")
+	_, attempts, err := queue.GetWebhookDelivery(context.Background(), 1)
+	require.NoError(t, err)
+	require.Len(t, attempts, 1)
+	assert.Contains(t, attempts[0].Error, ErrForbiddenAddress.Error())
+}
+
+func TestDispatcher_RetryDelay(t *testing.T) {
+	d, _ := newTestDispatcher(t, store.NewWebhookQueue())
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 4, want: 8 * time.Minute},
+		{attempts: 7, want: time.Hour},
+		{attempts: 1000, want: time.Hour},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, d.retryDelay(tt.attempts), "attempts %d", tt.attempts)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"id":"1"}`)
+	signature := Sign("secret", now.Unix(), body)
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+		now       time.Time
+		wantErr   bool
+	}{
+		{name: "Test valid", secret: "secret", timestamp: "1700000000", body: body, now: now},
+		{name: "Test wrong secret", secret: "other", timestamp: "1700000000", body: body, now: now, wantErr: true},
+		{name: "Test tampered body", secret: "secret", timestamp: "1700000000", body: []byte(`{"id":"2"}`), now: now, wantErr: true},
+		{name: "Test shifted timestamp", secret: "secret", timestamp: "1700000001", body: body, now: now, wantErr: true},
+		{name: "Test stale request", secret: "secret", timestamp: "1700000000", body: body, now: now.Add(time.Hour), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.timestamp, signature, tt.body, 5*time.Minute, tt.now)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSignature)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}